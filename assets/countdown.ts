@@ -0,0 +1,22 @@
+function formatRemaining(ms: number): string {
+    if (ms <= 0) return "0m";
+    const minutes = Math.ceil(ms / 60000);
+    if (minutes < 60) return `${minutes}m`;
+    return `${Math.floor(minutes / 60)}h ${minutes % 60}m`;
+}
+
+export function initCountdown() {
+    const elements = document.querySelectorAll<HTMLElement>("[data-expires-at]");
+    if (elements.length === 0) return;
+
+    const update = () => {
+        elements.forEach((el) => {
+            const expiresAt = Date.parse(el.dataset.expiresAt ?? "");
+            if (!Number.isNaN(expiresAt)) {
+                el.textContent = formatRemaining(expiresAt - Date.now());
+            }
+        });
+    };
+    update();
+    setInterval(update, 30000);
+}
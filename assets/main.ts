@@ -1,6 +1,8 @@
 import "./style.css";
+import { initCountdown } from "./countdown";
 import { initProtocolPortSync } from "./protocol-port-sync";
 
 document.addEventListener("DOMContentLoaded", () => {
     initProtocolPortSync();
+    initCountdown();
 });
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"twintail/internal/services"
+)
+
+// doctor prints the checks of the diagnostics page and returns the exit
+// code: 1 when any check failed.
+func doctor(w io.Writer) int {
+	t := services.LoadI18n().GetTranslator(services.ParseAcceptLanguage(os.Getenv("LANG")))
+	checks := services.NewDoctor().Run()
+	for _, c := range checks {
+		fmt.Fprintf(w, "%-6s %s: %s\n", "["+strings.ToUpper(c.Status)+"]", t(c.Name), t(c.Message))
+		if c.Detail != "" {
+			fmt.Fprintf(w, "       %s\n", c.Detail)
+		}
+		for _, fix := range c.Fix {
+			fmt.Fprintf(w, "       $ %s\n", fix)
+		}
+	}
+	if services.Failed(checks) {
+		return 1
+	}
+	return 0
+}
@@ -1,10 +1,20 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
 	"twintail/internal/config"
 	"twintail/internal/handlers"
 	"twintail/internal/server"
 	"twintail/internal/services"
+	"twintail/internal/systemd"
 	"twintail/internal/validator"
 	"twintail/internal/views"
 
@@ -12,7 +22,20 @@ import (
 	"github.com/labstack/echo/v5/middleware"
 )
 
+const (
+	mutationTimeout       = 30 * time.Second
+	containerSyncInterval = 30 * time.Second
+	expiryInterval        = 30 * time.Second
+	scheduleInterval      = 30 * time.Second
+	monitorInterval       = 60 * time.Second
+	trafficFlushInterval  = 60 * time.Second
+)
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		os.Exit(doctor(os.Stdout))
+	}
+
 	cfg := config.Load()
 
 	e := echo.New()
@@ -30,11 +53,238 @@ func main() {
 	e.Validator = validator.NewCustomValidator()
 
 	tailscaleSvc := services.NewTailscaleService()
+	if err := tailscaleSvc.CheckInstalled(); err != nil {
+		e.Logger.Error("tailscale CLI is not available", "error", err)
+	} else if v, ok := tailscaleSvc.Version(); ok {
+		if err := v.Supported(); err != nil {
+			e.Logger.Error("tailscale is too old to manage services", "error", err)
+		}
+	}
 	container := handlers.NewContainer(tailscaleSvc)
+	container.SetServiceQuota(cfg.ServiceQuota)
+	container.SetPortScanner(services.NewPortScanner())
+	container.SetStatusSource(tailscaleSvc)
+	container.SetVersionSource(tailscaleSvc)
+	container.SetCertSource(services.NewCertInspector(services.DefaultSocket))
+	container.SetDoctor(services.NewDoctor())
+
+	templates, err := services.LoadTemplates(cfg.TemplatesDir)
+	if err != nil {
+		e.Logger.Error("failed to load some service templates", "error", err)
+	}
+	container.SetTemplates(templates)
 
 	server.RegisterRoutes(e, container)
+	if err := setupMode(cfg, e, container, tailscaleSvc); err != nil {
+		e.Logger.Error("invalid "+cfg.Mode+" mode configuration", "error", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var publisher services.Publisher
+	if targets := notifyTargets(cfg.Notify, e.Logger); len(targets) > 0 {
+		dispatcher := services.NewDispatcher(targets, e.Logger)
+		container.SetNotifications(dispatcher)
+		tailscaleSvc.SetPublisher(dispatcher)
+		publisher = dispatcher
+		go dispatcher.Run(ctx)
+	}
+	monitor := services.NewMonitor(tailscaleSvc, publisher)
+	container.SetHealthReporter(monitor)
+	go services.RunMonitor(ctx, monitor, monitorInterval, e.Logger)
+
+	ln, err := listen(ctx, cfg, tailscaleSvc, e.Logger)
+	if err != nil {
+		e.Logger.Error("failed to listen", "error", err)
+		os.Exit(1)
+	}
+	defer ln.Close()
 
-	if err := e.Start(":" + cfg.Port); err != nil {
+	if cfg.SelfServe != "" {
+		cleanup, err := serveSelf(cfg, ln, tailscaleSvc, container)
+		if err != nil {
+			e.Logger.Error("failed to serve twintail on the tailnet", "error", err)
+		} else {
+			defer func() {
+				if err := cleanup(); err != nil {
+					e.Logger.Error("failed to stop serving twintail on the tailnet", "error", err)
+				}
+			}()
+		}
+	}
+
+	expiries, err := services.NewExpiryStore(cfg.DataDir)
+	if err != nil {
+		e.Logger.Error("failed to open expiry store, temporary exposure is disabled", "error", err)
+	} else {
+		container.SetExpiryStore(expiries)
+	}
+
+	var scheduleExecutor services.ScheduleExecutor = tailscaleSvc
+	var containerAdvertiser services.ContainerAdvertiser = tailscaleSvc
+	drains, err := services.NewDrainStore(cfg.DataDir)
+	if err != nil {
+		e.Logger.Error("failed to open drain store, draining is disabled", "error", err)
+	} else {
+		container.SetDrainStore(drains)
+		scheduleExecutor = drains.Guard(tailscaleSvc)
+		containerAdvertiser = drains.GuardAdvertiser(tailscaleSvc)
+	}
+
+	schedules, err := services.NewScheduleStore(cfg.DataDir)
+	if err != nil {
+		e.Logger.Error("failed to open schedule store, endpoint schedules are disabled", "error", err)
+	} else {
+		container.SetScheduleStore(schedules)
+		go services.RunScheduler(ctx, schedules, scheduleExecutor, scheduleInterval, e.Logger)
+	}
+
+	traffic, err := services.NewTrafficStore(cfg.DataDir)
+	if err != nil {
+		e.Logger.Error("failed to open traffic store, traffic statistics are disabled", "error", err)
+	} else {
+		if err := traffic.Resume(tailscaleSvc); err != nil {
+			e.Logger.Error("failed to restart measuring proxies", "error", err)
+		}
+		container.SetTrafficStore(traffic)
+		go services.RunTrafficFlush(ctx, traffic, trafficFlushInterval, e.Logger)
+		defer func() {
+			if err := traffic.Flush(); err != nil {
+				e.Logger.Error("failed to save traffic statistics", "error", err)
+			}
+		}()
+	}
+
+	if stores := container.Stores(); stores.Expiries != nil {
+		go services.RunExpiryScheduler(ctx, stores, tailscaleSvc, container.Protected, expiryInterval, e.Logger)
+	}
+
+	if cfg.ContainerSocket != "" {
+		containers := services.NewContainerClient(cfg.ContainerSocket)
+		container.SetContainerLister(containers)
+		labels, err := services.NewContainerSync(cfg.DataDir)
+		if err != nil {
+			e.Logger.Error("failed to open container store, container labels are not synced", "error", err)
+		} else {
+			go services.RunContainerSync(ctx, labels, containers, containerAdvertiser, containerSyncInterval, e.Logger)
+		}
+	}
+
+	// Missing a few polls in a row means tailscaled or the monitor is stuck.
+	go systemd.RunWatchdog(ctx, func() error {
+		return monitor.Healthy(3 * monitorInterval)
+	})
+	go func() {
+		<-ctx.Done()
+		_ = systemd.Stopping()
+	}()
+	if err := systemd.Ready(); err != nil {
+		e.Logger.Error("failed to notify systemd", "error", err)
+	}
+
+	if err := server.Serve(ctx, e, ln); err != nil {
 		e.Logger.Error("failed to start server", "error", err)
 	}
+
+	waitCtx, cancel := context.WithTimeout(context.Background(), mutationTimeout)
+	defer cancel()
+	if err := tailscaleSvc.WaitForMutations(waitCtx); err != nil {
+		e.Logger.Error("pending tailscale serve changes did not finish", "error", err)
+	}
+}
+
+// setupMode exposes the agent API or connects the hub to its agents. The hub
+// manages the local node alongside the agents under NODE_NAME.
+func setupMode(cfg *config.Config, e *echo.Echo, container *handlers.Container, tailscaleSvc *services.TailscaleService) error {
+	switch cfg.Mode {
+	case config.ModeStandalone:
+		return nil
+	case config.ModeAgent:
+		if cfg.AgentToken == "" {
+			return errors.New("AGENT_TOKEN is required")
+		}
+		server.RegisterAgentAPI(e, container, cfg.AgentToken)
+		return nil
+	case config.ModeHub:
+		if cfg.AgentToken == "" {
+			return errors.New("AGENT_TOKEN is required")
+		}
+		agents, err := services.ParseNodes(cfg.Nodes, cfg.AgentToken)
+		if err != nil {
+			return err
+		}
+		hub, err := services.NewHub(append([]services.Node{{Name: cfg.NodeName, Service: tailscaleSvc}}, agents...))
+		if err != nil {
+			return err
+		}
+		container.SetHub(hub)
+		return nil
+	}
+	return errors.New("MODE must be standalone, agent or hub")
+}
+
+func notifyTargets(cfg config.NotifyConfig, logger *slog.Logger) []services.Target {
+	var targets []services.Target
+	add := func(n services.Notifier, events []string) {
+		for _, event := range events {
+			if !services.IsEventType(event) {
+				logger.Error("unknown notification event, ignoring", "notifier", n.Name(), "event", event)
+			}
+		}
+		targets = append(targets, services.Target{Notifier: n, Events: events})
+	}
+	if cfg.WebhookURL != "" {
+		add(&services.WebhookNotifier{URL: cfg.WebhookURL, Secret: cfg.WebhookSecret}, cfg.WebhookEvents)
+	}
+	if cfg.NtfyURL != "" {
+		add(&services.NtfyNotifier{URL: cfg.NtfyURL, Token: cfg.NtfyToken}, cfg.NtfyEvents)
+	}
+	if cfg.SMTPAddr != "" {
+		add(&services.SMTPNotifier{
+			Addr:     cfg.SMTPAddr,
+			From:     cfg.SMTPFrom,
+			To:       cfg.SMTPTo,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+		}, cfg.SMTPEvents)
+	}
+	return targets
+}
+
+func listen(ctx context.Context, cfg *config.Config, tailscaleSvc *services.TailscaleService, logger *slog.Logger) (*server.MultiListener, error) {
+	activated, err := systemd.Listeners()
+	if err != nil {
+		return nil, err
+	}
+	if len(activated) == 0 {
+		return server.Listen(ctx, cfg.Listen, cfg.Port, tailscaleSvc, logger)
+	}
+
+	ml := server.NewMultiListener()
+	for i, ln := range activated {
+		ml.Add("systemd:"+strconv.Itoa(i), ln)
+	}
+	return ml, nil
+}
+
+func serveSelf(cfg *config.Config, ln *server.MultiListener, tailscaleSvc *services.TailscaleService, container *handlers.Container) (func() error, error) {
+	params, err := services.ParseSelfServe(cfg.SelfServe)
+	if err != nil {
+		return nil, err
+	}
+	params.Destination, err = server.SelfDestination(ln.Addrs())
+	if err != nil {
+		return nil, err
+	}
+	if params.Mode == services.SelfServeService {
+		container.ProtectService(params.ServiceName)
+	}
+	if err := tailscaleSvc.ServeSelf(params); err != nil {
+		return nil, err
+	}
+	return func() error {
+		return tailscaleSvc.UnserveSelf(params)
+	}, nil
 }
@@ -2,12 +2,48 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Port string
+	Port            string
+	Listen          []string
+	SelfServe       string
+	ServiceQuota    int
+	ContainerSocket string
+	TemplatesDir    string
+	DataDir         string
+	Notify          NotifyConfig
+	Mode            string
+	AgentToken      string
+	Nodes           []string
+	NodeName        string
+}
+
+const (
+	ModeStandalone = "standalone"
+	ModeAgent      = "agent"
+	ModeHub        = "hub"
+)
+
+// NotifyConfig holds the notification targets. A target is enabled by its
+// URL or address; each Events list filters what it receives (empty for all).
+type NotifyConfig struct {
+	WebhookURL    string
+	WebhookSecret string
+	WebhookEvents []string
+	NtfyURL       string
+	NtfyToken     string
+	NtfyEvents    []string
+	SMTPAddr      string
+	SMTPFrom      string
+	SMTPTo        []string
+	SMTPUsername  string
+	SMTPPassword  string
+	SMTPEvents    []string
 }
 
 func Load() *Config {
@@ -18,7 +54,70 @@ func Load() *Config {
 		port = "8077"
 	}
 
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = "data"
+	}
+
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv("MODE")))
+	if mode == "" {
+		mode = ModeStandalone
+	}
+
+	nodeName := os.Getenv("NODE_NAME")
+	if nodeName == "" {
+		nodeName, _ = os.Hostname()
+	}
+	if nodeName == "" {
+		nodeName = "local"
+	}
+
 	return &Config{
-		Port: port,
+		Port:            port,
+		Listen:          splitList(os.Getenv("LISTEN"), "all"),
+		SelfServe:       os.Getenv("SELF_SERVE"),
+		ServiceQuota:    intOrDefault(os.Getenv("SERVICE_QUOTA"), 10),
+		ContainerSocket: os.Getenv("CONTAINER_SOCKET"),
+		TemplatesDir:    os.Getenv("TEMPLATES_DIR"),
+		DataDir:         dataDir,
+		Notify: NotifyConfig{
+			WebhookURL:    os.Getenv("NOTIFY_WEBHOOK_URL"),
+			WebhookSecret: os.Getenv("NOTIFY_WEBHOOK_SECRET"),
+			WebhookEvents: splitList(os.Getenv("NOTIFY_WEBHOOK_EVENTS"), ""),
+			NtfyURL:       os.Getenv("NOTIFY_NTFY_URL"),
+			NtfyToken:     os.Getenv("NOTIFY_NTFY_TOKEN"),
+			NtfyEvents:    splitList(os.Getenv("NOTIFY_NTFY_EVENTS"), ""),
+			SMTPAddr:      os.Getenv("NOTIFY_SMTP_ADDR"),
+			SMTPFrom:      os.Getenv("NOTIFY_SMTP_FROM"),
+			SMTPTo:        splitList(os.Getenv("NOTIFY_SMTP_TO"), ""),
+			SMTPUsername:  os.Getenv("NOTIFY_SMTP_USERNAME"),
+			SMTPPassword:  os.Getenv("NOTIFY_SMTP_PASSWORD"),
+			SMTPEvents:    splitList(os.Getenv("NOTIFY_SMTP_EVENTS"), ""),
+		},
+		Mode:       mode,
+		AgentToken: os.Getenv("AGENT_TOKEN"),
+		Nodes:      splitList(os.Getenv("NODES"), ""),
+		NodeName:   nodeName,
+	}
+}
+
+func intOrDefault(value string, fallback int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 0 {
+		return fallback
+	}
+	return n
+}
+
+func splitList(value, fallback string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 && fallback != "" {
+		items = []string{fallback}
 	}
+	return items
 }
@@ -36,3 +36,130 @@ func TestLoad_EmptyPortFallsBackToDefault(t *testing.T) {
 		t.Errorf("expected default port '8077', got '%s'", cfg.Port)
 	}
 }
+
+func TestLoad_DefaultListen(t *testing.T) {
+	os.Unsetenv("LISTEN")
+
+	cfg := Load()
+
+	if len(cfg.Listen) != 1 || cfg.Listen[0] != "all" {
+		t.Errorf("expected default listen [all], got %v", cfg.Listen)
+	}
+}
+
+func TestLoad_MultipleListen(t *testing.T) {
+	os.Setenv("LISTEN", "tailnet, unix:/run/twintail/twintail.sock,")
+	defer os.Unsetenv("LISTEN")
+
+	cfg := Load()
+
+	if len(cfg.Listen) != 2 {
+		t.Fatalf("expected 2 listen entries, got %v", cfg.Listen)
+	}
+	if cfg.Listen[0] != "tailnet" || cfg.Listen[1] != "unix:/run/twintail/twintail.sock" {
+		t.Errorf("unexpected listen entries %v", cfg.Listen)
+	}
+}
+
+func TestLoad_ServiceQuota(t *testing.T) {
+	os.Unsetenv("SERVICE_QUOTA")
+	if cfg := Load(); cfg.ServiceQuota != 10 {
+		t.Errorf("expected default quota 10, got %d", cfg.ServiceQuota)
+	}
+
+	os.Setenv("SERVICE_QUOTA", "0")
+	defer os.Unsetenv("SERVICE_QUOTA")
+	if cfg := Load(); cfg.ServiceQuota != 0 {
+		t.Errorf("expected quota 0, got %d", cfg.ServiceQuota)
+	}
+
+	os.Setenv("SERVICE_QUOTA", "lots")
+	if cfg := Load(); cfg.ServiceQuota != 10 {
+		t.Errorf("expected invalid quota to fall back to 10, got %d", cfg.ServiceQuota)
+	}
+}
+
+func TestLoad_ContainerSocket(t *testing.T) {
+	os.Setenv("CONTAINER_SOCKET", "/run/podman/podman.sock")
+	defer os.Unsetenv("CONTAINER_SOCKET")
+
+	cfg := Load()
+
+	if cfg.ContainerSocket != "/run/podman/podman.sock" {
+		t.Errorf("expected container socket '/run/podman/podman.sock', got '%s'", cfg.ContainerSocket)
+	}
+}
+
+func TestLoad_TemplatesDir(t *testing.T) {
+	os.Setenv("TEMPLATES_DIR", "/etc/twintail/templates")
+	defer os.Unsetenv("TEMPLATES_DIR")
+
+	cfg := Load()
+
+	if cfg.TemplatesDir != "/etc/twintail/templates" {
+		t.Errorf("expected templates dir '/etc/twintail/templates', got '%s'", cfg.TemplatesDir)
+	}
+}
+
+func TestLoad_DataDir(t *testing.T) {
+	os.Unsetenv("DATA_DIR")
+	if cfg := Load(); cfg.DataDir != "data" {
+		t.Errorf("expected default data dir 'data', got '%s'", cfg.DataDir)
+	}
+
+	os.Setenv("DATA_DIR", "/var/lib/twintail")
+	defer os.Unsetenv("DATA_DIR")
+	if cfg := Load(); cfg.DataDir != "/var/lib/twintail" {
+		t.Errorf("expected data dir '/var/lib/twintail', got '%s'", cfg.DataDir)
+	}
+}
+
+func TestLoad_Notify(t *testing.T) {
+	os.Setenv("NOTIFY_WEBHOOK_URL", "https://hooks.example.com/twintail")
+	os.Setenv("NOTIFY_WEBHOOK_EVENTS", "health.failed, drift.detected")
+	os.Setenv("NOTIFY_SMTP_TO", "ops@example.com,oncall@example.com")
+	defer os.Unsetenv("NOTIFY_WEBHOOK_URL")
+	defer os.Unsetenv("NOTIFY_WEBHOOK_EVENTS")
+	defer os.Unsetenv("NOTIFY_SMTP_TO")
+	os.Unsetenv("NOTIFY_NTFY_EVENTS")
+
+	cfg := Load()
+
+	if cfg.Notify.WebhookURL != "https://hooks.example.com/twintail" {
+		t.Errorf("unexpected webhook URL '%s'", cfg.Notify.WebhookURL)
+	}
+	if len(cfg.Notify.WebhookEvents) != 2 || cfg.Notify.WebhookEvents[1] != "drift.detected" {
+		t.Errorf("unexpected webhook events %v", cfg.Notify.WebhookEvents)
+	}
+	if cfg.Notify.NtfyEvents != nil {
+		t.Errorf("expected no ntfy event filter, got %v", cfg.Notify.NtfyEvents)
+	}
+	if len(cfg.Notify.SMTPTo) != 2 {
+		t.Errorf("expected 2 SMTP recipients, got %v", cfg.Notify.SMTPTo)
+	}
+}
+
+func TestLoad_Mode(t *testing.T) {
+	os.Unsetenv("MODE")
+	if cfg := Load(); cfg.Mode != ModeStandalone || cfg.NodeName == "" {
+		t.Errorf("expected standalone mode with a node name, got %q %q", cfg.Mode, cfg.NodeName)
+	}
+
+	os.Setenv("MODE", " Hub ")
+	os.Setenv("AGENT_TOKEN", "secret")
+	os.Setenv("NODES", "web1=http://web1:8077, web2=http://web2:8077")
+	os.Setenv("NODE_NAME", "hub")
+	defer os.Unsetenv("MODE")
+	defer os.Unsetenv("AGENT_TOKEN")
+	defer os.Unsetenv("NODES")
+	defer os.Unsetenv("NODE_NAME")
+
+	cfg := Load()
+
+	if cfg.Mode != ModeHub || cfg.AgentToken != "secret" || cfg.NodeName != "hub" {
+		t.Errorf("unexpected mode config %q %q %q", cfg.Mode, cfg.AgentToken, cfg.NodeName)
+	}
+	if len(cfg.Nodes) != 2 || cfg.Nodes[1] != "web2=http://web2:8077" {
+		t.Errorf("unexpected nodes %v", cfg.Nodes)
+	}
+}
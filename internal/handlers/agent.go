@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"twintail/internal/requests"
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+// AgentHandler exposes the serve operations of this node as a JSON API for
+// a hub. Requests are authenticated by the agent token middleware.
+type AgentHandler struct {
+	*Shared
+	tailscale FullTailscaleService
+}
+
+func NewAgentHandler(tailscale FullTailscaleService, shared *Shared) *AgentHandler {
+	return &AgentHandler{
+		tailscale: tailscale,
+		Shared:    shared,
+	}
+}
+
+func agentError(ctx *echo.Context, code int, kind, message string) error {
+	return ctx.JSON(code, services.AgentError{Message: message, Kind: kind})
+}
+
+// fail reports err with the kind the AgentClient turns back into the
+// original error.
+func (h *AgentHandler) fail(ctx *echo.Context, err error) error {
+	if services.IsTailscaleNotInstalledError(err) {
+		return agentError(ctx, http.StatusServiceUnavailable, services.AgentErrorNotInstalled, err.Error())
+	}
+	if errors.Is(err, services.ErrUnsupportedVersion) {
+		return agentError(ctx, http.StatusServiceUnavailable, services.AgentErrorUnsupported, err.Error())
+	}
+	if errors.Is(err, services.ErrServiceDrained) {
+		return agentError(ctx, http.StatusConflict, services.AgentErrorDrained, err.Error())
+	}
+	var cmdErr *services.CommandError
+	if errors.As(err, &cmdErr) {
+		return agentError(ctx, http.StatusBadGateway, services.AgentErrorCommand, cmdErr.Error())
+	}
+	return agentError(ctx, http.StatusInternalServerError, "", err.Error())
+}
+
+// rejectName returns the status and message for a service name that may not
+// be changed, or 0 when it may.
+func (h *AgentHandler) rejectName(name string) (int, string) {
+	if err := requests.ValidateServiceName(name); err != nil {
+		return http.StatusBadRequest, "invalid service name: " + err.Error()
+	}
+	if h.protected[name] {
+		return http.StatusForbidden, "service is used to serve twintail and cannot be modified"
+	}
+	return 0, ""
+}
+
+func (h *AgentHandler) Installed(ctx *echo.Context) error {
+	if err := h.tailscale.CheckInstalled(); err != nil {
+		return h.fail(ctx, err)
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (h *AgentHandler) Index(ctx *echo.Context) error {
+	svcs, err := h.tailscale.GetServeStatus()
+	if err != nil {
+		return h.fail(ctx, err)
+	}
+	if svcs == nil {
+		svcs = []services.ServiceView{}
+	}
+	return ctx.JSON(http.StatusOK, svcs)
+}
+
+func (h *AgentHandler) Show(ctx *echo.Context) error {
+	name := ctx.Param("name")
+	if err := requests.ValidateServiceName(name); err != nil {
+		return agentError(ctx, http.StatusBadRequest, "", "invalid service name: "+err.Error())
+	}
+	detail, err := h.tailscale.GetServiceByName(name)
+	if err != nil {
+		return h.fail(ctx, err)
+	}
+	if detail == nil {
+		return agentError(ctx, http.StatusNotFound, services.AgentErrorNotFound, "service not found")
+	}
+	return ctx.JSON(http.StatusOK, detail)
+}
+
+func (h *AgentHandler) Store(ctx *echo.Context) error {
+	var req requests.AgentEndpointRequest
+	if err := req.FromContext(ctx); err != nil {
+		return agentError(ctx, http.StatusBadRequest, "", err.Error())
+	}
+	if code, message := h.rejectName(req.ServiceName); code != 0 {
+		return agentError(ctx, code, "", message)
+	}
+	err := h.stores().CheckDrained(req.ServiceName)
+	if err == nil {
+		err = h.tailscale.AdvertiseService(req.ToAdvertiseParams())
+	}
+	if err != nil {
+		return h.fail(ctx, err)
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (h *AgentHandler) Destroy(ctx *echo.Context) error {
+	name := ctx.Param("name")
+	if code, message := h.rejectName(name); code != 0 {
+		return agentError(ctx, code, "", message)
+	}
+	if err := h.tailscale.ClearService(name); err != nil {
+		return h.fail(ctx, err)
+	}
+	if err := h.stores().ForgetService(name); err != nil {
+		return h.fail(ctx, err)
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (h *AgentHandler) AddEndpoint(ctx *echo.Context) error {
+	var req requests.AgentEndpointRequest
+	if err := req.FromContext(ctx); err != nil {
+		return agentError(ctx, http.StatusBadRequest, "", err.Error())
+	}
+	if code, message := h.rejectName(req.ServiceName); code != 0 {
+		return agentError(ctx, code, "", message)
+	}
+	err := h.stores().CheckDrained(req.ServiceName)
+	if err == nil {
+		err = h.tailscale.AddEndpoint(req.ToParams())
+	}
+	if err != nil {
+		return h.fail(ctx, err)
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (h *AgentHandler) RemoveEndpoint(ctx *echo.Context) error {
+	var req requests.AgentEndpointRequest
+	if err := req.FromContext(ctx); err != nil {
+		return agentError(ctx, http.StatusBadRequest, "", err.Error())
+	}
+	if code, message := h.rejectName(req.ServiceName); code != 0 {
+		return agentError(ctx, code, "", message)
+	}
+	if err := h.tailscale.RemoveEndpoint(req.ToParams()); err != nil {
+		return h.fail(ctx, err)
+	}
+	if err := h.stores().ForgetEndpoint(req.ToParams()); err != nil {
+		return h.fail(ctx, err)
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (h *AgentHandler) UpdateEndpoint(ctx *echo.Context) error {
+	var req requests.AgentUpdateEndpointRequest
+	if err := req.FromContext(ctx); err != nil {
+		return agentError(ctx, http.StatusBadRequest, "", err.Error())
+	}
+	if code, message := h.rejectName(req.ServiceName); code != 0 {
+		return agentError(ctx, code, "", message)
+	}
+	err := h.stores().CheckDrained(req.ServiceName)
+	if err == nil {
+		err = h.tailscale.UpdateEndpoint(req.ToParams())
+	}
+	if err == nil {
+		err = h.stores().UpdateEndpoint(req.ToParams())
+	}
+	if err != nil {
+		return h.fail(ctx, err)
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+func callAgent(t *testing.T, h echo.HandlerFunc, method, route, target, body string) (*httptest.ResponseRecorder, services.AgentError) {
+	t.Helper()
+	e := echo.New()
+	e.Validator = newTestValidator()
+	e.Add(method, route, h)
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	var agentErr services.AgentError
+	json.Unmarshal(rec.Body.Bytes(), &agentErr)
+	return rec, agentErr
+}
+
+func TestAgentHandler_Index(t *testing.T) {
+	h := NewAgentHandler(newMockServeService(&services.ServiceDetailView{Name: "web"}), NewShared())
+
+	rec, _ := callAgent(t, h.Index, http.MethodGet, "/api/v1/services", "/api/v1/services", "")
+
+	var svcs []services.ServiceView
+	if err := json.Unmarshal(rec.Body.Bytes(), &svcs); err != nil || len(svcs) != 1 || svcs[0].Name != "web" {
+		t.Errorf("unexpected body %s", rec.Body.String())
+	}
+}
+
+func TestAgentHandler_ShowNotFound(t *testing.T) {
+	h := NewAgentHandler(newMockServeService(), NewShared())
+
+	rec, agentErr := callAgent(t, h.Show, http.MethodGet, "/api/v1/services/:name", "/api/v1/services/web", "")
+
+	if rec.Code != http.StatusNotFound || agentErr.Kind != services.AgentErrorNotFound {
+		t.Errorf("expected a not_found error, got %d %+v", rec.Code, agentErr)
+	}
+}
+
+func TestAgentHandler_AddEndpoint(t *testing.T) {
+	svc := newMockServeService()
+	h := NewAgentHandler(svc, NewShared())
+
+	rec, _ := callAgent(t, h.AddEndpoint, http.MethodPost, "/api/v1/endpoints", "/api/v1/endpoints",
+		`{"ServiceName":"web","Protocol":"https","ExposePort":"443","Path":"/api","Destination":"http://localhost:4000"}`)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(svc.added) != 1 || svc.added[0].Path != "/api" {
+		t.Errorf("unexpected endpoints %+v", svc.added)
+	}
+}
+
+func TestAgentHandler_AddEndpointInvalid(t *testing.T) {
+	svc := newMockServeService()
+	h := NewAgentHandler(svc, NewShared())
+
+	rec, _ := callAgent(t, h.AddEndpoint, http.MethodPost, "/api/v1/endpoints", "/api/v1/endpoints",
+		`{"ServiceName":"web","Protocol":"https","ExposePort":"443","Destination":"--help; id"}`)
+
+	if rec.Code != http.StatusBadRequest || len(svc.added) != 0 {
+		t.Errorf("expected status 400 and no change, got %d %+v", rec.Code, svc.added)
+	}
+}
+
+func TestAgentHandler_Protected(t *testing.T) {
+	svc := newMockServeService()
+	h := NewAgentHandler(svc, NewShared())
+	h.Protect("twintail")
+
+	rec, _ := callAgent(t, h.Destroy, http.MethodDelete, "/api/v1/services/:name", "/api/v1/services/twintail", "")
+
+	if rec.Code != http.StatusForbidden || len(svc.cleared) != 0 {
+		t.Errorf("expected status 403 and no change, got %d %v", rec.Code, svc.cleared)
+	}
+}
+
+func TestAgentHandler_CommandError(t *testing.T) {
+	svc := newMockServeService()
+	svc.err = &services.CommandError{Message: "serve config locked", Err: errors.New("exit status 1")}
+	h := NewAgentHandler(svc, NewShared())
+
+	rec, agentErr := callAgent(t, h.Destroy, http.MethodDelete, "/api/v1/services/:name", "/api/v1/services/web", "")
+
+	if rec.Code != http.StatusBadGateway || agentErr.Kind != services.AgentErrorCommand || agentErr.Message != "serve config locked" {
+		t.Errorf("expected a command error, got %d %+v", rec.Code, agentErr)
+	}
+}
+
+func TestAgentHandler_RejectsDrained(t *testing.T) {
+	svc := newMockServeService(&services.ServiceDetailView{Name: "web", Ports: []services.PortEntry{
+		{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3000"},
+	}})
+	h := NewAgentHandler(svc, drainedShared(t, svc, "web"))
+	endpoint := `{"ServiceName":"web","Protocol":"https","ExposePort":"443","Path":"/","Destination":"http://localhost:3000"}`
+	update := `{"ServiceName":"web","Protocol":"https","ExposePort":"443","Path":"/","OldDestination":"http://localhost:3000","NewDestination":"http://localhost:3001"}`
+
+	for _, call := range []struct {
+		handler     echo.HandlerFunc
+		method, url string
+		body        string
+	}{
+		{h.Store, http.MethodPost, "/api/v1/services", endpoint},
+		{h.AddEndpoint, http.MethodPost, "/api/v1/endpoints", endpoint},
+		{h.UpdateEndpoint, http.MethodPut, "/api/v1/endpoints", update},
+	} {
+		rec, agentErr := callAgent(t, call.handler, call.method, call.url, call.url, call.body)
+
+		if rec.Code != http.StatusConflict || agentErr.Kind != services.AgentErrorDrained {
+			t.Errorf("%s %s: expected a drained error, got %d %+v", call.method, call.url, rec.Code, agentErr)
+		}
+	}
+	if len(svc.advertised)+len(svc.added)+len(svc.updated) != 0 {
+		t.Errorf("expected the drained service to stay off, got %+v %+v %+v", svc.advertised, svc.added, svc.updated)
+	}
+}
+
+func TestAgentHandler_KeepsRecordsInStep(t *testing.T) {
+	svc := newMockServeService(&services.ServiceDetailView{Name: "web", Ports: []services.PortEntry{
+		{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3000"},
+	}})
+	dir := t.TempDir()
+	expiries, _ := services.NewExpiryStore(dir)
+	expiries.Add(services.ExpireService, services.EndpointParams{ServiceName: "web"}, time.Now().Add(time.Hour))
+	schedules, _ := services.NewScheduleStore(dir)
+	schedules.Add(services.EndpointParams{ServiceName: "web", Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3000"}, "0 9 * * *", "0 17 * * *", time.Now())
+	shared := NewShared()
+	shared.SetExpiryStore(expiries)
+	shared.SetScheduleStore(schedules)
+	h := NewAgentHandler(svc, shared)
+
+	callAgent(t, h.UpdateEndpoint, http.MethodPut, "/api/v1/endpoints", "/api/v1/endpoints",
+		`{"ServiceName":"web","Protocol":"https","ExposePort":"443","Path":"/","OldDestination":"http://localhost:3000","NewDestination":"http://localhost:3001"}`)
+
+	if left := schedules.ForService("web"); len(left) != 1 || left[0].Endpoint.Destination != "http://localhost:3001" {
+		t.Errorf("expected the schedule to follow the update, got %+v", left)
+	}
+
+	callAgent(t, h.Destroy, http.MethodDelete, "/api/v1/services/:name", "/api/v1/services/web", "")
+
+	if len(expiries.ForService("web")) != 0 || len(schedules.ForService("web")) != 0 {
+		t.Error("expected the records of the cleared service to be forgotten")
+	}
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"twintail/internal/requests"
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+type BulkService interface {
+	CheckInstalled() error
+	GetServiceByName(name string) (*services.ServiceDetailView, error)
+	RemoveEndpoint(params services.EndpointParams) error
+	UpdateEndpoint(params services.UpdateEndpointParams) error
+	ClearService(name string) error
+}
+
+type BulkHandler struct {
+	*Shared
+	tailscale BulkService
+}
+
+func NewBulkHandler(tailscale BulkService, shared *Shared) *BulkHandler {
+	return &BulkHandler{
+		tailscale: tailscale,
+		Shared:    shared,
+	}
+}
+
+func (h *BulkHandler) Plan(ctx *echo.Context) error {
+	if err := h.tailscale.CheckInstalled(); err != nil {
+		return err
+	}
+	var req requests.BulkRequest
+	plan, err := h.plan(ctx, &req)
+	data := map[string]any{
+		"Plan":     plan,
+		"FormData": req,
+		"Back":     bulkBack(req),
+	}
+	if err != nil {
+		data = withError(data, err)
+	}
+	return ctx.Render(http.StatusOK, "bulk_confirm.html", data)
+}
+
+func (h *BulkHandler) Store(ctx *echo.Context) error {
+	var req requests.BulkRequest
+	plan, err := h.plan(ctx, &req)
+	if err == nil && !plan.Valid() {
+		err = fmt.Errorf("nothing to change")
+	}
+	if err == nil && req.Signature != plan.Signature() {
+		err = services.ErrBulkPlanChanged
+	}
+	if err != nil {
+		return ctx.Render(http.StatusOK, "bulk_confirm.html", withError(map[string]any{
+			"Plan":     plan,
+			"FormData": req,
+			"Back":     bulkBack(req),
+		}, err))
+	}
+
+	results := services.ExecuteBulk(h.tailscale, plan)
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		if err := h.forget(plan.Action, r); err != nil {
+			ctx.Logger().Error("failed to update service records", "service", r.ServiceName, "error", err)
+		}
+	}
+	return ctx.Render(http.StatusOK, "bulk_result.html", map[string]any{
+		"Plan":    plan,
+		"Results": results,
+		"Failed":  services.BulkFailed(results),
+		"Back":    bulkBack(req),
+	})
+}
+
+// forget drops the records of endpoints that no longer exist and updates
+// those of repointed endpoints.
+func (h *BulkHandler) forget(action string, r services.BulkResult) error {
+	switch action {
+	case services.BulkRepoint:
+		return h.stores().UpdateEndpoint(r.Item.UpdateParams())
+	case services.BulkClear:
+		return h.stores().ForgetService(r.ServiceName)
+	case services.BulkDelete:
+		return h.stores().ForgetEndpoint(r.Item.Endpoint)
+	}
+	return nil
+}
+
+func (h *BulkHandler) plan(ctx *echo.Context, req *requests.BulkRequest) (services.BulkPlan, error) {
+	if err := req.FromContext(ctx); err != nil {
+		return services.BulkPlan{}, err
+	}
+
+	var endpoints []services.EndpointParams
+	names := req.Services
+	if len(req.Endpoints) > 0 {
+		names = []string{req.Service}
+	}
+	for _, name := range names {
+		if h.protected[name] {
+			return services.BulkPlan{}, fmt.Errorf("service %s is used to serve twintail and cannot be modified", name)
+		}
+		svc, err := h.tailscale.GetServiceByName(name)
+		if err != nil {
+			return services.BulkPlan{}, err
+		}
+		if svc == nil {
+			return services.BulkPlan{}, fmt.Errorf("service %s not found", name)
+		}
+		// Measured endpoints are planned by their real destination, not
+		// the proxy in front of it.
+		svc = h.stores().Resolve(svc)
+		ports := svc.Ports
+		if len(req.Endpoints) > 0 {
+			if ports, err = selectPorts(svc.Ports, req.Endpoints); err != nil {
+				return services.BulkPlan{}, err
+			}
+		}
+		for _, port := range ports {
+			endpoints = append(endpoints, services.EndpointParams{
+				ServiceName: name,
+				Protocol:    port.Protocol,
+				ExposePort:  port.ExposePort,
+				Path:        port.Path,
+				Destination: port.Destination,
+			})
+		}
+	}
+
+	return services.PlanBulk(req.Action, endpoints, req.Services, req.From, req.To), nil
+}
+
+func selectPorts(ports []services.PortEntry, keys []string) ([]services.PortEntry, error) {
+	var selected []services.PortEntry
+	for _, key := range keys {
+		want, err := requests.ParseEndpointKey(key)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, port := range ports {
+			if port.Key() == want.Key() {
+				selected = append(selected, port)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("endpoint %s not found", key)
+		}
+	}
+	return selected, nil
+}
+
+func bulkBack(req requests.BulkRequest) string {
+	if len(req.Endpoints) > 0 && req.Service != "" {
+		return "/services/" + req.Service
+	}
+	return "/"
+}
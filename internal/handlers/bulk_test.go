@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"twintail/internal/services"
+)
+
+func newMockBulkService() *mockServeService {
+	return newMockServeService(
+		&services.ServiceDetailView{Name: "web", Ports: []services.PortEntry{
+			{Protocol: "https", ExposePort: "443", Destination: "http://localhost:3000"},
+			{Protocol: "https", ExposePort: "443", Path: "/api", Destination: "http://localhost:4000"},
+		}},
+		&services.ServiceDetailView{Name: "docs", Ports: []services.PortEntry{
+			{Protocol: "https", ExposePort: "443", Destination: "http://localhost:3000/docs"},
+		}},
+	)
+}
+
+// confirmBulk shows the plan for form and submits it as confirmed.
+func confirmBulk(t *testing.T, ctrl *BulkHandler, form string) (*httptest.ResponseRecorder, *recordingRenderer) {
+	t.Helper()
+	_, renderer := callForm(t, ctrl.Plan, http.MethodPost, "/bulk", "/bulk", form)
+	plan := renderer.data["Plan"].(services.BulkPlan)
+	return callForm(t, ctrl.Store, http.MethodPost, "/bulk", "/bulk", form+"&signature="+plan.Signature())
+}
+
+func TestBulkPlan_RepointAcrossServices(t *testing.T) {
+	mockSvc := newMockBulkService()
+	ctrl := NewBulkHandler(mockSvc, NewShared())
+
+	rec, renderer := callForm(t, ctrl.Plan, http.MethodPost, "/bulk", "/bulk", "action=repoint&services=web&services=docs&from=localhost:3000&to=localhost:3001")
+
+	if rec.Code != http.StatusOK || renderer.name != "bulk_confirm.html" {
+		t.Fatalf("expected confirmation page, got %d %s", rec.Code, renderer.name)
+	}
+	if renderer.data["Error"] != nil {
+		t.Fatalf("expected no error, got %v", renderer.data["Error"])
+	}
+	plan := renderer.data["Plan"].(services.BulkPlan)
+	if len(plan.Items) != 2 || len(plan.Unchanged) != 1 {
+		t.Errorf("expected 2 changes and 1 unchanged, got %+v", plan)
+	}
+	if len(mockSvc.updated) != 0 {
+		t.Error("expected nothing to change before confirming")
+	}
+}
+
+func TestBulkStore_DeleteEndpoints(t *testing.T) {
+	mockSvc := newMockBulkService()
+	expiries, _ := services.NewExpiryStore(t.TempDir())
+	expiries.Add(services.ExpireEndpoint, services.EndpointParams{ServiceName: "web", Protocol: "https", ExposePort: "443", Path: "/api"}, time.Now().Add(time.Hour))
+	ctrl := NewBulkHandler(mockSvc, NewShared())
+	ctrl.SetExpiryStore(expiries)
+
+	rec, renderer := confirmBulk(t, ctrl, "action=delete&service=web&endpoints=https:443:/api")
+
+	if rec.Code != http.StatusOK || renderer.name != "bulk_result.html" {
+		t.Fatalf("expected result page, got %d %s: %v", rec.Code, renderer.name, renderer.data["Error"])
+	}
+	if len(mockSvc.removed) != 1 || mockSvc.removed[0].Destination != "http://localhost:4000" {
+		t.Errorf("expected /api endpoint to be removed, got %+v", mockSvc.removed)
+	}
+	if len(expiries.ForService("web")) != 0 {
+		t.Error("expected expiry of the deleted endpoint to be forgotten")
+	}
+	if renderer.data["Back"] != "/services/web" {
+		t.Errorf("expected back link to the service, got %v", renderer.data["Back"])
+	}
+}
+
+func TestBulkStore_RepointUpdatesSchedules(t *testing.T) {
+	mockSvc := newMockBulkService()
+	schedules, _ := services.NewScheduleStore(t.TempDir())
+	schedules.Add(services.EndpointParams{ServiceName: "web", Protocol: "https", ExposePort: "443", Destination: "http://localhost:3000"}, "0 9 * * *", "0 17 * * *", time.Now())
+	ctrl := NewBulkHandler(mockSvc, NewShared())
+	ctrl.SetScheduleStore(schedules)
+
+	_, renderer := confirmBulk(t, ctrl, "action=repoint&services=web&from=localhost:3000&to=localhost:3001")
+
+	if renderer.name != "bulk_result.html" || len(mockSvc.updated) != 1 {
+		t.Fatalf("expected one endpoint to be repointed, got %s %+v", renderer.name, mockSvc.updated)
+	}
+	if got := schedules.ForService("web"); len(got) != 1 || got[0].Endpoint.Destination != "http://localhost:3001" {
+		t.Errorf("expected the schedule to follow the repoint, got %+v", got)
+	}
+}
+
+func TestBulkStore_UnknownEndpoint(t *testing.T) {
+	mockSvc := newMockBulkService()
+	ctrl := NewBulkHandler(mockSvc, NewShared())
+
+	_, renderer := callForm(t, ctrl.Store, http.MethodPost, "/bulk", "/bulk", "action=delete&service=web&endpoints=https:8443:")
+
+	if renderer.name != "bulk_confirm.html" || renderer.data["Error"] == nil {
+		t.Fatalf("expected confirmation page with error, got %s", renderer.name)
+	}
+	if len(mockSvc.removed) != 0 {
+		t.Error("expected nothing to be removed")
+	}
+}
+
+func TestBulkStore_ClearProtected(t *testing.T) {
+	mockSvc := newMockBulkService()
+	ctrl := NewBulkHandler(mockSvc, NewShared())
+	ctrl.Protect("docs")
+
+	_, renderer := confirmBulk(t, ctrl, "action=clear&services=web&services=docs")
+
+	if renderer.data["Error"] == nil {
+		t.Fatal("expected protected service to be rejected")
+	}
+	if len(mockSvc.cleared) != 0 {
+		t.Errorf("expected nothing to be cleared, got %+v", mockSvc.cleared)
+	}
+}
+
+func TestBulkStore_PlanChangedSinceConfirmation(t *testing.T) {
+	mockSvc := newMockBulkService()
+	ctrl := NewBulkHandler(mockSvc, NewShared())
+	form := "action=repoint&services=web&from=localhost:3000&to=localhost:3001"
+	_, renderer := callForm(t, ctrl.Plan, http.MethodPost, "/bulk", "/bulk", form)
+	signature := renderer.data["Plan"].(services.BulkPlan).Signature()
+
+	mockSvc.details["web"].Ports = append(mockSvc.details["web"].Ports, services.PortEntry{Protocol: "https", ExposePort: "8443", Destination: "http://localhost:3000"})
+	_, renderer = callForm(t, ctrl.Store, http.MethodPost, "/bulk", "/bulk", form+"&signature="+signature)
+
+	if renderer.name != "bulk_confirm.html" || renderer.data["Error"] != services.ErrBulkPlanChanged.Error() {
+		t.Fatalf("expected confirmation page with plan changed error, got %s %v", renderer.name, renderer.data["Error"])
+	}
+	if len(mockSvc.updated) != 0 {
+		t.Errorf("expected nothing to be repointed, got %+v", mockSvc.updated)
+	}
+}
+
+func TestBulkStore_MissingSignature(t *testing.T) {
+	mockSvc := newMockBulkService()
+	ctrl := NewBulkHandler(mockSvc, NewShared())
+
+	_, renderer := callForm(t, ctrl.Store, http.MethodPost, "/bulk", "/bulk", "action=delete&service=web&endpoints=https:443:/api")
+
+	if renderer.data["Error"] == nil || len(mockSvc.removed) != 0 {
+		t.Fatalf("expected an unconfirmed plan to be refused, got %v %+v", renderer.data["Error"], mockSvc.removed)
+	}
+}
+
+func TestBulkPlan_RepointMeasuredEndpoint(t *testing.T) {
+	mockSvc := newMockBulkService()
+	port := mockSvc.details["web"].Ports[0]
+	meter := services.Meter{Service: "web", Endpoint: port, Addr: "127.0.0.1:40000"}
+	mockSvc.details["web"].Ports[0].Destination = meter.ProxyDestination()
+	dir := t.TempDir()
+	data, _ := json.Marshal([]services.Meter{meter})
+	if err := os.WriteFile(filepath.Join(dir, "traffic.json"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	traffic, err := services.NewTrafficStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl := NewBulkHandler(mockSvc, NewShared())
+	ctrl.SetTrafficStore(traffic)
+
+	_, renderer := callForm(t, ctrl.Plan, http.MethodPost, "/bulk", "/bulk", "action=repoint&services=web&from=localhost:3000&to=localhost:3001")
+
+	plan := renderer.data["Plan"].(services.BulkPlan)
+	if len(plan.Items) != 1 || plan.Items[0].NewDestination != "http://localhost:3001" {
+		t.Errorf("expected the real destination to be repointed, got %+v", plan)
+	}
+}
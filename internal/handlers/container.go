@@ -10,19 +10,130 @@ type FullTailscaleService interface {
 }
 
 type Container struct {
-	Service  *ServiceHandler
-	Endpoint *EndpointHandler
-	Settings *SettingsHandler
+	Service    *ServiceHandler
+	Endpoint   *EndpointHandler
+	Merge      *MergeHandler
+	Bulk       *BulkHandler
+	Duplicate  *DuplicateHandler
+	Rename     *RenameHandler
+	Containers *ContainerHandler
+	Expiry     *ExpiryHandler
+	Schedule   *ScheduleHandler
+	Settings   *SettingsHandler
+	Agent      *AgentHandler
+	Hub        *HubHandler
+	Drain      *DrainHandler
+	Doctor     *DoctorHandler
+	Probe      *ProbeHandler
+	Traffic    *TrafficHandler
+
+	shared *Shared
 }
 
 func NewContainer(tailscale FullTailscaleService) *Container {
+	shared := NewShared()
 	return &Container{
-		Service:  NewServiceHandler(tailscale),
-		Endpoint: NewEndpointHandler(tailscale),
-		Settings: NewSettingsHandler(),
+		Service:    NewServiceHandler(tailscale, shared),
+		Endpoint:   NewEndpointHandler(tailscale, shared),
+		Merge:      NewMergeHandler(tailscale, shared),
+		Bulk:       NewBulkHandler(tailscale, shared),
+		Duplicate:  NewDuplicateHandler(tailscale, shared),
+		Rename:     NewRenameHandler(tailscale, shared),
+		Containers: NewContainerHandler(tailscale),
+		Expiry:     NewExpiryHandler(shared),
+		Schedule:   NewScheduleHandler(tailscale, shared),
+		Settings:   NewSettingsHandler(),
+		Agent:      NewAgentHandler(tailscale, shared),
+		Hub:        NewHubHandler(shared),
+		Drain:      NewDrainHandler(tailscale, shared),
+		Doctor:     NewDoctorHandler(),
+		Probe:      NewProbeHandler(tailscale),
+		Traffic:    NewTrafficHandler(tailscale, shared),
+		shared:     shared,
 	}
 }
 
+func (c *Container) ProtectService(name string) {
+	c.shared.Protect(name)
+}
+
+// Protected reports whether name serves twintail itself.
+func (c *Container) Protected(name string) bool {
+	return c.shared.Protected(name)
+}
+
+// Stores returns the records the handlers keep next to the serve config,
+// for the background jobs that change it too.
+func (c *Container) Stores() services.Stores {
+	return c.shared.stores()
+}
+
+func (c *Container) SetServiceQuota(limit int) {
+	c.Service.SetQuota(limit)
+	c.Duplicate.SetQuota(limit)
+}
+
+func (c *Container) SetPortScanner(scanner PortScanner) {
+	c.Service.SetPortScanner(scanner)
+	c.Endpoint.SetPortScanner(scanner)
+}
+
+func (c *Container) SetTemplates(templates []services.ServiceTemplate) {
+	c.Service.SetTemplates(templates)
+}
+
+func (c *Container) SetExpiryStore(store *services.ExpiryStore) {
+	c.shared.SetExpiryStore(store)
+}
+
+func (c *Container) SetScheduleStore(store *services.ScheduleStore) {
+	c.shared.SetScheduleStore(store)
+}
+
+func (c *Container) SetStatusSource(source StatusSource) {
+	c.Service.SetStatusSource(source)
+}
+
+func (c *Container) SetCertSource(source CertSource) {
+	c.Service.SetCertSource(source)
+}
+
+func (c *Container) SetVersionSource(source VersionSource) {
+	c.Service.SetVersionSource(source)
+}
+
+func (c *Container) SetDrainStore(store *services.DrainStore) {
+	c.shared.SetDrainStore(store)
+}
+
+func (c *Container) SetTrafficStore(store *services.TrafficStore) {
+	c.shared.SetTrafficStore(store)
+}
+
+func (c *Container) SetDoctor(doctor Diagnoser) {
+	c.Doctor.SetDoctor(doctor)
+}
+
+func (c *Container) SetHub(hub *services.Hub) {
+	hub.SetResolver(func(svc *services.ServiceDetailView) *services.ServiceDetailView {
+		return c.shared.stores().Resolve(svc)
+	})
+	c.Hub.SetHub(hub)
+	c.Service.SetHub(hub)
+}
+
+func (c *Container) SetHealthReporter(health services.HealthReporter) {
+	c.Service.SetHealthReporter(health)
+}
+
+func (c *Container) SetNotifications(n Notifications) {
+	c.Settings.SetNotifications(n)
+}
+
+func (c *Container) SetContainerLister(lister services.ContainerLister) {
+	c.Containers.SetLister(lister)
+}
+
 func NewContainerWithTailscale(tailscale *services.TailscaleService) *Container {
 	return NewContainer(tailscale)
 }
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+type ContainerStatusService interface {
+	GetServeStatus() ([]services.ServiceView, error)
+}
+
+type ContainerHandler struct {
+	tailscale  ContainerStatusService
+	containers services.ContainerLister
+}
+
+type containerView struct {
+	services.Container
+	Label    *services.AdvertiseServiceParams
+	LabelErr error
+	Exposed  bool
+}
+
+func NewContainerHandler(tailscale ContainerStatusService) *ContainerHandler {
+	return &ContainerHandler{tailscale: tailscale}
+}
+
+func (h *ContainerHandler) SetLister(lister services.ContainerLister) {
+	h.containers = lister
+}
+
+func (h *ContainerHandler) Index(ctx *echo.Context) error {
+	if h.containers == nil {
+		return ctx.Render(http.StatusOK, "containers.html", map[string]any{
+			"Disabled": true,
+		})
+	}
+
+	list, err := h.containers.ListContainers()
+	if err != nil {
+		return ctx.Render(http.StatusOK, "containers.html", withError(map[string]any{}, err))
+	}
+	svcs, _ := h.tailscale.GetServeStatus()
+	existing := make(map[string]bool)
+	for _, svc := range svcs {
+		existing[svc.Name] = true
+	}
+
+	var views []containerView
+	for _, c := range list {
+		view := containerView{Container: c}
+		if c.Labeled() {
+			params, err := c.LabelParams()
+			if err != nil {
+				view.LabelErr = err
+			} else {
+				view.Label = &params
+				view.Exposed = existing[params.ServiceName]
+			}
+		}
+		views = append(views, view)
+	}
+
+	return ctx.Render(http.StatusOK, "containers.html", map[string]any{
+		"Containers": views,
+	})
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+type mockContainerLister struct {
+	containers []services.Container
+	err        error
+}
+
+func (m *mockContainerLister) ListContainers() ([]services.Container, error) {
+	return m.containers, m.err
+}
+
+func TestContainersIndex_Disabled(t *testing.T) {
+	ctrl := NewContainerHandler(&mockTailscaleService{})
+
+	e := echo.New()
+	renderer := &recordingRenderer{}
+	e.Renderer = renderer
+	req := httptest.NewRequest(http.MethodGet, "/containers", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := ctrl.Index(c); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if renderer.data["Disabled"] != true {
+		t.Errorf("expected Disabled to be set, got %v", renderer.data)
+	}
+}
+
+func TestContainersIndex_LabelStatus(t *testing.T) {
+	ctrl := NewContainerHandler(&mockTailscaleService{
+		services: []services.ServiceView{{Name: "grafana"}},
+	})
+	port := []services.ContainerPort{{PrivatePort: 3000, PublicPort: 3000, Type: "tcp"}}
+	ctrl.SetLister(&mockContainerLister{containers: []services.Container{
+		{Names: []string{"/grafana"}, State: "running", Ports: port, Labels: map[string]string{services.LabelService: "grafana"}},
+		{Names: []string{"/wiki"}, State: "running", Ports: port, Labels: map[string]string{services.LabelService: "wiki"}},
+		{Names: []string{"/plain"}, State: "running", Ports: port},
+	}})
+
+	e := echo.New()
+	renderer := &recordingRenderer{}
+	e.Renderer = renderer
+	req := httptest.NewRequest(http.MethodGet, "/containers", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := ctrl.Index(c); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	views, ok := renderer.data["Containers"].([]containerView)
+	if !ok || len(views) != 3 {
+		t.Fatalf("expected 3 containers, got %v", renderer.data["Containers"])
+	}
+	if !views[0].Exposed {
+		t.Error("expected grafana to be marked as exposed")
+	}
+	if views[1].Label == nil || views[1].Exposed {
+		t.Errorf("expected wiki to be labeled but not exposed, got %+v", views[1])
+	}
+	if views[2].Label != nil {
+		t.Errorf("expected plain to have no label, got %+v", views[2].Label)
+	}
+}
+
+func TestContainersIndex_ListError(t *testing.T) {
+	ctrl := NewContainerHandler(&mockTailscaleService{})
+	ctrl.SetLister(&mockContainerLister{err: errors.New("dial unix /var/run/docker.sock: connect: permission denied")})
+
+	e := echo.New()
+	e.Renderer = &mockRenderer{}
+	req := httptest.NewRequest(http.MethodGet, "/containers", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := ctrl.Index(c); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if rec.Body.String() == "" {
+		t.Error("expected error message in body")
+	}
+}
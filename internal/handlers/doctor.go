@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+type Diagnoser interface {
+	Run() []services.Check
+}
+
+// DoctorHandler shows the same checks as twintail doctor.
+type DoctorHandler struct {
+	doctor Diagnoser
+}
+
+func NewDoctorHandler() *DoctorHandler {
+	return &DoctorHandler{}
+}
+
+func (h *DoctorHandler) SetDoctor(doctor Diagnoser) {
+	h.doctor = doctor
+}
+
+func (h *DoctorHandler) Show(ctx *echo.Context) error {
+	if h.doctor == nil {
+		return echo.ErrNotFound
+	}
+	checks := h.doctor.Run()
+	return ctx.Render(http.StatusOK, "doctor.html", map[string]any{
+		"Checks": checks,
+		"Failed": services.Failed(checks),
+	})
+}
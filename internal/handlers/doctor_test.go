@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"twintail/internal/services"
+)
+
+type fixedDoctor []services.Check
+
+func (d fixedDoctor) Run() []services.Check {
+	return d
+}
+
+func TestDoctorHandler_Show(t *testing.T) {
+	h := NewDoctorHandler()
+	h.SetDoctor(fixedDoctor{
+		{Name: "doctor.binary", Status: services.CheckOK},
+		{Name: "doctor.operator", Status: services.CheckFail, Fix: []string{"sudo tailscale set --operator=twintail"}},
+	})
+
+	rec, renderer := callForm(t, h.Show, http.MethodGet, "/doctor", "/doctor", "")
+
+	if rec.Code != http.StatusOK || renderer.name != "doctor.html" {
+		t.Fatalf("expected the doctor page, got %d %s", rec.Code, renderer.name)
+	}
+	if renderer.data["Failed"] != true || len(renderer.data["Checks"].([]services.Check)) != 2 {
+		t.Errorf("unexpected data %v", renderer.data)
+	}
+}
+
+func TestDoctorHandler_Disabled(t *testing.T) {
+	rec, _ := callForm(t, NewDoctorHandler().Show, http.MethodGet, "/doctor", "/doctor", "")
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
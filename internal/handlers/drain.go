@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+type DrainHandler struct {
+	*Shared
+	tailscale services.DrainExecutor
+}
+
+func NewDrainHandler(tailscale services.DrainExecutor, shared *Shared) *DrainHandler {
+	return &DrainHandler{
+		tailscale: tailscale,
+		Shared:    shared,
+	}
+}
+
+// Drain stops advertising the service from this node and keeps its
+// endpoints so that Undrain can restore them.
+func (h *DrainHandler) Drain(ctx *echo.Context) error {
+	name, err := validateServiceNameParam(ctx)
+	if err != nil {
+		return err
+	}
+	if h.protected[name] {
+		return ctx.String(http.StatusForbidden, "Service is used to serve twintail and cannot be drained")
+	}
+	if h.records.Drains == nil {
+		return ctx.String(http.StatusNotFound, "Draining is not available")
+	}
+	if _, err := h.records.Drains.Drain(h.tailscale, name, time.Now()); err != nil {
+		if errors.Is(err, services.ErrNothingToDrain) {
+			return ctx.String(http.StatusNotFound, "Service not found")
+		}
+		return ctx.String(http.StatusInternalServerError, "Failed to drain service: "+err.Error())
+	}
+	return ctx.Redirect(http.StatusSeeOther, "/services/"+name)
+}
+
+func (h *DrainHandler) Undrain(ctx *echo.Context) error {
+	name, err := validateServiceNameParam(ctx)
+	if err != nil {
+		return err
+	}
+	if h.records.Drains == nil {
+		return ctx.String(http.StatusNotFound, "Draining is not available")
+	}
+	result, err := h.records.Drains.Undrain(h.tailscale, name)
+	if errors.Is(err, services.ErrNotDrained) {
+		return ctx.String(http.StatusNotFound, "Service is not drained")
+	}
+	if err != nil {
+		return ctx.String(http.StatusInternalServerError, "Failed to undrain service: "+err.Error())
+	}
+	if !result.OK() {
+		var failed []string
+		for _, f := range result.Failed {
+			failed = append(failed, f.Endpoint.Protocol+" "+f.Endpoint.ExposePort+f.Endpoint.Path+": "+f.Err.Error())
+		}
+		return ctx.String(http.StatusInternalServerError, "Failed to restore endpoints:\n"+strings.Join(failed, "\n"))
+	}
+	return ctx.Redirect(http.StatusSeeOther, "/services/"+name)
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"twintail/internal/services"
+)
+
+func newDrainTest(t *testing.T) (*DrainHandler, *ServiceHandler, *mockServeService, *services.DrainStore) {
+	t.Helper()
+	svc := newMockServeService(&services.ServiceDetailView{Name: "web", Ports: []services.PortEntry{
+		{Protocol: "https", ExposePort: "443", Destination: "http://localhost:3000"},
+		{Protocol: "https", ExposePort: "443", Path: "/api", Destination: "http://localhost:4000"},
+	}})
+	store, err := services.NewDrainStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := NewShared()
+	shared.SetDrainStore(store)
+	return NewDrainHandler(svc, shared), NewServiceHandler(svc, shared), svc, store
+}
+
+// drainedShared returns handler state in which name was drained from svc.
+func drainedShared(t *testing.T, svc services.DrainExecutor, name string) *Shared {
+	t.Helper()
+	store, err := services.NewDrainStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Drain(svc, name, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	shared := NewShared()
+	shared.SetDrainStore(store)
+	return shared
+}
+
+func TestDrainHandler_DrainAndUndrain(t *testing.T) {
+	h, show, svc, store := newDrainTest(t)
+
+	rec, _ := callForm(t, h.Drain, http.MethodPost, "/services/:name/drain", "/services/web/drain", "")
+
+	if rec.Code != http.StatusSeeOther || len(svc.cleared) != 1 {
+		t.Fatalf("expected the service to be cleared, got %d %v", rec.Code, svc.cleared)
+	}
+	if _, ok := store.Get("web"); !ok {
+		t.Fatal("expected the endpoints to be kept")
+	}
+
+	rec, renderer := callForm(t, show.Show, http.MethodGet, "/services/:name", "/services/web", "")
+	if rec.Code != http.StatusOK || renderer.data["Drain"] == nil {
+		t.Fatalf("expected the page to show the drained service, got %d %v", rec.Code, renderer.data["Drain"])
+	}
+
+	rec, _ = callForm(t, h.Undrain, http.MethodPost, "/services/:name/undrain", "/services/web/undrain", "")
+
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected a redirect, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(svc.advertised) != 1 || len(svc.added) != 1 || svc.added[0].Path != "/api" {
+		t.Errorf("expected both endpoints back, got %+v %+v", svc.advertised, svc.added)
+	}
+	if _, ok := store.Get("web"); ok {
+		t.Error("expected the drain to be forgotten")
+	}
+}
+
+func TestDrainHandler_Protected(t *testing.T) {
+	h, _, svc, _ := newDrainTest(t)
+	h.Protect("web")
+
+	rec, _ := callForm(t, h.Drain, http.MethodPost, "/services/:name/drain", "/services/web/drain", "")
+
+	if rec.Code != http.StatusForbidden || len(svc.cleared) != 0 {
+		t.Errorf("expected status 403 and no change, got %d %v", rec.Code, svc.cleared)
+	}
+}
+
+func TestDrainHandler_UndrainNotDrained(t *testing.T) {
+	h, _, _, _ := newDrainTest(t)
+
+	rec, _ := callForm(t, h.Undrain, http.MethodPost, "/services/:name/undrain", "/services/web/undrain", "")
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
+
+func TestEndpointStore_RejectsDrained(t *testing.T) {
+	h, _, svc, _ := newDrainTest(t)
+	callForm(t, h.Drain, http.MethodPost, "/services/:name/drain", "/services/web/drain", "")
+	endpoints := NewEndpointHandler(svc, h.Shared)
+
+	rec, renderer := callForm(t, endpoints.Store, http.MethodPost, "/services/:name/endpoints/new", "/services/web/endpoints/new", "protocol=https&expose_port=443&path=/admin&destination=http://localhost:5000")
+
+	if rec.Code != http.StatusOK || renderer.data["Error"] != services.ErrServiceDrained.Error() {
+		t.Fatalf("expected the form with the drained error, got %d %v", rec.Code, renderer.data["Error"])
+	}
+	if len(svc.added) != 0 {
+		t.Errorf("expected nothing to be advertised, got %+v", svc.added)
+	}
+}
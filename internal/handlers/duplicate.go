@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"twintail/internal/requests"
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+type DuplicateService interface {
+	CheckInstalled() error
+	GetServeStatus() ([]services.ServiceView, error)
+	GetServiceByName(name string) (*services.ServiceDetailView, error)
+	AdvertiseService(params services.AdvertiseServiceParams) error
+	AddEndpoint(params services.EndpointParams) error
+	ClearService(name string) error
+}
+
+type DuplicateHandler struct {
+	*Shared
+	tailscale DuplicateService
+	quota     int
+
+	// partial maps copies that were only partially created to their source,
+	// which are the only services Rollback clears.
+	mu      sync.Mutex
+	partial map[string]string
+}
+
+func NewDuplicateHandler(tailscale DuplicateService, shared *Shared) *DuplicateHandler {
+	return &DuplicateHandler{
+		tailscale: tailscale,
+		Shared:    shared,
+		partial:   make(map[string]string),
+	}
+}
+
+func (h *DuplicateHandler) SetQuota(limit int) {
+	h.quota = limit
+}
+
+func (h *DuplicateHandler) source(ctx *echo.Context) (*services.ServiceDetailView, error) {
+	name, err := validateServiceNameParam(ctx)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := h.tailscale.GetServiceByName(name)
+	if err != nil {
+		return nil, renderFailure(ctx, err)
+	}
+	if svc == nil {
+		return nil, ctx.String(http.StatusNotFound, "Service not found")
+	}
+	// Copies must not share the measuring proxies of the source.
+	return h.stores().Resolve(svc), nil
+}
+
+func (h *DuplicateHandler) Create(ctx *echo.Context) error {
+	if err := h.tailscale.CheckInstalled(); err != nil {
+		return err
+	}
+	svc, err := h.source(ctx)
+	if svc == nil {
+		return err
+	}
+	var req requests.DuplicateServiceRequest
+	return ctx.Render(http.StatusOK, "duplicate_service.html", map[string]any{
+		"Service":  svc,
+		"FormData": req.Default(svc.Name),
+	})
+}
+
+func (h *DuplicateHandler) Store(ctx *echo.Context) error {
+	svc, err := h.source(ctx)
+	if svc == nil {
+		return err
+	}
+	var req requests.DuplicateServiceRequest
+	if err := req.FromContext(ctx); err != nil {
+		return ctx.Render(http.StatusOK, "duplicate_service.html", withError(map[string]any{
+			"Service":  svc,
+			"FormData": req,
+		}, err))
+	}
+
+	existing, err := h.tailscale.GetServiceByName(req.Target)
+	if err == nil && existing != nil {
+		err = services.ErrServiceExists
+	}
+	if err == nil {
+		err = h.stores().CheckDrained(req.Target)
+	}
+	if err != nil {
+		return ctx.Render(http.StatusOK, "duplicate_service.html", withError(map[string]any{
+			"Service":  svc,
+			"FormData": req,
+		}, err))
+	}
+
+	if svcs, err := h.tailscale.GetServeStatus(); err == nil && !req.ConfirmQuota {
+		quota := services.NewQuotaUsage(svcs, h.quota)
+		if quota.WouldExceed(svcs, req.Target) {
+			return ctx.Render(http.StatusOK, "duplicate_service.html", map[string]any{
+				"Service":      svc,
+				"FormData":     req,
+				"Quota":        quota,
+				"QuotaWarning": true,
+			})
+		}
+	}
+
+	plan := services.PlanDuplicate(svc, req.Target, req.Rewrites())
+	result := services.ExecuteDuplicate(h.tailscale, plan)
+	if len(result.Added) > 0 && !result.OK() {
+		h.mu.Lock()
+		h.partial[plan.Target] = plan.Source
+		h.mu.Unlock()
+	}
+	return ctx.Render(http.StatusOK, "duplicate_result.html", map[string]any{
+		"Plan":   plan,
+		"Result": result,
+	})
+}
+
+// Rollback clears a copy of name that was only partially created. Any other
+// service is refused, so a forged form cannot clear it.
+func (h *DuplicateHandler) Rollback(ctx *echo.Context) error {
+	name, err := validateServiceNameParam(ctx)
+	if err != nil {
+		return err
+	}
+	var req requests.RollbackDuplicateRequest
+	if err := req.FromContext(ctx); err != nil {
+		return ctx.String(http.StatusBadRequest, "Invalid request: "+err.Error())
+	}
+	h.mu.Lock()
+	source, ok := h.partial[req.Target]
+	h.mu.Unlock()
+	if !ok || source != name || h.protected[req.Target] {
+		return ctx.String(http.StatusForbidden, "Service cannot be rolled back")
+	}
+	if err := h.tailscale.ClearService(req.Target); err != nil {
+		return ctx.String(http.StatusInternalServerError, "Failed to roll back: "+err.Error())
+	}
+	h.mu.Lock()
+	delete(h.partial, req.Target)
+	h.mu.Unlock()
+	if err := h.stores().ForgetService(req.Target); err != nil {
+		return err
+	}
+	return ctx.Redirect(http.StatusSeeOther, "/services/"+name)
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"twintail/internal/services"
+)
+
+func TestDuplicateStore(t *testing.T) {
+	mockSvc := newMockMergeService()
+	ctrl := NewDuplicateHandler(mockSvc, NewShared())
+
+	_, renderer := callForm(t, ctrl.Store, http.MethodPost, "/services/:name/duplicate", "/services/app1/duplicate", "target=app1-staging&from=localhost:3000&to=localhost:3001&from=&to=")
+
+	if renderer.name != "duplicate_result.html" {
+		t.Fatalf("expected result page, got %s: %v", renderer.name, renderer.data["Error"])
+	}
+	if len(mockSvc.advertised) != 1 {
+		t.Fatalf("expected copy to be advertised, got %+v", mockSvc.advertised)
+	}
+	got := mockSvc.advertised[0]
+	if got.ServiceName != "app1-staging" || got.Destination != "http://localhost:3001" {
+		t.Errorf("expected rewritten copy, got %+v", got)
+	}
+}
+
+func TestDuplicateStore_CopiesMeasuredDestination(t *testing.T) {
+	mockSvc := newMockMergeService()
+	port := mockSvc.details["app1"].Ports[0]
+	meter := services.Meter{Service: "app1", Endpoint: port, Addr: "127.0.0.1:40000"}
+	mockSvc.details["app1"].Ports[0].Destination = meter.ProxyDestination()
+	dir := t.TempDir()
+	data, _ := json.Marshal([]services.Meter{meter})
+	if err := os.WriteFile(filepath.Join(dir, "traffic.json"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	traffic, err := services.NewTrafficStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl := NewDuplicateHandler(mockSvc, NewShared())
+	ctrl.SetTrafficStore(traffic)
+
+	callForm(t, ctrl.Store, http.MethodPost, "/services/:name/duplicate", "/services/app1/duplicate", "target=app1-staging")
+
+	if len(mockSvc.advertised) != 1 || mockSvc.advertised[0].Destination != port.Destination {
+		t.Errorf("expected the copy to use the real destination, got %+v", mockSvc.advertised)
+	}
+}
+
+func TestDuplicateStore_TargetExists(t *testing.T) {
+	mockSvc := newMockMergeService()
+	ctrl := NewDuplicateHandler(mockSvc, NewShared())
+
+	_, renderer := callForm(t, ctrl.Store, http.MethodPost, "/services/:name/duplicate", "/services/app1/duplicate", "target=app2")
+
+	if renderer.name != "duplicate_service.html" || renderer.data["Error"] == nil {
+		t.Fatalf("expected form with error, got %s", renderer.name)
+	}
+	if len(mockSvc.advertised) != 0 {
+		t.Error("expected nothing to be advertised")
+	}
+}
+
+func TestDuplicateStore_QuotaWarning(t *testing.T) {
+	mockSvc := newMockMergeService()
+	ctrl := NewDuplicateHandler(mockSvc, NewShared())
+	ctrl.SetQuota(2)
+
+	_, renderer := callForm(t, ctrl.Store, http.MethodPost, "/services/:name/duplicate", "/services/app1/duplicate", "target=app3")
+
+	if renderer.data["QuotaWarning"] != true {
+		t.Fatalf("expected quota warning, got %+v", renderer.data)
+	}
+	if len(mockSvc.advertised) != 0 {
+		t.Error("expected nothing to be advertised before confirming")
+	}
+}
+
+func TestDuplicateRollback(t *testing.T) {
+	mockSvc := newMockMergeService()
+	mockSvc.details["app1"].Ports = append(mockSvc.details["app1"].Ports, services.PortEntry{Protocol: "tcp", ExposePort: "5432", Destination: "tcp://localhost:5432"})
+	mockSvc.addErr = &services.CommandError{Message: "serve failed"}
+	expiries, _ := services.NewExpiryStore(t.TempDir())
+	ctrl := NewDuplicateHandler(mockSvc, NewShared())
+	ctrl.SetExpiryStore(expiries)
+	callForm(t, ctrl.Store, http.MethodPost, "/services/:name/duplicate", "/services/app1/duplicate", "target=app1-staging")
+	expiries.Add(services.ExpireService, services.EndpointParams{ServiceName: "app1-staging"}, time.Now().Add(time.Hour))
+
+	rec, _ := callForm(t, ctrl.Rollback, http.MethodPost, "/services/:name/duplicate/rollback", "/services/app1/duplicate/rollback", "target=app1-staging")
+
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected status 303, got %d", rec.Code)
+	}
+	if len(mockSvc.cleared) != 1 || mockSvc.cleared[0] != "app1-staging" {
+		t.Errorf("expected copy to be cleared, got %+v", mockSvc.cleared)
+	}
+	if len(expiries.ForService("app1-staging")) != 0 {
+		t.Error("expected the records of the copy to be forgotten")
+	}
+}
+
+func TestDuplicateRollback_NotCreated(t *testing.T) {
+	mockSvc := newMockMergeService()
+	ctrl := NewDuplicateHandler(mockSvc, NewShared())
+
+	rec, _ := callForm(t, ctrl.Rollback, http.MethodPost, "/services/:name/duplicate/rollback", "/services/app1/duplicate/rollback", "target=app2")
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", rec.Code)
+	}
+	if len(mockSvc.cleared) != 0 {
+		t.Error("expected a service the copy did not create not to be cleared")
+	}
+}
+
+func TestDuplicateRollback_Source(t *testing.T) {
+	mockSvc := newMockMergeService()
+	ctrl := NewDuplicateHandler(mockSvc, NewShared())
+
+	rec, _ := callForm(t, ctrl.Rollback, http.MethodPost, "/services/:name/duplicate/rollback", "/services/app1/duplicate/rollback", "target=app1")
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", rec.Code)
+	}
+	if len(mockSvc.cleared) != 0 {
+		t.Error("expected source not to be cleared")
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"time"
 	"twintail/internal/requests"
 	"twintail/internal/services"
 
@@ -10,6 +11,7 @@ import (
 
 type EndpointService interface {
 	CheckInstalled() error
+	GetServeStatus() ([]services.ServiceView, error)
 	GetServiceByName(name string) (*services.ServiceDetailView, error)
 	AddEndpoint(params services.EndpointParams) error
 	RemoveEndpoint(params services.EndpointParams) error
@@ -17,15 +19,22 @@ type EndpointService interface {
 }
 
 type EndpointHandler struct {
+	*Shared
 	tailscale EndpointService
+	ports     PortScanner
 }
 
-func NewEndpointHandler(tailscale EndpointService) *EndpointHandler {
+func NewEndpointHandler(tailscale EndpointService, shared *Shared) *EndpointHandler {
 	return &EndpointHandler{
 		tailscale: tailscale,
+		Shared:    shared,
 	}
 }
 
+func (h *EndpointHandler) SetPortScanner(scanner PortScanner) {
+	h.ports = scanner
+}
+
 func (h *EndpointHandler) Create(ctx *echo.Context) error {
 	if err := h.tailscale.CheckInstalled(); err != nil {
 		return err
@@ -34,10 +43,13 @@ func (h *EndpointHandler) Create(ctx *echo.Context) error {
 	if err != nil {
 		return err
 	}
+	svcs, _ := h.tailscale.GetServeStatus()
 	var req requests.StoreEndpointRequest
 	return ctx.Render(http.StatusOK, "new_endpoint.html", map[string]any{
-		"ServiceName": name,
-		"FormData":    req.Default(),
+		"ServiceName":   name,
+		"FormData":      req.Default(),
+		"Suggestions":   destinationSuggestions(h.ports, svcs),
+		"ExpireOptions": expireOptions(h.records.Expiries),
 	})
 }
 
@@ -49,18 +61,32 @@ func (h *EndpointHandler) Store(ctx *echo.Context) error {
 	var req requests.StoreEndpointRequest
 	if err := req.FromContext(ctx); err != nil {
 		return ctx.Render(200, "new_endpoint.html", map[string]any{
-			"ServiceName": name,
-			"Error":       err.Error(),
-			"FormData":    req,
+			"ServiceName":   name,
+			"Error":         err.Error(),
+			"FormData":      req,
+			"ExpireOptions": expireOptions(h.records.Expiries),
 		})
 	}
 
-	if err := h.tailscale.AddEndpoint(req.ToParams(name)); err != nil {
-		return ctx.Render(http.StatusOK, "new_endpoint.html", map[string]any{
-			"ServiceName": name,
-			"Error":       err.Error(),
-			"FormData":    req,
-		})
+	err = h.stores().CheckDrained(name)
+	if err == nil && h.protected[name] && requests.ParseTTL(req.ExpireIn) > 0 {
+		err = services.ErrExpiryProtected
+	}
+	if err == nil {
+		err = h.tailscale.AddEndpoint(req.ToParams(name))
+	}
+	if err != nil {
+		return ctx.Render(http.StatusOK, "new_endpoint.html", withError(map[string]any{
+			"ServiceName":   name,
+			"FormData":      req,
+			"ExpireOptions": expireOptions(h.records.Expiries),
+		}, err))
+	}
+
+	if ttl := requests.ParseTTL(req.ExpireIn); ttl > 0 && h.records.Expiries != nil {
+		if _, err := h.records.Expiries.Add(services.ExpireEndpoint, req.ToParams(name), time.Now().Add(ttl)); err != nil {
+			return err
+		}
 	}
 
 	return ctx.Redirect(303, "/services/"+name)
@@ -74,14 +100,19 @@ func (h *EndpointHandler) Delete(ctx *echo.Context) error {
 	if err != nil {
 		return err
 	}
+	if h.protected[name] {
+		return ctx.String(http.StatusForbidden, "Service is used to serve twintail and cannot be modified")
+	}
 	protocol := ctx.QueryParam("protocol")
 	exposePort := ctx.QueryParam("port")
+	path := ctx.QueryParam("path")
 	destination := ctx.QueryParam("destination")
 
 	return ctx.Render(http.StatusOK, "confirm_delete_endpoint.html", map[string]any{
 		"ServiceName": name,
 		"Protocol":    protocol,
 		"ExposePort":  exposePort,
+		"Path":        path,
 		"Destination": destination,
 	})
 }
@@ -91,6 +122,9 @@ func (h *EndpointHandler) Destroy(ctx *echo.Context) error {
 	if err != nil {
 		return err
 	}
+	if h.protected[name] {
+		return ctx.String(http.StatusForbidden, "Service is used to serve twintail and cannot be modified")
+	}
 	var req requests.DestroyEndpointRequest
 	if err := req.FromContext(ctx); err != nil {
 		return ctx.String(http.StatusInternalServerError, "Invalid request: "+err.Error())
@@ -99,6 +133,9 @@ func (h *EndpointHandler) Destroy(ctx *echo.Context) error {
 	if err := h.tailscale.RemoveEndpoint(req.ToParams(name)); err != nil {
 		return ctx.String(http.StatusInternalServerError, "Failed to delete endpoint: "+err.Error())
 	}
+	if err := h.stores().ForgetEndpoint(req.ToParams(name)); err != nil {
+		return err
+	}
 
 	svc, _ := h.tailscale.GetServiceByName(name)
 	if svc == nil {
@@ -116,8 +153,12 @@ func (h *EndpointHandler) Edit(ctx *echo.Context) error {
 	if err != nil {
 		return err
 	}
+	if h.protected[name] {
+		return ctx.String(http.StatusForbidden, "Service is used to serve twintail and cannot be modified")
+	}
 	protocol := ctx.QueryParam("protocol")
 	exposePort := ctx.QueryParam("port")
+	path := ctx.QueryParam("path")
 	destination := ctx.QueryParam("destination")
 
 	return ctx.Render(http.StatusOK, "edit_endpoint.html", map[string]any{
@@ -125,6 +166,7 @@ func (h *EndpointHandler) Edit(ctx *echo.Context) error {
 		"FormData": requests.UpdateEndpointRequest{
 			Protocol:       protocol,
 			ExposePort:     exposePort,
+			Path:           path,
 			OldDestination: destination,
 			NewDestination: destination,
 		},
@@ -136,6 +178,9 @@ func (h *EndpointHandler) Update(ctx *echo.Context) error {
 	if err != nil {
 		return err
 	}
+	if h.protected[name] {
+		return ctx.String(http.StatusForbidden, "Service is used to serve twintail and cannot be modified")
+	}
 	var req requests.UpdateEndpointRequest
 	if err := req.FromContext(ctx); err != nil {
 		return ctx.Render(200, "edit_endpoint.html", map[string]any{
@@ -146,11 +191,13 @@ func (h *EndpointHandler) Update(ctx *echo.Context) error {
 	}
 
 	if err := h.tailscale.UpdateEndpoint(req.ToParams(name)); err != nil {
-		return ctx.Render(http.StatusOK, "edit_endpoint.html", map[string]any{
+		return ctx.Render(http.StatusOK, "edit_endpoint.html", withError(map[string]any{
 			"ServiceName": name,
-			"Error":       err.Error(),
 			"FormData":    req,
-		})
+		}, err))
+	}
+	if err := h.stores().UpdateEndpoint(req.ToParams(name)); err != nil {
+		return err
 	}
 
 	return ctx.Redirect(http.StatusSeeOther, "/services/"+name)
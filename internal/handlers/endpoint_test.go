@@ -5,6 +5,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"twintail/internal/services"
 
@@ -25,20 +26,30 @@ func newEndpointTestValidator() *endpointTestValidator {
 }
 
 type mockEndpointService struct {
+	services          []services.ServiceView
 	serviceDetail     *services.ServiceDetailView
 	endpointErr       error
 	checkInstalledErr error
+	added             []services.EndpointParams
+	updated           []services.UpdateEndpointParams
 }
 
 func (m *mockEndpointService) CheckInstalled() error {
 	return m.checkInstalledErr
 }
 
+func (m *mockEndpointService) GetServeStatus() ([]services.ServiceView, error) {
+	return m.services, nil
+}
+
 func (m *mockEndpointService) GetServiceByName(name string) (*services.ServiceDetailView, error) {
 	return m.serviceDetail, nil
 }
 
 func (m *mockEndpointService) AddEndpoint(params services.EndpointParams) error {
+	if m.endpointErr == nil {
+		m.added = append(m.added, params)
+	}
 	return m.endpointErr
 }
 
@@ -47,12 +58,13 @@ func (m *mockEndpointService) RemoveEndpoint(params services.EndpointParams) err
 }
 
 func (m *mockEndpointService) UpdateEndpoint(params services.UpdateEndpointParams) error {
+	m.updated = append(m.updated, params)
 	return m.endpointErr
 }
 
 func TestEndpointCreate(t *testing.T) {
 	mockSvc := &mockEndpointService{}
-	ctrl := NewEndpointHandler(mockSvc)
+	ctrl := NewEndpointHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -71,7 +83,7 @@ func TestEndpointStore_Success(t *testing.T) {
 	mockSvc := &mockEndpointService{
 		endpointErr: nil,
 	}
-	ctrl := NewEndpointHandler(mockSvc)
+	ctrl := NewEndpointHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -96,7 +108,7 @@ func TestEndpointStore_Failure(t *testing.T) {
 	mockSvc := &mockEndpointService{
 		endpointErr: &services.CommandError{Message: "Failed to add endpoint", Err: nil},
 	}
-	ctrl := NewEndpointHandler(mockSvc)
+	ctrl := NewEndpointHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -115,7 +127,7 @@ func TestEndpointStore_Failure(t *testing.T) {
 
 func TestEndpointDelete(t *testing.T) {
 	mockSvc := &mockEndpointService{}
-	ctrl := NewEndpointHandler(mockSvc)
+	ctrl := NewEndpointHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -141,7 +153,7 @@ func TestEndpointDestroy_Success_ServiceExists(t *testing.T) {
 			},
 		},
 	}
-	ctrl := NewEndpointHandler(mockSvc)
+	ctrl := NewEndpointHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -167,7 +179,7 @@ func TestEndpointDestroy_Success_ServiceGone(t *testing.T) {
 		endpointErr:   nil,
 		serviceDetail: nil,
 	}
-	ctrl := NewEndpointHandler(mockSvc)
+	ctrl := NewEndpointHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -192,7 +204,7 @@ func TestEndpointDestroy_Failure(t *testing.T) {
 	mockSvc := &mockEndpointService{
 		endpointErr: &services.CommandError{Message: "Failed to remove endpoint", Err: nil},
 	}
-	ctrl := NewEndpointHandler(mockSvc)
+	ctrl := NewEndpointHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -211,7 +223,7 @@ func TestEndpointDestroy_Failure(t *testing.T) {
 
 func TestEndpointStore_ValidationError_MissingDestination(t *testing.T) {
 	mockSvc := &mockEndpointService{}
-	ctrl := NewEndpointHandler(mockSvc)
+	ctrl := NewEndpointHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -231,7 +243,7 @@ func TestEndpointStore_ValidationError_MissingDestination(t *testing.T) {
 
 func TestEndpointStore_ValidationError_InvalidProtocol(t *testing.T) {
 	mockSvc := &mockEndpointService{}
-	ctrl := NewEndpointHandler(mockSvc)
+	ctrl := NewEndpointHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -253,7 +265,7 @@ func TestEndpointCreate_TailscaleNotInstalled(t *testing.T) {
 	mockSvc := &mockEndpointService{
 		checkInstalledErr: services.ErrTailscaleNotInstalled,
 	}
-	ctrl := NewEndpointHandler(mockSvc)
+	ctrl := NewEndpointHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -267,3 +279,49 @@ func TestEndpointCreate_TailscaleNotInstalled(t *testing.T) {
 		t.Errorf("expected status 500, got %d", rec.Code)
 	}
 }
+
+func TestEndpointDestroy_ProtectedService(t *testing.T) {
+	mockSvc := &mockEndpointService{}
+	ctrl := NewEndpointHandler(mockSvc, NewShared())
+	ctrl.Protect("twintail")
+
+	e := echo.New()
+	e.Renderer = &mockRenderer{}
+	e.Validator = newEndpointTestValidator()
+	e.POST("/services/:name/endpoints/delete", ctrl.Destroy)
+
+	form := strings.NewReader("protocol=https&expose_port=443&destination=http://127.0.0.1:8077")
+	req := httptest.NewRequest(http.MethodPost, "/services/twintail/endpoints/delete", form)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", rec.Code)
+	}
+}
+
+func TestEndpointUpdate_UpdatesSchedule(t *testing.T) {
+	schedules, _ := services.NewScheduleStore(t.TempDir())
+	schedules.Add(services.EndpointParams{ServiceName: "my-service", Protocol: "https", ExposePort: "443", Destination: "http://localhost:8080"}, "0 9 * * *", "0 17 * * *", time.Now())
+	ctrl := NewEndpointHandler(&mockEndpointService{}, NewShared())
+	ctrl.SetScheduleStore(schedules)
+
+	e := echo.New()
+	e.Renderer = &mockRenderer{}
+	e.Validator = newEndpointTestValidator()
+	e.POST("/services/:name/endpoints/edit", ctrl.Update)
+
+	form := strings.NewReader("protocol=https&expose_port=443&old_destination=http://localhost:8080&new_destination=http://localhost:9090")
+	req := httptest.NewRequest(http.MethodPost, "/services/my-service/endpoints/edit", form)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected status 303, got %d", rec.Code)
+	}
+	if got := schedules.ForService("my-service"); len(got) != 1 || got[0].Endpoint.Destination != "http://localhost:9090" {
+		t.Errorf("expected the schedule to follow the new destination, got %+v", got)
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 
 	"twintail/internal/services"
@@ -8,6 +9,41 @@ import (
 	"github.com/labstack/echo/v5"
 )
 
+// errorPages holds the response code and number of remediation steps of
+// each error kind's page. Steps are the locale keys
+// error_page.<kind>.step1 to stepN.
+var errorPages = map[string]struct {
+	code  int
+	steps int
+}{
+	services.ErrorKindDaemonDown:         {http.StatusServiceUnavailable, 3},
+	services.ErrorKindNeedsLogin:         {http.StatusServiceUnavailable, 3},
+	services.ErrorKindPermissionDenied:   {http.StatusInternalServerError, 3},
+	services.ErrorKindServiceNotDefined:  {http.StatusUnprocessableEntity, 3},
+	services.ErrorKindPortConflict:       {http.StatusConflict, 3},
+	services.ErrorKindQuotaExceeded:      {http.StatusConflict, 2},
+	services.ErrorKindUnsupportedVersion: {http.StatusServiceUnavailable, 3},
+	services.ErrorKindUnknown:            {http.StatusInternalServerError, 2},
+}
+
+// renderFailure shows the page explaining err and how to fix it.
+func renderFailure(c *echo.Context, err error) error {
+	if services.IsTailscaleNotInstalledError(err) {
+		return c.Render(http.StatusOK, "tailscale_not_installed.html", nil)
+	}
+	kind := services.ClassifyError(err)
+	page := errorPages[kind]
+	steps := make([]string, page.steps)
+	for i := range steps {
+		steps[i] = fmt.Sprintf("error_page.%s.step%d", kind, i+1)
+	}
+	return c.Render(page.code, "error.html", map[string]any{
+		"Kind":  kind,
+		"Error": err.Error(),
+		"Steps": steps,
+	})
+}
+
 func HTTPErrorHandler(c *echo.Context, err error) {
 	if services.IsTailscaleNotInstalledError(err) {
 		if err := c.Render(http.StatusOK, "tailscale_not_installed.html", nil); err != nil {
@@ -17,7 +53,8 @@ func HTTPErrorHandler(c *echo.Context, err error) {
 	}
 
 	code := http.StatusInternalServerError
-	if he, ok := err.(*echo.HTTPError); ok {
+	he, isHTTPError := err.(*echo.HTTPError)
+	if isHTTPError {
 		code = he.Code
 	}
 	c.Logger().Error("http error", "error", err)
@@ -29,7 +66,22 @@ func HTTPErrorHandler(c *echo.Context, err error) {
 	}
 	if c.Request().Method == http.MethodHead {
 		_ = c.NoContent(code)
-	} else {
-		_ = c.String(code, err.Error())
+		return
+	}
+	if !isHTTPError {
+		rErr := renderFailure(c, err)
+		if rErr == nil {
+			return
+		}
+		c.Logger().Error("render error", "error", rErr)
+	}
+	_ = c.String(code, err.Error())
+}
+
+func withError(data map[string]any, err error) map[string]any {
+	data["Error"] = err.Error()
+	if key := services.ErrorMessageKey(err); key != "" {
+		data["ErrorKey"] = key
 	}
+	return data
 }
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+func serveError(err error) (*httptest.ResponseRecorder, *recordingRenderer) {
+	e := echo.New()
+	renderer := &recordingRenderer{}
+	e.Renderer = renderer
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.GET("/", func(c *echo.Context) error { return err })
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	return rec, renderer
+}
+
+func TestHTTPErrorHandler_DaemonDown(t *testing.T) {
+	err := &services.CommandError{Message: "failed to connect to local tailscaled", Reason: services.ErrDaemonDown}
+
+	rec, renderer := serveError(err)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", rec.Code)
+	}
+	if renderer.name != "error.html" || renderer.data["Kind"] != services.ErrorKindDaemonDown {
+		t.Fatalf("expected the daemon_down page, got %s %v", renderer.name, renderer.data)
+	}
+	steps, _ := renderer.data["Steps"].([]string)
+	if len(steps) != 3 || steps[0] != "error_page.daemon_down.step1" {
+		t.Errorf("expected three remediation steps, got %v", steps)
+	}
+}
+
+func TestHTTPErrorHandler_UnsupportedVersion(t *testing.T) {
+	rec, renderer := serveError(services.Version{Major: 1, Minor: 80, Patch: 2}.Supported())
+
+	if rec.Code != http.StatusServiceUnavailable || renderer.data["Kind"] != services.ErrorKindUnsupportedVersion {
+		t.Errorf("expected the unsupported version page, got %d %v", rec.Code, renderer.data)
+	}
+}
+
+func TestHTTPErrorHandler_Unknown(t *testing.T) {
+	rec, renderer := serveError(&services.CommandError{Message: "boom"})
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", rec.Code)
+	}
+	if renderer.data["Kind"] != services.ErrorKindUnknown || renderer.data["Error"] != "boom" {
+		t.Errorf("expected the unknown page with the raw output, got %v", renderer.data)
+	}
+}
+
+func TestHTTPErrorHandler_NotInstalled(t *testing.T) {
+	rec, renderer := serveError(services.ErrTailscaleNotInstalled)
+
+	if rec.Code != http.StatusOK || renderer.name != "tailscale_not_installed.html" {
+		t.Errorf("expected the install page, got %d %s", rec.Code, renderer.name)
+	}
+}
+
+func TestHTTPErrorHandler_HTTPError(t *testing.T) {
+	rec, renderer := serveError(echo.NewHTTPError(http.StatusNotFound, "no such node"))
+
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "no such node") {
+		t.Errorf("expected a plain 404, got %d %q", rec.Code, rec.Body.String())
+	}
+	if renderer.name != "" {
+		t.Errorf("expected nothing rendered, got %s", renderer.name)
+	}
+}
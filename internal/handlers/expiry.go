@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+	"twintail/internal/requests"
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+func expireOptions(store *services.ExpiryStore) []string {
+	if store == nil {
+		return nil
+	}
+	return requests.ExpireOptions
+}
+
+type ExpiryHandler struct {
+	*Shared
+}
+
+func NewExpiryHandler(shared *Shared) *ExpiryHandler {
+	return &ExpiryHandler{Shared: shared}
+}
+
+func (h *ExpiryHandler) find(name, id string) (services.Expiry, bool) {
+	if h.records.Expiries == nil {
+		return services.Expiry{}, false
+	}
+	e, ok := h.records.Expiries.Get(id)
+	return e, ok && e.ServiceName() == name
+}
+
+func (h *ExpiryHandler) Extend(ctx *echo.Context) error {
+	name, err := validateServiceNameParam(ctx)
+	if err != nil {
+		return err
+	}
+	var req requests.ExtendExpiryRequest
+	if err := req.FromContext(ctx); err != nil {
+		return ctx.String(http.StatusBadRequest, "Invalid request: "+err.Error())
+	}
+	if h.protected[name] {
+		return ctx.String(http.StatusForbidden, "Service is used to serve twintail and cannot expire")
+	}
+	e, ok := h.find(name, req.ID)
+	if !ok {
+		return ctx.String(http.StatusNotFound, "Expiry not found")
+	}
+	if err := h.records.Expiries.Extend(e.ID, requests.ParseTTL(req.ExtendBy), time.Now()); err != nil {
+		return ctx.String(http.StatusInternalServerError, "Failed to extend expiry: "+err.Error())
+	}
+	return ctx.Redirect(http.StatusSeeOther, "/services/"+name)
+}
+
+func (h *ExpiryHandler) Cancel(ctx *echo.Context) error {
+	name, err := validateServiceNameParam(ctx)
+	if err != nil {
+		return err
+	}
+	var req requests.CancelExpiryRequest
+	if err := req.FromContext(ctx); err != nil {
+		return ctx.String(http.StatusBadRequest, "Invalid request: "+err.Error())
+	}
+	e, ok := h.find(name, req.ID)
+	if !ok {
+		return ctx.String(http.StatusNotFound, "Expiry not found")
+	}
+	if err := h.records.Expiries.Delete(e.ID); err != nil {
+		return ctx.String(http.StatusInternalServerError, "Failed to cancel expiry: "+err.Error())
+	}
+	return ctx.Redirect(http.StatusSeeOther, "/services/"+name)
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+func TestExpiryExtend(t *testing.T) {
+	store, _ := services.NewExpiryStore(t.TempDir())
+	expiresAt := time.Now().Add(time.Hour)
+	entry, _ := store.Add(services.ExpireService, services.EndpointParams{ServiceName: "dev"}, expiresAt)
+	ctrl := NewExpiryHandler(NewShared())
+	ctrl.SetExpiryStore(store)
+
+	rec, _ := callForm(t, ctrl.Extend, http.MethodPost, "/services/:name/expiry/extend", "/services/dev/expiry/extend", "id="+entry.ID+"&extend_by=2h")
+
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected status 303, got %d: %s", rec.Code, rec.Body.String())
+	}
+	got, _ := store.Get(entry.ID)
+	if !got.ExpiresAt.Equal(expiresAt.Add(2 * time.Hour)) {
+		t.Errorf("expected expiry to be extended by 2h, got %v", got.ExpiresAt)
+	}
+}
+
+func TestExpiryExtend_OtherService(t *testing.T) {
+	store, _ := services.NewExpiryStore(t.TempDir())
+	entry, _ := store.Add(services.ExpireService, services.EndpointParams{ServiceName: "dev"}, time.Now().Add(time.Hour))
+	ctrl := NewExpiryHandler(NewShared())
+	ctrl.SetExpiryStore(store)
+
+	rec, _ := callForm(t, ctrl.Extend, http.MethodPost, "/services/:name/expiry/extend", "/services/prod/expiry/extend", "id="+entry.ID+"&extend_by=2h")
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
+
+func TestExpiryCancel(t *testing.T) {
+	store, _ := services.NewExpiryStore(t.TempDir())
+	entry, _ := store.Add(services.ExpireService, services.EndpointParams{ServiceName: "dev"}, time.Now().Add(time.Hour))
+	ctrl := NewExpiryHandler(NewShared())
+	ctrl.SetExpiryStore(store)
+
+	rec, _ := callForm(t, ctrl.Cancel, http.MethodPost, "/services/:name/expiry/cancel", "/services/dev/expiry/cancel", "id="+entry.ID)
+
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected status 303, got %d", rec.Code)
+	}
+	if _, ok := store.Get(entry.ID); ok {
+		t.Error("expected expiry to be removed")
+	}
+}
+
+func TestStore_WithTTLRegistersExpiry(t *testing.T) {
+	store, _ := services.NewExpiryStore(t.TempDir())
+	ctrl := NewServiceHandler(&mockTailscaleService{}, NewShared())
+	ctrl.SetExpiryStore(store)
+
+	e := echo.New()
+	e.Renderer = &mockRenderer{}
+	e.Validator = newTestValidator()
+	form := strings.NewReader("service_name=dev&protocol=https&expose_port=443&destination=http://localhost:5173&expire_in=2h")
+	req := httptest.NewRequest(http.MethodPost, "/services/new", form)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := ctrl.Store(c); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	entries := store.ForService("dev")
+	if len(entries) != 1 || entries[0].Kind != services.ExpireService {
+		t.Fatalf("expected a service expiry, got %+v", entries)
+	}
+	if remaining := time.Until(entries[0].ExpiresAt); remaining < 119*time.Minute || remaining > 2*time.Hour {
+		t.Errorf("expected expiry in about 2h, got %v", remaining)
+	}
+}
+
+func TestStore_WithTTLRefusesProtected(t *testing.T) {
+	store, _ := services.NewExpiryStore(t.TempDir())
+	mockSvc := &mockTailscaleService{}
+	ctrl := NewServiceHandler(mockSvc, NewShared())
+	ctrl.SetExpiryStore(store)
+	ctrl.Protect("twintail")
+
+	form := "service_name=twintail&protocol=https&expose_port=443&destination=http://localhost:8080&expire_in=2h"
+	_, renderer := callForm(t, ctrl.Store, http.MethodPost, "/services/new", "/services/new", form)
+
+	if renderer.data["Error"] != services.ErrExpiryProtected.Error() {
+		t.Errorf("expected the protected error, got %v", renderer.data["Error"])
+	}
+	if mockSvc.advertiseCalls != 0 || len(store.ForService("twintail")) != 0 {
+		t.Errorf("expected nothing to be served or stored, got %d %+v", mockSvc.advertiseCalls, store.ForService("twintail"))
+	}
+}
+
+func TestEndpointStore_WithTTLRefusesProtected(t *testing.T) {
+	store, _ := services.NewExpiryStore(t.TempDir())
+	mockSvc := &mockEndpointService{}
+	ctrl := NewEndpointHandler(mockSvc, NewShared())
+	ctrl.SetExpiryStore(store)
+	ctrl.Protect("twintail")
+
+	form := "protocol=https&expose_port=443&path=/api&destination=http://localhost:4000&expire_in=2h"
+	_, renderer := callForm(t, ctrl.Store, http.MethodPost, "/services/:name/endpoints/new", "/services/twintail/endpoints/new", form)
+
+	if renderer.data["Error"] != services.ErrExpiryProtected.Error() {
+		t.Errorf("expected the protected error, got %v", renderer.data["Error"])
+	}
+	if len(mockSvc.added) != 0 || len(store.ForService("twintail")) != 0 {
+		t.Errorf("expected nothing to be served or stored, got %+v %+v", mockSvc.added, store.ForService("twintail"))
+	}
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"twintail/internal/requests"
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+// HubHandler shows the services of every node and manages endpoints on a
+// chosen node. It answers 404 unless hub mode is enabled.
+type HubHandler struct {
+	*Shared
+	hub *services.Hub
+}
+
+func NewHubHandler(shared *Shared) *HubHandler {
+	return &HubHandler{Shared: shared}
+}
+
+func (h *HubHandler) SetHub(hub *services.Hub) {
+	h.hub = hub
+}
+
+func (h *HubHandler) Index(ctx *echo.Context) error {
+	if h.hub == nil {
+		return echo.ErrNotFound
+	}
+	nodes, svcs := h.hub.Status()
+	return ctx.Render(http.StatusOK, "nodes.html", map[string]any{
+		"Nodes":    nodes,
+		"Services": svcs,
+	})
+}
+
+// target resolves the node and service of the request.
+func (h *HubHandler) target(ctx *echo.Context) (services.Node, string, error) {
+	if h.hub == nil {
+		return services.Node{}, "", echo.ErrNotFound
+	}
+	node, err := h.hub.Node(ctx.Param("node"))
+	if err != nil {
+		return services.Node{}, "", echo.ErrNotFound
+	}
+	name := ctx.Param("name")
+	if err := requests.ValidateServiceName(name); err != nil {
+		return services.Node{}, "", echo.NewHTTPError(http.StatusBadRequest, "Invalid service name: "+err.Error())
+	}
+	return node, name, nil
+}
+
+// local reports whether node is the one the hub runs on, whose drains,
+// expiries and schedules are the ones kept here.
+func (h *HubHandler) local(node services.Node) bool {
+	return node.Name == h.hub.Local().Name
+}
+
+func (h *HubHandler) showData(node services.Node, name string, req requests.StoreEndpointRequest) (map[string]any, error) {
+	detail, err := node.Service.GetServiceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	hosts := h.hub.Hosts(name)
+	return map[string]any{
+		"Node":        node.Name,
+		"ServiceName": name,
+		"Service":     detail,
+		"Protected":   h.protected[name],
+		"FormData":    req,
+		"Hosts":       hosts,
+		"SyncFrom":    node.Name,
+		"CanSync":     !h.protected[name] && services.CanSync(hosts, node.Name),
+	}, nil
+}
+
+func (h *HubHandler) Show(ctx *echo.Context) error {
+	node, name, err := h.target(ctx)
+	if err != nil {
+		return err
+	}
+	var req requests.StoreEndpointRequest
+	data, err := h.showData(node, name, req.Default())
+	if err != nil {
+		return err
+	}
+	return ctx.Render(http.StatusOK, "node_service.html", data)
+}
+
+func (h *HubHandler) redirect(ctx *echo.Context, node services.Node, name string) error {
+	return ctx.Redirect(http.StatusSeeOther, "/nodes/"+url.PathEscape(node.Name)+"/services/"+url.PathEscape(name))
+}
+
+// renderError shows the node page again with err, falling back to the bare
+// error when the node cannot be read either.
+func (h *HubHandler) renderError(ctx *echo.Context, node services.Node, name string, req requests.StoreEndpointRequest, err error) error {
+	data, readErr := h.showData(node, name, req)
+	if readErr != nil {
+		return readErr
+	}
+	return ctx.Render(http.StatusOK, "node_service.html", withError(data, err))
+}
+
+func (h *HubHandler) AddEndpoint(ctx *echo.Context) error {
+	node, name, err := h.target(ctx)
+	if err != nil {
+		return err
+	}
+	if h.protected[name] {
+		return ctx.String(http.StatusForbidden, "Service is used to serve twintail and cannot be modified")
+	}
+	var req requests.StoreEndpointRequest
+	if err := req.FromContext(ctx); err != nil {
+		return h.renderError(ctx, node, name, req, err)
+	}
+	err = nil
+	if h.local(node) {
+		err = h.stores().CheckDrained(name)
+	}
+	if err == nil {
+		err = node.Service.AddEndpoint(req.ToParams(name))
+	}
+	if err != nil {
+		return h.renderError(ctx, node, name, req, err)
+	}
+	return h.redirect(ctx, node, name)
+}
+
+func (h *HubHandler) RemoveEndpoint(ctx *echo.Context) error {
+	node, name, err := h.target(ctx)
+	if err != nil {
+		return err
+	}
+	if h.protected[name] {
+		return ctx.String(http.StatusForbidden, "Service is used to serve twintail and cannot be modified")
+	}
+	var req requests.DestroyEndpointRequest
+	var form requests.StoreEndpointRequest
+	if err := req.FromContext(ctx); err != nil {
+		return h.renderError(ctx, node, name, form.Default(), err)
+	}
+	if err := node.Service.RemoveEndpoint(req.ToParams(name)); err != nil {
+		return h.renderError(ctx, node, name, form.Default(), err)
+	}
+	if h.local(node) {
+		if err := h.stores().ForgetEndpoint(req.ToParams(name)); err != nil {
+			return err
+		}
+	}
+	return h.redirect(ctx, node, name)
+}
+
+// PlanSync shows what syncing the service on this node to the other nodes
+// hosting it would change.
+func (h *HubHandler) PlanSync(ctx *echo.Context) error {
+	node, name, err := h.target(ctx)
+	if err != nil {
+		return err
+	}
+	if h.protected[name] {
+		return ctx.String(http.StatusForbidden, "Service is used to serve twintail and cannot be modified")
+	}
+	plans, err := h.hub.PlanSyncService(name, node.Name)
+	if err != nil {
+		var form requests.StoreEndpointRequest
+		return h.renderError(ctx, node, name, form.Default(), err)
+	}
+	return ctx.Render(http.StatusOK, "sync_plan.html", syncPlanData(node, name, plans))
+}
+
+// Sync copies the endpoints of the service on this node to the other nodes
+// hosting it, provided the plan is still the one that was confirmed.
+func (h *HubHandler) Sync(ctx *echo.Context) error {
+	node, name, err := h.target(ctx)
+	if err != nil {
+		return err
+	}
+	if h.protected[name] {
+		return ctx.String(http.StatusForbidden, "Service is used to serve twintail and cannot be modified")
+	}
+	plans, err := h.hub.PlanSyncService(name, node.Name)
+	if err != nil {
+		var form requests.StoreEndpointRequest
+		return h.renderError(ctx, node, name, form.Default(), err)
+	}
+	var req requests.SyncServiceRequest
+	if err := req.FromContext(ctx); err != nil {
+		return ctx.Render(http.StatusOK, "sync_plan.html", withError(syncPlanData(node, name, plans), err))
+	}
+	if req.Plan != services.SyncSignature(plans) {
+		return ctx.Render(http.StatusOK, "sync_plan.html", withError(syncPlanData(node, name, plans), errSyncPlanChanged))
+	}
+	if drained := h.stores().CheckDrained(name); drained != nil {
+		for i := range plans {
+			if plans[i].Node == h.hub.Local().Name && plans[i].Err == nil {
+				plans[i].Err = drained
+			}
+		}
+	}
+	results := h.hub.ExecuteSync(name, plans)
+	if err := h.recordSync(name, results); err != nil {
+		ctx.Logger().Error("failed to update service records", "service", name, "error", err)
+	}
+	return ctx.Render(http.StatusOK, "sync_result.html", map[string]any{
+		"Node":        node.Name,
+		"ServiceName": name,
+		"Results":     results,
+	})
+}
+
+// recordSync keeps the records of this node in step with the endpoints a
+// sync repointed or removed on it.
+func (h *HubHandler) recordSync(name string, results []services.NodeSync) error {
+	var errs []error
+	for _, r := range results {
+		if r.Node != h.hub.Local().Name || r.Err != nil {
+			continue
+		}
+		for i, port := range r.Plan.Update {
+			errs = append(errs, h.stores().UpdateEndpoint(services.UpdateEndpointParams{
+				ServiceName:    name,
+				Protocol:       port.Protocol,
+				ExposePort:     port.ExposePort,
+				Path:           port.Path,
+				OldDestination: r.Plan.Previous[i].Destination,
+				NewDestination: port.Destination,
+			}))
+		}
+		for _, port := range r.Plan.Remove {
+			errs = append(errs, h.stores().ForgetEndpoint(services.EndpointParams{
+				ServiceName: name,
+				Protocol:    port.Protocol,
+				ExposePort:  port.ExposePort,
+				Path:        port.Path,
+				Destination: port.Destination,
+			}))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var errSyncPlanChanged = errors.New("the hosts changed since the plan was shown; review the new plan")
+
+func syncPlanData(node services.Node, name string, plans []services.NodeSync) map[string]any {
+	return map[string]any{
+		"Node":        node.Name,
+		"ServiceName": name,
+		"Plans":       plans,
+		"Signature":   services.SyncSignature(plans),
+	}
+}
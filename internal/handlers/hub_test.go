@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"twintail/internal/services"
+)
+
+func newTestHub(t *testing.T, nodes ...services.Node) *HubHandler {
+	t.Helper()
+	hub, err := services.NewHub(nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHubHandler(NewShared())
+	h.SetHub(hub)
+	return h
+}
+
+func TestHubHandler_IndexWithoutHub(t *testing.T) {
+	h := NewHubHandler(NewShared())
+
+	rec, _ := callForm(t, h.Index, http.MethodGet, "/nodes", "/nodes", "")
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
+
+func TestHubHandler_Index(t *testing.T) {
+	h := newTestHub(t,
+		services.Node{Name: "a", Service: &mockEndpointService{services: []services.ServiceView{{Name: "web"}}}},
+		services.Node{Name: "b", Service: &mockEndpointService{services: []services.ServiceView{{Name: "web"}}}},
+	)
+
+	rec, renderer := callForm(t, h.Index, http.MethodGet, "/nodes", "/nodes", "")
+
+	if rec.Code != http.StatusOK || renderer.name != "nodes.html" {
+		t.Fatalf("expected nodes.html, got %d %q", rec.Code, renderer.name)
+	}
+	svcs := renderer.data["Services"].([]services.HubService)
+	if len(svcs) != 1 || len(svcs[0].Backends) != 2 {
+		t.Errorf("expected web backed by both nodes, got %+v", svcs)
+	}
+}
+
+func TestHubHandler_ShowUnknownNode(t *testing.T) {
+	h := newTestHub(t, services.Node{Name: "a", Service: &mockEndpointService{}})
+
+	rec, _ := callForm(t, h.Show, http.MethodGet, "/nodes/:node/services/:name", "/nodes/b/services/web", "")
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
+
+func TestHubHandler_AddEndpoint(t *testing.T) {
+	a, b := &mockEndpointService{}, &mockEndpointService{}
+	h := newTestHub(t, services.Node{Name: "a", Service: a}, services.Node{Name: "b", Service: b})
+
+	rec, _ := callForm(t, h.AddEndpoint, http.MethodPost, "/nodes/:node/services/:name/endpoints", "/nodes/b/services/web/endpoints",
+		"protocol=https&expose_port=443&path=/&destination=http://localhost:3000")
+
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/nodes/b/services/web" {
+		t.Fatalf("expected redirect to the node page, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if len(a.added) != 0 || len(b.added) != 1 || b.added[0].ServiceName != "web" {
+		t.Errorf("expected the endpoint on node b only, got a=%+v b=%+v", a.added, b.added)
+	}
+}
+
+func TestHubHandler_AddEndpointError(t *testing.T) {
+	svc := &mockEndpointService{endpointErr: errors.New("agent unreachable")}
+	h := newTestHub(t, services.Node{Name: "a", Service: svc})
+
+	rec, renderer := callForm(t, h.AddEndpoint, http.MethodPost, "/nodes/:node/services/:name/endpoints", "/nodes/a/services/web/endpoints",
+		"protocol=https&expose_port=443&path=/&destination=http://localhost:3000")
+
+	if rec.Code != http.StatusOK || renderer.name != "node_service.html" {
+		t.Fatalf("expected node_service.html, got %d %q", rec.Code, renderer.name)
+	}
+	if renderer.data["Error"] == nil {
+		t.Error("expected an error in the page data")
+	}
+}
+
+func TestHubHandler_AddEndpointDrained(t *testing.T) {
+	svc := newMockServeService(&services.ServiceDetailView{Name: "web", Ports: []services.PortEntry{
+		{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3000"},
+	}})
+	hub, _ := services.NewHub([]services.Node{{Name: "a", Service: svc}})
+	h := NewHubHandler(drainedShared(t, svc, "web"))
+	h.SetHub(hub)
+
+	_, renderer := callForm(t, h.AddEndpoint, http.MethodPost, "/nodes/:node/services/:name/endpoints", "/nodes/a/services/web/endpoints",
+		"protocol=https&expose_port=443&path=/api&destination=http://localhost:4000")
+
+	if renderer.data["Error"] != services.ErrServiceDrained.Error() || len(svc.added) != 0 {
+		t.Errorf("expected the drained error and no change, got %v %+v", renderer.data["Error"], svc.added)
+	}
+}
+
+func TestHubHandler_RemoveEndpointForgetsLocalRecords(t *testing.T) {
+	api := services.EndpointParams{ServiceName: "web", Protocol: "https", ExposePort: "443", Path: "/api", Destination: "http://localhost:4000"}
+	a, b := newMockServeService(), newMockServeService()
+	h := newTestHub(t, services.Node{Name: "a", Service: a}, services.Node{Name: "b", Service: b})
+	expiries, _ := services.NewExpiryStore(t.TempDir())
+	expiries.Add(services.ExpireEndpoint, api, time.Now().Add(time.Hour))
+	h.SetExpiryStore(expiries)
+	form := "protocol=https&expose_port=443&path=/api&destination=http://localhost:4000"
+
+	callForm(t, h.RemoveEndpoint, http.MethodPost, "/nodes/:node/services/:name/endpoints/delete", "/nodes/b/services/web/endpoints/delete", form)
+	if len(expiries.ForService("web")) != 1 {
+		t.Fatal("expected a removal on another node to keep the records of this one")
+	}
+
+	callForm(t, h.RemoveEndpoint, http.MethodPost, "/nodes/:node/services/:name/endpoints/delete", "/nodes/a/services/web/endpoints/delete", form)
+	if len(a.removed) != 1 || len(expiries.ForService("web")) != 0 {
+		t.Errorf("expected the expiry of the removed endpoint to be forgotten, got %+v", expiries.ForService("web"))
+	}
+}
+
+func TestHubHandler_Protected(t *testing.T) {
+	svc := &mockEndpointService{}
+	h := newTestHub(t, services.Node{Name: "a", Service: svc})
+	h.Protect("twintail")
+
+	rec, _ := callForm(t, h.AddEndpoint, http.MethodPost, "/nodes/:node/services/:name/endpoints", "/nodes/a/services/twintail/endpoints",
+		"protocol=https&expose_port=443&path=/&destination=http://localhost:3000")
+
+	if rec.Code != http.StatusForbidden || len(svc.added) != 0 {
+		t.Errorf("expected status 403 and no change, got %d %+v", rec.Code, svc.added)
+	}
+}
+
+func TestHubHandler_Sync(t *testing.T) {
+	root := services.PortEntry{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3000"}
+	stale := services.PortEntry{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3001"}
+	a := &mockEndpointService{serviceDetail: &services.ServiceDetailView{Name: "web", Ports: []services.PortEntry{root}}}
+	b := &mockEndpointService{serviceDetail: &services.ServiceDetailView{Name: "web", Ports: []services.PortEntry{stale}}}
+	h := newTestHub(t, services.Node{Name: "a", Service: a}, services.Node{Name: "b", Service: b})
+
+	rec, renderer := callForm(t, h.PlanSync, http.MethodPost, "/nodes/:node/services/:name/sync/plan", "/nodes/a/services/web/sync/plan", "")
+
+	if rec.Code != http.StatusOK || renderer.name != "sync_plan.html" {
+		t.Fatalf("expected sync_plan.html, got %d %q", rec.Code, renderer.name)
+	}
+	if len(b.updated) != 0 {
+		t.Fatalf("expected the plan to change nothing, got %v", b.updated)
+	}
+	signature := renderer.data["Signature"].(string)
+
+	rec, renderer = callForm(t, h.Sync, http.MethodPost, "/nodes/:node/services/:name/sync", "/nodes/a/services/web/sync", "plan="+signature)
+
+	if rec.Code != http.StatusOK || renderer.name != "sync_result.html" {
+		t.Fatalf("expected sync_result.html, got %d %q", rec.Code, renderer.name)
+	}
+	results := renderer.data["Results"].([]services.NodeSync)
+	if len(results) != 1 || results[0].Node != "b" || len(results[0].Plan.Update) != 1 || results[0].Err != nil {
+		t.Errorf("expected node b to be updated, got %+v", results)
+	}
+}
+
+func TestHubHandler_SyncPlanChanged(t *testing.T) {
+	root := services.PortEntry{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3000"}
+	stale := services.PortEntry{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3001"}
+	a := &mockEndpointService{serviceDetail: &services.ServiceDetailView{Name: "web", Ports: []services.PortEntry{root}}}
+	b := &mockEndpointService{serviceDetail: &services.ServiceDetailView{Name: "web", Ports: []services.PortEntry{stale}}}
+	h := newTestHub(t, services.Node{Name: "a", Service: a}, services.Node{Name: "b", Service: b})
+	for _, form := range []string{"", "plan=" + services.SyncSignature(nil)} {
+		rec, renderer := callForm(t, h.Sync, http.MethodPost, "/nodes/:node/services/:name/sync", "/nodes/a/services/web/sync", form)
+
+		if rec.Code != http.StatusOK || renderer.name != "sync_plan.html" || renderer.data["Error"] == nil {
+			t.Errorf("%q: expected the plan again with an error, got %d %q", form, rec.Code, renderer.name)
+		}
+	}
+	if len(b.updated) != 0 {
+		t.Errorf("expected no change without a matching confirmation, got %v", b.updated)
+	}
+}
+
+func TestHubHandler_SyncNotOnNode(t *testing.T) {
+	h := newTestHub(t, services.Node{Name: "a", Service: &mockEndpointService{}})
+
+	rec, renderer := callForm(t, h.Sync, http.MethodPost, "/nodes/:node/services/:name/sync", "/nodes/a/services/web/sync", "")
+
+	if rec.Code != http.StatusOK || renderer.name != "node_service.html" || renderer.data["Error"] == nil {
+		t.Errorf("expected the node page with an error, got %d %q %v", rec.Code, renderer.name, renderer.data["Error"])
+	}
+}
+
+func TestShow_ListsHostsInHubMode(t *testing.T) {
+	detail := &services.ServiceDetailView{Name: "web", Ports: []services.PortEntry{{Protocol: "https", ExposePort: "443", Destination: "http://localhost:3000"}}}
+	hub, _ := services.NewHub([]services.Node{
+		{Name: "local", Service: &mockEndpointService{serviceDetail: detail}},
+		{Name: "edge", Service: &mockEndpointService{serviceDetail: detail}},
+	})
+	ctrl := NewServiceHandler(&mockTailscaleService{serviceDetail: detail}, NewShared())
+	ctrl.SetHub(hub)
+
+	rec, renderer := callForm(t, ctrl.Show, http.MethodGet, "/services/:name", "/services/web", "")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	hosts := renderer.data["Hosts"].([]services.ServiceHost)
+	if len(hosts) != 2 || !hosts[0].InSync || !hosts[1].InSync {
+		t.Errorf("expected both nodes as hosts in sync, got %+v", hosts)
+	}
+	if renderer.data["SyncFrom"] != "local" || renderer.data["CanSync"] != true {
+		t.Errorf("expected sync from the local node, got %v %v", renderer.data["SyncFrom"], renderer.data["CanSync"])
+	}
+}
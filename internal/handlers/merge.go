@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"twintail/internal/requests"
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+type MergeService interface {
+	CheckInstalled() error
+	GetServeStatus() ([]services.ServiceView, error)
+	GetServiceByName(name string) (*services.ServiceDetailView, error)
+	AddEndpoint(params services.EndpointParams) error
+	RemoveEndpoint(params services.EndpointParams) error
+	ClearService(name string) error
+}
+
+type MergeHandler struct {
+	*Shared
+	tailscale MergeService
+}
+
+func NewMergeHandler(tailscale MergeService, shared *Shared) *MergeHandler {
+	return &MergeHandler{
+		tailscale: tailscale,
+		Shared:    shared,
+	}
+}
+
+func (h *MergeHandler) Create(ctx *echo.Context) error {
+	if err := h.tailscale.CheckInstalled(); err != nil {
+		return err
+	}
+	svcs, err := h.tailscale.GetServeStatus()
+	if err != nil {
+		return err
+	}
+	return ctx.Render(http.StatusOK, "merge_services.html", map[string]any{
+		"Services": h.mergeable(svcs),
+		"FormData": requests.MergeServicesRequest{},
+	})
+}
+
+func (h *MergeHandler) Plan(ctx *echo.Context) error {
+	var req requests.MergeServicesRequest
+	plan, err := h.plan(ctx, &req)
+	if err != nil {
+		return h.renderForm(ctx, req, err)
+	}
+	return ctx.Render(http.StatusOK, "merge_plan.html", map[string]any{
+		"Plan":     plan,
+		"FormData": req,
+	})
+}
+
+func (h *MergeHandler) Store(ctx *echo.Context) error {
+	var req requests.MergeServicesRequest
+	plan, err := h.plan(ctx, &req)
+	if err != nil {
+		return h.renderForm(ctx, req, err)
+	}
+	if !plan.Valid() {
+		return ctx.Render(http.StatusOK, "merge_plan.html", withError(map[string]any{
+			"Plan":     plan,
+			"FormData": req,
+		}, services.ErrMergeConflict))
+	}
+
+	result := services.ExecuteMerge(h.tailscale, plan)
+	for _, name := range result.Cleared {
+		if err := h.stores().ForgetService(name); err != nil {
+			ctx.Logger().Error("failed to forget service records", "service", name, "error", err)
+		}
+	}
+	return ctx.Render(http.StatusOK, "merge_result.html", map[string]any{
+		"Plan":   plan,
+		"Result": result,
+	})
+}
+
+func (h *MergeHandler) plan(ctx *echo.Context, req *requests.MergeServicesRequest) (services.MergePlan, error) {
+	if err := req.FromContext(ctx); err != nil {
+		return services.MergePlan{}, err
+	}
+
+	if h.protected[req.Target] {
+		return services.MergePlan{}, fmt.Errorf("service %s is used to serve twintail and cannot be merged into", req.Target)
+	}
+	if err := h.stores().CheckDrained(req.Target); err != nil {
+		return services.MergePlan{}, err
+	}
+	target, err := h.tailscale.GetServiceByName(req.Target)
+	if err != nil {
+		return services.MergePlan{}, err
+	}
+
+	var sources []*services.ServiceDetailView
+	for _, name := range req.Sources {
+		if h.protected[name] {
+			return services.MergePlan{}, fmt.Errorf("service %s is used to serve twintail and cannot be merged", name)
+		}
+		svc, err := h.tailscale.GetServiceByName(name)
+		if err != nil {
+			return services.MergePlan{}, err
+		}
+		if svc == nil {
+			return services.MergePlan{}, fmt.Errorf("service %s not found", name)
+		}
+		// The mounts outlive the measuring proxies of their source.
+		sources = append(sources, h.stores().Resolve(svc))
+	}
+
+	return services.PlanMerge(req.Target, target, sources), nil
+}
+
+func (h *MergeHandler) renderForm(ctx *echo.Context, req requests.MergeServicesRequest, err error) error {
+	svcs, statusErr := h.tailscale.GetServeStatus()
+	if statusErr != nil {
+		return statusErr
+	}
+	return ctx.Render(http.StatusOK, "merge_services.html", withError(map[string]any{
+		"Services": h.mergeable(svcs),
+		"FormData": req,
+	}, err))
+}
+
+func (h *MergeHandler) mergeable(svcs []services.ServiceView) []services.ServiceView {
+	var result []services.ServiceView
+	for _, svc := range svcs {
+		if !h.protected[svc.Name] {
+			result = append(result, svc)
+		}
+	}
+	return result
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"twintail/internal/services"
+)
+
+func newMockMergeService() *mockServeService {
+	return newMockServeService(
+		&services.ServiceDetailView{Name: "app1", Ports: []services.PortEntry{{Protocol: "https", ExposePort: "443", Destination: "http://localhost:3000"}}},
+		&services.ServiceDetailView{Name: "app2", Ports: []services.PortEntry{{Protocol: "https", ExposePort: "443", Destination: "http://localhost:4000"}}},
+	)
+}
+
+func TestMergeStore_Success(t *testing.T) {
+	mockSvc := newMockMergeService()
+	ctrl := NewMergeHandler(mockSvc, NewShared())
+
+	rec, _ := callForm(t, ctrl.Store, http.MethodPost, "/services/merge", "/services/merge", "target=apps&sources=app1&sources=app2")
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+	if len(mockSvc.added) != 2 || mockSvc.added[0].ServiceName != "apps" || mockSvc.added[0].Path != "/app1" {
+		t.Errorf("unexpected endpoints added %+v", mockSvc.added)
+	}
+	if len(mockSvc.cleared) != 2 {
+		t.Errorf("expected 2 services to be cleared, got %v", mockSvc.cleared)
+	}
+}
+
+func TestMergeStore_ForgetsClearedSources(t *testing.T) {
+	mockSvc := newMockMergeService()
+	dir := t.TempDir()
+	expiries, _ := services.NewExpiryStore(dir)
+	expiries.Add(services.ExpireService, services.EndpointParams{ServiceName: "app1"}, time.Now().Add(time.Hour))
+	schedules, _ := services.NewScheduleStore(dir)
+	schedules.Add(services.EndpointParams{ServiceName: "app2", Protocol: "https", ExposePort: "443", Destination: "http://localhost:4000"}, "0 9 * * *", "0 17 * * *", time.Now())
+	ctrl := NewMergeHandler(mockSvc, NewShared())
+	ctrl.SetExpiryStore(expiries)
+	ctrl.SetScheduleStore(schedules)
+
+	callForm(t, ctrl.Store, http.MethodPost, "/services/merge", "/services/merge", "target=apps&sources=app1&sources=app2")
+
+	if len(expiries.ForService("app1")) != 0 || len(schedules.ForService("app2")) != 0 {
+		t.Error("expected the records of the cleared sources to be forgotten")
+	}
+}
+
+func TestMergeStore_AddFailureClearsNothing(t *testing.T) {
+	mockSvc := newMockMergeService()
+	mockSvc.addErr = &services.CommandError{Message: "serve failed"}
+	ctrl := NewMergeHandler(mockSvc, NewShared())
+
+	callForm(t, ctrl.Store, http.MethodPost, "/services/merge", "/services/merge", "target=apps&sources=app1&sources=app2")
+
+	if len(mockSvc.cleared) != 0 {
+		t.Errorf("expected no service to be cleared, got %v", mockSvc.cleared)
+	}
+}
+
+func TestMergeStore_ProtectedSource(t *testing.T) {
+	mockSvc := newMockMergeService()
+	ctrl := NewMergeHandler(mockSvc, NewShared())
+	ctrl.Protect("app1")
+
+	_, renderer := callForm(t, ctrl.Store, http.MethodPost, "/services/merge", "/services/merge", "target=apps&sources=app1")
+
+	if msg, _ := renderer.data["Error"].(string); !strings.Contains(msg, "cannot be merged") {
+		t.Errorf("expected protected error, got %q", msg)
+	}
+	if len(mockSvc.added) != 0 {
+		t.Errorf("expected nothing to be added, got %+v", mockSvc.added)
+	}
+}
+
+func TestMergeStore_ProtectedTarget(t *testing.T) {
+	mockSvc := newMockMergeService()
+	ctrl := NewMergeHandler(mockSvc, NewShared())
+	ctrl.Protect("app2")
+
+	_, renderer := callForm(t, ctrl.Store, http.MethodPost, "/services/merge", "/services/merge", "target=app2&sources=app1")
+
+	if msg, _ := renderer.data["Error"].(string); !strings.Contains(msg, "cannot be merged into") {
+		t.Errorf("expected protected error, got %q", msg)
+	}
+	if len(mockSvc.added) != 0 || len(mockSvc.cleared) != 0 {
+		t.Errorf("expected nothing to change, got added %+v cleared %+v", mockSvc.added, mockSvc.cleared)
+	}
+}
+
+func TestMergePlan_TargetAmongSources(t *testing.T) {
+	mockSvc := newMockMergeService()
+	ctrl := NewMergeHandler(mockSvc, NewShared())
+
+	_, renderer := callForm(t, ctrl.Plan, http.MethodPost, "/services/merge/plan", "/services/merge/plan", "target=app1&sources=app1&sources=app2")
+
+	if msg, _ := renderer.data["Error"].(string); !strings.Contains(msg, "must not be one of the merged services") {
+		t.Errorf("expected validation error, got %q", msg)
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"twintail/internal/requests"
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+type EndpointProber interface {
+	Probe(ctx context.Context, hostname string, endpoint services.PortEntry) services.EndpointProbe
+}
+
+type ProbeServiceReader interface {
+	GetServiceByName(name string) (*services.ServiceDetailView, error)
+}
+
+// ProbeHandler tests an endpoint through serve and against its destination
+// so that a failure can be pinned on one of them.
+type ProbeHandler struct {
+	tailscale ProbeServiceReader
+	prober    EndpointProber
+}
+
+func NewProbeHandler(tailscale ProbeServiceReader) *ProbeHandler {
+	return &ProbeHandler{
+		tailscale: tailscale,
+		prober:    services.NewProber(),
+	}
+}
+
+func (h *ProbeHandler) SetProber(prober EndpointProber) {
+	h.prober = prober
+}
+
+// Store only probes endpoints the service has, so it cannot be used to
+// send requests to arbitrary addresses.
+func (h *ProbeHandler) Store(ctx *echo.Context) error {
+	name, err := validateServiceNameParam(ctx)
+	if err != nil {
+		return err
+	}
+	var req requests.ProbeEndpointRequest
+	if err := req.FromContext(ctx); err != nil {
+		return ctx.String(http.StatusBadRequest, "Invalid endpoint: "+err.Error())
+	}
+	svc, err := h.tailscale.GetServiceByName(name)
+	if err != nil {
+		return renderFailure(ctx, err)
+	}
+	if svc == nil {
+		return ctx.String(http.StatusNotFound, "Service not found")
+	}
+	want := req.ToEntry()
+	for _, port := range svc.Ports {
+		if port.Key() == want.Key() && port.Destination == want.Destination {
+			return ctx.Render(http.StatusOK, "probe_result.html", map[string]any{
+				"ServiceName": name,
+				"Probe":       h.prober.Probe(ctx.Request().Context(), svc.Hostname, port),
+			})
+		}
+	}
+	return ctx.String(http.StatusNotFound, "Endpoint not found")
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"twintail/internal/services"
+)
+
+type recordingProber struct {
+	probed []services.PortEntry
+}
+
+func (p *recordingProber) Probe(ctx context.Context, hostname string, endpoint services.PortEntry) services.EndpointProbe {
+	p.probed = append(p.probed, endpoint)
+	return services.EndpointProbe{Endpoint: endpoint}
+}
+
+func newProbeTestHandler() (*ProbeHandler, *recordingProber) {
+	h := NewProbeHandler(&mockTailscaleService{
+		serviceDetail: &services.ServiceDetailView{
+			Name:     "web-app",
+			Hostname: "web-app.tail1234.ts.net",
+			Ports:    []services.PortEntry{{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3000"}},
+		},
+	})
+	prober := &recordingProber{}
+	h.SetProber(prober)
+	return h, prober
+}
+
+func TestProbeHandler_Store(t *testing.T) {
+	h, prober := newProbeTestHandler()
+
+	rec, renderer := callForm(t, h.Store, http.MethodPost, "/services/:name/endpoints/test", "/services/web-app/endpoints/test",
+		"protocol=https&expose_port=443&path=/&destination=http://localhost:3000")
+
+	if rec.Code != http.StatusOK || renderer.name != "probe_result.html" {
+		t.Fatalf("expected the probe result, got %d %s", rec.Code, rec.Body.String())
+	}
+	if len(prober.probed) != 1 || prober.probed[0].Destination != "http://localhost:3000" {
+		t.Errorf("expected the endpoint to be probed, got %v", prober.probed)
+	}
+}
+
+func TestProbeHandler_UnknownEndpoint(t *testing.T) {
+	h, prober := newProbeTestHandler()
+
+	rec, _ := callForm(t, h.Store, http.MethodPost, "/services/:name/endpoints/test", "/services/web-app/endpoints/test",
+		"protocol=https&expose_port=443&path=/&destination=http://169.254.169.254")
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+	if len(prober.probed) != 0 {
+		t.Errorf("expected nothing to be probed, got %v", prober.probed)
+	}
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"twintail/internal/requests"
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+type RenameService interface {
+	CheckInstalled() error
+	GetServiceByName(name string) (*services.ServiceDetailView, error)
+	AdvertiseService(params services.AdvertiseServiceParams) error
+	AddEndpoint(params services.EndpointParams) error
+	ClearService(name string) error
+}
+
+type RenameHandler struct {
+	*Shared
+	tailscale RenameService
+}
+
+func NewRenameHandler(tailscale RenameService, shared *Shared) *RenameHandler {
+	return &RenameHandler{
+		tailscale: tailscale,
+		Shared:    shared,
+	}
+}
+
+func (h *RenameHandler) source(ctx *echo.Context) (*services.ServiceDetailView, error) {
+	name, err := validateServiceNameParam(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if h.protected[name] {
+		return nil, ctx.String(http.StatusForbidden, "Service is used to serve twintail and cannot be renamed")
+	}
+	svc, err := h.tailscale.GetServiceByName(name)
+	if err != nil {
+		return nil, renderFailure(ctx, err)
+	}
+	if svc == nil {
+		return nil, ctx.String(http.StatusNotFound, "Service not found")
+	}
+	return svc, nil
+}
+
+func (h *RenameHandler) Create(ctx *echo.Context) error {
+	if err := h.tailscale.CheckInstalled(); err != nil {
+		return err
+	}
+	svc, err := h.source(ctx)
+	if svc == nil {
+		return err
+	}
+	return ctx.Render(http.StatusOK, "rename_service.html", map[string]any{
+		"Service":  svc,
+		"FormData": requests.RenameServiceRequest{NewName: svc.Name},
+	})
+}
+
+func (h *RenameHandler) Store(ctx *echo.Context) error {
+	svc, err := h.source(ctx)
+	if svc == nil {
+		return err
+	}
+	var req requests.RenameServiceRequest
+	if err := h.validate(ctx, svc, &req); err != nil {
+		return ctx.Render(http.StatusOK, "rename_service.html", withError(map[string]any{
+			"Service":  svc,
+			"FormData": req,
+		}, err))
+	}
+
+	result := services.RenameService(h.tailscale, svc, req.NewName)
+	// The records stay with the old name while it is still serving.
+	if result.OK() {
+		if err := h.stores().RenameService(svc.Name, req.NewName); err != nil {
+			ctx.Logger().Error("failed to move service records", "service", svc.Name, "error", err)
+		}
+	}
+	if result.OK() {
+		return ctx.Redirect(http.StatusSeeOther, "/services/"+req.NewName)
+	}
+	return ctx.Render(http.StatusOK, "rename_result.html", map[string]any{
+		"Source": svc.Name,
+		"Result": result,
+	})
+}
+
+func (h *RenameHandler) validate(ctx *echo.Context, svc *services.ServiceDetailView, req *requests.RenameServiceRequest) error {
+	if err := req.FromContext(ctx); err != nil {
+		return err
+	}
+	if req.NewName == svc.Name {
+		return fmt.Errorf("new name must differ from the current name")
+	}
+	if h.protected[req.NewName] {
+		return fmt.Errorf("service %s is used to serve twintail", req.NewName)
+	}
+	if len(svc.Ports) == 0 {
+		return fmt.Errorf("service %s has no endpoints to move", svc.Name)
+	}
+	if err := h.stores().CheckDrained(req.NewName); err != nil {
+		return err
+	}
+	existing, err := h.tailscale.GetServiceByName(req.NewName)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return services.ErrServiceExists
+	}
+	return nil
+}
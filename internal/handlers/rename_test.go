@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"twintail/internal/services"
+)
+
+func newMockRenameService() *mockServeService {
+	return newMockServeService(
+		&services.ServiceDetailView{Name: "wiki", Ports: []services.PortEntry{{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3000"}}},
+		&services.ServiceDetailView{Name: "docs", Ports: []services.PortEntry{{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:4000"}}},
+	)
+}
+
+func TestRenameStore(t *testing.T) {
+	mockSvc := newMockRenameService()
+	expiries, _ := services.NewExpiryStore(t.TempDir())
+	expiries.Add(services.ExpireService, services.EndpointParams{ServiceName: "wiki"}, time.Now().Add(time.Hour))
+	ctrl := NewRenameHandler(mockSvc, NewShared())
+	ctrl.SetExpiryStore(expiries)
+
+	rec, _ := callForm(t, ctrl.Store, http.MethodPost, "/services/:name/rename", "/services/wiki/rename", "new_name=handbook")
+
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/services/handbook" {
+		t.Fatalf("expected redirect to the new service, got %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+	if mockSvc.details["wiki"] != nil || mockSvc.details["handbook"] == nil {
+		t.Errorf("expected wiki to be moved to handbook, got %+v", mockSvc.details)
+	}
+	if len(expiries.ForService("wiki")) != 0 || len(expiries.ForService("handbook")) != 1 {
+		t.Error("expected the expiry to follow the rename")
+	}
+}
+
+func TestRenameStore_ClearFailedKeepsRecords(t *testing.T) {
+	mockSvc := newMockRenameService()
+	mockSvc.clearErr = errors.New("clear failed")
+	expiries, _ := services.NewExpiryStore(t.TempDir())
+	expiries.Add(services.ExpireService, services.EndpointParams{ServiceName: "wiki"}, time.Now().Add(time.Hour))
+	ctrl := NewRenameHandler(mockSvc, NewShared())
+	ctrl.SetExpiryStore(expiries)
+
+	_, renderer := callForm(t, ctrl.Store, http.MethodPost, "/services/:name/rename", "/services/wiki/rename", "new_name=handbook")
+
+	if renderer.name != "rename_result.html" {
+		t.Fatalf("expected the rename result, got %s", renderer.name)
+	}
+	if len(expiries.ForService("wiki")) != 1 || len(expiries.ForService("handbook")) != 0 {
+		t.Error("expected the expiry to stay with the service that still serves")
+	}
+}
+
+func TestRenameStore_TargetExists(t *testing.T) {
+	mockSvc := newMockRenameService()
+	ctrl := NewRenameHandler(mockSvc, NewShared())
+
+	_, renderer := callForm(t, ctrl.Store, http.MethodPost, "/services/:name/rename", "/services/wiki/rename", "new_name=docs")
+
+	if renderer.name != "rename_service.html" || renderer.data["Error"] == nil {
+		t.Fatalf("expected form with error, got %s", renderer.name)
+	}
+	if len(mockSvc.cleared) != 0 {
+		t.Errorf("expected nothing to be cleared, got %+v", mockSvc.cleared)
+	}
+}
+
+func TestRenameStore_Protected(t *testing.T) {
+	mockSvc := newMockRenameService()
+	ctrl := NewRenameHandler(mockSvc, NewShared())
+	ctrl.Protect("wiki")
+
+	rec, _ := callForm(t, ctrl.Store, http.MethodPost, "/services/:name/rename", "/services/wiki/rename", "new_name=handbook")
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", rec.Code)
+	}
+}
+
+func TestRenameStore_TargetDrained(t *testing.T) {
+	mockSvc := newMockRenameService()
+	ctrl := NewRenameHandler(mockSvc, drainedShared(t, mockSvc, "docs"))
+
+	_, renderer := callForm(t, ctrl.Store, http.MethodPost, "/services/:name/rename", "/services/wiki/rename", "new_name=docs")
+
+	if renderer.data["Error"] != services.ErrServiceDrained.Error() {
+		t.Fatalf("expected the drained error, got %v", renderer.data["Error"])
+	}
+	if len(mockSvc.advertised) != 0 || mockSvc.details["wiki"] == nil {
+		t.Errorf("expected wiki to stay where it is, got %+v", mockSvc.details)
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+	"twintail/internal/requests"
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+type scheduleView struct {
+	services.Schedule
+	Next        time.Time
+	NextEnables bool
+}
+
+func scheduleViews(schedules []services.Schedule, now time.Time) []scheduleView {
+	views := make([]scheduleView, len(schedules))
+	for i, sched := range schedules {
+		views[i] = scheduleView{Schedule: sched}
+		views[i].Next, views[i].NextEnables = sched.NextTransition(now)
+	}
+	return views
+}
+
+type ScheduleHandler struct {
+	*Shared
+	tailscale EndpointService
+}
+
+func NewScheduleHandler(tailscale EndpointService, shared *Shared) *ScheduleHandler {
+	return &ScheduleHandler{
+		tailscale: tailscale,
+		Shared:    shared,
+	}
+}
+
+func (h *ScheduleHandler) Create(ctx *echo.Context) error {
+	name, err := validateServiceNameParam(ctx)
+	if err != nil {
+		return err
+	}
+	if h.protected[name] {
+		return ctx.String(http.StatusForbidden, "Service is used to serve twintail and cannot be modified")
+	}
+	if h.records.Schedules == nil {
+		return ctx.String(http.StatusNotFound, "Schedules are not available")
+	}
+	req := requests.StoreScheduleRequest{
+		Protocol:    ctx.QueryParam("protocol"),
+		ExposePort:  ctx.QueryParam("port"),
+		Path:        ctx.QueryParam("path"),
+		Destination: ctx.QueryParam("destination"),
+	}
+	return ctx.Render(http.StatusOK, "new_schedule.html", map[string]any{
+		"ServiceName": name,
+		"FormData":    req.Default(),
+	})
+}
+
+func (h *ScheduleHandler) Store(ctx *echo.Context) error {
+	name, err := validateServiceNameParam(ctx)
+	if err != nil {
+		return err
+	}
+	if h.protected[name] {
+		return ctx.String(http.StatusForbidden, "Service is used to serve twintail and cannot be modified")
+	}
+	if h.records.Schedules == nil {
+		return ctx.String(http.StatusNotFound, "Schedules are not available")
+	}
+	var req requests.StoreScheduleRequest
+	if err := req.FromContext(ctx); err != nil {
+		return ctx.Render(http.StatusOK, "new_schedule.html", map[string]any{
+			"ServiceName": name,
+			"Error":       err.Error(),
+			"FormData":    req,
+		})
+	}
+
+	if _, err := h.records.Schedules.Add(req.ToParams(name), req.EnableAt, req.DisableAt, time.Now()); err != nil {
+		return ctx.Render(http.StatusOK, "new_schedule.html", map[string]any{
+			"ServiceName": name,
+			"Error":       err.Error(),
+			"FormData":    req,
+		})
+	}
+	return ctx.Redirect(http.StatusSeeOther, "/services/"+name)
+}
+
+// Destroy removes a schedule. An endpoint that the schedule had switched
+// off is exposed again so it does not disappear for good.
+func (h *ScheduleHandler) Destroy(ctx *echo.Context) error {
+	name, err := validateServiceNameParam(ctx)
+	if err != nil {
+		return err
+	}
+	if h.protected[name] {
+		return ctx.String(http.StatusForbidden, "Service is used to serve twintail and cannot be modified")
+	}
+	var req requests.DeleteScheduleRequest
+	if err := req.FromContext(ctx); err != nil {
+		return ctx.String(http.StatusBadRequest, "Invalid request: "+err.Error())
+	}
+	if h.records.Schedules == nil {
+		return ctx.String(http.StatusNotFound, "Schedule not found")
+	}
+	sched, ok := h.records.Schedules.Get(req.ID)
+	if !ok || sched.ServiceName() != name {
+		return ctx.String(http.StatusNotFound, "Schedule not found")
+	}
+
+	if !sched.Exposed {
+		if err := h.tailscale.AddEndpoint(sched.Endpoint); err != nil {
+			return ctx.String(http.StatusInternalServerError, "Failed to re-enable endpoint: "+err.Error())
+		}
+	}
+	if err := h.records.Schedules.Delete(sched.ID); err != nil {
+		return ctx.String(http.StatusInternalServerError, "Failed to delete schedule: "+err.Error())
+	}
+	return ctx.Redirect(http.StatusSeeOther, "/services/"+name)
+}
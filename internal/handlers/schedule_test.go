@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+var wikiEndpoint = services.EndpointParams{ServiceName: "wiki", Protocol: "https", ExposePort: "443", Destination: "http://localhost:3000"}
+
+func TestScheduleStore(t *testing.T) {
+	store, _ := services.NewScheduleStore(t.TempDir())
+	ctrl := NewScheduleHandler(&mockEndpointService{}, NewShared())
+	ctrl.SetScheduleStore(store)
+
+	form := "protocol=https&expose_port=443&destination=http://localhost:3000&enable_at=0+9+*+*+1-5&disable_at=0+18+*+*+1-5"
+	rec, _ := callForm(t, ctrl.Store, http.MethodPost, "/services/:name/schedules/new", "/services/wiki/schedules/new", form)
+
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected status 303, got %d: %s", rec.Code, rec.Body.String())
+	}
+	got := store.ForService("wiki")
+	if len(got) != 1 || got[0].EnableAt != "0 9 * * 1-5" || got[0].Endpoint != wikiEndpoint {
+		t.Errorf("expected schedule to be stored, got %+v", got)
+	}
+}
+
+func TestScheduleStore_InvalidCron(t *testing.T) {
+	store, _ := services.NewScheduleStore(t.TempDir())
+	ctrl := NewScheduleHandler(&mockEndpointService{}, NewShared())
+	ctrl.SetScheduleStore(store)
+
+	form := "protocol=https&expose_port=443&destination=http://localhost:3000&enable_at=0+25+*+*+*&disable_at=0+18+*+*+*"
+	rec, renderer := callForm(t, ctrl.Store, http.MethodPost, "/services/:name/schedules/new", "/services/wiki/schedules/new", form)
+
+	if msg, _ := renderer.data["Error"].(string); rec.Code != http.StatusOK || !strings.Contains(msg, "cron hour") {
+		t.Errorf("expected form with cron error, got %d: %s", rec.Code, msg)
+	}
+	if len(store.All()) != 0 {
+		t.Error("expected nothing to be stored")
+	}
+}
+
+func TestScheduleStore_Protected(t *testing.T) {
+	store, _ := services.NewScheduleStore(t.TempDir())
+	ctrl := NewScheduleHandler(&mockEndpointService{}, NewShared())
+	ctrl.SetScheduleStore(store)
+	ctrl.Protect("wiki")
+
+	form := "protocol=https&expose_port=443&destination=http://localhost:3000&enable_at=0+9+*+*+*&disable_at=0+18+*+*+*"
+	rec, _ := callForm(t, ctrl.Store, http.MethodPost, "/services/:name/schedules/new", "/services/wiki/schedules/new", form)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", rec.Code)
+	}
+}
+
+func TestScheduleDestroy_ReenablesDisabledEndpoint(t *testing.T) {
+	store, _ := services.NewScheduleStore(t.TempDir())
+	// Added on a Saturday, so the schedule switches the endpoint off.
+	sched, _ := store.Add(wikiEndpoint, "0 9 * * 1-5", "0 18 * * 1-5", time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC))
+	services.RunSchedules(store, &mockEndpointService{}, time.Date(2026, 3, 7, 12, 1, 0, 0, time.UTC))
+	mockSvc := &mockEndpointService{}
+	ctrl := NewScheduleHandler(mockSvc, NewShared())
+	ctrl.SetScheduleStore(store)
+
+	rec, _ := callForm(t, ctrl.Destroy, http.MethodPost, "/services/:name/schedules/delete", "/services/wiki/schedules/delete", "id="+sched.ID)
+
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected status 303, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(mockSvc.added) != 1 || mockSvc.added[0] != wikiEndpoint {
+		t.Errorf("expected endpoint to be exposed again, got %+v", mockSvc.added)
+	}
+	if len(store.All()) != 0 {
+		t.Error("expected schedule to be deleted")
+	}
+}
+
+func TestScheduleDestroy_OtherService(t *testing.T) {
+	store, _ := services.NewScheduleStore(t.TempDir())
+	sched, _ := store.Add(wikiEndpoint, "0 9 * * 1-5", "0 18 * * 1-5", time.Now())
+	ctrl := NewScheduleHandler(&mockEndpointService{}, NewShared())
+	ctrl.SetScheduleStore(store)
+
+	rec, _ := callForm(t, ctrl.Destroy, http.MethodPost, "/services/:name/schedules/delete", "/services/other/schedules/delete", "id="+sched.ID)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
+
+func TestShow_AllEndpointsScheduledOff(t *testing.T) {
+	store, _ := services.NewScheduleStore(t.TempDir())
+	store.Add(wikiEndpoint, "0 9 * * 1-5", "0 18 * * 1-5", time.Now())
+	ctrl := NewServiceHandler(&mockTailscaleService{}, NewShared())
+	ctrl.SetScheduleStore(store)
+	renderer := &recordingRenderer{}
+
+	e := echo.New()
+	e.Renderer = renderer
+	e.GET("/services/:name", ctrl.Show)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/services/wiki", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if views, _ := renderer.data["Schedules"].([]scheduleView); len(views) != 1 || views[0].Next.IsZero() {
+		t.Errorf("expected schedule with next transition, got %+v", renderer.data["Schedules"])
+	}
+}
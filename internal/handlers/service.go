@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 	"twintail/internal/requests"
 	"twintail/internal/services"
 
@@ -21,60 +25,252 @@ type TailscaleService interface {
 	GetServeStatus() ([]services.ServiceView, error)
 	GetServiceByName(name string) (*services.ServiceDetailView, error)
 	AdvertiseService(params services.AdvertiseServiceParams) error
+	AddEndpoint(params services.EndpointParams) error
 	ClearService(name string) error
 }
 
+type StatusSource interface {
+	GetTailnetStatus() (*services.TailnetStatus, error)
+}
+
+type CertSource interface {
+	Inspect(ctx context.Context, hostname string) (*services.CertInfo, error)
+}
+
+type VersionSource interface {
+	Version() (services.Version, bool)
+}
+
 type ServiceHandler struct {
+	*Shared
 	tailscale TailscaleService
+	quota     int
+	ports     PortScanner
+	templates []services.ServiceTemplate
+	health    services.HealthReporter
+	hub       *services.Hub
+	status    StatusSource
+	version   VersionSource
+	certs     CertSource
 }
 
-func NewServiceHandler(tailscale TailscaleService) *ServiceHandler {
+type portView struct {
+	services.PortEntry
+	Expiry   *services.Expiry
+	Schedule *services.Schedule
+	Meter    *services.Meter
+	// Measurable is set when the proxy can measure the endpoint.
+	Measurable bool
+}
+
+func NewServiceHandler(tailscale TailscaleService, shared *Shared) *ServiceHandler {
 	return &ServiceHandler{
 		tailscale: tailscale,
+		Shared:    shared,
 	}
 }
 
+func (h *ServiceHandler) SetQuota(limit int) {
+	h.quota = limit
+}
+
+func (h *ServiceHandler) SetPortScanner(scanner PortScanner) {
+	h.ports = scanner
+}
+
+func (h *ServiceHandler) SetTemplates(templates []services.ServiceTemplate) {
+	h.templates = templates
+}
+
+// SetHub links the service list to the nodes page and lists the hosts of
+// each service on its page.
+func (h *ServiceHandler) SetHub(hub *services.Hub) {
+	h.hub = hub
+}
+
+func (h *ServiceHandler) SetHealthReporter(health services.HealthReporter) {
+	h.health = health
+}
+
+func (h *ServiceHandler) SetStatusSource(source StatusSource) {
+	h.status = source
+}
+
+func (h *ServiceHandler) SetVersionSource(source VersionSource) {
+	h.version = source
+}
+
+func (h *ServiceHandler) SetCertSource(source CertSource) {
+	h.certs = source
+}
+
 func (h *ServiceHandler) Index(ctx *echo.Context) error {
 	svcs, err := h.tailscale.GetServeStatus()
 	if err != nil {
-		return ctx.Render(http.StatusInternalServerError, "error.html", map[string]any{
-			"Error": err.Error(),
-		})
+		return renderFailure(ctx, err)
 	}
-	return ctx.Render(http.StatusOK, "index.html", map[string]any{
-		"Services": svcs,
-	})
+
+	// Invalid parameters from an old bookmark fall back to the full list.
+	var req requests.ListServicesRequest
+	if err := req.FromContext(ctx); err != nil {
+		req = requests.ListServicesRequest{}
+	}
+	if h.health == nil {
+		req.Health = ""
+	}
+	health := map[string]string{}
+	for _, svc := range svcs {
+		health[svc.Name] = services.ServiceHealth(svc, h.health)
+	}
+	filter := req.ToFilter()
+	data := map[string]any{
+		"Services":      filter.Apply(svcs, h.health),
+		"Total":         len(svcs),
+		"Filter":        req,
+		"Filtered":      filter.Active(),
+		"HealthEnabled": h.health != nil,
+		"Health":        health,
+		"Quota":         services.NewQuotaUsage(svcs, h.quota),
+		"Protected":     h.protected,
+		"HubEnabled":    h.hub != nil,
+		"Drained":       h.drained(),
+	}
+	if h.status != nil {
+		status, err := h.status.GetTailnetStatus()
+		if err != nil {
+			data["StatusError"] = err.Error()
+		} else {
+			data["Status"] = status
+			data["StatusWarnings"] = status.Warnings(time.Now())
+		}
+	}
+	if h.version != nil {
+		if v, ok := h.version.Version(); ok {
+			data["TailscaleVersion"] = v
+		}
+	}
+	return ctx.Render(http.StatusOK, "index.html", data)
+}
+
+func servesHTTPS(svc *services.ServiceDetailView) bool {
+	if svc.Hostname == "" {
+		return false
+	}
+	for _, port := range svc.Ports {
+		if port.Protocol == "https" {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *ServiceHandler) drained() []services.Drain {
+	if h.records.Drains == nil {
+		return nil
+	}
+	return h.records.Drains.All()
 }
 
 func (h *ServiceHandler) Create(ctx *echo.Context) error {
 	if err := h.tailscale.CheckInstalled(); err != nil {
 		return err
 	}
+	svcs, _ := h.tailscale.GetServeStatus()
 	var req requests.StoreServiceRequest
-	return ctx.Render(http.StatusOK, "new_service.html", map[string]any{
-		"FormData": req.Default(),
-	})
+	req = req.Default()
+	if t, ok := services.FindTemplate(h.templates, ctx.QueryParam("template")); ok {
+		req = req.FromTemplate(t, "")
+	}
+	data := h.formData(req)
+	data["QuotaFull"] = services.NewQuotaUsage(svcs, h.quota).Full()
+	data["Suggestions"] = destinationSuggestions(h.ports, svcs)
+	return ctx.Render(http.StatusOK, "new_service.html", data)
 }
 
 func (h *ServiceHandler) Store(ctx *echo.Context) error {
 	var req requests.StoreServiceRequest
 	if err := req.FromContext(ctx); err != nil {
-		return ctx.Render(200, "new_service.html", map[string]any{
-			"Error":    err.Error(),
-			"FormData": req,
-		})
+		data := h.formData(req)
+		data["Error"] = err.Error()
+		return ctx.Render(200, "new_service.html", data)
+	}
+
+	if svcs, err := h.tailscale.GetServeStatus(); err == nil && !req.ConfirmQuota {
+		quota := services.NewQuotaUsage(svcs, h.quota)
+		if quota.WouldExceed(svcs, req.ServiceName) {
+			data := h.formData(req)
+			data["Quota"] = quota
+			data["QuotaWarning"] = true
+			return ctx.Render(http.StatusOK, "new_service.html", data)
+		}
+	}
+
+	if h.protected[req.ServiceName] && requests.ParseTTL(req.ExpireIn) > 0 {
+		return ctx.Render(http.StatusOK, "new_service.html", withError(h.formData(req), services.ErrExpiryProtected))
+	}
+
+	err := h.stores().CheckDrained(req.ServiceName)
+	if err == nil {
+		err = h.tailscale.AdvertiseService(req.ToParams())
+	}
+	if err != nil {
+		return ctx.Render(http.StatusOK, "new_service.html", withError(h.formData(req), err))
+	}
+
+	for _, ep := range h.extraEndpoints(req) {
+		if err := h.tailscale.AddEndpoint(services.EndpointParams{
+			ServiceName: req.ServiceName,
+			Protocol:    ep.Protocol,
+			ExposePort:  ep.ExposePort,
+			Path:        ep.Path,
+			Destination: ep.Destination,
+		}); err != nil {
+			data := withError(h.formData(req), err)
+			data["PartialService"] = req.ServiceName
+			return ctx.Render(http.StatusOK, "new_service.html", data)
+		}
 	}
 
-	if err := h.tailscale.AdvertiseService(req.ToParams()); err != nil {
-		return ctx.Render(http.StatusOK, "new_service.html", map[string]any{
-			"Error":    err.Error(),
-			"FormData": req,
-		})
+	if ttl := requests.ParseTTL(req.ExpireIn); ttl > 0 && h.records.Expiries != nil {
+		params := services.EndpointParams{ServiceName: req.ServiceName}
+		if _, err := h.records.Expiries.Add(services.ExpireService, params, time.Now().Add(ttl)); err != nil {
+			return err
+		}
 	}
 
 	return ctx.Redirect(http.StatusSeeOther, "/services/"+req.ServiceName)
 }
 
+func (h *ServiceHandler) formData(req requests.StoreServiceRequest) map[string]any {
+	data := map[string]any{
+		"FormData":      req,
+		"Templates":     h.templates,
+		"ExpireOptions": expireOptions(h.records.Expiries),
+	}
+	if t, ok := services.FindTemplate(h.templates, req.Template); ok {
+		data["Template"] = t
+		data["ExtraEndpoints"] = h.extraEndpoints(req)
+	}
+	return data
+}
+
+// extraEndpoints returns the template endpoints after the first, pointed at
+// the host of the submitted destination.
+func (h *ServiceHandler) extraEndpoints(req requests.StoreServiceRequest) []services.TemplateEndpoint {
+	t, ok := services.FindTemplate(h.templates, req.Template)
+	if !ok || len(t.Endpoints) < 2 {
+		return nil
+	}
+	host := "localhost"
+	if u, err := url.Parse(req.Destination); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+	}
+	return t.Resolve(host)[1:]
+}
+
 func (h *ServiceHandler) Show(ctx *echo.Context) error {
 	name, err := validateServiceNameParam(ctx)
 	if err != nil {
@@ -82,14 +278,90 @@ func (h *ServiceHandler) Show(ctx *echo.Context) error {
 	}
 	svc, err := h.tailscale.GetServiceByName(name)
 	if err != nil {
-		return ctx.String(http.StatusInternalServerError, "Failed to get service: "+err.Error())
+		return renderFailure(ctx, err)
 	}
-	if svc == nil {
+	var schedules []services.Schedule
+	if h.records.Schedules != nil {
+		schedules = h.records.Schedules.ForService(name)
+	}
+	var drain *services.Drain
+	if h.records.Drains != nil {
+		if d, ok := h.records.Drains.Get(name); ok {
+			drain = &d
+		}
+	}
+	if svc == nil && len(schedules) == 0 && drain == nil {
 		return ctx.String(http.StatusNotFound, "Service not found")
 	}
-	return ctx.Render(http.StatusOK, "show_service.html", map[string]any{
-		"Service": svc,
-	})
+	if svc == nil {
+		// Every endpoint is switched off by its schedule right now.
+		svc = &services.ServiceDetailView{Name: name}
+	}
+	data := map[string]any{
+		"Service":   svc,
+		"Protected": h.protected[name],
+		"CanDrain":  h.records.Drains != nil,
+		"Drain":     drain,
+	}
+
+	var expiries []services.Expiry
+	if h.records.Expiries != nil {
+		expiries = h.records.Expiries.ForService(name)
+		data["ExpireOptions"] = requests.ExpireOptions
+	}
+	var meters []services.Meter
+	if h.records.Traffic != nil {
+		meters = h.records.Traffic.ForService(name)
+		data["CanMeasure"] = true
+		data["Traffic"] = trafficViews(meters, time.Now())
+	}
+	ports := make([]portView, len(svc.Ports))
+	for i, port := range svc.Ports {
+		ports[i] = portView{PortEntry: port, Measurable: services.Measurable(port)}
+		for _, m := range meters {
+			if m.Matches(port) {
+				ports[i].Meter = &m
+			}
+		}
+		for _, e := range expiries {
+			if e.Matches(port) {
+				ports[i].Expiry = &e
+			}
+		}
+		for _, sched := range schedules {
+			if sched.Matches(port) {
+				ports[i].Schedule = &sched
+			}
+		}
+	}
+	for _, e := range expiries {
+		if e.Kind == services.ExpireService {
+			data["ServiceExpiry"] = e
+		}
+	}
+	data["Ports"] = ports
+	if h.hub != nil {
+		hosts := h.hub.Hosts(name)
+		data["ServiceName"] = name
+		data["Hosts"] = hosts
+		data["SyncFrom"] = h.hub.Local().Name
+		data["CanSync"] = !h.protected[name] && services.CanSync(hosts, h.hub.Local().Name)
+	}
+	if h.records.Schedules != nil {
+		data["CanSchedule"] = true
+		data["Schedules"] = scheduleViews(schedules, time.Now())
+	}
+	if h.certs != nil && servesHTTPS(svc) {
+		cert, err := h.certs.Inspect(ctx.Request().Context(), svc.Hostname)
+		if err != nil {
+			data["CertError"] = err.Error()
+		} else {
+			data["Cert"] = cert
+			data["CertWarnings"] = cert.Warnings(time.Now())
+		}
+	}
+
+	return ctx.Render(http.StatusOK, "show_service.html", data)
 }
 
 func (h *ServiceHandler) Delete(ctx *echo.Context) error {
@@ -97,9 +369,12 @@ func (h *ServiceHandler) Delete(ctx *echo.Context) error {
 	if err != nil {
 		return err
 	}
+	if h.protected[name] {
+		return ctx.String(http.StatusForbidden, "Service is used to serve twintail and cannot be deleted")
+	}
 	svc, err := h.tailscale.GetServiceByName(name)
 	if err != nil {
-		return ctx.String(http.StatusInternalServerError, "Failed to get service: "+err.Error())
+		return renderFailure(ctx, err)
 	}
 	if svc == nil {
 		return ctx.String(http.StatusNotFound, "Service not found")
@@ -114,8 +389,14 @@ func (h *ServiceHandler) Destroy(ctx *echo.Context) error {
 	if err != nil {
 		return err
 	}
+	if h.protected[name] {
+		return ctx.String(http.StatusForbidden, "Service is used to serve twintail and cannot be deleted")
+	}
 	if err := h.tailscale.ClearService(name); err != nil {
 		return ctx.String(http.StatusInternalServerError, "Failed to delete service: "+err.Error())
 	}
+	if err := h.stores().ForgetService(name); err != nil {
+		return err
+	}
 	return ctx.Redirect(http.StatusSeeOther, "/")
 }
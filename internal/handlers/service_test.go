@@ -1,12 +1,19 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"io"
+	"maps"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"twintail/internal/requests"
 	"twintail/internal/services"
 
 	"github.com/go-playground/validator/v10"
@@ -31,6 +38,9 @@ type mockTailscaleService struct {
 	advertiseErr      error
 	clearErr          error
 	checkInstalledErr error
+	advertiseCalls    int
+	addEndpointErr    error
+	addedEndpoints    []services.EndpointParams
 }
 
 func (m *mockTailscaleService) CheckInstalled() error {
@@ -46,9 +56,15 @@ func (m *mockTailscaleService) GetServiceByName(name string) (*services.ServiceD
 }
 
 func (m *mockTailscaleService) AdvertiseService(params services.AdvertiseServiceParams) error {
+	m.advertiseCalls++
 	return m.advertiseErr
 }
 
+func (m *mockTailscaleService) AddEndpoint(params services.EndpointParams) error {
+	m.addedEndpoints = append(m.addedEndpoints, params)
+	return m.addEndpointErr
+}
+
 func (m *mockTailscaleService) ClearService(name string) error {
 	return m.clearErr
 }
@@ -64,13 +80,159 @@ func (m *mockRenderer) Render(ctx *echo.Context, w io.Writer, name string, data
 	return nil
 }
 
+type recordingRenderer struct {
+	name string
+	data map[string]any
+}
+
+func (r *recordingRenderer) Render(ctx *echo.Context, w io.Writer, name string, data any) error {
+	r.name = name
+	r.data, _ = data.(map[string]any)
+	return nil
+}
+
+// mockServeService keeps a serve config in details and applies and records
+// every change to it, for the handlers that act on more than one service.
+type mockServeService struct {
+	details    map[string]*services.ServiceDetailView
+	err        error
+	addErr     error
+	clearErr   error
+	advertised []services.AdvertiseServiceParams
+	added      []services.EndpointParams
+	removed    []services.EndpointParams
+	updated    []services.UpdateEndpointParams
+	cleared    []string
+}
+
+func newMockServeService(details ...*services.ServiceDetailView) *mockServeService {
+	m := &mockServeService{details: make(map[string]*services.ServiceDetailView)}
+	for _, svc := range details {
+		m.details[svc.Name] = svc
+	}
+	return m
+}
+
+func (m *mockServeService) CheckInstalled() error {
+	return nil
+}
+
+func (m *mockServeService) GetServeStatus() ([]services.ServiceView, error) {
+	var views []services.ServiceView
+	for _, name := range slices.Sorted(maps.Keys(m.details)) {
+		views = append(views, services.ServiceView{Name: name})
+	}
+	return views, nil
+}
+
+func (m *mockServeService) GetServiceByName(name string) (*services.ServiceDetailView, error) {
+	return m.details[name], nil
+}
+
+func (m *mockServeService) AdvertiseService(params services.AdvertiseServiceParams) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.advertised = append(m.advertised, params)
+	m.addPort(services.EndpointParams(params))
+	return nil
+}
+
+func (m *mockServeService) AddEndpoint(params services.EndpointParams) error {
+	if m.err != nil {
+		return m.err
+	}
+	if m.addErr != nil {
+		return m.addErr
+	}
+	m.added = append(m.added, params)
+	m.addPort(params)
+	return nil
+}
+
+func (m *mockServeService) RemoveEndpoint(params services.EndpointParams) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.removed = append(m.removed, params)
+	if svc := m.details[params.ServiceName]; svc != nil {
+		svc.Ports = slices.DeleteFunc(svc.Ports, func(p services.PortEntry) bool {
+			return p.Protocol == params.Protocol && p.ExposePort == params.ExposePort && p.Path == params.Path
+		})
+	}
+	return nil
+}
+
+func (m *mockServeService) UpdateEndpoint(params services.UpdateEndpointParams) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.updated = append(m.updated, params)
+	if svc := m.details[params.ServiceName]; svc != nil {
+		for i, p := range svc.Ports {
+			if p.Protocol == params.Protocol && p.ExposePort == params.ExposePort && p.Path == params.Path {
+				svc.Ports[i].Destination = params.NewDestination
+			}
+		}
+	}
+	return nil
+}
+
+func (m *mockServeService) ClearService(name string) error {
+	if m.err != nil {
+		return m.err
+	}
+	if m.clearErr != nil {
+		return m.clearErr
+	}
+	m.cleared = append(m.cleared, name)
+	delete(m.details, name)
+	return nil
+}
+
+func (m *mockServeService) addPort(params services.EndpointParams) {
+	svc := m.details[params.ServiceName]
+	if svc == nil {
+		svc = &services.ServiceDetailView{Name: params.ServiceName}
+		m.details[params.ServiceName] = svc
+	}
+	svc.Ports = append(svc.Ports, services.PortEntry{Protocol: params.Protocol, ExposePort: params.ExposePort, Path: params.Path, Destination: params.Destination})
+}
+
+// callForm serves a request with an url-encoded form body to handler mounted
+// at route, and returns the response along with what was rendered.
+func callForm(t *testing.T, handler echo.HandlerFunc, method, route, target, form string) (*httptest.ResponseRecorder, *recordingRenderer) {
+	t.Helper()
+	e := echo.New()
+	renderer := &recordingRenderer{}
+	e.Renderer = renderer
+	e.Validator = newTestValidator()
+	e.Add(method, route, handler)
+	req := httptest.NewRequest(method, target, strings.NewReader(form))
+	if form != "" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec, renderer
+}
+
+type mockPortScanner struct {
+	ports []services.ListeningPort
+	err   error
+}
+
+func (m *mockPortScanner) ListeningPorts() ([]services.ListeningPort, error) {
+	return m.ports, m.err
+}
+
 func TestIndex_Success(t *testing.T) {
 	mockSvc := &mockTailscaleService{
 		services: []services.ServiceView{
 			{Name: "web-app", HTTPSUrl: "https://example.com", Proxy: "http://localhost:3000"},
 		},
 	}
-	ctrl := NewServiceHandler(mockSvc)
+	ctrl := NewServiceHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -92,7 +254,7 @@ func TestIndex_GetServeStatusError(t *testing.T) {
 	mockSvc := &mockTailscaleService{
 		advertiseErr: &services.CommandError{Message: "Failed to get serve status", Err: nil},
 	}
-	ctrl := NewServiceHandler(mockSvc)
+	ctrl := NewServiceHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -116,7 +278,7 @@ func TestIndex_GetServeStatusError(t *testing.T) {
 
 func TestCreate(t *testing.T) {
 	mockSvc := &mockTailscaleService{}
-	ctrl := NewServiceHandler(mockSvc)
+	ctrl := NewServiceHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -138,7 +300,7 @@ func TestStore_Success(t *testing.T) {
 	mockSvc := &mockTailscaleService{
 		advertiseErr: nil,
 	}
-	ctrl := NewServiceHandler(mockSvc)
+	ctrl := NewServiceHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -163,7 +325,7 @@ func TestStore_Failure(t *testing.T) {
 	mockSvc := &mockTailscaleService{
 		advertiseErr: &services.CommandError{Message: "Service already exists", Err: nil},
 	}
-	ctrl := NewServiceHandler(mockSvc)
+	ctrl := NewServiceHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -194,7 +356,7 @@ func TestShow_Success(t *testing.T) {
 			},
 		},
 	}
-	ctrl := NewServiceHandler(mockSvc)
+	ctrl := NewServiceHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -213,7 +375,7 @@ func TestShow_NotFound(t *testing.T) {
 	mockSvc := &mockTailscaleService{
 		serviceDetail: nil,
 	}
-	ctrl := NewServiceHandler(mockSvc)
+	ctrl := NewServiceHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -236,7 +398,7 @@ func TestDelete_Success(t *testing.T) {
 			URL:      "https://example.com",
 		},
 	}
-	ctrl := NewServiceHandler(mockSvc)
+	ctrl := NewServiceHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -255,7 +417,7 @@ func TestDelete_NotFound(t *testing.T) {
 	mockSvc := &mockTailscaleService{
 		serviceDetail: nil,
 	}
-	ctrl := NewServiceHandler(mockSvc)
+	ctrl := NewServiceHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -274,7 +436,7 @@ func TestDestroy_Success(t *testing.T) {
 	mockSvc := &mockTailscaleService{
 		clearErr: nil,
 	}
-	ctrl := NewServiceHandler(mockSvc)
+	ctrl := NewServiceHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -296,7 +458,7 @@ func TestDestroy_Failure(t *testing.T) {
 	mockSvc := &mockTailscaleService{
 		clearErr: &services.CommandError{Message: "Failed to clear service", Err: nil},
 	}
-	ctrl := NewServiceHandler(mockSvc)
+	ctrl := NewServiceHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -313,7 +475,7 @@ func TestDestroy_Failure(t *testing.T) {
 
 func TestStore_ValidationError_MissingServiceName(t *testing.T) {
 	mockSvc := &mockTailscaleService{}
-	ctrl := NewServiceHandler(mockSvc)
+	ctrl := NewServiceHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -336,7 +498,7 @@ func TestStore_ValidationError_MissingServiceName(t *testing.T) {
 
 func TestStore_ValidationError_InvalidProtocol(t *testing.T) {
 	mockSvc := &mockTailscaleService{}
-	ctrl := NewServiceHandler(mockSvc)
+	ctrl := NewServiceHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -359,7 +521,7 @@ func TestStore_ValidationError_InvalidProtocol(t *testing.T) {
 
 func TestStore_ValidationError_NonNumericPort(t *testing.T) {
 	mockSvc := &mockTailscaleService{}
-	ctrl := NewServiceHandler(mockSvc)
+	ctrl := NewServiceHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -380,11 +542,65 @@ func TestStore_ValidationError_NonNumericPort(t *testing.T) {
 	}
 }
 
+func TestCreate_DestinationSuggestions(t *testing.T) {
+	mockSvc := &mockTailscaleService{
+		services: []services.ServiceView{
+			{Name: "web-app", Destinations: []string{"http://localhost:3000"}},
+		},
+	}
+	ctrl := NewServiceHandler(mockSvc, NewShared())
+	ctrl.SetPortScanner(&mockPortScanner{ports: []services.ListeningPort{
+		{Address: netip.MustParseAddr("127.0.0.1"), Port: 3000},
+		{Address: netip.MustParseAddr("127.0.0.1"), Port: 8080},
+	}})
+
+	e := echo.New()
+	renderer := &recordingRenderer{}
+	e.Renderer = renderer
+	req := httptest.NewRequest(http.MethodGet, "/services/new", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := ctrl.Create(c); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	suggestions, ok := renderer.data["Suggestions"].([]services.ListeningPort)
+	if !ok || len(suggestions) != 2 {
+		t.Fatalf("expected 2 suggestions, got %v", renderer.data["Suggestions"])
+	}
+	if !suggestions[0].InUse() || suggestions[1].InUse() {
+		t.Errorf("expected only port 3000 to be in use, got %+v", suggestions)
+	}
+}
+
+func TestCreate_SuggestionsUnavailable(t *testing.T) {
+	ctrl := NewServiceHandler(&mockTailscaleService{}, NewShared())
+	ctrl.SetPortScanner(&mockPortScanner{err: errors.New("permission denied")})
+
+	e := echo.New()
+	renderer := &recordingRenderer{}
+	e.Renderer = renderer
+	req := httptest.NewRequest(http.MethodGet, "/services/new", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := ctrl.Create(c); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+	if renderer.data["Suggestions"] != nil && len(renderer.data["Suggestions"].([]services.ListeningPort)) != 0 {
+		t.Errorf("expected no suggestions, got %v", renderer.data["Suggestions"])
+	}
+}
+
 func TestCreate_TailscaleNotInstalled(t *testing.T) {
 	mockSvc := &mockTailscaleService{
 		checkInstalledErr: services.ErrTailscaleNotInstalled,
 	}
-	ctrl := NewServiceHandler(mockSvc)
+	ctrl := NewServiceHandler(mockSvc, NewShared())
 
 	e := echo.New()
 	e.Renderer = &mockRenderer{}
@@ -398,3 +614,384 @@ func TestCreate_TailscaleNotInstalled(t *testing.T) {
 		t.Errorf("expected ErrTailscaleNotInstalled, got %v", err)
 	}
 }
+
+func TestDestroy_ProtectedService(t *testing.T) {
+	mockSvc := &mockTailscaleService{}
+	ctrl := NewServiceHandler(mockSvc, NewShared())
+	ctrl.Protect("twintail")
+
+	e := echo.New()
+	e.Renderer = &mockRenderer{}
+	e.GET("/services/:name/delete", ctrl.Delete)
+	e.POST("/services/:name/delete", ctrl.Destroy)
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		req := httptest.NewRequest(method, "/services/twintail/delete", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: expected status 403, got %d", method, rec.Code)
+		}
+	}
+}
+
+func TestStore_QuotaWarning(t *testing.T) {
+	mockSvc := &mockTailscaleService{
+		services: []services.ServiceView{{Name: "existing"}},
+	}
+	ctrl := NewServiceHandler(mockSvc, NewShared())
+	ctrl.SetQuota(1)
+
+	e := echo.New()
+	e.Renderer = &mockRenderer{}
+	e.Validator = newTestValidator()
+	form := strings.NewReader("service_name=my-service&protocol=https&expose_port=443&destination=http://localhost:8080")
+	req := httptest.NewRequest(http.MethodPost, "/services/new", form)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := ctrl.Store(c)
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200 with warning, got %d", rec.Code)
+	}
+	if mockSvc.advertiseCalls != 0 {
+		t.Errorf("expected service not to be advertised, got %d calls", mockSvc.advertiseCalls)
+	}
+}
+
+func TestStore_QuotaConfirmed(t *testing.T) {
+	mockSvc := &mockTailscaleService{
+		services: []services.ServiceView{{Name: "existing"}},
+	}
+	ctrl := NewServiceHandler(mockSvc, NewShared())
+	ctrl.SetQuota(1)
+
+	e := echo.New()
+	e.Renderer = &mockRenderer{}
+	e.Validator = newTestValidator()
+	form := strings.NewReader("service_name=my-service&protocol=https&expose_port=443&destination=http://localhost:8080&confirm_quota=true")
+	req := httptest.NewRequest(http.MethodPost, "/services/new", form)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := ctrl.Store(c)
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if rec.Code != http.StatusSeeOther {
+		t.Errorf("expected status 303, got %d", rec.Code)
+	}
+}
+
+func TestStore_QuotaAllowsExistingService(t *testing.T) {
+	mockSvc := &mockTailscaleService{
+		services: []services.ServiceView{{Name: "my-service"}},
+	}
+	ctrl := NewServiceHandler(mockSvc, NewShared())
+	ctrl.SetQuota(1)
+
+	e := echo.New()
+	e.Renderer = &mockRenderer{}
+	e.Validator = newTestValidator()
+	form := strings.NewReader("service_name=my-service&protocol=https&expose_port=443&destination=http://localhost:8080")
+	req := httptest.NewRequest(http.MethodPost, "/services/new", form)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := ctrl.Store(c)
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if rec.Code != http.StatusSeeOther {
+		t.Errorf("expected status 303, got %d", rec.Code)
+	}
+}
+
+func monitoringTemplate() services.ServiceTemplate {
+	return services.ServiceTemplate{ID: "monitoring", Name: "Monitoring", Endpoints: []services.TemplateEndpoint{
+		{Protocol: "https", ExposePort: "443", Destination: "http://{host}:3000"},
+		{Protocol: "https", ExposePort: "443", Path: "/prometheus", Destination: "http://{host}:9090"},
+	}}
+}
+
+func TestCreate_FromTemplate(t *testing.T) {
+	ctrl := NewServiceHandler(&mockTailscaleService{}, NewShared())
+	ctrl.SetTemplates([]services.ServiceTemplate{monitoringTemplate()})
+
+	e := echo.New()
+	renderer := &recordingRenderer{}
+	e.Renderer = renderer
+	req := httptest.NewRequest(http.MethodGet, "/services/new?template=monitoring", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := ctrl.Create(c); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	form, ok := renderer.data["FormData"].(requests.StoreServiceRequest)
+	if !ok {
+		t.Fatalf("expected FormData to be a StoreServiceRequest, got %T", renderer.data["FormData"])
+	}
+	if form.ServiceName != "monitoring" || form.Destination != "http://localhost:3000" || form.Template != "monitoring" {
+		t.Errorf("expected form to be pre-filled from template, got %+v", form)
+	}
+	extra, _ := renderer.data["ExtraEndpoints"].([]services.TemplateEndpoint)
+	if len(extra) != 1 || extra[0].Path != "/prometheus" {
+		t.Errorf("expected one extra endpoint, got %+v", extra)
+	}
+}
+
+func TestStore_FromTemplateAddsExtraEndpoints(t *testing.T) {
+	mockSvc := &mockTailscaleService{}
+	ctrl := NewServiceHandler(mockSvc, NewShared())
+	ctrl.SetTemplates([]services.ServiceTemplate{monitoringTemplate()})
+
+	e := echo.New()
+	e.Renderer = &mockRenderer{}
+	e.Validator = newTestValidator()
+	form := strings.NewReader("service_name=monitoring&protocol=https&expose_port=443&destination=http://nas:3000&template=monitoring")
+	req := httptest.NewRequest(http.MethodPost, "/services/new", form)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := ctrl.Store(c); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if rec.Code != http.StatusSeeOther {
+		t.Errorf("expected status 303, got %d", rec.Code)
+	}
+	if len(mockSvc.addedEndpoints) != 1 {
+		t.Fatalf("expected 1 extra endpoint, got %d", len(mockSvc.addedEndpoints))
+	}
+	added := mockSvc.addedEndpoints[0]
+	if added.ServiceName != "monitoring" || added.Path != "/prometheus" || added.Destination != "http://nas:9090" {
+		t.Errorf("unexpected extra endpoint %+v", added)
+	}
+}
+
+func TestStore_FromTemplatePartialFailure(t *testing.T) {
+	mockSvc := &mockTailscaleService{addEndpointErr: &services.CommandError{Message: "path in use"}}
+	ctrl := NewServiceHandler(mockSvc, NewShared())
+	ctrl.SetTemplates([]services.ServiceTemplate{monitoringTemplate()})
+
+	e := echo.New()
+	renderer := &recordingRenderer{}
+	e.Renderer = renderer
+	e.Validator = newTestValidator()
+	form := strings.NewReader("service_name=monitoring&protocol=https&expose_port=443&destination=http://localhost:3000&template=monitoring")
+	req := httptest.NewRequest(http.MethodPost, "/services/new", form)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := ctrl.Store(c); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+	if renderer.data["PartialService"] != "monitoring" {
+		t.Errorf("expected partial service to be reported, got %v", renderer.data["PartialService"])
+	}
+}
+
+type fixedHealth map[string]bool
+
+func (h fixedHealth) DestinationUp(dest string) (bool, bool) {
+	up, known := h[dest]
+	return up, known
+}
+
+func TestIndex_FiltersAndSorts(t *testing.T) {
+	mockSvc := &mockTailscaleService{
+		services: []services.ServiceView{
+			{Name: "api", HTTPSUrl: "https://api.example.com", Destinations: []string{"http://localhost:9000"}},
+			{Name: "app", HTTPSUrl: "https://app.example.com", Destinations: []string{"http://localhost:3000"}},
+			{Name: "wiki", HTTPUrl: "http://wiki.example.com", Destinations: []string{"http://localhost:8080"}},
+		},
+	}
+	ctrl := NewServiceHandler(mockSvc, NewShared())
+	ctrl.SetHealthReporter(fixedHealth{"http://localhost:9000": false, "http://localhost:3000": true})
+
+	e := echo.New()
+	renderer := &recordingRenderer{}
+	e.Renderer = renderer
+	e.Validator = newTestValidator()
+	req := httptest.NewRequest(http.MethodGet, "/?q=a&protocol=https&order=desc", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := ctrl.Index(c); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	svcs := renderer.data["Services"].([]services.ServiceView)
+	if len(svcs) != 2 || svcs[0].Name != "app" || svcs[1].Name != "api" {
+		t.Errorf("expected [app api], got %v", svcs)
+	}
+	if renderer.data["Total"] != 3 || renderer.data["Filtered"] != true {
+		t.Errorf("expected total 3 and an active filter, got %v %v", renderer.data["Total"], renderer.data["Filtered"])
+	}
+	if health := renderer.data["Health"].(map[string]string); health["api"] != services.HealthDown {
+		t.Errorf("expected api to be down, got %v", health)
+	}
+}
+
+func TestIndex_InvalidFilterShowsEverything(t *testing.T) {
+	mockSvc := &mockTailscaleService{
+		services: []services.ServiceView{{Name: "api"}, {Name: "app"}},
+	}
+	ctrl := NewServiceHandler(mockSvc, NewShared())
+
+	e := echo.New()
+	renderer := &recordingRenderer{}
+	e.Renderer = renderer
+	e.Validator = newTestValidator()
+	req := httptest.NewRequest(http.MethodGet, "/?q=api&sort=popularity", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := ctrl.Index(c); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if svcs := renderer.data["Services"].([]services.ServiceView); len(svcs) != 2 {
+		t.Errorf("expected the unfiltered list, got %v", svcs)
+	}
+}
+
+type fixedStatus struct {
+	status *services.TailnetStatus
+	err    error
+}
+
+func (f fixedStatus) GetTailnetStatus() (*services.TailnetStatus, error) {
+	return f.status, f.err
+}
+
+func TestIndex_TailnetStatus(t *testing.T) {
+	ctrl := NewServiceHandler(&mockTailscaleService{}, NewShared())
+	ctrl.SetStatusSource(fixedStatus{status: &services.TailnetStatus{BackendState: services.BackendNeedsLogin}})
+
+	e := echo.New()
+	renderer := &recordingRenderer{}
+	e.Renderer = renderer
+	e.Validator = newTestValidator()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+
+	if err := ctrl.Index(c); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if renderer.data["Status"] == nil {
+		t.Error("expected the status to be passed")
+	}
+	warnings := renderer.data["StatusWarnings"].([]string)
+	if len(warnings) != 1 || warnings[0] != "status.warn_logged_out" {
+		t.Errorf("expected a logged out warning, got %v", warnings)
+	}
+}
+
+func TestIndex_TailnetStatusError(t *testing.T) {
+	ctrl := NewServiceHandler(&mockTailscaleService{}, NewShared())
+	ctrl.SetStatusSource(fixedStatus{err: errors.New("exit status 1")})
+
+	e := echo.New()
+	renderer := &recordingRenderer{}
+	e.Renderer = renderer
+	e.Validator = newTestValidator()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+
+	if err := ctrl.Index(c); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if renderer.data["StatusError"] != "exit status 1" || renderer.data["Status"] != nil {
+		t.Errorf("expected only the status error, got %v %v", renderer.data["StatusError"], renderer.data["Status"])
+	}
+}
+
+type fixedVersion services.Version
+
+func (f fixedVersion) Version() (services.Version, bool) {
+	return services.Version(f), true
+}
+
+func TestIndex_TailscaleVersion(t *testing.T) {
+	ctrl := NewServiceHandler(&mockTailscaleService{}, NewShared())
+	ctrl.SetVersionSource(fixedVersion{Major: 1, Minor: 90, Patch: 1})
+
+	e := echo.New()
+	renderer := &recordingRenderer{}
+	e.Renderer = renderer
+	e.Validator = newTestValidator()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+
+	if err := ctrl.Index(c); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if v, ok := renderer.data["TailscaleVersion"].(services.Version); !ok || v.String() != "1.90.1" {
+		t.Errorf("expected version 1.90.1, got %v", renderer.data["TailscaleVersion"])
+	}
+}
+
+type fixedCert struct {
+	cert      *services.CertInfo
+	err       error
+	hostnames []string
+}
+
+func (f *fixedCert) Inspect(ctx context.Context, hostname string) (*services.CertInfo, error) {
+	f.hostnames = append(f.hostnames, hostname)
+	return f.cert, f.err
+}
+
+func TestShow_Certificate(t *testing.T) {
+	mockSvc := &mockTailscaleService{
+		serviceDetail: &services.ServiceDetailView{
+			Name:     "web-app",
+			Hostname: "web-app.tail1234.ts.net",
+			Ports:    []services.PortEntry{{Protocol: "https", ExposePort: "443", Destination: "http://localhost:3000"}},
+		},
+	}
+	certs := &fixedCert{cert: &services.CertInfo{NotAfter: time.Now().Add(24 * time.Hour)}}
+	ctrl := NewServiceHandler(mockSvc, NewShared())
+	ctrl.SetCertSource(certs)
+
+	_, renderer := callForm(t, ctrl.Show, http.MethodGet, "/services/:name", "/services/web-app", "")
+
+	if len(certs.hostnames) != 1 || certs.hostnames[0] != "web-app.tail1234.ts.net" {
+		t.Fatalf("expected the service hostname to be inspected, got %v", certs.hostnames)
+	}
+	warnings, _ := renderer.data["CertWarnings"].([]string)
+	if renderer.data["Cert"] == nil || len(warnings) != 1 || warnings[0] != "cert.warn_expiring" {
+		t.Errorf("expected the certificate with an expiry warning, got %v", renderer.data)
+	}
+}
+
+func TestShow_CertificateSkippedWithoutHTTPS(t *testing.T) {
+	mockSvc := &mockTailscaleService{
+		serviceDetail: &services.ServiceDetailView{
+			Name:     "db",
+			Hostname: "db.tail1234.ts.net",
+			Ports:    []services.PortEntry{{Protocol: "tcp", ExposePort: "5432", Destination: "tcp://localhost:5432"}},
+		},
+	}
+	certs := &fixedCert{err: errors.New("unexpected")}
+	ctrl := NewServiceHandler(mockSvc, NewShared())
+	ctrl.SetCertSource(certs)
+
+	_, renderer := callForm(t, ctrl.Show, http.MethodGet, "/services/:name", "/services/db", "")
+
+	if len(certs.hostnames) != 0 || renderer.data["CertError"] != nil {
+		t.Errorf("expected no certificate lookup, got %v", certs.hostnames)
+	}
+}
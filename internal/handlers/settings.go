@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
 	"time"
 
@@ -10,18 +11,47 @@ import (
 	"github.com/labstack/echo/v5"
 )
 
-type SettingsHandler struct{}
+type Notifications interface {
+	Targets() []services.Target
+	SendTest(ctx context.Context) []services.Delivery
+}
+
+type SettingsHandler struct {
+	notifications Notifications
+}
 
 func NewSettingsHandler() *SettingsHandler {
 	return &SettingsHandler{}
 }
 
-func (h *SettingsHandler) Show(ctx *echo.Context) error {
-	currentLang := ctx.Get("lang").(string)
-	return ctx.Render(http.StatusOK, "settings.html", map[string]any{
-		"CurrentLang": currentLang,
+func (h *SettingsHandler) SetNotifications(n Notifications) {
+	h.notifications = n
+}
+
+func (h *SettingsHandler) data(ctx *echo.Context) map[string]any {
+	data := map[string]any{
+		"CurrentLang": ctx.Get("lang").(string),
 		"Languages":   services.GetSupportedLanguages(),
-	})
+	}
+	if h.notifications != nil {
+		data["NotifyTargets"] = h.notifications.Targets()
+	}
+	return data
+}
+
+func (h *SettingsHandler) Show(ctx *echo.Context) error {
+	return ctx.Render(http.StatusOK, "settings.html", h.data(ctx))
+}
+
+// TestNotifications sends a test event to every target and shows how each
+// one fared.
+func (h *SettingsHandler) TestNotifications(ctx *echo.Context) error {
+	if h.notifications == nil || len(h.notifications.Targets()) == 0 {
+		return ctx.Redirect(http.StatusSeeOther, "/settings")
+	}
+	data := h.data(ctx)
+	data["NotifyResults"] = h.notifications.SendTest(ctx.Request().Context())
+	return ctx.Render(http.StatusOK, "settings.html", data)
 }
 
 func (h *SettingsHandler) Update(ctx *echo.Context) error {
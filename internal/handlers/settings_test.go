@@ -1,14 +1,45 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"twintail/internal/services"
+
 	"github.com/labstack/echo/v5"
 )
 
+type namedNotifier string
+
+func (n namedNotifier) Name() string {
+	return string(n)
+}
+
+func (n namedNotifier) Notify(ctx context.Context, e services.Event) error {
+	return nil
+}
+
+type mockNotifications struct {
+	targets []services.Target
+	tested  int
+}
+
+func (m *mockNotifications) Targets() []services.Target {
+	return m.targets
+}
+
+func (m *mockNotifications) SendTest(ctx context.Context) []services.Delivery {
+	m.tested++
+	return []services.Delivery{
+		{Target: m.targets[0], Attempts: 1},
+		{Target: m.targets[1], Attempts: 1, Err: errors.New("connection refused")},
+	}
+}
+
 func TestSettingsHandler_Show(t *testing.T) {
 	handler := NewSettingsHandler()
 
@@ -187,3 +218,73 @@ func TestSettingsHandler_Update_MissingField(t *testing.T) {
 		t.Errorf("expected status 303, got %d", rec.Code)
 	}
 }
+
+func TestSettingsHandler_Show_ListsNotificationTargets(t *testing.T) {
+	handler := NewSettingsHandler()
+	handler.SetNotifications(&mockNotifications{targets: []services.Target{{Notifier: namedNotifier("webhook")}}})
+
+	e := echo.New()
+	renderer := &recordingRenderer{}
+	e.Renderer = renderer
+	req := httptest.NewRequest(http.MethodGet, "/settings", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("lang", "en")
+
+	if err := handler.Show(c); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	targets, _ := renderer.data["NotifyTargets"].([]services.Target)
+	if len(targets) != 1 {
+		t.Errorf("expected 1 notification target, got %v", renderer.data["NotifyTargets"])
+	}
+}
+
+func TestSettingsHandler_TestNotifications(t *testing.T) {
+	notifications := &mockNotifications{targets: []services.Target{
+		{Notifier: namedNotifier("webhook")},
+		{Notifier: namedNotifier("smtp")},
+	}}
+	handler := NewSettingsHandler()
+	handler.SetNotifications(notifications)
+
+	e := echo.New()
+	renderer := &recordingRenderer{}
+	e.Renderer = renderer
+	req := httptest.NewRequest(http.MethodPost, "/settings/notifications/test", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("lang", "en")
+
+	if err := handler.TestNotifications(c); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if notifications.tested != 1 {
+		t.Errorf("expected one test send, got %d", notifications.tested)
+	}
+	if renderer.name != "settings.html" {
+		t.Errorf("expected settings.html, got %s", renderer.name)
+	}
+	results, _ := renderer.data["NotifyResults"].([]services.Delivery)
+	if len(results) != 2 || results[1].Err == nil {
+		t.Errorf("expected per-target results, got %v", renderer.data["NotifyResults"])
+	}
+}
+
+func TestSettingsHandler_TestNotifications_NotConfigured(t *testing.T) {
+	handler := NewSettingsHandler()
+
+	e := echo.New()
+	e.Renderer = &mockRenderer{}
+	req := httptest.NewRequest(http.MethodPost, "/settings/notifications/test", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("lang", "en")
+
+	if err := handler.TestNotifications(c); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if rec.Code != http.StatusSeeOther {
+		t.Errorf("expected status 303, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import "twintail/internal/services"
+
+// Shared is what every handler that changes the serve config consults: the
+// services that serve twintail itself and the records kept next to the
+// serve config. The Container hands one Shared to all of its handlers, so a
+// name protected or a store set up once applies to every handler.
+type Shared struct {
+	protected map[string]bool
+	records   services.Stores
+}
+
+func NewShared() *Shared {
+	return &Shared{protected: make(map[string]bool)}
+}
+
+// Protect keeps name from being changed, since it serves twintail itself.
+func (s *Shared) Protect(name string) {
+	s.protected[name] = true
+}
+
+func (s *Shared) Protected(name string) bool {
+	return s.protected[name]
+}
+
+func (s *Shared) SetExpiryStore(store *services.ExpiryStore) {
+	s.records.Expiries = store
+}
+
+func (s *Shared) SetScheduleStore(store *services.ScheduleStore) {
+	s.records.Schedules = store
+}
+
+func (s *Shared) SetDrainStore(store *services.DrainStore) {
+	s.records.Drains = store
+}
+
+func (s *Shared) SetTrafficStore(store *services.TrafficStore) {
+	s.records.Traffic = store
+}
+
+// stores returns the records kept next to the serve config.
+func (s *Shared) stores() services.Stores {
+	return s.records
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"twintail/internal/services"
+)
+
+type PortScanner interface {
+	ListeningPorts() ([]services.ListeningPort, error)
+}
+
+func destinationSuggestions(scanner PortScanner, svcs []services.ServiceView) []services.ListeningPort {
+	if scanner == nil {
+		return nil
+	}
+	ports, err := scanner.ListeningPorts()
+	if err != nil {
+		return nil
+	}
+	return services.MarkInUse(ports, svcs)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"twintail/internal/requests"
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+type trafficView struct {
+	services.Meter
+	Chart   []services.TrafficBar
+	Status  []services.TrafficCount
+	Clients []services.TrafficCount
+}
+
+// topClients is how many clients the traffic card of an endpoint lists.
+const topClients = 5
+
+func trafficViews(meters []services.Meter, now time.Time) []trafficView {
+	views := make([]trafficView, len(meters))
+	for i, m := range meters {
+		views[i] = trafficView{
+			Meter:   m,
+			Chart:   m.Stats.Chart(now),
+			Status:  m.Stats.StatusCounts(),
+			Clients: m.Stats.TopClients(topClients),
+		}
+	}
+	return views
+}
+
+// TrafficHandler puts the measuring proxy in front of an endpoint and
+// takes it away again.
+type TrafficHandler struct {
+	*Shared
+	tailscale services.TrafficExecutor
+}
+
+func NewTrafficHandler(tailscale services.TrafficExecutor, shared *Shared) *TrafficHandler {
+	return &TrafficHandler{
+		tailscale: tailscale,
+		Shared:    shared,
+	}
+}
+
+func (h *TrafficHandler) Start(ctx *echo.Context) error {
+	name, err := validateServiceNameParam(ctx)
+	if err != nil {
+		return err
+	}
+	if h.protected[name] {
+		return ctx.String(http.StatusForbidden, "Service is used to serve twintail and cannot be measured")
+	}
+	if h.records.Traffic == nil {
+		return ctx.String(http.StatusNotFound, "Traffic statistics are not available")
+	}
+	var req requests.MeasureEndpointRequest
+	if err := req.FromContext(ctx); err != nil {
+		return ctx.String(http.StatusBadRequest, "Invalid endpoint: "+err.Error())
+	}
+	svc, err := h.tailscale.GetServiceByName(name)
+	if err != nil {
+		return renderFailure(ctx, err)
+	}
+	if svc == nil {
+		return ctx.String(http.StatusNotFound, "Service not found")
+	}
+	want := req.ToEntry()
+	for _, port := range svc.Ports {
+		if port.Key() != want.Key() || port.Destination != want.Destination {
+			continue
+		}
+		_, err := h.records.Traffic.Start(h.tailscale, name, port, time.Now())
+		switch {
+		case errors.Is(err, services.ErrNotMeasurable):
+			return ctx.String(http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrAlreadyMeasured):
+			return ctx.String(http.StatusConflict, err.Error())
+		case err != nil:
+			return renderFailure(ctx, err)
+		}
+		return ctx.Redirect(http.StatusSeeOther, "/services/"+name)
+	}
+	return ctx.String(http.StatusNotFound, "Endpoint not found")
+}
+
+func (h *TrafficHandler) Stop(ctx *echo.Context) error {
+	name, err := validateServiceNameParam(ctx)
+	if err != nil {
+		return err
+	}
+	if h.records.Traffic == nil {
+		return ctx.String(http.StatusNotFound, "Traffic statistics are not available")
+	}
+	var req requests.MeasureEndpointRequest
+	if err := req.FromContext(ctx); err != nil {
+		return ctx.String(http.StatusBadRequest, "Invalid endpoint: "+err.Error())
+	}
+	err = h.records.Traffic.Stop(h.tailscale, name, req.ToEntry().Key())
+	if errors.Is(err, services.ErrNotMeasured) {
+		return ctx.String(http.StatusNotFound, "Endpoint is not measured")
+	}
+	if err != nil {
+		return renderFailure(ctx, err)
+	}
+	return ctx.Redirect(http.StatusSeeOther, "/services/"+name)
+}
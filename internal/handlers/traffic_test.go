@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"twintail/internal/services"
+)
+
+const trafficForm = "protocol=https&expose_port=443&path=/&destination=http://localhost:3000"
+
+func newTrafficTest(t *testing.T) (*TrafficHandler, *mockServeService, *services.TrafficStore) {
+	t.Helper()
+	svc := newMockServeService(&services.ServiceDetailView{Name: "web", Ports: []services.PortEntry{{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3000"}}})
+	store, err := services.NewTrafficStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewTrafficHandler(svc, NewShared())
+	h.SetTrafficStore(store)
+	return h, svc, store
+}
+
+func TestTrafficHandler_StartAndStop(t *testing.T) {
+	h, svc, store := newTrafficTest(t)
+
+	rec, _ := callForm(t, h.Start, http.MethodPost, "/services/:name/endpoints/measure", "/services/web/endpoints/measure", trafficForm)
+
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected a redirect, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(svc.updated) != 1 || !strings.HasPrefix(svc.updated[0].NewDestination, "http://127.0.0.1:") {
+		t.Fatalf("expected the endpoint to point at the proxy, got %+v", svc.updated)
+	}
+
+	proxied := services.PortEntry{Protocol: "https", ExposePort: "443", Path: "/", Destination: svc.updated[0].NewDestination}
+	show := NewServiceHandler(&mockTailscaleService{serviceDetail: &services.ServiceDetailView{Name: "web", Ports: []services.PortEntry{proxied}}}, NewShared())
+	show.SetTrafficStore(store)
+	_, renderer := callForm(t, show.Show, http.MethodGet, "/services/:name", "/services/web", "")
+	ports := renderer.data["Ports"].([]portView)
+	if ports[0].Meter == nil || ports[0].Meter.Endpoint.Destination != "http://localhost:3000" || len(renderer.data["Traffic"].([]trafficView)) != 1 {
+		t.Fatalf("expected the page to show the measured endpoint, got %+v", renderer.data)
+	}
+
+	svc.details["web"].Ports = []services.PortEntry{proxied}
+	rec, _ = callForm(t, h.Stop, http.MethodPost, "/services/:name/endpoints/measure/stop", "/services/web/endpoints/measure/stop", trafficForm)
+
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected a redirect, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(svc.updated) != 2 || svc.updated[1].NewDestination != "http://localhost:3000" {
+		t.Errorf("expected the endpoint to point at its destination again, got %+v", svc.updated)
+	}
+}
+
+func TestTrafficHandler_UnknownEndpoint(t *testing.T) {
+	h, svc, _ := newTrafficTest(t)
+
+	rec, _ := callForm(t, h.Start, http.MethodPost, "/services/:name/endpoints/measure", "/services/web/endpoints/measure",
+		"protocol=https&expose_port=443&path=/&destination=http://localhost:4000")
+
+	if rec.Code != http.StatusNotFound || len(svc.updated) != 0 {
+		t.Errorf("expected status 404 and no change, got %d %+v", rec.Code, svc.updated)
+	}
+}
+
+func TestTrafficHandler_Protected(t *testing.T) {
+	h, svc, _ := newTrafficTest(t)
+	h.Protect("web")
+
+	rec, _ := callForm(t, h.Start, http.MethodPost, "/services/:name/endpoints/measure", "/services/web/endpoints/measure", trafficForm)
+
+	if rec.Code != http.StatusForbidden || len(svc.updated) != 0 {
+		t.Errorf("expected status 403 and no change, got %d %+v", rec.Code, svc.updated)
+	}
+}
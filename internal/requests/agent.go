@@ -0,0 +1,66 @@
+package requests
+
+import (
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+// AgentEndpointRequest is the JSON body of the agent API calls that take
+// services.EndpointParams or services.AdvertiseServiceParams.
+type AgentEndpointRequest struct {
+	ServiceName string `json:"ServiceName" validate:"required,excludesall=; \n\r\x60\x00"`
+	Protocol    string `json:"Protocol" validate:"required,oneof=https http tcp+tls tcp"`
+	ExposePort  string `json:"ExposePort" validate:"required,numeric"`
+	Path        string `json:"Path" validate:"omitempty,startswith=/,excludesall=; \n\r\x60\x00"`
+	Destination string `json:"Destination" validate:"required,excludesall=; \n\r\x60\x00"`
+}
+
+func (r *AgentEndpointRequest) FromContext(ctx *echo.Context) error {
+	if err := ctx.Bind(r); err != nil {
+		return err
+	}
+	return ctx.Validate(r)
+}
+
+func (r *AgentEndpointRequest) ToParams() services.EndpointParams {
+	return services.EndpointParams{
+		ServiceName: r.ServiceName,
+		Protocol:    r.Protocol,
+		ExposePort:  r.ExposePort,
+		Path:        r.Path,
+		Destination: r.Destination,
+	}
+}
+
+func (r *AgentEndpointRequest) ToAdvertiseParams() services.AdvertiseServiceParams {
+	return services.AdvertiseServiceParams(r.ToParams())
+}
+
+// AgentUpdateEndpointRequest is the JSON body of services.UpdateEndpointParams.
+type AgentUpdateEndpointRequest struct {
+	ServiceName    string `json:"ServiceName" validate:"required,excludesall=; \n\r\x60\x00"`
+	Protocol       string `json:"Protocol" validate:"required,oneof=https http tcp+tls tcp"`
+	ExposePort     string `json:"ExposePort" validate:"required,numeric"`
+	Path           string `json:"Path" validate:"omitempty,startswith=/,excludesall=; \n\r\x60\x00"`
+	OldDestination string `json:"OldDestination" validate:"required,excludesall=; \n\r\x60\x00"`
+	NewDestination string `json:"NewDestination" validate:"required,excludesall=; \n\r\x60\x00"`
+}
+
+func (r *AgentUpdateEndpointRequest) FromContext(ctx *echo.Context) error {
+	if err := ctx.Bind(r); err != nil {
+		return err
+	}
+	return ctx.Validate(r)
+}
+
+func (r *AgentUpdateEndpointRequest) ToParams() services.UpdateEndpointParams {
+	return services.UpdateEndpointParams{
+		ServiceName:    r.ServiceName,
+		Protocol:       r.Protocol,
+		ExposePort:     r.ExposePort,
+		Path:           r.Path,
+		OldDestination: r.OldDestination,
+		NewDestination: r.NewDestination,
+	}
+}
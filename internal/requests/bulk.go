@@ -0,0 +1,62 @@
+package requests
+
+import (
+	"fmt"
+	"strings"
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+// BulkRequest selects either whole services (from the service list) or
+// endpoints of a single service, given as "protocol:port:path" keys.
+type BulkRequest struct {
+	Action    string   `form:"action" validate:"required,oneof=delete repoint clear"`
+	Services  []string `form:"services" validate:"dive,required,excludesall=; \n\r\x60\x00"`
+	Service   string   `form:"service" validate:"excludesall=; \n\r\x60\x00"`
+	Endpoints []string `form:"endpoints" validate:"dive,required,excludesall=; \n\r\x60\x00"`
+	From      string   `form:"from" validate:"required_if=Action repoint,excludesall=; \n\r\x60\x00"`
+	To        string   `form:"to" validate:"required_if=Action repoint,excludesall=; \n\r\x60\x00"`
+	// Signature identifies the plan shown on the confirmation page; storing
+	// refuses a plan that no longer matches it.
+	Signature string `form:"signature" validate:"omitempty,hexadecimal"`
+}
+
+func (r *BulkRequest) FromContext(ctx *echo.Context) error {
+	if err := ctx.Bind(r); err != nil {
+		return err
+	}
+	if err := ctx.Validate(r); err != nil {
+		return err
+	}
+	for _, name := range r.Services {
+		if err := ValidateServiceName(name); err != nil {
+			return err
+		}
+	}
+	if len(r.Endpoints) > 0 {
+		if err := ValidateServiceName(r.Service); err != nil {
+			return err
+		}
+	}
+	switch {
+	case len(r.Services) == 0 && len(r.Endpoints) == 0:
+		return fmt.Errorf("nothing selected")
+	case len(r.Services) > 0 && len(r.Endpoints) > 0:
+		return fmt.Errorf("select either services or endpoints, not both")
+	case r.Action == services.BulkClear && len(r.Services) == 0:
+		return fmt.Errorf("clear applies to whole services")
+	case r.Action == services.BulkDelete && len(r.Endpoints) == 0:
+		return fmt.Errorf("delete applies to endpoints")
+	}
+	return nil
+}
+
+// ParseEndpointKey is the inverse of services.PortEntry.Key.
+func ParseEndpointKey(key string) (services.PortEntry, error) {
+	parts := strings.SplitN(key, ":", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+		return services.PortEntry{}, fmt.Errorf("invalid endpoint %q", key)
+	}
+	return services.PortEntry{Protocol: parts[0], ExposePort: parts[1], Path: parts[2]}, nil
+}
@@ -0,0 +1,71 @@
+package requests
+
+import (
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+func TestBulkRequest_Validation(t *testing.T) {
+	v := validator.New()
+
+	tests := []struct {
+		name    string
+		req     BulkRequest
+		wantErr bool
+	}{
+		{
+			name:    "clear services",
+			req:     BulkRequest{Action: "clear", Services: []string{"app1", "app2"}},
+			wantErr: false,
+		},
+		{
+			name:    "repoint endpoints",
+			req:     BulkRequest{Action: "repoint", Service: "app1", Endpoints: []string{"https:443:"}, From: "localhost:3000", To: "localhost:3001"},
+			wantErr: false,
+		},
+		{
+			name:    "repoint without replacement",
+			req:     BulkRequest{Action: "repoint", Services: []string{"app1"}, From: "localhost:3000"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown action",
+			req:     BulkRequest{Action: "restart", Services: []string{"app1"}},
+			wantErr: true,
+		},
+		{
+			name:    "destination with semicolon",
+			req:     BulkRequest{Action: "repoint", Services: []string{"app1"}, From: "a", To: "b;rm"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Struct(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error=%v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestParseEndpointKey(t *testing.T) {
+	port, err := ParseEndpointKey("https:443:/app:v2")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if port.Protocol != "https" || port.ExposePort != "443" || port.Path != "/app:v2" {
+		t.Errorf("unexpected port: %+v", port)
+	}
+	if port.Key() != "https:443:/app:v2" {
+		t.Errorf("expected key to round-trip, got %q", port.Key())
+	}
+
+	for _, key := range []string{"", "https", "https:443", ":443:/"} {
+		if _, err := ParseEndpointKey(key); err == nil {
+			t.Errorf("expected %q to be rejected", key)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package requests
+
+import (
+	"fmt"
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+type DuplicateServiceRequest struct {
+	Target       string   `form:"target" validate:"required,excludesall=; \n\r\x60\x00"`
+	From         []string `form:"from" validate:"dive,excludesall=; \n\r\x60\x00"`
+	To           []string `form:"to" validate:"dive,excludesall=; \n\r\x60\x00"`
+	ConfirmQuota bool     `form:"confirm_quota"`
+}
+
+func (r *DuplicateServiceRequest) FromContext(ctx *echo.Context) error {
+	if err := ctx.Bind(r); err != nil {
+		return err
+	}
+	if err := ctx.Validate(r); err != nil {
+		return err
+	}
+	if err := ValidateServiceName(r.Target); err != nil {
+		return err
+	}
+	if len(r.From) != len(r.To) {
+		return fmt.Errorf("every rewrite needs both a from and a to value")
+	}
+	return nil
+}
+
+// Rewrites pairs up the from and to fields, skipping rows left blank.
+func (r *DuplicateServiceRequest) Rewrites() []services.Rewrite {
+	var rewrites []services.Rewrite
+	for i := range r.From {
+		if r.From[i] == "" {
+			continue
+		}
+		rewrites = append(rewrites, services.Rewrite{From: r.From[i], To: r.To[i]})
+	}
+	return rewrites
+}
+
+// Rows returns every from/to pair for redisplaying the form, including
+// blank ones.
+func (r DuplicateServiceRequest) Rows() []services.Rewrite {
+	rows := make([]services.Rewrite, max(len(r.From), len(r.To)))
+	for i := range rows {
+		if i < len(r.From) {
+			rows[i].From = r.From[i]
+		}
+		if i < len(r.To) {
+			rows[i].To = r.To[i]
+		}
+	}
+	return rows
+}
+
+func (r *DuplicateServiceRequest) Default(source string) DuplicateServiceRequest {
+	return DuplicateServiceRequest{
+		Target: source + "-copy",
+		From:   []string{"", ""},
+		To:     []string{"", ""},
+	}
+}
+
+type RollbackDuplicateRequest struct {
+	Target string `form:"target" validate:"required,excludesall=; \n\r\x60\x00"`
+}
+
+func (r *RollbackDuplicateRequest) FromContext(ctx *echo.Context) error {
+	if err := ctx.Bind(r); err != nil {
+		return err
+	}
+	if err := ctx.Validate(r); err != nil {
+		return err
+	}
+	return ValidateServiceName(r.Target)
+}
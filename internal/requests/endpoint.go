@@ -9,7 +9,9 @@ import (
 type StoreEndpointRequest struct {
 	Protocol    string `form:"protocol" validate:"required,oneof=https http tcp+tls tcp"`
 	ExposePort  string `form:"expose_port" validate:"required,numeric"`
+	Path        string `form:"path" validate:"omitempty,startswith=/,excludesall=; \n\r\x60\x00"`
 	Destination string `form:"destination" validate:"required,excludesall=; \n\r\x60\x00"`
+	ExpireIn    string `form:"expire_in" validate:"omitempty,oneof=30m 1h 2h 4h 8h 24h"`
 }
 
 func (r *StoreEndpointRequest) FromContext(ctx *echo.Context) error {
@@ -24,6 +26,7 @@ func (r *StoreEndpointRequest) ToParams(serviceName string) services.EndpointPar
 		ServiceName: serviceName,
 		Protocol:    r.Protocol,
 		ExposePort:  r.ExposePort,
+		Path:        r.Path,
 		Destination: r.Destination,
 	}
 }
@@ -38,6 +41,7 @@ func (r *StoreEndpointRequest) Default() StoreEndpointRequest {
 type DestroyEndpointRequest struct {
 	Protocol    string `form:"protocol" validate:"required,oneof=https http tcp+tls tcp"`
 	ExposePort  string `form:"expose_port" validate:"required,numeric"`
+	Path        string `form:"path" validate:"omitempty,startswith=/,excludesall=; \n\r\x60\x00"`
 	Destination string `form:"destination" validate:"required,excludesall=; \n\r\x60\x00"`
 }
 
@@ -53,6 +57,7 @@ func (r *DestroyEndpointRequest) ToParams(serviceName string) services.EndpointP
 		ServiceName: serviceName,
 		Protocol:    r.Protocol,
 		ExposePort:  r.ExposePort,
+		Path:        r.Path,
 		Destination: r.Destination,
 	}
 }
@@ -60,6 +65,7 @@ func (r *DestroyEndpointRequest) ToParams(serviceName string) services.EndpointP
 type UpdateEndpointRequest struct {
 	Protocol       string `form:"protocol" validate:"required,oneof=https http tcp+tls tcp"`
 	ExposePort     string `form:"expose_port" validate:"required,numeric"`
+	Path           string `form:"path" validate:"omitempty,startswith=/,excludesall=; \n\r\x60\x00"`
 	OldDestination string `form:"old_destination" validate:"required,excludesall=; \n\r\x60\x00"`
 	NewDestination string `form:"new_destination" validate:"required,excludesall=; \n\r\x60\x00"`
 }
@@ -76,7 +82,54 @@ func (r *UpdateEndpointRequest) ToParams(serviceName string) services.UpdateEndp
 		ServiceName:    serviceName,
 		Protocol:       r.Protocol,
 		ExposePort:     r.ExposePort,
+		Path:           r.Path,
 		OldDestination: r.OldDestination,
 		NewDestination: r.NewDestination,
 	}
 }
+
+type ProbeEndpointRequest struct {
+	Protocol    string `form:"protocol" validate:"required,oneof=https http tcp+tls tcp"`
+	ExposePort  string `form:"expose_port" validate:"required,numeric"`
+	Path        string `form:"path" validate:"omitempty,startswith=/,excludesall=; \n\r\x60\x00"`
+	Destination string `form:"destination" validate:"required,excludesall=; \n\r\x60\x00"`
+}
+
+func (r *ProbeEndpointRequest) FromContext(ctx *echo.Context) error {
+	if err := ctx.Bind(r); err != nil {
+		return err
+	}
+	return ctx.Validate(r)
+}
+
+func (r *ProbeEndpointRequest) ToEntry() services.PortEntry {
+	return services.PortEntry{
+		Protocol:    r.Protocol,
+		ExposePort:  r.ExposePort,
+		Path:        r.Path,
+		Destination: r.Destination,
+	}
+}
+
+type MeasureEndpointRequest struct {
+	Protocol    string `form:"protocol" validate:"required,oneof=https http tcp+tls tcp"`
+	ExposePort  string `form:"expose_port" validate:"required,numeric"`
+	Path        string `form:"path" validate:"omitempty,startswith=/,excludesall=; \n\r\x60\x00"`
+	Destination string `form:"destination" validate:"required,excludesall=; \n\r\x60\x00"`
+}
+
+func (r *MeasureEndpointRequest) FromContext(ctx *echo.Context) error {
+	if err := ctx.Bind(r); err != nil {
+		return err
+	}
+	return ctx.Validate(r)
+}
+
+func (r *MeasureEndpointRequest) ToEntry() services.PortEntry {
+	return services.PortEntry{
+		Protocol:    r.Protocol,
+		ExposePort:  r.ExposePort,
+		Path:        r.Path,
+		Destination: r.Destination,
+	}
+}
@@ -49,6 +49,26 @@ func TestStoreEndpointRequest_Validation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid path",
+			req: StoreEndpointRequest{
+				Protocol:    "https",
+				ExposePort:  "443",
+				Path:        "/app1",
+				Destination: "http://localhost:8080",
+			},
+			wantErr: false,
+		},
+		{
+			name: "relative path",
+			req: StoreEndpointRequest{
+				Protocol:    "https",
+				ExposePort:  "443",
+				Path:        "app1",
+				Destination: "http://localhost:8080",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,43 @@
+package requests
+
+import (
+	"time"
+
+	"github.com/labstack/echo/v5"
+)
+
+// ExpireOptions are the choices offered for expire_in and extend_by.
+var ExpireOptions = []string{"30m", "1h", "2h", "4h", "8h", "24h"}
+
+// ParseTTL returns the duration of an expire_in value, or zero when the
+// exposure should not expire.
+func ParseTTL(value string) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0
+	}
+	return d
+}
+
+type ExtendExpiryRequest struct {
+	ID       string `form:"id" validate:"required,hexadecimal"`
+	ExtendBy string `form:"extend_by" validate:"required,oneof=30m 1h 2h 4h 8h 24h"`
+}
+
+func (r *ExtendExpiryRequest) FromContext(ctx *echo.Context) error {
+	if err := ctx.Bind(r); err != nil {
+		return err
+	}
+	return ctx.Validate(r)
+}
+
+type CancelExpiryRequest struct {
+	ID string `form:"id" validate:"required,hexadecimal"`
+}
+
+func (r *CancelExpiryRequest) FromContext(ctx *echo.Context) error {
+	if err := ctx.Bind(r); err != nil {
+		return err
+	}
+	return ctx.Validate(r)
+}
@@ -0,0 +1,36 @@
+package requests
+
+import (
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+// ListServicesRequest holds the search, filter and sort query parameters of
+// the service list, so that a filtered view can be bookmarked.
+type ListServicesRequest struct {
+	Query    string `query:"q" validate:"max=200"`
+	Protocol string `query:"protocol" validate:"omitempty,oneof=https http tcp tcp+tls"`
+	Health   string `query:"health" validate:"omitempty,oneof=up down"`
+	Funnel   string `query:"funnel" validate:"omitempty,oneof=on off"`
+	Sort     string `query:"sort" validate:"omitempty,oneof=name hostname destination endpoints"`
+	Order    string `query:"order" validate:"omitempty,oneof=asc desc"`
+}
+
+func (r *ListServicesRequest) FromContext(ctx *echo.Context) error {
+	if err := echo.BindQueryParams(ctx, r); err != nil {
+		return err
+	}
+	return ctx.Validate(r)
+}
+
+func (r *ListServicesRequest) ToFilter() services.ServiceFilter {
+	return services.ServiceFilter{
+		Query:    r.Query,
+		Protocol: r.Protocol,
+		Health:   r.Health,
+		Funnel:   r.Funnel,
+		Sort:     r.Sort,
+		Desc:     r.Order == "desc",
+	}
+}
@@ -0,0 +1,33 @@
+package requests
+
+import (
+	"fmt"
+
+	"github.com/labstack/echo/v5"
+)
+
+type MergeServicesRequest struct {
+	Target  string   `form:"target" validate:"required,excludesall=; \n\r\x60\x00"`
+	Sources []string `form:"sources" validate:"required,min=1,dive,required,excludesall=; \n\r\x60\x00"`
+}
+
+func (r *MergeServicesRequest) FromContext(ctx *echo.Context) error {
+	if err := ctx.Bind(r); err != nil {
+		return err
+	}
+	if err := ctx.Validate(r); err != nil {
+		return err
+	}
+	if err := ValidateServiceName(r.Target); err != nil {
+		return err
+	}
+	for _, source := range r.Sources {
+		if err := ValidateServiceName(source); err != nil {
+			return err
+		}
+		if source == r.Target {
+			return fmt.Errorf("target service must not be one of the merged services")
+		}
+	}
+	return nil
+}
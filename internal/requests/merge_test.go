@@ -0,0 +1,47 @@
+package requests
+
+import (
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+func TestMergeServicesRequest_Validation(t *testing.T) {
+	v := validator.New()
+
+	tests := []struct {
+		name    string
+		req     MergeServicesRequest
+		wantErr bool
+	}{
+		{
+			name:    "valid request",
+			req:     MergeServicesRequest{Target: "apps", Sources: []string{"app1", "app2"}},
+			wantErr: false,
+		},
+		{
+			name:    "missing target",
+			req:     MergeServicesRequest{Sources: []string{"app1"}},
+			wantErr: true,
+		},
+		{
+			name:    "no sources",
+			req:     MergeServicesRequest{Target: "apps"},
+			wantErr: true,
+		},
+		{
+			name:    "source with semicolon",
+			req:     MergeServicesRequest{Target: "apps", Sources: []string{"app1;rm"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Struct(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
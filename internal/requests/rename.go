@@ -0,0 +1,19 @@
+package requests
+
+import (
+	"github.com/labstack/echo/v5"
+)
+
+type RenameServiceRequest struct {
+	NewName string `form:"new_name" validate:"required,excludesall=; \n\r\x60\x00"`
+}
+
+func (r *RenameServiceRequest) FromContext(ctx *echo.Context) error {
+	if err := ctx.Bind(r); err != nil {
+		return err
+	}
+	if err := ctx.Validate(r); err != nil {
+		return err
+	}
+	return ValidateServiceName(r.NewName)
+}
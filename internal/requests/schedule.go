@@ -0,0 +1,56 @@
+package requests
+
+import (
+	"twintail/internal/services"
+
+	"github.com/labstack/echo/v5"
+)
+
+type StoreScheduleRequest struct {
+	Protocol    string `form:"protocol" validate:"required,oneof=https http tcp+tls tcp"`
+	ExposePort  string `form:"expose_port" validate:"required,numeric"`
+	Path        string `form:"path" validate:"omitempty,startswith=/,excludesall=; \n\r\x60\x00"`
+	Destination string `form:"destination" validate:"required,excludesall=; \n\r\x60\x00"`
+	EnableAt    string `form:"enable_at" validate:"required,printascii"`
+	DisableAt   string `form:"disable_at" validate:"required,printascii"`
+}
+
+func (r *StoreScheduleRequest) FromContext(ctx *echo.Context) error {
+	if err := ctx.Bind(r); err != nil {
+		return err
+	}
+	return ctx.Validate(r)
+}
+
+func (r *StoreScheduleRequest) ToParams(serviceName string) services.EndpointParams {
+	return services.EndpointParams{
+		ServiceName: serviceName,
+		Protocol:    r.Protocol,
+		ExposePort:  r.ExposePort,
+		Path:        r.Path,
+		Destination: r.Destination,
+	}
+}
+
+// Default is an office-hours window: weekdays from 9:00 to 18:00.
+func (r *StoreScheduleRequest) Default() StoreScheduleRequest {
+	return StoreScheduleRequest{
+		Protocol:    r.Protocol,
+		ExposePort:  r.ExposePort,
+		Path:        r.Path,
+		Destination: r.Destination,
+		EnableAt:    "0 9 * * 1-5",
+		DisableAt:   "0 18 * * 1-5",
+	}
+}
+
+type DeleteScheduleRequest struct {
+	ID string `form:"id" validate:"required,hexadecimal"`
+}
+
+func (r *DeleteScheduleRequest) FromContext(ctx *echo.Context) error {
+	if err := ctx.Bind(r); err != nil {
+		return err
+	}
+	return ctx.Validate(r)
+}
@@ -7,10 +7,14 @@ import (
 )
 
 type StoreServiceRequest struct {
-	ServiceName string `form:"service_name" validate:"required,excludesall=; \n\r\x60\x00"`
-	Protocol    string `form:"protocol" validate:"required,oneof=https http tcp+tls tcp"`
-	ExposePort  string `form:"expose_port" validate:"required,numeric"`
-	Destination string `form:"destination" validate:"required,excludesall=; \n\r\x60\x00"`
+	ServiceName  string `form:"service_name" validate:"required,excludesall=; \n\r\x60\x00"`
+	Protocol     string `form:"protocol" validate:"required,oneof=https http tcp+tls tcp"`
+	ExposePort   string `form:"expose_port" validate:"required,numeric"`
+	Path         string `form:"path" validate:"omitempty,startswith=/,excludesall=; \n\r\x60\x00"`
+	Destination  string `form:"destination" validate:"required,excludesall=; \n\r\x60\x00"`
+	Template     string `form:"template" validate:"excludesall=; \n\r\x60\x00"`
+	ExpireIn     string `form:"expire_in" validate:"omitempty,oneof=30m 1h 2h 4h 8h 24h"`
+	ConfirmQuota bool   `form:"confirm_quota"`
 }
 
 func (r *StoreServiceRequest) FromContext(ctx *echo.Context) error {
@@ -25,6 +29,7 @@ func (r *StoreServiceRequest) ToParams() services.AdvertiseServiceParams {
 		ServiceName: r.ServiceName,
 		Protocol:    r.Protocol,
 		ExposePort:  r.ExposePort,
+		Path:        r.Path,
 		Destination: r.Destination,
 	}
 }
@@ -35,3 +40,17 @@ func (r *StoreServiceRequest) Default() StoreServiceRequest {
 		ExposePort: "443",
 	}
 }
+
+// FromTemplate pre-fills the request with the first endpoint of t. The
+// remaining endpoints are added after the service is advertised.
+func (r *StoreServiceRequest) FromTemplate(t services.ServiceTemplate, host string) StoreServiceRequest {
+	first := t.Resolve(host)[0]
+	return StoreServiceRequest{
+		ServiceName: t.ID,
+		Protocol:    first.Protocol,
+		ExposePort:  first.ExposePort,
+		Path:        first.Path,
+		Destination: first.Destination,
+		Template:    t.ID,
+	}
+}
@@ -3,6 +3,8 @@ package requests
 import (
 	"testing"
 
+	"twintail/internal/services"
+
 	"github.com/go-playground/validator/v10"
 )
 
@@ -169,3 +171,20 @@ func TestStoreServiceRequest_ToParams(t *testing.T) {
 		t.Errorf("expected Destination '%s', got '%s'", req.Destination, params.Destination)
 	}
 }
+
+func TestStoreServiceRequest_FromTemplate(t *testing.T) {
+	tmpl := services.ServiceTemplate{ID: "monitoring", Endpoints: []services.TemplateEndpoint{
+		{Protocol: "https", ExposePort: "443", Path: "/grafana", Destination: "http://{host}:3000"},
+		{Protocol: "https", ExposePort: "443", Path: "/prometheus", Destination: "http://{host}:9090"},
+	}}
+
+	var req StoreServiceRequest
+	got := req.FromTemplate(tmpl, "")
+
+	if got.ServiceName != "monitoring" || got.Template != "monitoring" {
+		t.Errorf("expected service name and template 'monitoring', got '%s' and '%s'", got.ServiceName, got.Template)
+	}
+	if got.Path != "/grafana" || got.Destination != "http://localhost:3000" {
+		t.Errorf("expected first endpoint to be pre-filled, got %+v", got)
+	}
+}
@@ -0,0 +1,18 @@
+package requests
+
+import (
+	"github.com/labstack/echo/v5"
+)
+
+// SyncServiceRequest confirms a sync plan by the signature it was shown
+// with.
+type SyncServiceRequest struct {
+	Plan string `form:"plan" validate:"required,hexadecimal"`
+}
+
+func (r *SyncServiceRequest) FromContext(ctx *echo.Context) error {
+	if err := ctx.Bind(r); err != nil {
+		return err
+	}
+	return ctx.Validate(r)
+}
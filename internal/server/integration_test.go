@@ -174,3 +174,40 @@ func TestIntegration_SettingsRouteExists(t *testing.T) {
 		t.Errorf("expected status 200, got %d", rec.Code)
 	}
 }
+
+func TestIntegration_AgentAPI(t *testing.T) {
+	mockSvc := &mockTailscaleService{
+		services:      []services.ServiceView{{Name: "web", Destinations: []string{"http://localhost:3000"}}},
+		serviceDetail: &services.ServiceDetailView{Name: "web"},
+	}
+	e := setupTestServer(mockSvc)
+	container := handlers.NewContainer(mockSvc)
+	RegisterAgentAPI(e, container, "secret")
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	if _, err := services.NewAgentClient(srv.URL, "wrong").GetServeStatus(); err == nil {
+		t.Error("expected an error with a wrong token")
+	}
+
+	client := services.NewAgentClient(srv.URL, "secret")
+	svcs, err := client.GetServeStatus()
+	if err != nil || len(svcs) != 1 || svcs[0].Name != "web" {
+		t.Fatalf("unexpected status %+v, %v", svcs, err)
+	}
+	detail, err := client.GetServiceByName("web")
+	if err != nil || detail == nil || detail.Name != "web" {
+		t.Errorf("unexpected detail %+v, %v", detail, err)
+	}
+	err = client.AddEndpoint(services.EndpointParams{
+		ServiceName: "web", Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3000",
+	})
+	if err != nil {
+		t.Errorf("expected endpoint to be added, got %v", err)
+	}
+
+	mockSvc.serviceDetail = nil
+	if detail, err := client.GetServiceByName("gone"); err != nil || detail != nil {
+		t.Errorf("expected a missing service, got %+v, %v", detail, err)
+	}
+}
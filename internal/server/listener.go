@@ -0,0 +1,291 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type TailnetIPSource interface {
+	GetTailnetIPs() ([]netip.Addr, error)
+}
+
+var tailnetRefreshInterval = 30 * time.Second
+
+// Listen opens one listener per spec and merges them. Specs are "all",
+// "tailnet" or "unix:<path>". Failures to follow tailnet IP changes are
+// reported to logger.
+func Listen(ctx context.Context, specs []string, port string, source TailnetIPSource, logger *slog.Logger) (*MultiListener, error) {
+	ml := NewMultiListener()
+	for _, spec := range specs {
+		switch {
+		case spec == "all":
+			ln, err := net.Listen("tcp", ":"+port)
+			if err != nil {
+				ml.Close()
+				return nil, err
+			}
+			ml.Add(spec, ln)
+		case spec == "tailnet":
+			binder := NewTailnetBinder(ml, port, source)
+			if err := binder.Refresh(); err != nil {
+				ml.Close()
+				return nil, err
+			}
+			go binder.Run(ctx, tailnetRefreshInterval, logger)
+		case strings.HasPrefix(spec, "unix:"):
+			ln, err := ListenUnix(strings.TrimPrefix(spec, "unix:"))
+			if err != nil {
+				ml.Close()
+				return nil, err
+			}
+			ml.Add(spec, ln)
+		default:
+			ml.Close()
+			return nil, fmt.Errorf("unknown listen spec %q", spec)
+		}
+	}
+	return ml, nil
+}
+
+func ListenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o660); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// MultiListener accepts connections from a changing set of listeners.
+type MultiListener struct {
+	conns     chan net.Conn
+	done      chan struct{}
+	closeOnce sync.Once
+	mu        sync.Mutex
+	children  map[string]net.Listener
+}
+
+func NewMultiListener() *MultiListener {
+	return &MultiListener{
+		conns:    make(chan net.Conn),
+		done:     make(chan struct{}),
+		children: make(map[string]net.Listener),
+	}
+}
+
+func (m *MultiListener) Add(key string, ln net.Listener) {
+	m.mu.Lock()
+	if old, ok := m.children[key]; ok {
+		old.Close()
+	}
+	m.children[key] = ln
+	m.mu.Unlock()
+
+	go m.acceptLoop(ln)
+}
+
+func (m *MultiListener) Remove(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ln, ok := m.children[key]; ok {
+		ln.Close()
+		delete(m.children, key)
+	}
+}
+
+func (m *MultiListener) Has(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.children[key]
+	return ok
+}
+
+func (m *MultiListener) Keys() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]string, 0, len(m.children))
+	for key := range m.children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (m *MultiListener) Addrs() []net.Addr {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	addrs := make([]net.Addr, 0, len(m.children))
+	for _, ln := range m.children {
+		addrs = append(addrs, ln.Addr())
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].String() < addrs[j].String()
+	})
+	return addrs
+}
+
+func (m *MultiListener) acceptLoop(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			select {
+			case <-m.done:
+				return
+			case <-time.After(100 * time.Millisecond):
+				continue
+			}
+		}
+		select {
+		case m.conns <- conn:
+		case <-m.done:
+			conn.Close()
+			return
+		}
+	}
+}
+
+func (m *MultiListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-m.conns:
+		return conn, nil
+	case <-m.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (m *MultiListener) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.done)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for key, ln := range m.children {
+			ln.Close()
+			delete(m.children, key)
+		}
+	})
+	return nil
+}
+
+func (m *MultiListener) Addr() net.Addr {
+	if addrs := m.Addrs(); len(addrs) > 0 {
+		return addrs[0]
+	}
+	return &net.TCPAddr{}
+}
+
+// TailnetBinder keeps one listener per tailnet IP of this node.
+type TailnetBinder struct {
+	listener *MultiListener
+	port     string
+	source   TailnetIPSource
+}
+
+func NewTailnetBinder(listener *MultiListener, port string, source TailnetIPSource) *TailnetBinder {
+	return &TailnetBinder{
+		listener: listener,
+		port:     port,
+		source:   source,
+	}
+}
+
+func (b *TailnetBinder) Refresh() error {
+	ips, err := b.source.GetTailnetIPs()
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[string]string, len(ips))
+	for _, ip := range ips {
+		wanted["tailnet:"+ip.String()] = net.JoinHostPort(ip.String(), b.port)
+	}
+
+	for _, key := range b.listener.Keys() {
+		if _, ok := wanted[key]; !ok && strings.HasPrefix(key, "tailnet:") {
+			b.listener.Remove(key)
+		}
+	}
+
+	var errs []error
+	for key, addr := range wanted {
+		if b.listener.Has(key) {
+			continue
+		}
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		b.listener.Add(key, ln)
+	}
+	return errors.Join(errs...)
+}
+
+func (b *TailnetBinder) Run(ctx context.Context, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.Refresh(); err != nil {
+				logger.Error("failed to refresh tailnet listeners", "error", err)
+			}
+		}
+	}
+}
+
+// SelfDestination returns the address tailscaled should proxy to in order
+// to reach this server through one of the given listener addresses.
+func SelfDestination(addrs []net.Addr) (string, error) {
+	var unixPath string
+	var tailnet []netip.AddrPort
+	for _, addr := range addrs {
+		switch a := addr.(type) {
+		case *net.UnixAddr:
+			if unixPath == "" {
+				unixPath = a.Name
+			}
+		case *net.TCPAddr:
+			ap := a.AddrPort()
+			ip := ap.Addr().Unmap()
+			if ip.IsUnspecified() || ip.IsLoopback() {
+				return "http://" + net.JoinHostPort("127.0.0.1", strconv.Itoa(int(ap.Port()))), nil
+			}
+			tailnet = append(tailnet, netip.AddrPortFrom(ip, ap.Port()))
+		}
+	}
+	if unixPath != "" {
+		return "unix:" + unixPath, nil
+	}
+	for _, ap := range tailnet {
+		if ap.Addr().Is4() {
+			return "http://" + ap.String(), nil
+		}
+	}
+	if len(tailnet) > 0 {
+		return "http://" + tailnet[0].String(), nil
+	}
+	return "", errors.New("no listener reachable by tailscaled")
+}
@@ -0,0 +1,303 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v5"
+)
+
+type fakeIPSource struct {
+	mu  sync.Mutex
+	ips []netip.Addr
+	err error
+}
+
+func (f *fakeIPSource) GetTailnetIPs() ([]netip.Addr, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.ips, f.err
+}
+
+func (f *fakeIPSource) set(ips ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ips = nil
+	for _, ip := range ips {
+		f.ips = append(f.ips, netip.MustParseAddr(ip))
+	}
+}
+
+func freePort(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve port: %v", err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	return port
+}
+
+func startTestServer(t *testing.T, ln net.Listener) {
+	t.Helper()
+	e := echo.New()
+	e.GET("/", func(c *echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = Serve(ctx, e, ln)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func get(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("request to %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestListen_Tailnet(t *testing.T) {
+	source := &fakeIPSource{}
+	source.set("127.0.0.1")
+	port := freePort(t)
+
+	ln, err := Listen(context.Background(), []string{"tailnet"}, port, source, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer ln.Close()
+	startTestServer(t, ln)
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	if body := get(t, client, "http://127.0.0.1:"+port+"/"); body != "ok" {
+		t.Errorf("expected 'ok', got '%s'", body)
+	}
+}
+
+func TestListen_TailnetDiscoveryError(t *testing.T) {
+	source := &fakeIPSource{err: errors.New("tailscaled not running")}
+
+	_, err := Listen(context.Background(), []string{"tailnet"}, freePort(t), source, slog.New(slog.DiscardHandler))
+
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestTailnetBinder_RefreshFollowsIPChanges(t *testing.T) {
+	source := &fakeIPSource{}
+	source.set("127.0.0.1")
+	port := freePort(t)
+
+	ml := NewMultiListener()
+	defer ml.Close()
+	binder := NewTailnetBinder(ml, port, source)
+	if err := binder.Refresh(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	startTestServer(t, ml)
+
+	source.set("127.0.0.2")
+	if err := binder.Refresh(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	keys := ml.Keys()
+	if len(keys) != 1 || keys[0] != "tailnet:127.0.0.2" {
+		t.Fatalf("expected only tailnet:127.0.0.2, got %v", keys)
+	}
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	if body := get(t, client, "http://127.0.0.2:"+port+"/"); body != "ok" {
+		t.Errorf("expected 'ok', got '%s'", body)
+	}
+	if _, err := net.DialTimeout("tcp", "127.0.0.1:"+port, time.Second); err == nil {
+		t.Error("expected old address to stop accepting connections")
+	}
+}
+
+type recordingHandler struct {
+	slog.Handler
+	records chan slog.Record
+}
+
+func (h *recordingHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *recordingHandler) Handle(ctx context.Context, r slog.Record) error {
+	select {
+	case h.records <- r:
+	default:
+	}
+	return nil
+}
+
+func TestTailnetBinder_RunLogsRefreshErrors(t *testing.T) {
+	source := &fakeIPSource{err: errors.New("tailscaled not running")}
+	ml := NewMultiListener()
+	defer ml.Close()
+	binder := NewTailnetBinder(ml, freePort(t), source)
+
+	handler := &recordingHandler{Handler: slog.DiscardHandler, records: make(chan slog.Record, 1)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go binder.Run(ctx, time.Millisecond, slog.New(handler))
+
+	select {
+	case r := <-handler.records:
+		if r.Level != slog.LevelError {
+			t.Errorf("expected an error record, got %v", r.Level)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the refresh error to be logged")
+	}
+}
+
+func TestListen_Unix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "twintail.sock")
+
+	ln, err := Listen(context.Background(), []string{"unix:" + path}, "0", nil, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer ln.Close()
+	startTestServer(t, ln)
+
+	client := &http.Client{
+		Timeout: 2 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+	if body := get(t, client, "http://twintail/"); body != "ok" {
+		t.Errorf("expected 'ok', got '%s'", body)
+	}
+}
+
+func TestListenUnix_ReplacesStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "twintail.sock")
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("failed to create stale socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := ListenUnix(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	ln.Close()
+}
+
+func TestListen_UnknownSpec(t *testing.T) {
+	_, err := Listen(context.Background(), []string{"bogus"}, "0", nil, slog.New(slog.DiscardHandler))
+
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestSelfDestination(t *testing.T) {
+	wildcard := &net.TCPAddr{IP: net.IPv6unspecified, Port: 8077}
+	tailnet4 := &net.TCPAddr{IP: net.ParseIP("100.64.0.1"), Port: 8077}
+	tailnet6 := &net.TCPAddr{IP: net.ParseIP("fd7a:115c:a1e0::1"), Port: 8077}
+	socket := &net.UnixAddr{Name: "/run/twintail.sock", Net: "unix"}
+
+	tests := []struct {
+		name  string
+		addrs []net.Addr
+		want  string
+	}{
+		{"all", []net.Addr{tailnet4, wildcard}, "http://127.0.0.1:8077"},
+		{"unix", []net.Addr{tailnet4, socket}, "unix:/run/twintail.sock"},
+		{"tailnet prefers ipv4", []net.Addr{tailnet6, tailnet4}, "http://100.64.0.1:8077"},
+		{"tailnet ipv6 only", []net.Addr{tailnet6}, "http://[fd7a:115c:a1e0::1]:8077"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SelfDestination(tt.addrs)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected '%s', got '%s'", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSelfDestination_NoListeners(t *testing.T) {
+	_, err := SelfDestination(nil)
+
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestServe_DrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	e := echo.New()
+	e.GET("/slow", func(c *echo.Context) error {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		return c.String(http.StatusOK, "done")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() { served <- Serve(ctx, e, ln) }()
+
+	result := make(chan string)
+	go func() {
+		client := &http.Client{Timeout: 2 * time.Second}
+		resp, err := client.Get("http://" + ln.Addr().String() + "/slow")
+		if err != nil {
+			result <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		result <- string(body)
+	}()
+
+	<-started
+	cancel()
+
+	if body := <-result; body != "done" {
+		t.Errorf("expected in-flight request to complete, got '%s'", body)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
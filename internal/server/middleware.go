@@ -1,7 +1,10 @@
 package server
 
 import (
+	"crypto/subtle"
 	"html/template"
+	"net/http"
+	"strings"
 
 	"twintail/internal/services"
 
@@ -45,3 +48,18 @@ func NoCacheMiddleware() echo.MiddlewareFunc {
 		}
 	}
 }
+
+// AgentAuthMiddleware only lets through requests carrying the agent token as
+// a bearer token.
+func AgentAuthMiddleware(token string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c *echo.Context) error {
+			auth := c.Request().Header.Get("Authorization")
+			given, ok := strings.CutPrefix(auth, "Bearer ")
+			if !ok || token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				return c.JSON(http.StatusUnauthorized, services.AgentError{Message: "invalid agent token"})
+			}
+			return next(c)
+		}
+	}
+}
@@ -10,19 +10,64 @@ func RegisterRoutes(e *echo.Echo, h *handlers.Container) {
 	e.GET("/", h.Service.Index)
 	e.GET("/services/new", h.Service.Create)
 	e.POST("/services/new", h.Service.Store)
+	e.GET("/services/merge", h.Merge.Create)
+	e.POST("/services/merge/plan", h.Merge.Plan)
+	e.POST("/services/merge", h.Merge.Store)
+	e.POST("/bulk/plan", h.Bulk.Plan)
+	e.POST("/bulk", h.Bulk.Store)
 	e.GET("/services/:name", h.Service.Show)
 	e.GET("/services/:name/delete", h.Service.Delete)
 	e.POST("/services/:name/delete", h.Service.Destroy)
+	e.GET("/services/:name/duplicate", h.Duplicate.Create)
+	e.POST("/services/:name/duplicate", h.Duplicate.Store)
+	e.POST("/services/:name/duplicate/rollback", h.Duplicate.Rollback)
+	e.GET("/services/:name/rename", h.Rename.Create)
+	e.POST("/services/:name/rename", h.Rename.Store)
 	e.GET("/services/:name/endpoints/new", h.Endpoint.Create)
 	e.POST("/services/:name/endpoints/new", h.Endpoint.Store)
 	e.GET("/services/:name/endpoints/edit", h.Endpoint.Edit)
 	e.POST("/services/:name/endpoints/edit", h.Endpoint.Update)
 	e.GET("/services/:name/endpoints/delete", h.Endpoint.Delete)
 	e.POST("/services/:name/endpoints/delete", h.Endpoint.Destroy)
+	e.POST("/services/:name/endpoints/test", h.Probe.Store)
+	e.POST("/services/:name/endpoints/measure", h.Traffic.Start)
+	e.POST("/services/:name/endpoints/measure/stop", h.Traffic.Stop)
+	e.POST("/services/:name/expiry/extend", h.Expiry.Extend)
+	e.POST("/services/:name/expiry/cancel", h.Expiry.Cancel)
+	e.GET("/services/:name/schedules/new", h.Schedule.Create)
+	e.POST("/services/:name/schedules/new", h.Schedule.Store)
+	e.POST("/services/:name/schedules/delete", h.Schedule.Destroy)
+	e.POST("/services/:name/drain", h.Drain.Drain)
+	e.POST("/services/:name/undrain", h.Drain.Undrain)
+
+	e.GET("/containers", h.Containers.Index)
+
+	e.GET("/nodes", h.Hub.Index)
+	e.GET("/nodes/:node/services/:name", h.Hub.Show)
+	e.POST("/nodes/:node/services/:name/endpoints", h.Hub.AddEndpoint)
+	e.POST("/nodes/:node/services/:name/endpoints/delete", h.Hub.RemoveEndpoint)
+	e.POST("/nodes/:node/services/:name/sync/plan", h.Hub.PlanSync)
+	e.POST("/nodes/:node/services/:name/sync", h.Hub.Sync)
+
+	e.GET("/doctor", h.Doctor.Show)
 
 	e.GET("/settings", h.Settings.Show)
 	e.POST("/settings", h.Settings.Update)
+	e.POST("/settings/notifications/test", h.Settings.TestNotifications)
 
 	// Static files
 	e.StaticFS("/static", GetStaticFS())
 }
+
+// RegisterAgentAPI exposes this node's serve operations to a hub.
+func RegisterAgentAPI(e *echo.Echo, h *handlers.Container, token string) {
+	api := e.Group("/api/v1", AgentAuthMiddleware(token))
+	api.GET("/installed", h.Agent.Installed)
+	api.GET("/services", h.Agent.Index)
+	api.POST("/services", h.Agent.Store)
+	api.GET("/services/:name", h.Agent.Show)
+	api.DELETE("/services/:name", h.Agent.Destroy)
+	api.POST("/endpoints", h.Agent.AddEndpoint)
+	api.POST("/endpoints/delete", h.Agent.RemoveEndpoint)
+	api.PUT("/endpoints", h.Agent.UpdateEndpoint)
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v5"
+)
+
+var shutdownTimeout = 10 * time.Second
+
+func Serve(ctx context.Context, e *echo.Echo, ln net.Listener) error {
+	srv := &http.Server{
+		Handler:      e,
+		ErrorLog:     slog.NewLogLogger(e.Logger.Handler(), slog.LevelError),
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			e.Logger.Error("failed to shut down server within given timeout", "error", err)
+		}
+	}()
+
+	if ml, ok := ln.(*MultiListener); ok {
+		for _, addr := range ml.Addrs() {
+			e.Logger.Info("http server started", "address", addr.String())
+		}
+	} else {
+		e.Logger.Info("http server started", "address", ln.Addr().String())
+	}
+
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	<-shutdownDone
+	return nil
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Error kinds reported by the agent API so that clients can rebuild the
+// errors the handlers already know how to present.
+const (
+	AgentErrorCommand      = "command"
+	AgentErrorNotInstalled = "not_installed"
+	AgentErrorNotFound     = "not_found"
+	AgentErrorUnsupported  = "unsupported_version"
+	AgentErrorDrained      = "drained"
+)
+
+// AgentError is the JSON body of a failed agent API call.
+type AgentError struct {
+	Message string `json:"error"`
+	Kind    string `json:"kind,omitempty"`
+}
+
+var errAgentCommand = errors.New("tailscale command failed on agent")
+
+const agentTimeout = 15 * time.Second
+
+// AgentClient manages the serve config of another twintail running in agent
+// mode. It offers the same operations as TailscaleService.
+type AgentClient struct {
+	BaseURL string
+	Token   string
+	Client  *http.Client
+}
+
+func NewAgentClient(baseURL, token string) *AgentClient {
+	return &AgentClient{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Token:   token,
+		Client:  &http.Client{Timeout: agentTimeout},
+	}
+}
+
+// do calls the agent API, decoding a successful response into out when it
+// is not nil. found is false when the agent reports the service missing.
+func (c *AgentClient) do(method, path string, in, out any) (found bool, err error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return false, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.BaseURL+"/api/v1"+path, body)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		if out == nil || resp.StatusCode == http.StatusNoContent {
+			return true, nil
+		}
+		return true, json.NewDecoder(resp.Body).Decode(out)
+	}
+
+	var agentErr AgentError
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&agentErr); err != nil || agentErr.Message == "" {
+		agentErr.Message = resp.Status
+	}
+	switch {
+	case agentErr.Kind == AgentErrorNotFound:
+		return false, nil
+	case agentErr.Kind == AgentErrorNotInstalled:
+		return false, ErrTailscaleNotInstalled
+	case agentErr.Kind == AgentErrorUnsupported:
+		return false, fmt.Errorf("agent %s: %s: %w", c.BaseURL, agentErr.Message, ErrUnsupportedVersion)
+	case agentErr.Kind == AgentErrorDrained:
+		return false, fmt.Errorf("agent %s: %w", c.BaseURL, ErrServiceDrained)
+	case agentErr.Kind == AgentErrorCommand:
+		return false, &CommandError{
+			Message: agentErr.Message,
+			Err:     errAgentCommand,
+			Reason:  classifyOutput(agentErr.Message),
+		}
+	}
+	return false, fmt.Errorf("agent %s: %s", c.BaseURL, agentErr.Message)
+}
+
+func (c *AgentClient) CheckInstalled() error {
+	_, err := c.do(http.MethodGet, "/installed", nil, nil)
+	return err
+}
+
+func (c *AgentClient) GetServeStatus() ([]ServiceView, error) {
+	var svcs []ServiceView
+	if _, err := c.do(http.MethodGet, "/services", nil, &svcs); err != nil {
+		return nil, err
+	}
+	return svcs, nil
+}
+
+func (c *AgentClient) GetServiceByName(name string) (*ServiceDetailView, error) {
+	var detail ServiceDetailView
+	found, err := c.do(http.MethodGet, "/services/"+url.PathEscape(name), nil, &detail)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &detail, nil
+}
+
+func (c *AgentClient) AdvertiseService(params AdvertiseServiceParams) error {
+	_, err := c.do(http.MethodPost, "/services", params, nil)
+	return err
+}
+
+func (c *AgentClient) ClearService(name string) error {
+	_, err := c.do(http.MethodDelete, "/services/"+url.PathEscape(name), nil, nil)
+	return err
+}
+
+func (c *AgentClient) AddEndpoint(params EndpointParams) error {
+	_, err := c.do(http.MethodPost, "/endpoints", params, nil)
+	return err
+}
+
+func (c *AgentClient) RemoveEndpoint(params EndpointParams) error {
+	_, err := c.do(http.MethodPost, "/endpoints/delete", params, nil)
+	return err
+}
+
+func (c *AgentClient) UpdateEndpoint(params UpdateEndpointParams) error {
+	_, err := c.do(http.MethodPut, "/endpoints", params, nil)
+	return err
+}
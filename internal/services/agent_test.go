@@ -0,0 +1,126 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func agentServer(t *testing.T, handler http.HandlerFunc) *AgentClient {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return NewAgentClient(srv.URL+"/", "tok")
+}
+
+func writeAgentError(w http.ResponseWriter, code int, kind, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(AgentError{Message: message, Kind: kind})
+}
+
+func TestAgentClient_GetServeStatus(t *testing.T) {
+	var auth, path string
+	client := agentServer(t, func(w http.ResponseWriter, r *http.Request) {
+		auth, path = r.Header.Get("Authorization"), r.URL.Path
+		json.NewEncoder(w).Encode([]ServiceView{{Name: "web", Destinations: []string{"http://localhost:3000"}}})
+	})
+
+	svcs, err := client.GetServeStatus()
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if auth != "Bearer tok" || path != "/api/v1/services" {
+		t.Errorf("unexpected request auth=%q path=%q", auth, path)
+	}
+	if len(svcs) != 1 || svcs[0].Name != "web" {
+		t.Errorf("unexpected services %v", svcs)
+	}
+}
+
+func TestAgentClient_GetServiceByNameNotFound(t *testing.T) {
+	client := agentServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeAgentError(w, http.StatusNotFound, AgentErrorNotFound, "service not found")
+	})
+
+	detail, err := client.GetServiceByName("web")
+
+	if err != nil || detail != nil {
+		t.Errorf("expected nil detail and error, got %v, %v", detail, err)
+	}
+}
+
+func TestAgentClient_UnknownRouteIsAnError(t *testing.T) {
+	client := agentServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+
+	if err := client.AddEndpoint(EndpointParams{ServiceName: "web"}); err == nil {
+		t.Error("expected an error for an agent without the API")
+	}
+}
+
+func TestAgentClient_CommandError(t *testing.T) {
+	var got EndpointParams
+	client := agentServer(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		writeAgentError(w, http.StatusBadGateway, AgentErrorCommand, "service limit reached for tailnet")
+	})
+
+	err := client.AddEndpoint(EndpointParams{ServiceName: "web", Protocol: "https", ExposePort: "443", Destination: "3000"})
+
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("expected a CommandError, got %T %v", err, err)
+	}
+	if !errors.Is(err, ErrServiceQuotaExceeded) {
+		t.Error("expected the quota reason to be classified again")
+	}
+	if got.ServiceName != "web" || got.Destination != "3000" {
+		t.Errorf("unexpected request body %+v", got)
+	}
+}
+
+func TestAgentClient_NotInstalled(t *testing.T) {
+	client := agentServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeAgentError(w, http.StatusServiceUnavailable, AgentErrorNotInstalled, "tailscale CLI not installed")
+	})
+
+	if err := client.CheckInstalled(); !IsTailscaleNotInstalledError(err) {
+		t.Errorf("expected ErrTailscaleNotInstalled, got %v", err)
+	}
+}
+
+func TestAgentClient_UnsupportedVersion(t *testing.T) {
+	client := agentServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeAgentError(w, http.StatusServiceUnavailable, AgentErrorUnsupported, "tailscale 1.80.2 has no Tailscale Services")
+	})
+
+	if _, err := client.GetServeStatus(); ClassifyError(err) != ErrorKindUnsupportedVersion {
+		t.Errorf("expected an unsupported version error, got %v", err)
+	}
+}
+
+func TestAgentClient_Drained(t *testing.T) {
+	client := agentServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeAgentError(w, http.StatusConflict, AgentErrorDrained, ErrServiceDrained.Error())
+	})
+
+	if err := client.AddEndpoint(EndpointParams{ServiceName: "web"}); !errors.Is(err, ErrServiceDrained) {
+		t.Errorf("expected ErrServiceDrained, got %v", err)
+	}
+}
+
+func TestAgentClient_Unauthorized(t *testing.T) {
+	client := agentServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeAgentError(w, http.StatusUnauthorized, "", "invalid agent token")
+	})
+
+	_, err := client.GetServeStatus()
+
+	if err == nil || err.Error() != "agent "+client.BaseURL+": invalid agent token" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
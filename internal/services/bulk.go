@@ -0,0 +1,186 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+)
+
+// ErrBulkPlanChanged is returned when the services changed between showing
+// a bulk plan and confirming it.
+var ErrBulkPlanChanged = errors.New("the services changed since the plan was shown, review it again")
+
+const (
+	BulkDelete  = "delete"
+	BulkRepoint = "repoint"
+	BulkClear   = "clear"
+)
+
+type BulkItem struct {
+	Endpoint       EndpointParams
+	NewDestination string
+}
+
+// UpdateParams repoints the endpoint of the item to its new destination.
+func (i BulkItem) UpdateParams() UpdateEndpointParams {
+	return UpdateEndpointParams{
+		ServiceName:    i.Endpoint.ServiceName,
+		Protocol:       i.Endpoint.Protocol,
+		ExposePort:     i.Endpoint.ExposePort,
+		Path:           i.Endpoint.Path,
+		OldDestination: i.Endpoint.Destination,
+		NewDestination: i.NewDestination,
+	}
+}
+
+// ReplaceAddress replaces the address of destination with to when it is
+// exactly from. from is a host:port, a bare port, a host or a whole
+// scheme://host:port; the rest of destination is kept. Matching the whole
+// address leaves localhost:30000 alone when repointing localhost:3000.
+func ReplaceAddress(destination, from, to string) string {
+	if from == "" {
+		return destination
+	}
+	scheme, rest := "", destination
+	if i := strings.Index(destination, "://"); i >= 0 {
+		scheme, rest = destination[:i+3], destination[i+3:]
+	}
+	authority, suffix := rest, ""
+	if i := strings.Index(rest, "/"); i >= 0 {
+		authority, suffix = rest[:i], rest[i:]
+	}
+
+	switch {
+	case strings.Contains(from, "://"):
+		if scheme+authority == from {
+			return to + suffix
+		}
+	case authority == from:
+		return scheme + to + suffix
+	default:
+		host, port, err := net.SplitHostPort(authority)
+		if err != nil {
+			break
+		}
+		switch {
+		case port == from && isPort(to):
+			return scheme + net.JoinHostPort(host, to) + suffix
+		case host == from && !strings.Contains(to, ":"):
+			return scheme + net.JoinHostPort(to, port) + suffix
+		case port == from, host == from:
+			return scheme + to + suffix
+		}
+	}
+	return destination
+}
+
+func isPort(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+type BulkPlan struct {
+	Action    string
+	Items     []BulkItem
+	Unchanged []EndpointParams
+	Services  []string
+}
+
+func (p BulkPlan) Valid() bool {
+	if p.Action == BulkClear {
+		return len(p.Services) > 0
+	}
+	return len(p.Items) > 0
+}
+
+// Signature identifies the changes of the plan, so a confirmation can be
+// matched against the plan the user reviewed.
+func (p BulkPlan) Signature() string {
+	entries := []string{p.Action}
+	for _, item := range p.Items {
+		e := item.Endpoint
+		entries = append(entries, e.ServiceName+" "+e.Protocol+":"+e.ExposePort+":"+e.Path+"="+e.Destination+">"+item.NewDestination)
+	}
+	entries = append(entries, p.Services...)
+	sum := sha256.Sum256([]byte(strings.Join(entries, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// PlanBulk works out what action does to the selected endpoints. Repointing
+// replaces the address from with to in each destination; endpoints whose
+// destination has a different address are left unchanged. Clearing lists every endpoint
+// of the affected services so they can be reviewed before confirming.
+func PlanBulk(action string, endpoints []EndpointParams, services []string, from, to string) BulkPlan {
+	plan := BulkPlan{Action: action}
+	for _, params := range endpoints {
+		switch action {
+		case BulkRepoint:
+			dest := ReplaceAddress(params.Destination, from, to)
+			if from == "" || dest == params.Destination {
+				plan.Unchanged = append(plan.Unchanged, params)
+				continue
+			}
+			plan.Items = append(plan.Items, BulkItem{Endpoint: params, NewDestination: dest})
+		default:
+			plan.Items = append(plan.Items, BulkItem{Endpoint: params})
+		}
+	}
+	if action == BulkClear {
+		plan.Services = services
+	}
+	return plan
+}
+
+type BulkExecutor interface {
+	RemoveEndpoint(params EndpointParams) error
+	UpdateEndpoint(params UpdateEndpointParams) error
+	ClearService(name string) error
+}
+
+type BulkResult struct {
+	ServiceName string
+	Item        BulkItem
+	Err         error
+}
+
+// ExecuteBulk applies the plan item by item. A failure does not stop the
+// remaining items; every outcome is reported.
+func ExecuteBulk(executor BulkExecutor, plan BulkPlan) []BulkResult {
+	var results []BulkResult
+	if plan.Action == BulkClear {
+		for _, name := range plan.Services {
+			results = append(results, BulkResult{ServiceName: name, Err: executor.ClearService(name)})
+		}
+		return results
+	}
+
+	for _, item := range plan.Items {
+		var err error
+		if plan.Action == BulkRepoint {
+			err = executor.UpdateEndpoint(item.UpdateParams())
+		} else {
+			err = executor.RemoveEndpoint(item.Endpoint)
+		}
+		results = append(results, BulkResult{ServiceName: item.Endpoint.ServiceName, Item: item, Err: err})
+	}
+	return results
+}
+
+func BulkFailed(results []BulkResult) int {
+	n := 0
+	for _, r := range results {
+		if r.Err != nil {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+type mockBulkExecutor struct {
+	removed []EndpointParams
+	updated []UpdateEndpointParams
+	cleared []string
+	failOn  string
+}
+
+func (m *mockBulkExecutor) RemoveEndpoint(params EndpointParams) error {
+	if params.ServiceName == m.failOn {
+		return errors.New("remove failed")
+	}
+	m.removed = append(m.removed, params)
+	return nil
+}
+
+func (m *mockBulkExecutor) UpdateEndpoint(params UpdateEndpointParams) error {
+	if params.ServiceName == m.failOn {
+		return errors.New("update failed")
+	}
+	m.updated = append(m.updated, params)
+	return nil
+}
+
+func (m *mockBulkExecutor) ClearService(name string) error {
+	if name == m.failOn {
+		return errors.New("clear failed")
+	}
+	m.cleared = append(m.cleared, name)
+	return nil
+}
+
+var bulkEndpoints = []EndpointParams{
+	{ServiceName: "web", Protocol: "https", ExposePort: "443", Destination: "http://localhost:3000"},
+	{ServiceName: "web", Protocol: "https", ExposePort: "8443", Destination: "http://localhost:4000"},
+	{ServiceName: "api", Protocol: "http", ExposePort: "80", Path: "/v1", Destination: "http://localhost:3000/v1"},
+}
+
+func TestPlanBulk_Repoint(t *testing.T) {
+	plan := PlanBulk(BulkRepoint, bulkEndpoints, nil, "localhost:3000", "localhost:3001")
+
+	if len(plan.Items) != 2 {
+		t.Fatalf("expected 2 items, got %+v", plan.Items)
+	}
+	if plan.Items[0].NewDestination != "http://localhost:3001" || plan.Items[1].NewDestination != "http://localhost:3001/v1" {
+		t.Errorf("unexpected destinations: %+v", plan.Items)
+	}
+	if len(plan.Unchanged) != 1 || plan.Unchanged[0].ExposePort != "8443" {
+		t.Errorf("expected the 8443 endpoint to be unchanged, got %+v", plan.Unchanged)
+	}
+	if !plan.Valid() {
+		t.Error("expected plan to be valid")
+	}
+}
+
+func TestPlanBulk_RepointNothingMatches(t *testing.T) {
+	plan := PlanBulk(BulkRepoint, bulkEndpoints, nil, "localhost:9999", "localhost:1")
+	if plan.Valid() {
+		t.Errorf("expected empty plan to be invalid, got %+v", plan)
+	}
+}
+
+func TestPlanBulk_RepointLeavesPrefixSharingPorts(t *testing.T) {
+	endpoints := []EndpointParams{
+		{ServiceName: "web", Protocol: "https", ExposePort: "443", Destination: "http://localhost:30000"},
+		{ServiceName: "web", Protocol: "https", ExposePort: "8443", Destination: "http://localhost:3000x"},
+		{ServiceName: "web", Protocol: "https", ExposePort: "9443", Destination: "http://localhost:3000"},
+	}
+
+	plan := PlanBulk(BulkRepoint, endpoints, nil, "localhost:3000", "localhost:3001")
+
+	if len(plan.Items) != 1 || plan.Items[0].Endpoint.ExposePort != "9443" {
+		t.Errorf("expected only the exact address to be repointed, got %+v", plan.Items)
+	}
+	if len(plan.Unchanged) != 2 {
+		t.Errorf("expected the prefix-sharing ports to be unchanged, got %+v", plan.Unchanged)
+	}
+}
+
+func TestReplaceAddress(t *testing.T) {
+	tests := []struct {
+		destination, from, to, want string
+	}{
+		{"http://localhost:3000/api", "localhost:3000", "localhost:3001", "http://localhost:3001/api"},
+		{"http://localhost:3000", "3000", "3001", "http://localhost:3001"},
+		{"http://localhost:3000", "localhost", "staging", "http://staging:3000"},
+		{"http://localhost:3000", "http://localhost:3000", "https+insecure://localhost:3443", "https+insecure://localhost:3443"},
+		{"3000", "3000", "3001", "3001"},
+		{"tcp://localhost:5432", "localhost:5432", "db:5432", "tcp://db:5432"},
+		{"http://localhost:30000", "3000", "3001", "http://localhost:30000"},
+		{"http://localhost:3000/3000", "3000", "3001", "http://localhost:3001/3000"},
+		{"http://localhost:3000", "", "x", "http://localhost:3000"},
+	}
+	for _, tt := range tests {
+		if got := ReplaceAddress(tt.destination, tt.from, tt.to); got != tt.want {
+			t.Errorf("ReplaceAddress(%q, %q, %q) = %q, want %q", tt.destination, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestExecuteBulk_ContinuesAfterFailure(t *testing.T) {
+	executor := &mockBulkExecutor{failOn: "web"}
+	plan := PlanBulk(BulkDelete, bulkEndpoints, nil, "", "")
+
+	results := ExecuteBulk(executor, plan)
+
+	if len(results) != 3 {
+		t.Fatalf("expected a result per endpoint, got %+v", results)
+	}
+	if results[0].Err == nil || results[1].Err == nil || results[2].Err != nil {
+		t.Errorf("expected only the web endpoints to fail, got %+v", results)
+	}
+	if len(executor.removed) != 1 || executor.removed[0].ServiceName != "api" {
+		t.Errorf("expected api endpoint to be removed, got %+v", executor.removed)
+	}
+	if BulkFailed(results) != 2 {
+		t.Errorf("expected 2 failures, got %d", BulkFailed(results))
+	}
+}
+
+func TestExecuteBulk_Repoint(t *testing.T) {
+	executor := &mockBulkExecutor{}
+	plan := PlanBulk(BulkRepoint, bulkEndpoints[:1], nil, "3000", "3001")
+
+	ExecuteBulk(executor, plan)
+
+	want := UpdateEndpointParams{ServiceName: "web", Protocol: "https", ExposePort: "443", OldDestination: "http://localhost:3000", NewDestination: "http://localhost:3001"}
+	if len(executor.updated) != 1 || executor.updated[0] != want {
+		t.Errorf("expected %+v, got %+v", want, executor.updated)
+	}
+}
+
+func TestExecuteBulk_Clear(t *testing.T) {
+	executor := &mockBulkExecutor{failOn: "api"}
+	plan := PlanBulk(BulkClear, bulkEndpoints, []string{"web", "api"}, "", "")
+
+	results := ExecuteBulk(executor, plan)
+
+	if len(results) != 2 || results[0].Err != nil || results[1].Err == nil {
+		t.Fatalf("expected a result per service, got %+v", results)
+	}
+	if len(executor.cleared) != 1 || executor.cleared[0] != "web" {
+		t.Errorf("expected web to be cleared, got %+v", executor.cleared)
+	}
+}
+
+func TestBulkPlan_Signature(t *testing.T) {
+	plan := PlanBulk(BulkRepoint, bulkEndpoints, nil, "localhost:3000", "localhost:3001")
+	if plan.Signature() != PlanBulk(BulkRepoint, bulkEndpoints, nil, "localhost:3000", "localhost:3001").Signature() {
+		t.Error("expected the same plan to have the same signature")
+	}
+	if plan.Signature() == PlanBulk(BulkRepoint, bulkEndpoints, nil, "localhost:3000", "localhost:3002").Signature() {
+		t.Error("expected a different target to change the signature")
+	}
+	if plan.Signature() == PlanBulk(BulkRepoint, bulkEndpoints[:1], nil, "localhost:3000", "localhost:3001").Signature() {
+		t.Error("expected fewer endpoints to change the signature")
+	}
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CertExpiryWarning is how long before a certificate expires the service
+// page starts warning about it. Tailscale renews certificates well before
+// this, so reaching it means renewal is failing.
+const CertExpiryWarning = 14 * 24 * time.Hour
+
+const (
+	certTimeout    = 3 * time.Second
+	certCacheTTL   = 10 * time.Minute
+	certFailureTTL = 30 * time.Second
+	certMaxSize    = 64 << 10
+)
+
+var ErrNoCertificate = errors.New("no certificate presented")
+
+// CertInfo describes the certificate served for a hostname. VerifyError is
+// empty when the chain is trusted by the system roots for Hostname.
+type CertInfo struct {
+	Hostname    string
+	Subject     string
+	Issuer      string
+	SANs        []string
+	NotBefore   time.Time
+	NotAfter    time.Time
+	VerifyError string
+	CheckedAt   time.Time
+}
+
+func newCertInfo(hostname string, chain []*x509.Certificate, roots *x509.CertPool, now time.Time) (*CertInfo, error) {
+	if len(chain) == 0 {
+		return nil, ErrNoCertificate
+	}
+	leaf := chain[0]
+	info := &CertInfo{
+		Hostname:  hostname,
+		Subject:   leaf.Subject.CommonName,
+		Issuer:    leaf.Issuer.CommonName,
+		SANs:      leaf.DNSNames,
+		NotBefore: leaf.NotBefore,
+		NotAfter:  leaf.NotAfter,
+		CheckedAt: now,
+	}
+	if info.Issuer == "" && len(leaf.Issuer.Organization) > 0 {
+		info.Issuer = leaf.Issuer.Organization[0]
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       hostname,
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	})
+	if err != nil {
+		info.VerifyError = err.Error()
+	}
+	return info, nil
+}
+
+// parseCertChain reads the PEM certificates of a chain, leaf first.
+func parseCertChain(data []byte) ([]*x509.Certificate, error) {
+	var chain []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return chain, nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, cert)
+	}
+}
+
+// Warnings returns locale keys for the problems of the certificate, most
+// severe first.
+func (c *CertInfo) Warnings(now time.Time) []string {
+	var warnings []string
+	switch {
+	case !now.Before(c.NotAfter):
+		warnings = append(warnings, "cert.warn_expired")
+	case c.NotAfter.Sub(now) < CertExpiryWarning:
+		warnings = append(warnings, "cert.warn_expiring")
+	}
+	if c.VerifyError != "" && now.Before(c.NotAfter) {
+		warnings = append(warnings, "cert.warn_untrusted")
+	}
+	return warnings
+}
+
+// CertInspector reads the certificate tailscaled serves for a service
+// hostname from the LocalAPI of this node, so it sees what clients of this
+// host get even when the hostname resolves to another host. Results are
+// cached briefly since the service page asks on every view, and failures for
+// a shorter while so that a missing certificate does not stall every view.
+type CertInspector struct {
+	client *http.Client
+	roots  *x509.CertPool
+	now    func() time.Time
+
+	mu     sync.Mutex
+	cache  map[string]*CertInfo
+	failed map[string]certFailure
+}
+
+type certFailure struct {
+	err error
+	at  time.Time
+}
+
+func NewCertInspector(socket string) *CertInspector {
+	return &CertInspector{
+		client: &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}},
+		now:    time.Now,
+		cache:  make(map[string]*CertInfo),
+		failed: make(map[string]certFailure),
+	}
+}
+
+func (i *CertInspector) Inspect(ctx context.Context, hostname string) (*CertInfo, error) {
+	now := i.now()
+	i.mu.Lock()
+	cached, ok := i.cache[hostname]
+	failure, failed := i.failed[hostname]
+	i.mu.Unlock()
+	if ok && now.Sub(cached.CheckedAt) < certCacheTTL {
+		return cached, nil
+	}
+	if failed && now.Sub(failure.at) < certFailureTTL {
+		return nil, failure.err
+	}
+
+	info, err := i.fetch(ctx, hostname, now)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if err != nil {
+		i.failed[hostname] = certFailure{err: err, at: now}
+		return nil, err
+	}
+	delete(i.failed, hostname)
+	i.cache[hostname] = info
+	return info, nil
+}
+
+// fetch asks tailscaled for the certificate it holds for hostname. It only
+// returns the certificate, never the key.
+func (i *CertInspector) fetch(ctx context.Context, hostname string, now time.Time) (*CertInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, certTimeout)
+	defer cancel()
+	target := "http://local-tailscaled.sock/localapi/v0/cert/" + url.PathEscape(hostname) + "?type=cert"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, certMaxSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tailscaled: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	chain, err := parseCertChain(body)
+	if err != nil {
+		return nil, err
+	}
+	return newCertInfo(hostname, chain, i.roots, now)
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestInspector answers the cert LocalAPI from a unix socket with the
+// certificate of a stand-in TLS server. It counts the requests and fails
+// them while unavailable is set.
+func newTestInspector(t *testing.T) (*CertInspector, *x509.Certificate, *certAPI) {
+	t.Helper()
+	tlsSrv := httptest.NewTLSServer(http.NotFoundHandler())
+	tlsSrv.Close()
+	cert := tlsSrv.Certificate()
+
+	api := &certAPI{pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})}
+	socket := filepath.Join(t.TempDir(), "tailscaled.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(api)
+	srv.Listener = ln
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return NewCertInspector(socket), cert, api
+}
+
+type certAPI struct {
+	pem         []byte
+	unavailable bool
+	paths       []string
+}
+
+func (a *certAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.paths = append(a.paths, r.URL.Path+"?"+r.URL.RawQuery)
+	if a.unavailable {
+		http.Error(w, "no certificate", http.StatusInternalServerError)
+		return
+	}
+	w.Write(a.pem)
+}
+
+func TestCertInspector_Inspect(t *testing.T) {
+	i, cert, api := newTestInspector(t)
+	i.roots = x509.NewCertPool()
+	i.roots.AddCert(cert)
+
+	info, err := i.Inspect(context.Background(), "example.com")
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(api.paths) != 1 || api.paths[0] != "/localapi/v0/cert/example.com?type=cert" {
+		t.Errorf("expected the certificate to be asked for, got %v", api.paths)
+	}
+	if info.SANs[0] != "example.com" || info.Issuer != cert.Issuer.Organization[0] {
+		t.Errorf("unexpected certificate %+v", info)
+	}
+	if info.VerifyError != "" || len(info.Warnings(time.Now())) != 0 {
+		t.Errorf("expected a trusted certificate, got %q %v", info.VerifyError, info.Warnings(time.Now()))
+	}
+}
+
+func TestCertInspector_Untrusted(t *testing.T) {
+	i, _, _ := newTestInspector(t)
+
+	info, err := i.Inspect(context.Background(), "example.com")
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if warnings := info.Warnings(time.Now()); !reflect.DeepEqual(warnings, []string{"cert.warn_untrusted"}) {
+		t.Errorf("expected an untrusted warning, got %v", warnings)
+	}
+}
+
+func TestCertInspector_Cache(t *testing.T) {
+	i, _, api := newTestInspector(t)
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	i.now = func() time.Time { return now }
+	first, err := i.Inspect(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.unavailable = true
+
+	if second, err := i.Inspect(context.Background(), "example.com"); err != nil || second != first {
+		t.Errorf("expected the cached certificate, got %v", err)
+	}
+	now = now.Add(certCacheTTL)
+	if _, err := i.Inspect(context.Background(), "example.com"); err == nil {
+		t.Error("expected the cache to expire")
+	}
+}
+
+func TestCertInspector_CachesFailures(t *testing.T) {
+	i, _, api := newTestInspector(t)
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	i.now = func() time.Time { return now }
+	api.unavailable = true
+
+	if _, err := i.Inspect(context.Background(), "example.com"); err == nil {
+		t.Fatal("expected an error")
+	}
+	api.unavailable = false
+	if _, err := i.Inspect(context.Background(), "example.com"); err == nil || len(api.paths) != 1 {
+		t.Errorf("expected the failure to be reused, got %v after %d requests", err, len(api.paths))
+	}
+	now = now.Add(certFailureTTL)
+	if _, err := i.Inspect(context.Background(), "example.com"); err != nil {
+		t.Errorf("expected a retry after the failure expired, got %v", err)
+	}
+}
+
+func TestCertInfo_Warnings(t *testing.T) {
+	notAfter := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	info := &CertInfo{NotAfter: notAfter}
+
+	tests := []struct {
+		now  time.Time
+		want []string
+	}{
+		{notAfter.Add(-30 * 24 * time.Hour), nil},
+		{notAfter.Add(-3 * 24 * time.Hour), []string{"cert.warn_expiring"}},
+		{notAfter, []string{"cert.warn_expired"}},
+	}
+	for _, tt := range tests {
+		if got := info.Warnings(tt.now); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Warnings(%v) = %v, want %v", tt.now, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	LabelService  = "twintail.service"
+	LabelPort     = "twintail.port"
+	LabelProtocol = "twintail.protocol"
+	LabelTarget   = "twintail.target-port"
+)
+
+type ContainerPort struct {
+	IP          string `json:"IP"`
+	PrivatePort int    `json:"PrivatePort"`
+	PublicPort  int    `json:"PublicPort"`
+	Type        string `json:"Type"`
+}
+
+// Destination returns the host address that reaches a published port.
+func (p ContainerPort) Destination() string {
+	host := "localhost"
+	if p.IP != "" && p.IP != "0.0.0.0" && p.IP != "::" {
+		host = p.IP
+	}
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(p.PublicPort))
+}
+
+type Container struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Image  string            `json:"Image"`
+	State  string            `json:"State"`
+	Status string            `json:"Status"`
+	Ports  []ContainerPort   `json:"Ports"`
+	Labels map[string]string `json:"Labels"`
+}
+
+func (c Container) Name() string {
+	if len(c.Names) == 0 {
+		return c.ID
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+func (c Container) ShortID() string {
+	if len(c.ID) > 12 {
+		return c.ID[:12]
+	}
+	return c.ID
+}
+
+// PublishedPorts returns the TCP ports reachable from the host, one entry per
+// host port even when Docker reports both IPv4 and IPv6 bindings.
+func (c Container) PublishedPorts() []ContainerPort {
+	seen := make(map[int]bool)
+	var ports []ContainerPort
+	for _, p := range c.Ports {
+		if p.PublicPort == 0 || (p.Type != "" && p.Type != "tcp") || seen[p.PublicPort] {
+			continue
+		}
+		seen[p.PublicPort] = true
+		ports = append(ports, p)
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		return ports[i].PublicPort < ports[j].PublicPort
+	})
+	return ports
+}
+
+// SuggestedServiceName turns the container name into a valid service name.
+func (c Container) SuggestedServiceName() string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(c.Name()) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('-')
+		}
+	}
+	return strings.Trim(b.String(), "-")
+}
+
+func (c Container) Labeled() bool {
+	return c.Labels[LabelService] != ""
+}
+
+// LabelParams builds the service declared by the twintail.* labels.
+func (c Container) LabelParams() (AdvertiseServiceParams, error) {
+	params := AdvertiseServiceParams{
+		ServiceName: c.Labels[LabelService],
+		Protocol:    c.Labels[LabelProtocol],
+		ExposePort:  c.Labels[LabelPort],
+	}
+	if !validServiceName(params.ServiceName) {
+		return params, fmt.Errorf("container %s: invalid %s label %q", c.Name(), LabelService, params.ServiceName)
+	}
+	if params.ExposePort == "" {
+		params.ExposePort = "443"
+	}
+	if _, err := strconv.Atoi(params.ExposePort); err != nil {
+		return params, fmt.Errorf("container %s: invalid %s label %q", c.Name(), LabelPort, params.ExposePort)
+	}
+	if params.Protocol == "" {
+		params.Protocol = "http"
+		if params.ExposePort == "443" {
+			params.Protocol = "https"
+		}
+	}
+	switch params.Protocol {
+	case "https", "http", "tcp", "tcp+tls":
+	default:
+		return params, fmt.Errorf("container %s: invalid %s label %q", c.Name(), LabelProtocol, params.Protocol)
+	}
+
+	published := c.PublishedPorts()
+	if len(published) == 0 {
+		return params, fmt.Errorf("container %s publishes no TCP ports", c.Name())
+	}
+	port := published[0]
+	if target := c.Labels[LabelTarget]; target != "" {
+		found := false
+		for _, p := range published {
+			if strconv.Itoa(p.PrivatePort) == target || strconv.Itoa(p.PublicPort) == target {
+				port, found = p, true
+				break
+			}
+		}
+		if !found {
+			return params, fmt.Errorf("container %s: %s %s is not published", c.Name(), LabelTarget, target)
+		}
+	}
+	params.Destination = port.Destination()
+	if strings.HasPrefix(params.Protocol, "tcp") {
+		params.Destination = "tcp://" + strings.TrimPrefix(params.Destination, "http://")
+	}
+	return params, nil
+}
+
+type ContainerClient struct {
+	Socket string
+	client *http.Client
+}
+
+// NewContainerClient talks to a Docker-compatible API, such as Docker or the
+// Podman service, on the given unix socket.
+func NewContainerClient(socket string) *ContainerClient {
+	socket = strings.TrimPrefix(socket, "unix://")
+	return &ContainerClient{
+		Socket: socket,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+func (c *ContainerClient) ListContainers() ([]Container, error) {
+	resp, err := c.client.Get("http://docker/containers/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("container API returned %s", resp.Status)
+	}
+
+	var containers []Container
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return nil, err
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].Name() < containers[j].Name()
+	})
+	return containers, nil
+}
+
+type ContainerLister interface {
+	ListContainers() ([]Container, error)
+}
+
+type ContainerAdvertiser interface {
+	GetServeStatus() ([]ServiceView, error)
+	AdvertiseService(params AdvertiseServiceParams) error
+}
+
+type ContainerSyncResult struct {
+	Container string
+	Service   string
+	Err       error
+	// Repeated is set when the container failed with the same error on the
+	// previous sync, so that it is not logged again every interval.
+	Repeated bool
+}
+
+// containerForgetAfter is how long a container that is no longer running
+// is remembered. A container started again within that time is not exposed
+// again.
+const containerForgetAfter = 7 * 24 * time.Hour
+
+// ContainerSync advertises the service declared by the labels of a
+// container once, when the container first shows up. Services that are
+// deleted or drained afterwards stay that way until the container is
+// recreated.
+type ContainerSync struct {
+	path   string
+	now    func() time.Time
+	mu     sync.Mutex
+	seen   map[string]time.Time
+	failed map[string]string
+}
+
+func NewContainerSync(dataDir string) (*ContainerSync, error) {
+	if err := os.MkdirAll(dataDir, 0o750); err != nil {
+		return nil, err
+	}
+	s := &ContainerSync{
+		path:   filepath.Join(dataDir, "containers.json"),
+		now:    time.Now,
+		seen:   make(map[string]time.Time),
+		failed: make(map[string]string),
+	}
+	if err := loadJSON(s.path, &s.seen); err != nil {
+		return nil, err
+	}
+	if s.seen == nil {
+		s.seen = make(map[string]time.Time)
+	}
+	return s, nil
+}
+
+// Sync advertises the services of labeled containers that were not seen
+// before. A service that already exists only marks its container as seen;
+// failures are retried on the next sync.
+func (s *ContainerSync) Sync(lister ContainerLister, tailscale ContainerAdvertiser) ([]ContainerSyncResult, error) {
+	containers, err := lister.ListContainers()
+	if err != nil {
+		return nil, err
+	}
+	svcs, err := tailscale.GetServeStatus()
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool)
+	for _, svc := range svcs {
+		existing[svc.Name] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	var results []ContainerSyncResult
+	for _, c := range containers {
+		if !c.Labeled() || c.State != "running" {
+			continue
+		}
+		if _, ok := s.seen[c.ID]; ok {
+			s.seen[c.ID] = now
+			continue
+		}
+		params, err := c.LabelParams()
+		if err == nil && !existing[params.ServiceName] {
+			err = tailscale.AdvertiseService(params)
+			if err == nil {
+				existing[params.ServiceName] = true
+				results = append(results, ContainerSyncResult{Container: c.Name(), Service: params.ServiceName})
+			}
+		}
+		if err != nil && !errors.Is(err, ErrServiceDrained) {
+			results = append(results, ContainerSyncResult{
+				Container: c.Name(),
+				Service:   params.ServiceName,
+				Err:       err,
+				Repeated:  s.failed[c.ID] == err.Error(),
+			})
+			s.failed[c.ID] = err.Error()
+			continue
+		}
+		delete(s.failed, c.ID)
+		s.seen[c.ID] = now
+	}
+	for id, last := range s.seen {
+		if now.Sub(last) > containerForgetAfter {
+			delete(s.seen, id)
+		}
+	}
+	return results, saveJSON(s.path, s.seen)
+}
+
+func RunContainerSync(ctx context.Context, labels *ContainerSync, lister ContainerLister, tailscale ContainerAdvertiser, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		results, err := labels.Sync(lister, tailscale)
+		if err != nil {
+			logger.Error("failed to sync container labels", "error", err)
+		}
+		for _, r := range results {
+			switch {
+			case r.Err == nil:
+				logger.Info("exposed container", "container", r.Container, "service", r.Service)
+			case !r.Repeated:
+				logger.Error("failed to expose container", "container", r.Container, "service", r.Service, "error", r.Err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const fakeContainersJSON = `[
+	{
+		"Id": "f00dbabe0000000000000000",
+		"Names": ["/web"],
+		"Image": "nginx:latest",
+		"State": "running",
+		"Ports": [{"PrivatePort": 80, "PublicPort": 8080, "Type": "tcp", "IP": "0.0.0.0"}, {"PrivatePort": 80, "PublicPort": 8080, "Type": "tcp", "IP": "::"}],
+		"Labels": {}
+	},
+	{
+		"Id": "deadbeef0000000000000000",
+		"Names": ["/grafana"],
+		"Image": "grafana/grafana",
+		"State": "running",
+		"Ports": [{"PrivatePort": 3000, "PublicPort": 3001, "Type": "tcp", "IP": "127.0.0.1"}, {"PrivatePort": 9000, "Type": "tcp"}],
+		"Labels": {"twintail.service": "grafana", "twintail.port": "443"}
+	}
+]`
+
+func newFakeContainerAPI(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(handler)
+	srv.Listener = ln
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return socket
+}
+
+func TestListContainers_FakeSocket(t *testing.T) {
+	var gotPath string
+	socket := newFakeContainerAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(fakeContainersJSON))
+	})
+
+	containers, err := NewContainerClient("unix://" + socket).ListContainers()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if gotPath != "/containers/json" {
+		t.Errorf("expected request to /containers/json, got %s", gotPath)
+	}
+	if len(containers) != 2 {
+		t.Fatalf("expected 2 containers, got %d", len(containers))
+	}
+	if containers[0].Name() != "grafana" || containers[1].Name() != "web" {
+		t.Errorf("expected containers sorted by name, got %s, %s", containers[0].Name(), containers[1].Name())
+	}
+	if ports := containers[1].PublishedPorts(); len(ports) != 1 || ports[0].Destination() != "http://localhost:8080" {
+		t.Errorf("expected one published port on localhost:8080, got %+v", ports)
+	}
+}
+
+func TestListContainers_APIError(t *testing.T) {
+	socket := newFakeContainerAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "permission denied", http.StatusForbidden)
+	})
+
+	if _, err := NewContainerClient(socket).ListContainers(); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestContainer_LabelParams(t *testing.T) {
+	published := []ContainerPort{
+		{PrivatePort: 3000, PublicPort: 3001, Type: "tcp"},
+		{PrivatePort: 9090, PublicPort: 9091, Type: "tcp"},
+	}
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		want    AdvertiseServiceParams
+		wantErr bool
+	}{
+		{
+			name:   "defaults to https on 443",
+			labels: map[string]string{LabelService: "grafana"},
+			want:   AdvertiseServiceParams{ServiceName: "grafana", Protocol: "https", ExposePort: "443", Destination: "http://localhost:3001"},
+		},
+		{
+			name:   "non-443 port defaults to http",
+			labels: map[string]string{LabelService: "grafana", LabelPort: "8443"},
+			want:   AdvertiseServiceParams{ServiceName: "grafana", Protocol: "http", ExposePort: "8443", Destination: "http://localhost:3001"},
+		},
+		{
+			name:   "target port selects published port",
+			labels: map[string]string{LabelService: "prom", LabelTarget: "9090"},
+			want:   AdvertiseServiceParams{ServiceName: "prom", Protocol: "https", ExposePort: "443", Destination: "http://localhost:9091"},
+		},
+		{
+			name:   "tcp protocol",
+			labels: map[string]string{LabelService: "db", LabelPort: "5432", LabelProtocol: "tcp"},
+			want:   AdvertiseServiceParams{ServiceName: "db", Protocol: "tcp", ExposePort: "5432", Destination: "tcp://localhost:3001"},
+		},
+		{
+			name:    "invalid service name",
+			labels:  map[string]string{LabelService: "-rm"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown target port",
+			labels:  map[string]string{LabelService: "grafana", LabelTarget: "1234"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid protocol",
+			labels:  map[string]string{LabelService: "grafana", LabelProtocol: "ftp"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Container{Names: []string{"/c"}, Ports: published, Labels: tt.labels}
+			got, err := c.LabelParams()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LabelParams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("LabelParams() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainer_SuggestedServiceName(t *testing.T) {
+	c := Container{Names: []string{"/My_App.1"}}
+	if got := c.SuggestedServiceName(); got != "my-app-1" {
+		t.Errorf("expected 'my-app-1', got %q", got)
+	}
+}
+
+type fakeContainerLister struct {
+	containers []Container
+}
+
+func (f *fakeContainerLister) ListContainers() ([]Container, error) {
+	return f.containers, nil
+}
+
+type fakeAdvertiser struct {
+	services   []ServiceView
+	advertised []AdvertiseServiceParams
+	err        error
+}
+
+func (f *fakeAdvertiser) GetServeStatus() ([]ServiceView, error) {
+	return f.services, nil
+}
+
+func (f *fakeAdvertiser) AdvertiseService(params AdvertiseServiceParams) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.advertised = append(f.advertised, params)
+	return nil
+}
+
+func TestSyncContainerLabels(t *testing.T) {
+	port := []ContainerPort{{PrivatePort: 80, PublicPort: 8080, Type: "tcp"}}
+	lister := &fakeContainerLister{containers: []Container{
+		{ID: "c1", Names: []string{"/new"}, State: "running", Ports: port, Labels: map[string]string{LabelService: "new-app"}},
+		{ID: "c2", Names: []string{"/existing"}, State: "running", Ports: port, Labels: map[string]string{LabelService: "old-app"}},
+		{ID: "c3", Names: []string{"/unlabeled"}, State: "running", Ports: port},
+		{ID: "c4", Names: []string{"/stopped"}, State: "exited", Ports: port, Labels: map[string]string{LabelService: "stopped-app"}},
+		{ID: "c5", Names: []string{"/broken"}, State: "running", Labels: map[string]string{LabelService: "broken-app"}},
+	}}
+	advertiser := &fakeAdvertiser{services: []ServiceView{{Name: "old-app"}}}
+
+	labels, _ := NewContainerSync(t.TempDir())
+	results, err := labels.Sync(lister, advertiser)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(advertiser.advertised) != 1 || advertiser.advertised[0].ServiceName != "new-app" {
+		t.Fatalf("expected only new-app to be advertised, got %+v", advertiser.advertised)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %+v", results)
+	}
+	if results[0].Err != nil || results[1].Err == nil {
+		t.Errorf("expected new success and broken failure, got %+v", results)
+	}
+}
+
+func TestSyncContainerLabels_AdvertiseFailure(t *testing.T) {
+	port := []ContainerPort{{PrivatePort: 80, PublicPort: 8080, Type: "tcp"}}
+	lister := &fakeContainerLister{containers: []Container{
+		{ID: "c1", Names: []string{"/new"}, State: "running", Ports: port, Labels: map[string]string{LabelService: "new-app"}},
+	}}
+	advertiser := &fakeAdvertiser{err: errors.New("serve failed")}
+	labels, _ := NewContainerSync(t.TempDir())
+
+	results, err := labels.Sync(lister, advertiser)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(results) != 1 || results[0].Err == nil || results[0].Repeated {
+		t.Errorf("expected advertise failure to be reported, got %+v", results)
+	}
+
+	results, _ = labels.Sync(lister, advertiser)
+	if len(results) != 1 || !results[0].Repeated {
+		t.Errorf("expected the same failure to be marked as repeated, got %+v", results)
+	}
+
+	advertiser.err = nil
+	if results, _ = labels.Sync(lister, advertiser); len(results) != 1 || results[0].Err != nil {
+		t.Errorf("expected the retry to advertise the service, got %+v", results)
+	}
+}
+
+func TestContainerSync_OnlyFirstAppearance(t *testing.T) {
+	port := []ContainerPort{{PrivatePort: 80, PublicPort: 8080, Type: "tcp"}}
+	lister := &fakeContainerLister{containers: []Container{
+		{ID: "c1", Names: []string{"/new"}, State: "running", Ports: port, Labels: map[string]string{LabelService: "new-app"}},
+	}}
+	advertiser := &fakeAdvertiser{}
+	dir := t.TempDir()
+	labels, _ := NewContainerSync(dir)
+	labels.Sync(lister, advertiser)
+
+	// The service was deleted by hand; neither another sync nor a restart
+	// of twintail brings it back.
+	labels.Sync(lister, advertiser)
+	reopened, _ := NewContainerSync(dir)
+	reopened.Sync(lister, advertiser)
+	if len(advertiser.advertised) != 1 {
+		t.Errorf("expected the service to be advertised once, got %+v", advertiser.advertised)
+	}
+
+	lister.containers[0].ID = "c2"
+	reopened.Sync(lister, advertiser)
+	if len(advertiser.advertised) != 2 {
+		t.Errorf("expected a recreated container to be exposed again, got %+v", advertiser.advertised)
+	}
+}
+
+func TestContainerSync_SkipsDrained(t *testing.T) {
+	port := []ContainerPort{{PrivatePort: 80, PublicPort: 8080, Type: "tcp"}}
+	lister := &fakeContainerLister{containers: []Container{
+		{ID: "c1", Names: []string{"/web"}, State: "running", Ports: port, Labels: map[string]string{LabelService: "web"}},
+	}}
+	drains, _ := NewDrainStore(t.TempDir())
+	drains.Drain(&mockDrainExecutor{detail: duplicateSource}, "web", time.Now())
+	advertiser := &fakeAdvertiser{}
+	labels, _ := NewContainerSync(t.TempDir())
+
+	results, err := labels.Sync(lister, drains.GuardAdvertiser(advertiser))
+
+	if err != nil || len(results) != 0 || len(advertiser.advertised) != 0 {
+		t.Errorf("expected the drained service to be left alone, got %+v, %v", results, err)
+	}
+}
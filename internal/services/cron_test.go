@@ -0,0 +1,65 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron_Invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	}
+	for _, expr := range tests {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("expected %q to be rejected", expr)
+		}
+	}
+}
+
+func TestCronSpec_Next(t *testing.T) {
+	// 2026-03-06 is a Friday.
+	base := time.Date(2026, 3, 6, 17, 30, 15, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 3, 6, 17, 31, 0, 0, time.UTC)},
+		{"0 18 * * 1-5", time.Date(2026, 3, 6, 18, 0, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 3, 6, 17, 45, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 2 * * 7", time.Date(2026, 3, 8, 2, 30, 0, 0, time.UTC)},
+		{"0 12 15,20 * *", time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Day-of-month and day-of-week both restricted: either matches.
+		{"0 0 13 * 1", time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		spec, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("%q: expected no error, got %v", tt.expr, err)
+		}
+		if got := spec.Next(base); !got.Equal(tt.want) {
+			t.Errorf("%q: expected %v, got %v", tt.expr, tt.want, got)
+		}
+	}
+}
+
+func TestCronSpec_NextNeverFires(t *testing.T) {
+	spec, err := ParseCron("0 0 31 2 *")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := spec.Next(time.Now()); !got.IsZero() {
+		t.Errorf("expected zero time, got %v", got)
+	}
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Check states, from best to worst.
+const (
+	CheckOK   = "ok"
+	CheckSkip = "skip"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+)
+
+// DefaultSocket is where tailscaled listens on Linux.
+const DefaultSocket = "/var/run/tailscale/tailscaled.sock"
+
+const installCommand = "curl -fsSL https://tailscale.com/install.sh | sh"
+
+// doctorChecks are the checks in the order they run. A failure of the CLI or
+// the socket skips everything after it, as does a serve failure for HTTPS.
+var doctorChecks = []string{
+	"doctor.binary",
+	"doctor.version",
+	"doctor.socket",
+	"doctor.operator",
+	"doctor.serve",
+	"doctor.https",
+}
+
+// Check is the result of one diagnostic. Name and Message are locale keys,
+// Detail is what the check found and Fix holds the commands that resolve a
+// warning or failure.
+type Check struct {
+	Name    string
+	Status  string
+	Message string
+	Detail  string
+	Fix     []string
+}
+
+// Doctor checks that this node can run twintail: the tailscale CLI, access
+// to tailscaled, the right to change the serve config and HTTPS certs.
+type Doctor struct {
+	socket   string
+	lookPath func(file string) (string, error)
+	user     func() (uid int, name string)
+}
+
+func NewDoctor() *Doctor {
+	return &Doctor{
+		socket:   DefaultSocket,
+		lookPath: exec.LookPath,
+		user:     currentUser,
+	}
+}
+
+func currentUser() (int, string) {
+	uid := os.Geteuid()
+	if u, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+		return uid, u.Username
+	}
+	return uid, strconv.Itoa(uid)
+}
+
+// Failed reports whether any check failed.
+func Failed(checks []Check) bool {
+	for _, c := range checks {
+		if c.Status == CheckFail {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Doctor) Run() []Check {
+	var checks []Check
+	add := func(c Check) bool {
+		checks = append(checks, c)
+		return c.Status != CheckFail
+	}
+	if add(d.checkBinary()) && add(d.checkVersion()) && add(d.checkSocket()) {
+		add(d.checkOperator())
+		status, serve := d.checkServe()
+		if add(serve) {
+			add(d.checkHTTPS(status))
+		}
+	}
+	for _, name := range doctorChecks[len(checks):] {
+		checks = append(checks, Check{Name: name, Status: CheckSkip, Message: "doctor.skipped"})
+	}
+	return checks
+}
+
+func (d *Doctor) checkBinary() Check {
+	c := Check{Name: "doctor.binary"}
+	path, err := d.lookPath("tailscale")
+	if err != nil {
+		c.Status, c.Message, c.Detail = CheckFail, "doctor.binary.missing", err.Error()
+		c.Fix = []string{installCommand}
+		return c
+	}
+	c.Status, c.Message, c.Detail = CheckOK, "doctor.binary.ok", path
+	return c
+}
+
+func (d *Doctor) checkVersion() Check {
+	c := Check{Name: "doctor.version"}
+	output, err := execCommand("tailscale", "version").Output()
+	if err != nil {
+		c.Status, c.Message, c.Detail = CheckFail, "doctor.version.failed", err.Error()
+		c.Fix = []string{installCommand}
+		return c
+	}
+	v, err := ParseVersion(string(output))
+	if err != nil {
+		c.Status, c.Message, c.Detail = CheckWarn, "doctor.version.unknown", err.Error()
+		return c
+	}
+	if err := v.Supported(); err != nil {
+		c.Status, c.Message, c.Detail = CheckFail, "doctor.version.unsupported", err.Error()
+		c.Fix = []string{installCommand}
+		return c
+	}
+	c.Status, c.Message, c.Detail = CheckOK, "doctor.version.ok", v.String()
+	return c
+}
+
+func (d *Doctor) checkSocket() Check {
+	c := Check{Name: "doctor.socket"}
+	conn, err := net.DialTimeout("unix", d.socket, 2*time.Second)
+	switch {
+	case err == nil:
+		conn.Close()
+		c.Status, c.Message, c.Detail = CheckOK, "doctor.socket.ok", d.socket
+	case errors.Is(err, fs.ErrPermission):
+		c.Status, c.Message, c.Detail = CheckFail, "doctor.socket.denied", err.Error()
+		c.Fix = []string{"ls -l " + d.socket, "sudo systemctl restart tailscaled"}
+	default:
+		c.Status, c.Message, c.Detail = CheckFail, "doctor.socket.down", err.Error()
+		c.Fix = []string{"sudo systemctl enable --now tailscaled"}
+	}
+	return c
+}
+
+// checkOperator passes when twintail runs as root or as the operator user
+// tailscaled lets change the serve config.
+func (d *Doctor) checkOperator() Check {
+	c := Check{Name: "doctor.operator"}
+	uid, name := d.user()
+	if uid == 0 {
+		c.Status, c.Message, c.Detail = CheckOK, "doctor.operator.root", name
+		return c
+	}
+	fix := []string{"sudo tailscale set --operator=" + name}
+
+	output, err := execCommand("tailscale", "debug", "prefs").Output()
+	if err != nil {
+		c.Status, c.Message, c.Detail, c.Fix = CheckWarn, "doctor.operator.unknown", outputError(err).Error(), fix
+		return c
+	}
+	var prefs struct {
+		OperatorUser string `json:"OperatorUser"`
+	}
+	if err := json.Unmarshal(output, &prefs); err != nil {
+		c.Status, c.Message, c.Detail, c.Fix = CheckWarn, "doctor.operator.unknown", err.Error(), fix
+		return c
+	}
+	if prefs.OperatorUser != name {
+		c.Status, c.Message, c.Detail, c.Fix = CheckFail, "doctor.operator.missing", name, fix
+		return c
+	}
+	c.Status, c.Message, c.Detail = CheckOK, "doctor.operator.ok", name
+	return c
+}
+
+func (d *Doctor) checkServe() (*TailnetStatus, Check) {
+	c := Check{Name: "doctor.serve"}
+	status, err := readTailnetStatus()
+	if err != nil {
+		c.Status, c.Message, c.Detail = CheckFail, "doctor.serve.failed", err.Error()
+		return nil, c
+	}
+	if !status.Running() {
+		c.Status, c.Message, c.Detail = CheckFail, "doctor.serve.not_running", status.BackendState
+		c.Fix = []string{"sudo tailscale up"}
+		return status, c
+	}
+	if _, err := serveStatusOutput(); err != nil {
+		c.Status, c.Message, c.Detail = CheckFail, "doctor.serve.failed", err.Error()
+		if errors.Is(err, ErrPermissionDenied) {
+			_, name := d.user()
+			c.Fix = []string{"sudo tailscale set --operator=" + name}
+		}
+		return status, c
+	}
+	c.Status, c.Message, c.Detail = CheckOK, "doctor.serve.ok", status.BackendState
+	return status, c
+}
+
+func (d *Doctor) checkHTTPS(status *TailnetStatus) Check {
+	c := Check{Name: "doctor.https"}
+	if len(status.CertDomains) == 0 {
+		c.Status, c.Message = CheckWarn, "doctor.https.disabled"
+		return c
+	}
+	c.Status, c.Message, c.Detail = CheckOK, "doctor.https.ok", strings.Join(status.CertDomains, ", ")
+	return c
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeTailscale answers the commands the doctor runs. prefs is the output of
+// tailscale debug prefs.
+func fakeTailscale(t *testing.T, prefs string) {
+	t.Helper()
+	status, err := os.ReadFile("testdata/status.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldExecCommand := execCommand
+	t.Cleanup(func() { execCommand = oldExecCommand })
+	execCommand = func(name string, args ...string) interface {
+		Output() ([]byte, error)
+		CombinedOutput() ([]byte, error)
+	} {
+		switch strings.Join(args, " ") {
+		case "version":
+			return &mockCmd{output: []byte("1.90.1\n  tailscale commit: 8e3c9a1a4\n")}
+		case "debug prefs":
+			return &mockCmd{output: []byte(prefs)}
+		case "status --json":
+			return &mockCmd{output: status}
+		case "serve status --json":
+			return &mockCmd{output: []byte(`{"Services": {}}`)}
+		}
+		return &mockCmd{err: errors.New("unexpected command")}
+	}
+}
+
+func newTestDoctor(t *testing.T) *Doctor {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "tailscaled.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return &Doctor{
+		socket:   socket,
+		lookPath: func(string) (string, error) { return "/usr/bin/tailscale", nil },
+		user:     func() (int, string) { return 1000, "twintail" },
+	}
+}
+
+func statuses(checks []Check) []string {
+	var out []string
+	for _, c := range checks {
+		out = append(out, c.Status)
+	}
+	return out
+}
+
+func TestDoctor_AllPass(t *testing.T) {
+	fakeTailscale(t, `{"OperatorUser": "twintail"}`)
+
+	checks := newTestDoctor(t).Run()
+
+	want := []string{CheckOK, CheckOK, CheckOK, CheckOK, CheckOK, CheckOK}
+	if got := statuses(checks); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected every check to pass, got %v: %+v", got, checks)
+	}
+	if checks[1].Detail != "1.90.1" || checks[5].Detail != "homelab.tail1234.ts.net" {
+		t.Errorf("unexpected details %q and %q", checks[1].Detail, checks[5].Detail)
+	}
+	if Failed(checks) {
+		t.Error("expected no failure")
+	}
+}
+
+func TestDoctor_NotOperator(t *testing.T) {
+	fakeTailscale(t, `{"OperatorUser": ""}`)
+
+	checks := newTestDoctor(t).Run()
+
+	operator := checks[3]
+	if operator.Status != CheckFail || !reflect.DeepEqual(operator.Fix, []string{"sudo tailscale set --operator=twintail"}) {
+		t.Errorf("expected the operator fix, got %+v", operator)
+	}
+	if checks[4].Status != CheckOK {
+		t.Errorf("expected serve to be checked anyway, got %+v", checks[4])
+	}
+	if !Failed(checks) {
+		t.Error("expected a failure")
+	}
+}
+
+func TestDoctor_Root(t *testing.T) {
+	fakeTailscale(t, `{}`)
+	d := newTestDoctor(t)
+	d.user = func() (int, string) { return 0, "root" }
+
+	checks := d.Run()
+
+	if checks[3].Status != CheckOK || checks[3].Message != "doctor.operator.root" {
+		t.Errorf("expected root to pass, got %+v", checks[3])
+	}
+}
+
+func TestDoctor_DaemonDown(t *testing.T) {
+	fakeTailscale(t, `{}`)
+	d := newTestDoctor(t)
+	d.socket = filepath.Join(t.TempDir(), "missing.sock")
+
+	checks := d.Run()
+
+	want := []string{CheckOK, CheckOK, CheckFail, CheckSkip, CheckSkip, CheckSkip}
+	if got := statuses(checks); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected the checks after the socket to be skipped, got %v", got)
+	}
+	if checks[2].Message != "doctor.socket.down" || checks[2].Fix[0] != "sudo systemctl enable --now tailscaled" {
+		t.Errorf("unexpected socket check %+v", checks[2])
+	}
+}
+
+func TestDoctor_MissingBinary(t *testing.T) {
+	d := newTestDoctor(t)
+	d.lookPath = func(string) (string, error) { return "", errors.New("not found") }
+
+	checks := d.Run()
+
+	if len(checks) != len(doctorChecks) || checks[0].Status != CheckFail || checks[1].Status != CheckSkip {
+		t.Errorf("expected only the binary check to run, got %+v", checks)
+	}
+}
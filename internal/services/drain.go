@@ -0,0 +1,214 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	ErrServiceDrained = errors.New("service is drained on this node")
+	ErrNotDrained     = errors.New("service is not drained")
+	ErrNothingToDrain = errors.New("service has no endpoints on this node")
+)
+
+// Drain keeps the endpoints of a service that was taken off this node so
+// that undraining advertises exactly the same config again.
+type Drain struct {
+	Service   string      `json:"service"`
+	Ports     []PortEntry `json:"ports"`
+	DrainedAt time.Time   `json:"drained_at"`
+}
+
+type DrainStore struct {
+	path    string
+	mu      sync.Mutex
+	entries []Drain
+}
+
+func NewDrainStore(dataDir string) (*DrainStore, error) {
+	if err := os.MkdirAll(dataDir, 0o750); err != nil {
+		return nil, err
+	}
+	s := &DrainStore{path: filepath.Join(dataDir, "drains.json")}
+	if err := loadJSON(s.path, &s.entries); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *DrainStore) save() error {
+	return saveJSON(s.path, s.entries)
+}
+
+func (s *DrainStore) Get(name string) (Drain, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range s.entries {
+		if d.Service == name {
+			return d, true
+		}
+	}
+	return Drain{}, false
+}
+
+func (s *DrainStore) All() []Drain {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Drain(nil), s.entries...)
+}
+
+func (s *DrainStore) put(d Drain) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.without(d.Service), d)
+	return s.save()
+}
+
+func (s *DrainStore) ForgetService(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = s.without(name)
+	return s.save()
+}
+
+// ForgetEndpoint drops an endpoint from the record of a drained service so
+// that undraining does not restore it. A record left without endpoints is
+// removed.
+func (s *DrainStore) ForgetEndpoint(params EndpointParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var kept []Drain
+	for _, d := range s.entries {
+		if d.Service == params.ServiceName {
+			var ports []PortEntry
+			for _, port := range d.Ports {
+				if port.Protocol != params.Protocol || port.ExposePort != params.ExposePort ||
+					normalizePath(port.Path) != normalizePath(params.Path) {
+					ports = append(ports, port)
+				}
+			}
+			if len(ports) == 0 {
+				continue
+			}
+			d.Ports = ports
+		}
+		kept = append(kept, d)
+	}
+	s.entries = kept
+	return s.save()
+}
+
+func (s *DrainStore) RenameService(oldName, newName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.entries {
+		if s.entries[i].Service == oldName {
+			s.entries[i].Service = newName
+		}
+	}
+	return s.save()
+}
+
+func (s *DrainStore) without(name string) []Drain {
+	kept := s.entries[:0:0]
+	for _, d := range s.entries {
+		if d.Service != name {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+type DrainExecutor interface {
+	DuplicateExecutor
+	GetServiceByName(name string) (*ServiceDetailView, error)
+	ClearService(name string) error
+}
+
+// Drain records the endpoints of name and then clears it from this node.
+// The record is written first so that a crash in between loses nothing.
+func (s *DrainStore) Drain(executor DrainExecutor, name string, now time.Time) (Drain, error) {
+	if _, ok := s.Get(name); ok {
+		return Drain{}, ErrServiceDrained
+	}
+	svc, err := executor.GetServiceByName(name)
+	if err != nil {
+		return Drain{}, err
+	}
+	if svc == nil || len(svc.Ports) == 0 {
+		return Drain{}, ErrNothingToDrain
+	}
+
+	d := Drain{Service: name, Ports: svc.Ports, DrainedAt: now}
+	if err := s.put(d); err != nil {
+		return Drain{}, err
+	}
+	if err := executor.ClearService(name); err != nil {
+		if forgetErr := s.ForgetService(name); forgetErr != nil {
+			return Drain{}, errors.Join(err, forgetErr)
+		}
+		return Drain{}, err
+	}
+	return d, nil
+}
+
+// Undrain advertises the recorded endpoints again. The record is kept when
+// any endpoint fails so that undraining can be retried.
+func (s *DrainStore) Undrain(executor DrainExecutor, name string) (DuplicateResult, error) {
+	d, ok := s.Get(name)
+	if !ok {
+		return DuplicateResult{}, ErrNotDrained
+	}
+	plan := PlanDuplicate(&ServiceDetailView{Name: name, Ports: d.Ports}, name, nil)
+	result := ExecuteDuplicate(executor, plan)
+	if !result.OK() {
+		return result, nil
+	}
+	return result, s.ForgetService(name)
+}
+
+// Guard wraps executor so that scheduled changes to a drained service fail
+// and are retried after it is undrained, instead of advertising it again.
+func (s *DrainStore) Guard(executor ScheduleExecutor) ScheduleExecutor {
+	return drainGuard{store: s, executor: executor}
+}
+
+type drainGuard struct {
+	store    *DrainStore
+	executor ScheduleExecutor
+}
+
+func (g drainGuard) AddEndpoint(params EndpointParams) error {
+	if _, ok := g.store.Get(params.ServiceName); ok {
+		return ErrServiceDrained
+	}
+	return g.executor.AddEndpoint(params)
+}
+
+func (g drainGuard) RemoveEndpoint(params EndpointParams) error {
+	if _, ok := g.store.Get(params.ServiceName); ok {
+		return ErrServiceDrained
+	}
+	return g.executor.RemoveEndpoint(params)
+}
+
+// GuardAdvertiser wraps executor so that container sync does not advertise
+// a drained service again.
+func (s *DrainStore) GuardAdvertiser(executor ContainerAdvertiser) ContainerAdvertiser {
+	return drainAdvertiserGuard{store: s, ContainerAdvertiser: executor}
+}
+
+type drainAdvertiserGuard struct {
+	ContainerAdvertiser
+	store *DrainStore
+}
+
+func (g drainAdvertiserGuard) AdvertiseService(params AdvertiseServiceParams) error {
+	if _, ok := g.store.Get(params.ServiceName); ok {
+		return ErrServiceDrained
+	}
+	return g.ContainerAdvertiser.AdvertiseService(params)
+}
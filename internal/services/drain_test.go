@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type mockDrainExecutor struct {
+	mockDuplicateExecutor
+	detail   *ServiceDetailView
+	cleared  []string
+	clearErr error
+}
+
+func (m *mockDrainExecutor) GetServiceByName(name string) (*ServiceDetailView, error) {
+	return m.detail, nil
+}
+
+func (m *mockDrainExecutor) ClearService(name string) error {
+	if m.clearErr != nil {
+		return m.clearErr
+	}
+	m.cleared = append(m.cleared, name)
+	return nil
+}
+
+func TestDrainStore_DrainAndUndrain(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewDrainStore(dir)
+	executor := &mockDrainExecutor{detail: duplicateSource}
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	if _, err := store.Drain(executor, "web", now); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(executor.cleared) != 1 {
+		t.Fatalf("expected the service to be cleared, got %v", executor.cleared)
+	}
+	if _, err := store.Drain(executor, "web", now); !errors.Is(err, ErrServiceDrained) {
+		t.Errorf("expected ErrServiceDrained, got %v", err)
+	}
+
+	reopened, _ := NewDrainStore(dir)
+	d, ok := reopened.Get("web")
+	if !ok || len(d.Ports) != 3 || !d.DrainedAt.Equal(now) {
+		t.Fatalf("expected the drain to persist, got %+v", d)
+	}
+
+	result, err := reopened.Undrain(executor, "web")
+	if err != nil || !result.OK() {
+		t.Fatalf("expected undrain to succeed, got %+v, %v", result, err)
+	}
+	if len(executor.advertised) != 1 || len(executor.added) != 2 || executor.added[0].Path != "/api" {
+		t.Errorf("expected the same endpoints back, got %+v %+v", executor.advertised, executor.added)
+	}
+	if _, ok := reopened.Get("web"); ok {
+		t.Error("expected the drain to be forgotten")
+	}
+	if _, err := reopened.Undrain(executor, "web"); !errors.Is(err, ErrNotDrained) {
+		t.Errorf("expected ErrNotDrained, got %v", err)
+	}
+}
+
+func TestDrainStore_ClearFails(t *testing.T) {
+	store, _ := NewDrainStore(t.TempDir())
+	executor := &mockDrainExecutor{detail: duplicateSource, clearErr: errors.New("locked")}
+
+	if _, err := store.Drain(executor, "web", time.Now()); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, ok := store.Get("web"); ok {
+		t.Error("expected no drain to be recorded")
+	}
+}
+
+func TestDrainStore_NothingToDrain(t *testing.T) {
+	store, _ := NewDrainStore(t.TempDir())
+
+	if _, err := store.Drain(&mockDrainExecutor{}, "web", time.Now()); !errors.Is(err, ErrNothingToDrain) {
+		t.Errorf("expected ErrNothingToDrain, got %v", err)
+	}
+}
+
+func TestDrainStore_UndrainKeepsRecordOnFailure(t *testing.T) {
+	store, _ := NewDrainStore(t.TempDir())
+	executor := &mockDrainExecutor{detail: duplicateSource}
+	store.Drain(executor, "web", time.Now())
+	executor.failPort = "5432"
+
+	result, err := store.Undrain(executor, "web")
+
+	if err != nil || result.OK() {
+		t.Fatalf("expected a partial failure, got %+v, %v", result, err)
+	}
+	if _, ok := store.Get("web"); !ok {
+		t.Error("expected the drain to be kept for a retry")
+	}
+}
+
+func TestDrainStore_Guard(t *testing.T) {
+	store, _ := NewDrainStore(t.TempDir())
+	store.Drain(&mockDrainExecutor{detail: duplicateSource}, "web", time.Now())
+	inner := &mockScheduleExecutor{}
+	guard := store.Guard(inner)
+
+	if err := guard.AddEndpoint(EndpointParams{ServiceName: "web"}); !errors.Is(err, ErrServiceDrained) {
+		t.Errorf("expected ErrServiceDrained, got %v", err)
+	}
+	if err := guard.RemoveEndpoint(EndpointParams{ServiceName: "wiki"}); err != nil || len(inner.removed) != 1 {
+		t.Errorf("expected other services to pass through, got %v", err)
+	}
+}
+
+func TestDrainStore_ForgetEndpoint(t *testing.T) {
+	store, _ := NewDrainStore(t.TempDir())
+	store.Drain(&mockDrainExecutor{detail: duplicateSource}, "web", time.Now())
+
+	store.ForgetEndpoint(EndpointParams{ServiceName: "web", Protocol: "https", ExposePort: "443", Path: "/api"})
+	if d, _ := store.Get("web"); len(d.Ports) != 2 || d.Ports[1].Protocol != "tcp" {
+		t.Fatalf("expected the /api endpoint to be dropped, got %+v", d.Ports)
+	}
+
+	store.ForgetEndpoint(EndpointParams{ServiceName: "web", Protocol: "https", ExposePort: "443"})
+	store.ForgetEndpoint(EndpointParams{ServiceName: "web", Protocol: "tcp", ExposePort: "5432"})
+	if _, ok := store.Get("web"); ok {
+		t.Error("expected a drain without endpoints to be removed")
+	}
+}
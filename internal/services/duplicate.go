@@ -0,0 +1,88 @@
+package services
+
+import "errors"
+
+var ErrServiceExists = errors.New("a service with that name already exists")
+
+type Rewrite struct {
+	From string
+	To   string
+}
+
+// Apply replaces the address of destination when it is exactly r.From, the
+// same way bulk repointing does.
+func (r Rewrite) Apply(destination string) string {
+	return ReplaceAddress(destination, r.From, r.To)
+}
+
+type DuplicatePlan struct {
+	Source    string
+	Target    string
+	Endpoints []EndpointParams
+}
+
+// PlanDuplicate copies every endpoint of source onto target, applying the
+// destination rewrites in order.
+func PlanDuplicate(source *ServiceDetailView, target string, rewrites []Rewrite) DuplicatePlan {
+	plan := DuplicatePlan{Source: source.Name, Target: target}
+	for _, port := range source.Ports {
+		dest := port.Destination
+		for _, r := range rewrites {
+			dest = r.Apply(dest)
+		}
+		plan.Endpoints = append(plan.Endpoints, EndpointParams{
+			ServiceName: target,
+			Protocol:    port.Protocol,
+			ExposePort:  port.ExposePort,
+			Path:        port.Path,
+			Destination: dest,
+		})
+	}
+	return plan
+}
+
+type DuplicateExecutor interface {
+	AdvertiseService(params AdvertiseServiceParams) error
+	AddEndpoint(params EndpointParams) error
+}
+
+type DuplicateFailure struct {
+	Endpoint EndpointParams
+	Err      error
+}
+
+type DuplicateResult struct {
+	Added  []EndpointParams
+	Failed []DuplicateFailure
+}
+
+func (r DuplicateResult) OK() bool {
+	return len(r.Failed) == 0
+}
+
+// ExecuteDuplicate advertises the target with the first endpoint that
+// succeeds and adds the rest to it. Failures do not stop the remaining
+// endpoints; the caller can roll back by clearing the target.
+func ExecuteDuplicate(executor DuplicateExecutor, plan DuplicatePlan) DuplicateResult {
+	var result DuplicateResult
+	for _, params := range plan.Endpoints {
+		var err error
+		if len(result.Added) == 0 {
+			err = executor.AdvertiseService(AdvertiseServiceParams{
+				ServiceName: params.ServiceName,
+				Protocol:    params.Protocol,
+				ExposePort:  params.ExposePort,
+				Path:        params.Path,
+				Destination: params.Destination,
+			})
+		} else {
+			err = executor.AddEndpoint(params)
+		}
+		if err != nil {
+			result.Failed = append(result.Failed, DuplicateFailure{Endpoint: params, Err: err})
+			continue
+		}
+		result.Added = append(result.Added, params)
+	}
+	return result
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+type mockDuplicateExecutor struct {
+	advertised []AdvertiseServiceParams
+	added      []EndpointParams
+	failPort   string
+}
+
+func (m *mockDuplicateExecutor) AdvertiseService(params AdvertiseServiceParams) error {
+	if params.ExposePort == m.failPort {
+		return errors.New("advertise failed")
+	}
+	m.advertised = append(m.advertised, params)
+	return nil
+}
+
+func (m *mockDuplicateExecutor) AddEndpoint(params EndpointParams) error {
+	if params.ExposePort == m.failPort {
+		return errors.New("add failed")
+	}
+	m.added = append(m.added, params)
+	return nil
+}
+
+var duplicateSource = &ServiceDetailView{
+	Name: "web",
+	Ports: []PortEntry{
+		{Protocol: "https", ExposePort: "443", Destination: "http://localhost:3000"},
+		{Protocol: "https", ExposePort: "443", Path: "/api", Destination: "http://localhost:4000"},
+		{Protocol: "tcp", ExposePort: "5432", Destination: "tcp://localhost:5432"},
+	},
+}
+
+func TestPlanDuplicate_Rewrites(t *testing.T) {
+	plan := PlanDuplicate(duplicateSource, "web-staging", []Rewrite{
+		{From: "localhost:3000", To: "localhost:3001"},
+		{From: "localhost:4000", To: "staging-api:4000"},
+	})
+
+	want := []string{"http://localhost:3001", "http://staging-api:4000", "tcp://localhost:5432"}
+	if len(plan.Endpoints) != len(want) {
+		t.Fatalf("expected %d endpoints, got %+v", len(want), plan.Endpoints)
+	}
+	for i, ep := range plan.Endpoints {
+		if ep.ServiceName != "web-staging" || ep.Destination != want[i] {
+			t.Errorf("endpoint %d: expected web-staging → %s, got %+v", i, want[i], ep)
+		}
+	}
+	if plan.Endpoints[1].Path != "/api" {
+		t.Errorf("expected path to be kept, got %q", plan.Endpoints[1].Path)
+	}
+}
+
+func TestExecuteDuplicate(t *testing.T) {
+	executor := &mockDuplicateExecutor{}
+	result := ExecuteDuplicate(executor, PlanDuplicate(duplicateSource, "copy", nil))
+
+	if !result.OK() || len(result.Added) != 3 {
+		t.Fatalf("expected all endpoints to be copied, got %+v", result)
+	}
+	if len(executor.advertised) != 1 || len(executor.added) != 2 {
+		t.Errorf("expected one advertise and two adds, got %d and %d", len(executor.advertised), len(executor.added))
+	}
+}
+
+func TestExecuteDuplicate_FirstFailsAdvertisesNext(t *testing.T) {
+	executor := &mockDuplicateExecutor{failPort: "443"}
+	result := ExecuteDuplicate(executor, PlanDuplicate(duplicateSource, "copy", nil))
+
+	if result.OK() || len(result.Failed) != 2 {
+		t.Fatalf("expected both 443 endpoints to fail, got %+v", result)
+	}
+	if len(executor.advertised) != 1 || executor.advertised[0].ExposePort != "5432" {
+		t.Errorf("expected the tcp endpoint to advertise the copy, got %+v", executor.advertised)
+	}
+	if len(result.Added) != 1 {
+		t.Errorf("expected one endpoint to be added, got %+v", result.Added)
+	}
+}
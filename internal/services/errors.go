@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrServiceQuotaExceeded = errors.New("tailnet service limit reached")
+	ErrDaemonDown           = errors.New("tailscaled is not running")
+	ErrNeedsLogin           = errors.New("tailscale is logged out")
+	ErrPermissionDenied     = errors.New("not allowed to change the serve config")
+	ErrServiceNotDefined    = errors.New("service is not defined in the admin console")
+	ErrPortConflict         = errors.New("port is already in use")
+)
+
+// Error kinds name the failures the dashboard explains with their own page.
+const (
+	ErrorKindNotInstalled       = "not_installed"
+	ErrorKindDaemonDown         = "daemon_down"
+	ErrorKindNeedsLogin         = "needs_login"
+	ErrorKindPermissionDenied   = "permission_denied"
+	ErrorKindServiceNotDefined  = "service_not_defined"
+	ErrorKindPortConflict       = "port_conflict"
+	ErrorKindQuotaExceeded      = "quota_exceeded"
+	ErrorKindUnsupportedVersion = "unsupported_version"
+	ErrorKindUnknown            = "unknown"
+)
+
+// outputPatterns map lower-cased tailscale CLI output to its cause. The
+// first match wins, so a socket permission error is not mistaken for the
+// daemon being down. Patterns are whole phrases of the tailscale messages,
+// since short words such as "quota" also show up in unrelated errors.
+var outputPatterns = []struct {
+	err      error
+	patterns []string
+}{
+	{ErrPermissionDenied, []string{
+		"access denied",
+		"permission denied",
+		"must be root",
+		"--operator",
+	}},
+	{ErrDaemonDown, []string{
+		"failed to connect to local tailscale",
+		"is tailscaled running",
+		"doesn't appear to be running",
+		"tailscaled.sock",
+	}},
+	{ErrNeedsLogin, []string{
+		"logged out",
+		"needslogin",
+		"not logged in",
+		"tailscale is stopped",
+	}},
+	{ErrServiceQuotaExceeded, []string{
+		"service quota exceeded",
+		"service limit reached",
+		"maximum number of services",
+		"too many services",
+	}},
+	{ErrServiceNotDefined, []string{
+		"service not found",
+		"unknown service",
+		"no such service",
+		"is not defined in the tailnet",
+		"must be defined in the admin console",
+	}},
+	{ErrPortConflict, []string{
+		"address already in use",
+		"already serving",
+		"listener already exists",
+		"port is already in use",
+		"conflicts with an existing",
+	}},
+}
+
+func classifyOutput(output string) error {
+	lower := strings.ToLower(output)
+	for _, group := range outputPatterns {
+		for _, pattern := range group.patterns {
+			if strings.Contains(lower, pattern) {
+				return group.err
+			}
+		}
+	}
+	return nil
+}
+
+// ClassifyError returns the error kind of err.
+func ClassifyError(err error) string {
+	switch {
+	case IsTailscaleNotInstalledError(err):
+		return ErrorKindNotInstalled
+	case errors.Is(err, ErrUnsupportedVersion):
+		return ErrorKindUnsupportedVersion
+	case errors.Is(err, ErrPermissionDenied):
+		return ErrorKindPermissionDenied
+	case errors.Is(err, ErrDaemonDown):
+		return ErrorKindDaemonDown
+	case errors.Is(err, ErrNeedsLogin):
+		return ErrorKindNeedsLogin
+	case errors.Is(err, ErrServiceQuotaExceeded):
+		return ErrorKindQuotaExceeded
+	case errors.Is(err, ErrServiceNotDefined):
+		return ErrorKindServiceNotDefined
+	case errors.Is(err, ErrPortConflict):
+		return ErrorKindPortConflict
+	}
+	return ErrorKindUnknown
+}
+
+// ErrorMessageKey returns the locale key describing err, or "" when err has
+// no friendly message.
+func ErrorMessageKey(err error) string {
+	if kind := ClassifyError(err); kind != ErrorKindUnknown {
+		return "errors." + kind
+	}
+	return ""
+}
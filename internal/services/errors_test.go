@@ -0,0 +1,83 @@
+package services
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestNewCommandError_ClassifiesQuota(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   bool
+	}{
+		{"quota message", "error: service quota exceeded for tailnet", true},
+		{"limit message", "backend error: maximum number of services reached", true},
+		{"unrelated", "error: invalid port", false},
+		{"rate limit", "error: rate limit exceeded, retry later", false},
+		{"disk quota", "write /var/lib/tailscale/serve.json: disk quota exceeded", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newCommandError([]byte(tt.output), errors.New("exit status 1"))
+			if got := errors.Is(err, ErrServiceQuotaExceeded); got != tt.want {
+				t.Errorf("errors.Is(ErrServiceQuotaExceeded) = %v, want %v", got, tt.want)
+			}
+			if err.Error() != tt.output {
+				t.Errorf("expected raw output as message, got '%s'", err.Error())
+			}
+		})
+	}
+}
+
+func TestErrorMessageKey(t *testing.T) {
+	quotaErr := newCommandError([]byte("service limit reached"), errors.New("exit status 1"))
+	if got := ErrorMessageKey(quotaErr); got != "errors.quota_exceeded" {
+		t.Errorf("expected 'errors.quota_exceeded', got '%s'", got)
+	}
+	if got := ErrorMessageKey(errors.New("other")); got != "" {
+		t.Errorf("expected empty key, got '%s'", got)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"failed to connect to local tailscaled; it doesn't appear to be running (sudo systemctl start tailscaled ?)", ErrorKindDaemonDown},
+		{"failed to connect to local Tailscale daemon for /localapi/v0/serve-config; not running? Error: dial unix /var/run/tailscale/tailscaled.sock: connect: no such file or directory", ErrorKindDaemonDown},
+		{"dial unix /var/run/tailscale/tailscaled.sock: connect: permission denied", ErrorKindPermissionDenied},
+		{"sending serve config: Access denied: serve config denied\nUse 'sudo tailscale serve' or 'tailscale set --operator=$USER'", ErrorKindPermissionDenied},
+		{"Logged out.\nLog in at: https://login.tailscale.com/a/abc", ErrorKindNeedsLogin},
+		{"Tailscale is stopped.", ErrorKindNeedsLogin},
+		{"service \"svc:web\" is not defined in the tailnet", ErrorKindServiceNotDefined},
+		{"foreground listener already exists for port 443", ErrorKindPortConflict},
+		{"backend error: maximum number of services reached", ErrorKindQuotaExceeded},
+		{"error: invalid port", ErrorKindUnknown},
+		{"flag provided but not defined: -service", ErrorKindUnknown},
+		{"--https conflicts with --tcp", ErrorKindUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			err := newCommandError([]byte(tt.output), errors.New("exit status 1"))
+			if got := ClassifyError(err); got != tt.want {
+				t.Errorf("ClassifyError(%q) = %s, want %s", tt.output, got, tt.want)
+			}
+		})
+	}
+	if got := ClassifyError(ErrTailscaleNotInstalled); got != ErrorKindNotInstalled {
+		t.Errorf("expected not_installed, got %s", got)
+	}
+}
+
+func TestOutputError_KeepsStderr(t *testing.T) {
+	_, err := exec.Command("sh", "-c", "echo 'Logged out.' >&2; exit 1").Output()
+
+	if got := outputError(err); !errors.Is(got, ErrNeedsLogin) || got.Error() != "Logged out.\n" {
+		t.Errorf("expected a classified CommandError, got %v", got)
+	}
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	ExpireService  = "service"
+	ExpireEndpoint = "endpoint"
+)
+
+var (
+	ErrExpiryNotFound  = errors.New("expiry not found")
+	ErrExpiryProtected = errors.New("service serves twintail and cannot expire")
+)
+
+type Expiry struct {
+	ID        string         `json:"id"`
+	Kind      string         `json:"kind"`
+	Endpoint  EndpointParams `json:"endpoint"`
+	ExpiresAt time.Time      `json:"expires_at"`
+}
+
+func (e Expiry) ServiceName() string {
+	return e.Endpoint.ServiceName
+}
+
+// RemainingText formats the time left as "1h 5m", rounded up to the minute.
+func (e Expiry) RemainingText() string {
+	d := time.Until(e.ExpiresAt)
+	if d <= 0 {
+		return "0m"
+	}
+	minutes := int((d + time.Minute - 1) / time.Minute)
+	if minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh %dm", minutes/60, minutes%60)
+}
+
+func (e Expiry) Matches(port PortEntry) bool {
+	return e.Kind == ExpireEndpoint &&
+		e.Endpoint.Protocol == port.Protocol &&
+		e.Endpoint.ExposePort == port.ExposePort &&
+		normalizePath(e.Endpoint.Path) == normalizePath(port.Path)
+}
+
+func normalizePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+type ExpiryStore struct {
+	path    string
+	mu      sync.Mutex
+	entries []Expiry
+}
+
+// NewExpiryStore loads the expiries saved in dataDir, creating the
+// directory when needed.
+func NewExpiryStore(dataDir string) (*ExpiryStore, error) {
+	if err := os.MkdirAll(dataDir, 0o750); err != nil {
+		return nil, err
+	}
+	s := &ExpiryStore{path: filepath.Join(dataDir, "expiry.json")}
+	if err := loadJSON(s.path, &s.entries); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *ExpiryStore) save() error {
+	return saveJSON(s.path, s.entries)
+}
+
+func (s *ExpiryStore) Add(kind string, params EndpointParams, expiresAt time.Time) (Expiry, error) {
+	id, err := newStoreID()
+	if err != nil {
+		return Expiry{}, err
+	}
+	e := Expiry{ID: id, Kind: kind, Endpoint: params, ExpiresAt: expiresAt}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = s.without(func(old Expiry) bool {
+		return old.Kind == kind && old.Endpoint.ServiceName == params.ServiceName &&
+			(kind == ExpireService || old.Matches(PortEntry{Protocol: params.Protocol, ExposePort: params.ExposePort, Path: params.Path}))
+	})
+	s.entries = append(s.entries, e)
+	return e, s.save()
+}
+
+// Extend pushes the expiry back by d, counting from now if it already passed.
+func (s *ExpiryStore) Extend(id string, d time.Duration, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.entries {
+		if s.entries[i].ID != id {
+			continue
+		}
+		base := s.entries[i].ExpiresAt
+		if base.Before(now) {
+			base = now
+		}
+		s.entries[i].ExpiresAt = base.Add(d)
+		return s.save()
+	}
+	return ErrExpiryNotFound
+}
+
+func (s *ExpiryStore) Get(id string) (Expiry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if e.ID == id {
+			return e, true
+		}
+	}
+	return Expiry{}, false
+}
+
+func (s *ExpiryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = s.without(func(e Expiry) bool { return e.ID == id })
+	return s.save()
+}
+
+// ForgetService drops every expiry of a service that was removed by hand.
+func (s *ExpiryStore) ForgetService(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = s.without(func(e Expiry) bool { return e.ServiceName() == name })
+	return s.save()
+}
+
+func (s *ExpiryStore) ForgetEndpoint(params EndpointParams) error {
+	port := PortEntry{Protocol: params.Protocol, ExposePort: params.ExposePort, Path: params.Path}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = s.without(func(e Expiry) bool {
+		return e.ServiceName() == params.ServiceName && e.Matches(port)
+	})
+	return s.save()
+}
+
+// RenameService moves every expiry of a renamed service to its new name.
+func (s *ExpiryStore) RenameService(oldName, newName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.entries {
+		if s.entries[i].ServiceName() == oldName {
+			s.entries[i].Endpoint.ServiceName = newName
+		}
+	}
+	return s.save()
+}
+
+// UpdateEndpoint points the expiry of an edited endpoint at its new
+// destination, which removing the endpoint needs.
+func (s *ExpiryStore) UpdateEndpoint(params UpdateEndpointParams) error {
+	port := PortEntry{Protocol: params.Protocol, ExposePort: params.ExposePort, Path: params.Path}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.entries {
+		if s.entries[i].ServiceName() == params.ServiceName && s.entries[i].Matches(port) {
+			s.entries[i].Endpoint.Destination = params.NewDestination
+		}
+	}
+	return s.save()
+}
+
+func (s *ExpiryStore) ForService(name string) []Expiry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []Expiry
+	for _, e := range s.entries {
+		if e.ServiceName() == name {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func (s *ExpiryStore) Due(now time.Time) []Expiry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []Expiry
+	for _, e := range s.entries {
+		if !e.ExpiresAt.After(now) {
+			due = append(due, e)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].ExpiresAt.Before(due[j].ExpiresAt)
+	})
+	return due
+}
+
+func (s *ExpiryStore) without(drop func(Expiry) bool) []Expiry {
+	kept := s.entries[:0:0]
+	for _, e := range s.entries {
+		if !drop(e) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+type ExpiryExecutor interface {
+	GetServiceByName(name string) (*ServiceDetailView, error)
+	RemoveEndpoint(params EndpointParams) error
+	ClearService(name string) error
+}
+
+type ExpiryResult struct {
+	Expiry Expiry
+	Err    error
+}
+
+// RunDueExpiries removes everything that expired at or before now and
+// forgets the other records of what was removed. A drained service is not
+// advertised, so only its drain record is updated and undraining does not
+// restore what expired. Failed removals stay in the store and are retried on
+// the next run, unless the endpoint is already gone, for instance because a
+// schedule turned it off. Expiries of protected services are dropped without removing
+// anything, so the dashboard never takes itself off the tailnet.
+func RunDueExpiries(stores Stores, executor ExpiryExecutor, protected func(name string) bool, now time.Time) []ExpiryResult {
+	store := stores.Expiries
+	var results []ExpiryResult
+	for _, e := range store.Due(now) {
+		if _, ok := store.Get(e.ID); !ok {
+			continue
+		}
+		if protected(e.ServiceName()) {
+			results = append(results, ExpiryResult{Expiry: e, Err: errors.Join(ErrExpiryProtected, store.Delete(e.ID))})
+			continue
+		}
+		drained := stores.CheckDrained(e.ServiceName()) != nil
+		var err error
+		if e.Kind == ExpireService {
+			if !drained {
+				err = executor.ClearService(e.ServiceName())
+			}
+			if err == nil {
+				err = stores.ForgetService(e.ServiceName())
+			}
+		} else {
+			if !drained {
+				err = executor.RemoveEndpoint(e.Endpoint)
+				if err != nil && endpointGone(executor, e.Endpoint) {
+					err = nil
+				}
+			}
+			if err == nil {
+				err = errors.Join(store.Delete(e.ID), stores.ForgetEndpoint(e.Endpoint))
+			}
+		}
+		results = append(results, ExpiryResult{Expiry: e, Err: err})
+	}
+	return results
+}
+
+// endpointGone reports whether the serve config no longer has params.
+func endpointGone(executor ExpiryExecutor, params EndpointParams) bool {
+	svc, err := executor.GetServiceByName(params.ServiceName)
+	if err != nil {
+		return false
+	}
+	if svc == nil {
+		return true
+	}
+	for _, port := range svc.Ports {
+		if port.Protocol == params.Protocol && port.ExposePort == params.ExposePort && normalizePath(port.Path) == normalizePath(params.Path) {
+			return false
+		}
+	}
+	return true
+}
+
+// RunExpiryScheduler runs due expiries right away, so that anything that
+// expired while twintail was stopped is removed, and then every interval.
+func RunExpiryScheduler(ctx context.Context, stores Stores, executor ExpiryExecutor, protected func(name string) bool, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, r := range RunDueExpiries(stores, executor, protected, time.Now()) {
+			if r.Err != nil {
+				logger.Error("failed to remove expired exposure", "service", r.Expiry.ServiceName(), "kind", r.Expiry.Kind, "error", r.Err)
+			} else {
+				logger.Info("removed expired exposure", "service", r.Expiry.ServiceName(), "kind", r.Expiry.Kind)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
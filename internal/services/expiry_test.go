@@ -0,0 +1,272 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type mockExpiryExecutor struct {
+	removed []EndpointParams
+	cleared []string
+	err     error
+	// details is what the serve config holds; nil services are absent.
+	details map[string]*ServiceDetailView
+}
+
+func (m *mockExpiryExecutor) GetServiceByName(name string) (*ServiceDetailView, error) {
+	return m.details[name], nil
+}
+
+func (m *mockExpiryExecutor) RemoveEndpoint(params EndpointParams) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.removed = append(m.removed, params)
+	return nil
+}
+
+func (m *mockExpiryExecutor) ClearService(name string) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.cleared = append(m.cleared, name)
+	return nil
+}
+
+func unprotected(string) bool { return false }
+
+func TestExpiryStore_PersistsAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	store, err := NewExpiryStore(dir)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	added, err := store.Add(ExpireEndpoint, EndpointParams{ServiceName: "dev", Protocol: "https", ExposePort: "443", Destination: "http://localhost:5173"}, now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	reopened, err := NewExpiryStore(dir)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	entries := reopened.ForService("dev")
+	if len(entries) != 1 || entries[0].ID != added.ID || !entries[0].ExpiresAt.Equal(added.ExpiresAt) {
+		t.Fatalf("expected expiry to survive reopening, got %+v", entries)
+	}
+
+	executor := &mockExpiryExecutor{}
+	if results := RunDueExpiries(Stores{Expiries: reopened}, executor, unprotected, now.Add(time.Hour)); len(results) != 0 {
+		t.Errorf("expected nothing due yet, got %+v", results)
+	}
+	results := RunDueExpiries(Stores{Expiries: reopened}, executor, unprotected, now.Add(3*time.Hour))
+	if len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("expected one successful removal, got %+v", results)
+	}
+	if len(executor.removed) != 1 || executor.removed[0].Destination != "http://localhost:5173" {
+		t.Errorf("expected endpoint to be removed, got %+v", executor.removed)
+	}
+	if len(reopened.ForService("dev")) != 0 {
+		t.Error("expected expiry to be deleted after removal")
+	}
+}
+
+func TestRunDueExpiries_ServiceClearsEndpointExpiries(t *testing.T) {
+	store, _ := NewExpiryStore(t.TempDir())
+	now := time.Now()
+	store.Add(ExpireService, EndpointParams{ServiceName: "dev"}, now.Add(-time.Minute))
+	store.Add(ExpireEndpoint, EndpointParams{ServiceName: "dev", Protocol: "https", ExposePort: "443"}, now.Add(-time.Second))
+
+	executor := &mockExpiryExecutor{}
+	results := RunDueExpiries(Stores{Expiries: store}, executor, unprotected, now)
+
+	if len(results) != 1 {
+		t.Fatalf("expected only the service expiry to run, got %+v", results)
+	}
+	if len(executor.cleared) != 1 || executor.cleared[0] != "dev" || len(executor.removed) != 0 {
+		t.Errorf("expected dev to be cleared only, got cleared=%v removed=%v", executor.cleared, executor.removed)
+	}
+	if len(store.ForService("dev")) != 0 {
+		t.Error("expected all expiries of dev to be forgotten")
+	}
+}
+
+func TestRunDueExpiries_ForgetsOtherRecords(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	expiries, _ := NewExpiryStore(dir)
+	schedules, _ := NewScheduleStore(dir)
+	api := EndpointParams{ServiceName: "dev", Protocol: "https", ExposePort: "443", Path: "/api", Destination: "http://localhost:4000"}
+	expiries.Add(ExpireEndpoint, api, now.Add(-time.Minute))
+	schedules.Add(api, "0 9 * * *", "0 17 * * *", now)
+	schedules.Add(EndpointParams{ServiceName: "dev", Protocol: "https", ExposePort: "443", Destination: "http://localhost:3000"}, "0 9 * * *", "0 17 * * *", now)
+
+	results := RunDueExpiries(Stores{Expiries: expiries, Schedules: schedules}, &mockExpiryExecutor{}, unprotected, now)
+
+	if len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("expected one successful removal, got %+v", results)
+	}
+	if left := schedules.ForService("dev"); len(left) != 1 || left[0].Endpoint.Path != "" {
+		t.Errorf("expected only the schedule of the expired endpoint to be forgotten, got %+v", left)
+	}
+}
+
+func TestRunDueExpiries_UpdatesDrains(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	expiries, _ := NewExpiryStore(dir)
+	drains, _ := NewDrainStore(dir)
+	root := PortEntry{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3000"}
+	api := PortEntry{Protocol: "https", ExposePort: "443", Path: "/api", Destination: "http://localhost:4000"}
+	drains.put(Drain{Service: "dev", Ports: []PortEntry{root, api}, DrainedAt: now})
+	drains.put(Drain{Service: "old", Ports: []PortEntry{root}, DrainedAt: now})
+	expiries.Add(ExpireEndpoint, api.params("dev"), now.Add(-time.Minute))
+	expiries.Add(ExpireService, EndpointParams{ServiceName: "old"}, now.Add(-time.Minute))
+
+	executor := &mockExpiryExecutor{}
+	results := RunDueExpiries(Stores{Expiries: expiries, Drains: drains}, executor, unprotected, now)
+
+	if len(results) != 2 || results[0].Err != nil || results[1].Err != nil {
+		t.Fatalf("expected two successful expiries, got %+v", results)
+	}
+	if len(executor.removed)+len(executor.cleared) != 0 {
+		t.Errorf("expected drained services to be left alone, got %v %v", executor.removed, executor.cleared)
+	}
+	if d, ok := drains.Get("dev"); !ok || len(d.Ports) != 1 || d.Ports[0].Path != "/" {
+		t.Errorf("expected the expired endpoint to leave the drain record, got %+v", d)
+	}
+	if _, ok := drains.Get("old"); ok {
+		t.Error("expected the expired service to leave the drain records")
+	}
+	if len(expiries.Due(now)) != 0 {
+		t.Error("expected both expiries to be done")
+	}
+}
+
+func TestRunDueExpiries_EndpointAlreadyGone(t *testing.T) {
+	store, _ := NewExpiryStore(t.TempDir())
+	now := time.Now()
+	store.Add(ExpireEndpoint, EndpointParams{ServiceName: "dev", Protocol: "https", ExposePort: "443", Path: "/api"}, now.Add(-time.Minute))
+
+	executor := &mockExpiryExecutor{
+		err: errors.New("handler does not exist"),
+		details: map[string]*ServiceDetailView{"dev": {Name: "dev", Ports: []PortEntry{
+			{Protocol: "https", ExposePort: "443", Destination: "http://localhost:3000"},
+		}}},
+	}
+	results := RunDueExpiries(Stores{Expiries: store}, executor, unprotected, now)
+
+	if len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("expected the expiry to be done, got %+v", results)
+	}
+	if len(store.ForService("dev")) != 0 {
+		t.Error("expected the expiry of the absent endpoint to be forgotten")
+	}
+}
+
+func TestRunDueExpiries_EndpointFailureIsRetried(t *testing.T) {
+	store, _ := NewExpiryStore(t.TempDir())
+	now := time.Now()
+	store.Add(ExpireEndpoint, EndpointParams{ServiceName: "dev", Protocol: "https", ExposePort: "443", Path: "/api"}, now.Add(-time.Minute))
+
+	executor := &mockExpiryExecutor{
+		err: errors.New("permission denied"),
+		details: map[string]*ServiceDetailView{"dev": {Name: "dev", Ports: []PortEntry{
+			{Protocol: "https", ExposePort: "443", Path: "/api", Destination: "http://localhost:4000"},
+		}}},
+	}
+	results := RunDueExpiries(Stores{Expiries: store}, executor, unprotected, now)
+
+	if len(results) != 1 || results[0].Err == nil {
+		t.Fatalf("expected a failed result, got %+v", results)
+	}
+	if len(store.Due(now)) != 1 {
+		t.Error("expected the expiry of a still served endpoint to stay in the store")
+	}
+}
+
+func TestRunDueExpiries_FailureIsRetried(t *testing.T) {
+	store, _ := NewExpiryStore(t.TempDir())
+	now := time.Now()
+	store.Add(ExpireService, EndpointParams{ServiceName: "dev"}, now.Add(-time.Minute))
+
+	executor := &mockExpiryExecutor{err: errors.New("tailscaled not running")}
+	results := RunDueExpiries(Stores{Expiries: store}, executor, unprotected, now)
+	if len(results) != 1 || results[0].Err == nil {
+		t.Fatalf("expected a failed result, got %+v", results)
+	}
+	if len(store.Due(now)) != 1 {
+		t.Fatal("expected failed expiry to stay in the store")
+	}
+
+	executor.err = nil
+	RunDueExpiries(Stores{Expiries: store}, executor, unprotected, now)
+	if len(executor.cleared) != 1 {
+		t.Errorf("expected retry to clear the service, got %v", executor.cleared)
+	}
+}
+
+func TestExpiryStore_Extend(t *testing.T) {
+	store, _ := NewExpiryStore(t.TempDir())
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	future, _ := store.Add(ExpireService, EndpointParams{ServiceName: "a"}, now.Add(time.Hour))
+	past, _ := store.Add(ExpireService, EndpointParams{ServiceName: "b"}, now.Add(-time.Hour))
+
+	if err := store.Extend(future.ID, 2*time.Hour, now); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := store.Extend(past.ID, 2*time.Hour, now); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if e, _ := store.Get(future.ID); !e.ExpiresAt.Equal(now.Add(3 * time.Hour)) {
+		t.Errorf("expected future expiry to move to 15:00, got %v", e.ExpiresAt)
+	}
+	if e, _ := store.Get(past.ID); !e.ExpiresAt.Equal(now.Add(2 * time.Hour)) {
+		t.Errorf("expected past expiry to count from now, got %v", e.ExpiresAt)
+	}
+	if err := store.Extend("missing", time.Hour, now); !errors.Is(err, ErrExpiryNotFound) {
+		t.Errorf("expected ErrExpiryNotFound, got %v", err)
+	}
+}
+
+func TestExpiryStore_AddReplacesSameTarget(t *testing.T) {
+	store, _ := NewExpiryStore(t.TempDir())
+	now := time.Now()
+	params := EndpointParams{ServiceName: "dev", Protocol: "https", ExposePort: "443", Path: "/"}
+	store.Add(ExpireEndpoint, params, now.Add(time.Hour))
+	store.Add(ExpireEndpoint, EndpointParams{ServiceName: "dev", Protocol: "https", ExposePort: "443"}, now.Add(2*time.Hour))
+
+	if entries := store.ForService("dev"); len(entries) != 1 {
+		t.Errorf("expected a single expiry for the endpoint, got %+v", entries)
+	}
+
+	if err := store.ForgetEndpoint(params); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if entries := store.ForService("dev"); len(entries) != 0 {
+		t.Errorf("expected endpoint expiry to be forgotten, got %+v", entries)
+	}
+}
+
+func TestRunDueExpiries_SkipsProtected(t *testing.T) {
+	store, _ := NewExpiryStore(t.TempDir())
+	now := time.Now()
+	store.Add(ExpireService, EndpointParams{ServiceName: "twintail"}, now.Add(-time.Minute))
+
+	executor := &mockExpiryExecutor{}
+	results := RunDueExpiries(Stores{Expiries: store}, executor, func(name string) bool { return name == "twintail" }, now)
+
+	if len(results) != 1 || !errors.Is(results[0].Err, ErrExpiryProtected) {
+		t.Fatalf("expected the expiry to be refused, got %+v", results)
+	}
+	if len(executor.cleared) != 0 {
+		t.Errorf("expected twintail to stay on air, got %v", executor.cleared)
+	}
+	if len(store.Due(now)) != 0 {
+		t.Error("expected the expiry to be dropped")
+	}
+}
@@ -0,0 +1,383 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrNodeNotFound     = errors.New("node not found")
+	ErrServiceNotOnNode = errors.New("service is not advertised by this node")
+)
+
+// NodeService is what the hub needs from each node, whether it is the local
+// TailscaleService or an AgentClient.
+type NodeService interface {
+	GetServeStatus() ([]ServiceView, error)
+	GetServiceByName(name string) (*ServiceDetailView, error)
+	AddEndpoint(params EndpointParams) error
+	RemoveEndpoint(params EndpointParams) error
+	UpdateEndpoint(params UpdateEndpointParams) error
+}
+
+type Node struct {
+	Name    string
+	Service NodeService
+}
+
+// ParseNodes reads agent entries of the form name=https://host:8077.
+func ParseNodes(entries []string, token string) ([]Node, error) {
+	var nodes []Node
+	for _, entry := range entries {
+		name, rawURL, ok := strings.Cut(entry, "=")
+		name, rawURL = strings.TrimSpace(name), strings.TrimSpace(rawURL)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("node %q must be name=url", entry)
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("node %s has invalid URL %q", name, rawURL)
+		}
+		nodes = append(nodes, Node{Name: name, Service: NewAgentClient(rawURL, token)})
+	}
+	return nodes, nil
+}
+
+type NodeStatus struct {
+	Name     string
+	Services []ServiceView
+	Err      error
+}
+
+// Backend is one node advertising a service.
+type Backend struct {
+	Node         string
+	Destinations []string
+}
+
+type HubService struct {
+	Name     string
+	Backends []Backend
+}
+
+func (s HubService) BackedBy(node string) bool {
+	for _, b := range s.Backends {
+		if b.Node == node {
+			return true
+		}
+	}
+	return false
+}
+
+type Hub struct {
+	nodes   []Node
+	resolve func(svc *ServiceDetailView) *ServiceDetailView
+}
+
+// NewHub manages nodes, the first of which is the one the hub runs on.
+func NewHub(nodes []Node) (*Hub, error) {
+	seen := map[string]bool{}
+	for _, n := range nodes {
+		if seen[n.Name] {
+			return nil, fmt.Errorf("node %s is listed twice", n.Name)
+		}
+		seen[n.Name] = true
+	}
+	return &Hub{nodes: nodes}, nil
+}
+
+// SetResolver makes the hub read the service details of its own node through
+// resolve, so that destinations which only work on this node, such as a
+// measuring proxy, are neither compared with nor copied to the other nodes.
+func (h *Hub) SetResolver(resolve func(svc *ServiceDetailView) *ServiceDetailView) {
+	h.resolve = resolve
+}
+
+func (h *Hub) Nodes() []Node {
+	return h.nodes
+}
+
+func (h *Hub) Local() Node {
+	if len(h.nodes) == 0 {
+		return Node{}
+	}
+	return h.nodes[0]
+}
+
+func (h *Hub) Node(name string) (Node, error) {
+	for _, n := range h.nodes {
+		if n.Name == name {
+			return n, nil
+		}
+	}
+	return Node{}, ErrNodeNotFound
+}
+
+// Status reads every node in parallel and groups their services by name.
+// Nodes that cannot be reached are reported in their NodeStatus and left
+// out of the services.
+func (h *Hub) Status() ([]NodeStatus, []HubService) {
+	statuses := make([]NodeStatus, len(h.nodes))
+	var wg sync.WaitGroup
+	for i, n := range h.nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			svcs, err := n.Service.GetServeStatus()
+			statuses[i] = NodeStatus{Name: n.Name, Services: svcs, Err: err}
+		}()
+	}
+	wg.Wait()
+
+	byName := map[string]*HubService{}
+	var names []string
+	for _, status := range statuses {
+		for _, svc := range status.Services {
+			hs, ok := byName[svc.Name]
+			if !ok {
+				hs = &HubService{Name: svc.Name}
+				byName[svc.Name] = hs
+				names = append(names, svc.Name)
+			}
+			hs.Backends = append(hs.Backends, Backend{Node: status.Name, Destinations: svc.Destinations})
+		}
+	}
+	sort.Strings(names)
+
+	hubServices := make([]HubService, 0, len(names))
+	for _, name := range names {
+		hubServices = append(hubServices, *byName[name])
+	}
+	return statuses, hubServices
+}
+
+// ServiceHost is a node's view of one service. Nodes that do not serve it
+// are left out; nodes that cannot be read are reported with Err.
+type ServiceHost struct {
+	Node   string
+	Ports  []PortEntry
+	InSync bool
+	Err    error
+}
+
+// configSignature identifies the endpoints of a host regardless of order.
+func configSignature(ports []PortEntry) string {
+	entries := make([]string, len(ports))
+	for i, port := range ports {
+		entries[i] = port.Key() + "=" + port.Destination
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, "\n")
+}
+
+// Hosts reads name from every node in parallel. A host is in sync when its
+// endpoints match the config most hosts share, preferring the earlier node
+// on a tie.
+func (h *Hub) Hosts(name string) []ServiceHost {
+	details := make([]*ServiceDetailView, len(h.nodes))
+	errs := make([]error, len(h.nodes))
+	var wg sync.WaitGroup
+	for i, n := range h.nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			details[i], errs[i] = n.Service.GetServiceByName(name)
+		}()
+	}
+	wg.Wait()
+	if len(details) > 0 && h.resolve != nil {
+		details[0] = h.resolve(details[0])
+	}
+
+	var hosts []ServiceHost
+	count := map[string]int{}
+	reference := ""
+	for i, n := range h.nodes {
+		if errs[i] != nil {
+			hosts = append(hosts, ServiceHost{Node: n.Name, Err: errs[i]})
+			continue
+		}
+		if details[i] == nil || len(details[i].Ports) == 0 {
+			continue
+		}
+		hosts = append(hosts, ServiceHost{Node: n.Name, Ports: details[i].Ports})
+		sig := configSignature(details[i].Ports)
+		count[sig]++
+		if count[sig] > count[reference] {
+			reference = sig
+		}
+	}
+	for i := range hosts {
+		hosts[i].InSync = hosts[i].Err == nil && configSignature(hosts[i].Ports) == reference
+	}
+	return hosts
+}
+
+// CanSync reports whether from hosts the service alongside another node.
+func CanSync(hosts []ServiceHost, from string) bool {
+	source, others := false, false
+	for _, host := range hosts {
+		if host.Node == from {
+			source = host.Err == nil
+		} else {
+			others = true
+		}
+	}
+	return source && others
+}
+
+// SyncPlan changes the endpoints of a host to match a source host. Updated
+// holds the wanted endpoints whose destination differs from Previous.
+type SyncPlan struct {
+	Add      []PortEntry
+	Update   []PortEntry
+	Previous []PortEntry
+	Remove   []PortEntry
+}
+
+func (p SyncPlan) Empty() bool {
+	return len(p.Add) == 0 && len(p.Update) == 0 && len(p.Remove) == 0
+}
+
+func PlanSync(source, target []PortEntry) SyncPlan {
+	var plan SyncPlan
+	current := make(map[string]PortEntry, len(target))
+	for _, port := range target {
+		current[port.Key()] = port
+	}
+	wanted := make(map[string]bool, len(source))
+	for _, port := range source {
+		wanted[port.Key()] = true
+		have, ok := current[port.Key()]
+		switch {
+		case !ok:
+			plan.Add = append(plan.Add, port)
+		case have.Destination != port.Destination:
+			plan.Update = append(plan.Update, port)
+			plan.Previous = append(plan.Previous, have)
+		}
+	}
+	for _, port := range target {
+		if !wanted[port.Key()] {
+			plan.Remove = append(plan.Remove, port)
+		}
+	}
+	return plan
+}
+
+// NodeSync is the outcome of syncing one host. Err is the first step that
+// failed; the steps after it were not attempted.
+type NodeSync struct {
+	Node string
+	Plan SyncPlan
+	Err  error
+}
+
+// apply adds and updates endpoints before removing any, so the host keeps
+// serving throughout.
+func (p SyncPlan) apply(svc NodeService, name string) error {
+	for _, port := range p.Add {
+		if err := svc.AddEndpoint(port.params(name)); err != nil {
+			return err
+		}
+	}
+	for i, port := range p.Update {
+		err := svc.UpdateEndpoint(UpdateEndpointParams{
+			ServiceName:    name,
+			Protocol:       port.Protocol,
+			ExposePort:     port.ExposePort,
+			Path:           port.Path,
+			OldDestination: p.Previous[i].Destination,
+			NewDestination: port.Destination,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, port := range p.Remove {
+		if err := svc.RemoveEndpoint(port.params(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PlanSyncService plans copying the endpoints of name on the node from to
+// every other node hosting it. Unreachable hosts are reported with Err and
+// hosts that already match are left out; nodes that do not serve the service
+// are left alone.
+func (h *Hub) PlanSyncService(name, from string) ([]NodeSync, error) {
+	var source *ServiceHost
+	hosts := h.Hosts(name)
+	for i := range hosts {
+		if hosts[i].Node == from && hosts[i].Err == nil {
+			source = &hosts[i]
+		}
+	}
+	if source == nil {
+		if _, err := h.Node(from); err != nil {
+			return nil, err
+		}
+		return nil, ErrServiceNotOnNode
+	}
+
+	var plans []NodeSync
+	for _, host := range hosts {
+		if host.Node == from {
+			continue
+		}
+		if host.Err != nil {
+			plans = append(plans, NodeSync{Node: host.Node, Err: host.Err})
+			continue
+		}
+		plan := PlanSync(source.Ports, host.Ports)
+		if plan.Empty() {
+			continue
+		}
+		plans = append(plans, NodeSync{Node: host.Node, Plan: plan})
+	}
+	return plans, nil
+}
+
+// SyncSignature identifies planned syncs, so a confirmed plan can be told
+// apart from one that changed since it was shown.
+func SyncSignature(plans []NodeSync) string {
+	hash := sha256.New()
+	for _, p := range plans {
+		fmt.Fprintf(hash, "node %s %t\n", p.Node, p.Err != nil)
+		for _, step := range []struct {
+			op    string
+			ports []PortEntry
+		}{{"add", p.Plan.Add}, {"update", p.Plan.Update}, {"previous", p.Plan.Previous}, {"remove", p.Plan.Remove}} {
+			for _, port := range step.ports {
+				fmt.Fprintf(hash, "%s %s=%s\n", step.op, port.Key(), port.Destination)
+			}
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// ExecuteSync applies planned syncs to their nodes. Hosts that were
+// unreachable when planning are reported as they were.
+func (h *Hub) ExecuteSync(name string, plans []NodeSync) []NodeSync {
+	results := make([]NodeSync, len(plans))
+	for i, p := range plans {
+		results[i] = p
+		if p.Err != nil {
+			continue
+		}
+		node, err := h.Node(p.Node)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Err = p.Plan.apply(node.Service, name)
+	}
+	return results
+}
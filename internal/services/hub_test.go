@@ -0,0 +1,245 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+type fakeNode struct {
+	svcs   []ServiceView
+	detail *ServiceDetailView
+	err    error
+	calls  []string
+}
+
+func (n *fakeNode) GetServeStatus() ([]ServiceView, error) {
+	return n.svcs, n.err
+}
+
+func (n *fakeNode) GetServiceByName(name string) (*ServiceDetailView, error) {
+	return n.detail, n.err
+}
+
+func (n *fakeNode) AddEndpoint(params EndpointParams) error {
+	n.calls = append(n.calls, "add "+params.ExposePort+params.Path+" "+params.Destination)
+	return n.err
+}
+
+func (n *fakeNode) RemoveEndpoint(params EndpointParams) error {
+	n.calls = append(n.calls, "remove "+params.ExposePort+params.Path)
+	return n.err
+}
+
+func (n *fakeNode) UpdateEndpoint(params UpdateEndpointParams) error {
+	n.calls = append(n.calls, "update "+params.ExposePort+params.Path+" "+params.NewDestination)
+	return n.err
+}
+
+func hostedBy(ports ...PortEntry) *fakeNode {
+	return &fakeNode{detail: &ServiceDetailView{Name: "web", Ports: ports}}
+}
+
+func TestParseNodes(t *testing.T) {
+	nodes, err := ParseNodes([]string{"edge=https://edge.tail.ts.net:8077/", " nas = http://100.64.0.2:8077"}, "tok")
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(nodes) != 2 || nodes[0].Name != "edge" || nodes[1].Name != "nas" {
+		t.Fatalf("unexpected nodes %+v", nodes)
+	}
+	client := nodes[0].Service.(*AgentClient)
+	if client.BaseURL != "https://edge.tail.ts.net:8077" || client.Token != "tok" {
+		t.Errorf("unexpected client %+v", client)
+	}
+
+	for _, bad := range []string{"edge", "=http://x", "edge=ftp://x", "edge=http://"} {
+		if _, err := ParseNodes([]string{bad}, "tok"); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestNewHub_RejectsDuplicateNames(t *testing.T) {
+	if _, err := NewHub([]Node{{Name: "a", Service: &fakeNode{}}, {Name: "a", Service: &fakeNode{}}}); err == nil {
+		t.Error("expected duplicate node names to be rejected")
+	}
+}
+
+func TestHub_Status(t *testing.T) {
+	hub, _ := NewHub([]Node{
+		{Name: "local", Service: &fakeNode{svcs: []ServiceView{{Name: "web", Destinations: []string{"http://localhost:3000"}}, {Name: "db"}}}},
+		{Name: "edge", Service: &fakeNode{svcs: []ServiceView{{Name: "web", Destinations: []string{"http://localhost:3001"}}}}},
+		{Name: "down", Service: &fakeNode{err: errors.New("connection refused")}},
+	})
+
+	nodes, svcs := hub.Status()
+
+	if len(nodes) != 3 || nodes[2].Err == nil || nodes[0].Name != "local" {
+		t.Fatalf("unexpected node statuses %+v", nodes)
+	}
+	if len(svcs) != 2 || svcs[0].Name != "db" || svcs[1].Name != "web" {
+		t.Fatalf("unexpected services %+v", svcs)
+	}
+	web := svcs[1]
+	if len(web.Backends) != 2 || web.Backends[0].Node != "local" || web.Backends[1].Destinations[0] != "http://localhost:3001" {
+		t.Errorf("unexpected backends %+v", web.Backends)
+	}
+	if !web.BackedBy("edge") || web.BackedBy("down") {
+		t.Error("unexpected BackedBy result")
+	}
+}
+
+func TestHub_Node(t *testing.T) {
+	hub, _ := NewHub([]Node{{Name: "local", Service: &fakeNode{}}})
+	if _, err := hub.Node("edge"); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("expected ErrNodeNotFound, got %v", err)
+	}
+}
+
+func TestHub_Hosts(t *testing.T) {
+	api := PortEntry{Protocol: "https", ExposePort: "443", Path: "/api", Destination: "http://localhost:4000"}
+	root := PortEntry{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3000"}
+	stale := PortEntry{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3001"}
+	hub, _ := NewHub([]Node{
+		{Name: "local", Service: hostedBy(stale)},
+		{Name: "a", Service: hostedBy(root, api)},
+		{Name: "b", Service: hostedBy(api, root)},
+		{Name: "idle", Service: &fakeNode{}},
+		{Name: "down", Service: &fakeNode{err: errors.New("connection refused")}},
+	})
+
+	hosts := hub.Hosts("web")
+
+	if len(hosts) != 4 {
+		t.Fatalf("expected 4 hosts, got %+v", hosts)
+	}
+	want := map[string]bool{"local": false, "a": true, "b": true, "down": false}
+	for _, host := range hosts {
+		if host.InSync != want[host.Node] {
+			t.Errorf("expected %s in sync = %v", host.Node, want[host.Node])
+		}
+	}
+	if hosts[3].Node != "down" || hosts[3].Err == nil {
+		t.Errorf("expected the unreachable node to carry its error, got %+v", hosts[3])
+	}
+}
+
+func TestPlanSync(t *testing.T) {
+	source := []PortEntry{
+		{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3000"},
+		{Protocol: "https", ExposePort: "443", Path: "/api", Destination: "http://localhost:4000"},
+	}
+	target := []PortEntry{
+		{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3001"},
+		{Protocol: "http", ExposePort: "80", Path: "/", Destination: "http://localhost:3000"},
+	}
+
+	plan := PlanSync(source, target)
+
+	if len(plan.Add) != 1 || plan.Add[0].Path != "/api" {
+		t.Errorf("unexpected additions %+v", plan.Add)
+	}
+	if len(plan.Update) != 1 || plan.Update[0].Destination != "http://localhost:3000" || plan.Previous[0].Destination != "http://localhost:3001" {
+		t.Errorf("unexpected updates %+v from %+v", plan.Update, plan.Previous)
+	}
+	if len(plan.Remove) != 1 || plan.Remove[0].Protocol != "http" {
+		t.Errorf("unexpected removals %+v", plan.Remove)
+	}
+	if !PlanSync(source, source).Empty() {
+		t.Error("expected no changes between identical configs")
+	}
+}
+
+func TestHub_SyncService(t *testing.T) {
+	root := PortEntry{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3000"}
+	old := PortEntry{Protocol: "https", ExposePort: "443", Path: "/old", Destination: "http://localhost:5000"}
+	local, same, drifted, idle := hostedBy(root), hostedBy(root), hostedBy(old), &fakeNode{}
+	hub, _ := NewHub([]Node{
+		{Name: "local", Service: local},
+		{Name: "same", Service: same},
+		{Name: "drifted", Service: drifted},
+		{Name: "idle", Service: idle},
+	})
+
+	plans, err := hub.PlanSyncService("web", "local")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(drifted.calls) != 0 {
+		t.Fatalf("expected planning to change nothing, got %v", drifted.calls)
+	}
+	results := hub.ExecuteSync("web", plans)
+
+	if len(results) != 1 || results[0].Node != "drifted" || results[0].Err != nil {
+		t.Fatalf("expected only the drifted host to change, got %+v", results)
+	}
+	want := []string{"add 443/ http://localhost:3000", "remove 443/old"}
+	if len(drifted.calls) != 2 || drifted.calls[0] != want[0] || drifted.calls[1] != want[1] {
+		t.Errorf("expected %v, got %v", want, drifted.calls)
+	}
+	if len(local.calls)+len(same.calls)+len(idle.calls) != 0 {
+		t.Error("expected the other nodes to be left alone")
+	}
+}
+
+func TestHub_SyncServiceResolvesLocalDestinations(t *testing.T) {
+	root := PortEntry{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3000"}
+	measured := PortEntry{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://127.0.0.1:41234"}
+	drifted := hostedBy(PortEntry{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3001"})
+	hub, _ := NewHub([]Node{
+		{Name: "local", Service: hostedBy(measured)},
+		{Name: "same", Service: hostedBy(root)},
+		{Name: "drifted", Service: drifted},
+	})
+	hub.SetResolver(func(svc *ServiceDetailView) *ServiceDetailView {
+		resolved := *svc
+		resolved.Ports = []PortEntry{root}
+		return &resolved
+	})
+
+	for _, host := range hub.Hosts("web") {
+		if host.InSync != (host.Node != "drifted") {
+			t.Errorf("expected only the drifted host out of sync, got %+v", host)
+		}
+	}
+	plans, err := hub.PlanSyncService("web", "local")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(plans) != 1 || plans[0].Node != "drifted" || len(plans[0].Plan.Update) != 1 || plans[0].Plan.Update[0].Destination != root.Destination {
+		t.Errorf("expected the real destination to be copied, got %+v", plans)
+	}
+}
+
+func TestHub_PlanSyncServiceNotOnSource(t *testing.T) {
+	hub, _ := NewHub([]Node{{Name: "local", Service: &fakeNode{}}, {Name: "a", Service: hostedBy()}})
+
+	if _, err := hub.PlanSyncService("web", "local"); !errors.Is(err, ErrServiceNotOnNode) {
+		t.Errorf("expected ErrServiceNotOnNode, got %v", err)
+	}
+	if _, err := hub.PlanSyncService("web", "nope"); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("expected ErrNodeNotFound, got %v", err)
+	}
+}
+
+func TestSyncSignature(t *testing.T) {
+	root := PortEntry{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3000"}
+	moved := PortEntry{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3001"}
+	plans := []NodeSync{{Node: "a", Plan: SyncPlan{Add: []PortEntry{root}}}}
+
+	if SyncSignature(plans) != SyncSignature([]NodeSync{{Node: "a", Plan: SyncPlan{Add: []PortEntry{root}}}}) {
+		t.Error("expected equal plans to share a signature")
+	}
+	for _, other := range [][]NodeSync{
+		{{Node: "a", Plan: SyncPlan{Add: []PortEntry{moved}}}},
+		{{Node: "a", Plan: SyncPlan{Remove: []PortEntry{root}}}},
+		{{Node: "b", Plan: SyncPlan{Add: []PortEntry{root}}}},
+		{{Node: "a", Err: errors.New("unreachable")}},
+		nil,
+	} {
+		if SyncSignature(plans) == SyncSignature(other) {
+			t.Errorf("expected %+v to differ from %+v", other, plans)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	HealthUp   = "up"
+	HealthDown = "down"
+)
+
+// HealthReporter answers with the last known reachability of a destination.
+type HealthReporter interface {
+	DestinationUp(dest string) (up, known bool)
+}
+
+// ServiceHealth is HealthDown when any checked destination of svc is
+// unreachable, HealthUp when all checked ones are reachable, and empty
+// when none has been checked yet.
+func ServiceHealth(svc ServiceView, health HealthReporter) string {
+	if health == nil {
+		return ""
+	}
+	state := ""
+	for _, dest := range svc.Destinations {
+		up, known := health.DestinationUp(dest)
+		if !known {
+			continue
+		}
+		if !up {
+			return HealthDown
+		}
+		state = HealthUp
+	}
+	return state
+}
+
+// ServiceFilter narrows and orders the service list. Empty fields match
+// everything; the list is sorted by name unless Sort says otherwise.
+type ServiceFilter struct {
+	Query    string
+	Protocol string
+	Health   string
+	Funnel   string
+	Sort     string
+	Desc     bool
+}
+
+func (f ServiceFilter) Active() bool {
+	return f.Query != "" || f.Protocol != "" || f.Health != "" || f.Funnel != ""
+}
+
+func (f ServiceFilter) matches(svc ServiceView, health HealthReporter) bool {
+	if q := strings.ToLower(strings.TrimSpace(f.Query)); q != "" {
+		found := strings.Contains(strings.ToLower(svc.Name), q) ||
+			strings.Contains(strings.ToLower(svc.Hostname), q)
+		for _, dest := range svc.Destinations {
+			found = found || strings.Contains(strings.ToLower(dest), q)
+		}
+		if !found {
+			return false
+		}
+	}
+	switch f.Protocol {
+	case "https":
+		if svc.HTTPSUrl == "" {
+			return false
+		}
+	case "http":
+		if svc.HTTPUrl == "" {
+			return false
+		}
+	case "tcp", "tcp+tls":
+		if !slices.Contains(svc.TCPProtocols, f.Protocol) {
+			return false
+		}
+	}
+	switch f.Funnel {
+	case "on":
+		if !svc.Funnel {
+			return false
+		}
+	case "off":
+		if svc.Funnel {
+			return false
+		}
+	}
+	if f.Health != "" && health != nil && ServiceHealth(svc, health) != f.Health {
+		return false
+	}
+	return true
+}
+
+func (f ServiceFilter) less(a, b ServiceView) bool {
+	var ka, kb string
+	switch f.Sort {
+	case "hostname":
+		ka, kb = a.Hostname, b.Hostname
+	case "destination":
+		ka, kb = a.Proxy, b.Proxy
+	case "endpoints":
+		if len(a.Destinations) != len(b.Destinations) {
+			return len(a.Destinations) < len(b.Destinations)
+		}
+	}
+	if ka != kb {
+		return ka < kb
+	}
+	return a.Name < b.Name
+}
+
+// Apply returns the services matching f in the requested order. Health
+// filters are ignored without a reporter.
+func (f ServiceFilter) Apply(svcs []ServiceView, health HealthReporter) []ServiceView {
+	var result []ServiceView
+	for _, svc := range svcs {
+		if f.matches(svc, health) {
+			result = append(result, svc)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if f.Desc {
+			return f.less(result[j], result[i])
+		}
+		return f.less(result[i], result[j])
+	})
+	return result
+}
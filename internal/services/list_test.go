@@ -0,0 +1,85 @@
+package services
+
+import (
+	"testing"
+)
+
+type fakeHealth map[string]bool
+
+func (h fakeHealth) DestinationUp(dest string) (bool, bool) {
+	up, known := h[dest]
+	return up, known
+}
+
+func listFixture() []ServiceView {
+	return []ServiceView{
+		{Name: "grafana", Hostname: "grafana.tail.ts.net", HTTPSUrl: "https://grafana.tail.ts.net", Proxy: "http://localhost:3000", Destinations: []string{"http://localhost:3000"}},
+		{Name: "api", Hostname: "api.tail.ts.net", HTTPUrl: "http://api.tail.ts.net:8080", Proxy: "http://10.0.0.5:9000", Destinations: []string{"http://10.0.0.5:9000", "http://10.0.0.6:9000"}, TCPProtocols: []string{"tcp"}, Funnel: true},
+		{Name: "wiki", Hostname: "docs.tail.ts.net", HTTPSUrl: "https://docs.tail.ts.net", Proxy: "http://localhost:8080", Destinations: []string{"http://localhost:8080"}},
+	}
+}
+
+func names(svcs []ServiceView) []string {
+	var result []string
+	for _, svc := range svcs {
+		result = append(result, svc.Name)
+	}
+	return result
+}
+
+func TestServiceFilter_Apply(t *testing.T) {
+	health := fakeHealth{"http://localhost:3000": true, "http://10.0.0.5:9000": true, "http://10.0.0.6:9000": false}
+	tests := []struct {
+		name   string
+		filter ServiceFilter
+		want   []string
+	}{
+		{"default sorts by name", ServiceFilter{}, []string{"api", "grafana", "wiki"}},
+		{"search name", ServiceFilter{Query: "GRAF"}, []string{"grafana"}},
+		{"search hostname", ServiceFilter{Query: "docs"}, []string{"wiki"}},
+		{"search destination", ServiceFilter{Query: "10.0.0.6"}, []string{"api"}},
+		{"protocol", ServiceFilter{Protocol: "https"}, []string{"grafana", "wiki"}},
+		{"protocol tcp", ServiceFilter{Protocol: "tcp"}, []string{"api"}},
+		{"protocol tcp+tls", ServiceFilter{Protocol: "tcp+tls"}, nil},
+		{"funnel on", ServiceFilter{Funnel: "on"}, []string{"api"}},
+		{"funnel off", ServiceFilter{Funnel: "off"}, []string{"grafana", "wiki"}},
+		{"health down", ServiceFilter{Health: HealthDown}, []string{"api"}},
+		{"health up skips unchecked", ServiceFilter{Health: HealthUp}, []string{"grafana"}},
+		{"sort by hostname", ServiceFilter{Sort: "hostname"}, []string{"api", "wiki", "grafana"}},
+		{"sort by destination descending", ServiceFilter{Sort: "destination", Desc: true}, []string{"wiki", "grafana", "api"}},
+		{"sort by endpoints", ServiceFilter{Sort: "endpoints", Desc: true}, []string{"api", "wiki", "grafana"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := names(tt.filter.Apply(listFixture(), health))
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("expected %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestServiceFilter_HealthIgnoredWithoutReporter(t *testing.T) {
+	got := ServiceFilter{Health: HealthDown}.Apply(listFixture(), nil)
+	if len(got) != 3 {
+		t.Errorf("expected every service without a health reporter, got %v", names(got))
+	}
+}
+
+func TestServiceHealth(t *testing.T) {
+	svc := listFixture()[1]
+	if got := ServiceHealth(svc, fakeHealth{}); got != "" {
+		t.Errorf("expected unknown health, got %q", got)
+	}
+	if got := ServiceHealth(svc, fakeHealth{"http://10.0.0.5:9000": true}); got != HealthUp {
+		t.Errorf("expected up, got %q", got)
+	}
+	if got := ServiceHealth(svc, fakeHealth{"http://10.0.0.5:9000": true, "http://10.0.0.6:9000": false}); got != HealthDown {
+		t.Errorf("expected down, got %q", got)
+	}
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrMergeConflict = errors.New("merge plan has conflicting mounts")
+
+type MergeStep struct {
+	Source      string
+	Protocol    string
+	ExposePort  string
+	SourcePath  string
+	Path        string
+	Destination string
+}
+
+func (s MergeStep) Params(target string) EndpointParams {
+	return EndpointParams{
+		ServiceName: target,
+		Protocol:    s.Protocol,
+		ExposePort:  s.ExposePort,
+		Path:        s.Path,
+		Destination: s.Destination,
+	}
+}
+
+type MergePlan struct {
+	Target    string
+	Steps     []MergeStep
+	Skipped   []MergeStep
+	Conflicts []MergeStep
+	Clear     []string
+	Keep      []string
+}
+
+func (p MergePlan) Valid() bool {
+	return len(p.Steps) > 0 && len(p.Conflicts) == 0
+}
+
+func mountPath(source, path string) string {
+	mount := "/" + source
+	if path != "" && path != "/" {
+		mount += "/" + strings.Trim(path, "/")
+	}
+	return mount
+}
+
+// PlanMerge mounts every HTTP(S) endpoint of sources under /<source name> on
+// target. target may be nil when the service does not exist yet. Sources with
+// endpoints that cannot be path-mounted are kept instead of cleared.
+func PlanMerge(targetName string, target *ServiceDetailView, sources []*ServiceDetailView) MergePlan {
+	plan := MergePlan{Target: targetName}
+
+	taken := make(map[string]bool)
+	if target != nil {
+		for _, port := range target.Ports {
+			path := port.Path
+			if path == "" {
+				path = "/"
+			}
+			taken[port.ExposePort+path] = true
+		}
+	}
+
+	for _, source := range sources {
+		skipped := false
+		for _, port := range source.Ports {
+			step := MergeStep{
+				Source:      source.Name,
+				Protocol:    port.Protocol,
+				ExposePort:  port.ExposePort,
+				SourcePath:  port.Path,
+				Path:        mountPath(source.Name, port.Path),
+				Destination: port.Destination,
+			}
+			if step.Protocol != "http" && step.Protocol != "https" {
+				plan.Skipped = append(plan.Skipped, step)
+				skipped = true
+				continue
+			}
+			if taken[step.ExposePort+step.Path] {
+				plan.Conflicts = append(plan.Conflicts, step)
+				continue
+			}
+			taken[step.ExposePort+step.Path] = true
+			plan.Steps = append(plan.Steps, step)
+		}
+		if skipped {
+			plan.Keep = append(plan.Keep, source.Name)
+		} else {
+			plan.Clear = append(plan.Clear, source.Name)
+		}
+	}
+
+	return plan
+}
+
+type MergeExecutor interface {
+	AddEndpoint(params EndpointParams) error
+	RemoveEndpoint(params EndpointParams) error
+	ClearService(name string) error
+}
+
+type ClearFailure struct {
+	Service string
+	Err     error
+}
+
+type MergeResult struct {
+	Added       []MergeStep
+	Failed      *MergeStep
+	Err         error
+	RolledBack  bool
+	Cleared     []string
+	ClearFailed []ClearFailure
+}
+
+func (r MergeResult) OK() bool {
+	return r.Err == nil && len(r.ClearFailed) == 0
+}
+
+// ExecuteMerge adds every mount to the target and, only when all of them
+// succeeded, clears the source services. A failed mount rolls back the
+// mounts added so far.
+func ExecuteMerge(executor MergeExecutor, plan MergePlan) MergeResult {
+	var result MergeResult
+	if !plan.Valid() {
+		result.Err = ErrMergeConflict
+		return result
+	}
+
+	for i, step := range plan.Steps {
+		if err := executor.AddEndpoint(step.Params(plan.Target)); err != nil {
+			result.Failed = &plan.Steps[i]
+			result.Err = err
+			result.RolledBack = true
+			for j := len(result.Added) - 1; j >= 0; j-- {
+				if rbErr := executor.RemoveEndpoint(result.Added[j].Params(plan.Target)); rbErr != nil {
+					result.RolledBack = false
+				}
+			}
+			return result
+		}
+		result.Added = append(result.Added, step)
+	}
+
+	for _, name := range plan.Clear {
+		if err := executor.ClearService(name); err != nil {
+			result.ClearFailed = append(result.ClearFailed, ClearFailure{Service: name, Err: err})
+			continue
+		}
+		result.Cleared = append(result.Cleared, name)
+	}
+
+	return result
+}
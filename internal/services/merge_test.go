@@ -0,0 +1,163 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPlanMerge_MountsUnderSourceName(t *testing.T) {
+	sources := []*ServiceDetailView{
+		{Name: "app1", Ports: []PortEntry{{Protocol: "https", ExposePort: "443", Destination: "http://localhost:3000"}}},
+		{Name: "app2", Ports: []PortEntry{{Protocol: "https", ExposePort: "443", Path: "/api", Destination: "http://localhost:4000"}}},
+	}
+
+	plan := PlanMerge("apps", nil, sources)
+
+	if !plan.Valid() {
+		t.Fatalf("expected valid plan, got %+v", plan)
+	}
+	var paths []string
+	for _, step := range plan.Steps {
+		paths = append(paths, step.Path)
+	}
+	if !reflect.DeepEqual(paths, []string{"/app1", "/app2/api"}) {
+		t.Errorf("unexpected mount paths %v", paths)
+	}
+	if !reflect.DeepEqual(plan.Clear, []string{"app1", "app2"}) {
+		t.Errorf("expected both sources to be cleared, got %v", plan.Clear)
+	}
+}
+
+func TestPlanMerge_KeepsSourcesWithTCPEndpoints(t *testing.T) {
+	sources := []*ServiceDetailView{
+		{Name: "db", Ports: []PortEntry{
+			{Protocol: "https", ExposePort: "443", Destination: "http://localhost:8080"},
+			{Protocol: "tcp", ExposePort: "5432", Destination: "tcp://localhost:5432"},
+		}},
+	}
+
+	plan := PlanMerge("apps", nil, sources)
+
+	if len(plan.Steps) != 1 || len(plan.Skipped) != 1 {
+		t.Fatalf("expected 1 step and 1 skipped, got %+v", plan)
+	}
+	if len(plan.Clear) != 0 || !reflect.DeepEqual(plan.Keep, []string{"db"}) {
+		t.Errorf("expected db to be kept, got clear=%v keep=%v", plan.Clear, plan.Keep)
+	}
+}
+
+func TestPlanMerge_ConflictWithTarget(t *testing.T) {
+	target := &ServiceDetailView{Name: "apps", Ports: []PortEntry{
+		{Protocol: "https", ExposePort: "443", Path: "/app1", Destination: "http://localhost:9000"},
+	}}
+	sources := []*ServiceDetailView{
+		{Name: "app1", Ports: []PortEntry{{Protocol: "https", ExposePort: "443", Destination: "http://localhost:3000"}}},
+	}
+
+	plan := PlanMerge("apps", target, sources)
+
+	if plan.Valid() {
+		t.Fatal("expected plan with conflicts to be invalid")
+	}
+	if len(plan.Conflicts) != 1 {
+		t.Errorf("expected 1 conflict, got %d", len(plan.Conflicts))
+	}
+}
+
+type mockMergeExecutor struct {
+	addErrAt int
+	added    []string
+	removed  []string
+	cleared  []string
+	clearErr map[string]error
+}
+
+func (m *mockMergeExecutor) AddEndpoint(params EndpointParams) error {
+	if m.addErrAt > 0 && len(m.added)+1 == m.addErrAt {
+		return errors.New("add failed")
+	}
+	m.added = append(m.added, params.Path)
+	return nil
+}
+
+func (m *mockMergeExecutor) RemoveEndpoint(params EndpointParams) error {
+	m.removed = append(m.removed, params.Path)
+	return nil
+}
+
+func (m *mockMergeExecutor) ClearService(name string) error {
+	if err := m.clearErr[name]; err != nil {
+		return err
+	}
+	m.cleared = append(m.cleared, name)
+	return nil
+}
+
+func testMergePlan() MergePlan {
+	return PlanMerge("apps", nil, []*ServiceDetailView{
+		{Name: "app1", Ports: []PortEntry{{Protocol: "https", ExposePort: "443", Destination: "http://localhost:3000"}}},
+		{Name: "app2", Ports: []PortEntry{{Protocol: "https", ExposePort: "443", Destination: "http://localhost:4000"}}},
+	})
+}
+
+func TestExecuteMerge_Success(t *testing.T) {
+	executor := &mockMergeExecutor{}
+
+	result := ExecuteMerge(executor, testMergePlan())
+
+	if !result.OK() {
+		t.Fatalf("expected success, got %+v", result)
+	}
+	if !reflect.DeepEqual(executor.cleared, []string{"app1", "app2"}) {
+		t.Errorf("expected sources to be cleared, got %v", executor.cleared)
+	}
+}
+
+func TestExecuteMerge_RollsBackOnFailure(t *testing.T) {
+	executor := &mockMergeExecutor{addErrAt: 2}
+
+	result := ExecuteMerge(executor, testMergePlan())
+
+	if result.Err == nil || result.Failed == nil || result.Failed.Source != "app2" {
+		t.Fatalf("expected failure on app2, got %+v", result)
+	}
+	if !result.RolledBack {
+		t.Error("expected mounts to be rolled back")
+	}
+	if !reflect.DeepEqual(executor.removed, []string{"/app1"}) {
+		t.Errorf("expected /app1 to be removed, got %v", executor.removed)
+	}
+	if len(executor.cleared) != 0 {
+		t.Errorf("expected no service to be cleared, got %v", executor.cleared)
+	}
+}
+
+func TestExecuteMerge_ReportsClearFailures(t *testing.T) {
+	executor := &mockMergeExecutor{clearErr: map[string]error{"app1": errors.New("clear failed")}}
+
+	result := ExecuteMerge(executor, testMergePlan())
+
+	if result.OK() {
+		t.Fatal("expected clear failure to be reported")
+	}
+	if len(result.ClearFailed) != 1 || result.ClearFailed[0].Service != "app1" {
+		t.Errorf("unexpected clear failures %+v", result.ClearFailed)
+	}
+	if !reflect.DeepEqual(result.Cleared, []string{"app2"}) {
+		t.Errorf("expected app2 to be cleared, got %v", result.Cleared)
+	}
+}
+
+func TestExecuteMerge_InvalidPlan(t *testing.T) {
+	executor := &mockMergeExecutor{}
+
+	result := ExecuteMerge(executor, MergePlan{Target: "apps"})
+
+	if !errors.Is(result.Err, ErrMergeConflict) {
+		t.Errorf("expected ErrMergeConflict, got %v", result.Err)
+	}
+	if len(executor.added) != 0 {
+		t.Errorf("expected nothing to be added, got %v", executor.added)
+	}
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MonitorSource is the serve state the monitor watches.
+type MonitorSource interface {
+	GetServiceDetails() ([]ServiceDetailView, error)
+	Generation() uint64
+}
+
+const healthTimeout = 3 * time.Second
+
+// destinationAddr returns the host:port a proxy destination connects to.
+// Destinations that do not reach a TCP port, such as text or file
+// handlers, report false.
+func destinationAddr(dest string) (string, bool) {
+	if port, err := strconv.Atoi(dest); err == nil && port > 0 && port <= 65535 {
+		return net.JoinHostPort("localhost", dest), true
+	}
+	if !strings.Contains(dest, "://") {
+		_, port, err := net.SplitHostPort(dest)
+		if _, perr := strconv.Atoi(port); err == nil && perr == nil {
+			return dest, true
+		}
+		return "", false
+	}
+	u, err := url.Parse(dest)
+	if err != nil || u.Hostname() == "" {
+		return "", false
+	}
+	port := u.Port()
+	if port == "" {
+		switch strings.TrimSuffix(u.Scheme, "+insecure") {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		default:
+			return "", false
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port), true
+}
+
+func dialCheck(ctx context.Context, addr string) error {
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// Monitor polls the serve config, reporting destinations that stop or start
+// accepting connections and endpoints that change without going through
+// twintail. The publisher may be nil when only the health state is needed.
+type Monitor struct {
+	source    MonitorSource
+	publisher Publisher
+	check     func(ctx context.Context, addr string) error
+	now       func() time.Time
+
+	primed     bool
+	generation uint64
+	endpoints  map[string]map[string]PortEntry
+
+	mu     sync.Mutex
+	up     map[string]bool
+	polled time.Time
+}
+
+func NewMonitor(source MonitorSource, publisher Publisher) *Monitor {
+	return &Monitor{
+		source:    source,
+		publisher: publisher,
+		check:     dialCheck,
+		now:       time.Now,
+		up:        map[string]bool{},
+	}
+}
+
+func (m *Monitor) publish(e Event) {
+	if m.publisher != nil {
+		m.publisher.Publish(e)
+	}
+}
+
+// DestinationUp reports the last health check of dest. known is false until
+// the destination has been checked, or when it cannot be checked at all.
+func (m *Monitor) DestinationUp(dest string) (up, known bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	up, known = m.up[dest]
+	return up, known
+}
+
+// Poll runs one round of drift and health checks.
+func (m *Monitor) Poll(ctx context.Context) error {
+	before := m.source.Generation()
+	details, err := m.source.GetServiceDetails()
+	if err != nil {
+		return err
+	}
+	after := m.source.Generation()
+
+	current := map[string]map[string]PortEntry{}
+	for _, detail := range details {
+		ports := map[string]PortEntry{}
+		for _, port := range detail.Ports {
+			ports[port.Key()] = port
+		}
+		current[detail.Name] = ports
+	}
+
+	// A change made by twintail between two polls bumps the generation, so
+	// only differences seen with an unchanged generation are drift.
+	if m.primed && before == after && before == m.generation {
+		m.reportDrift(current)
+	}
+	m.primed, m.generation, m.endpoints = true, after, current
+
+	m.checkHealth(ctx, details)
+
+	m.mu.Lock()
+	m.polled = m.now()
+	m.mu.Unlock()
+	return nil
+}
+
+// Healthy fails unless the serve status was read successfully within maxAge,
+// so a wedged tailscaled or monitor stops the watchdog pings.
+func (m *Monitor) Healthy(maxAge time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.polled.IsZero() {
+		return errors.New("serve status has not been read yet")
+	}
+	if age := m.now().Sub(m.polled); age > maxAge {
+		return fmt.Errorf("serve status last read %s ago", age.Round(time.Second))
+	}
+	return nil
+}
+
+func (m *Monitor) reportDrift(current map[string]map[string]PortEntry) {
+	names := map[string]bool{}
+	for name := range current {
+		names[name] = true
+	}
+	for name := range m.endpoints {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		oldPorts, newPorts := m.endpoints[name], current[name]
+		var changes []string
+		for key, port := range newPorts {
+			old, ok := oldPorts[key]
+			switch {
+			case !ok:
+				changes = append(changes, "+ "+key+" → "+port.Destination)
+			case old.Destination != port.Destination:
+				changes = append(changes, "~ "+key+" → "+port.Destination+" (was "+old.Destination+")")
+			}
+		}
+		for key, port := range oldPorts {
+			if _, ok := newPorts[key]; !ok {
+				changes = append(changes, "- "+key+" → "+port.Destination)
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		sort.Strings(changes)
+		m.publish(Event{
+			Type:    EventDriftDetected,
+			Service: name,
+			Message: strings.Join(changes, "\n"),
+		})
+	}
+}
+
+func (m *Monitor) checkHealth(ctx context.Context, details []ServiceDetailView) {
+	users := map[string][]string{}
+	for _, detail := range details {
+		for _, port := range detail.Ports {
+			if _, ok := destinationAddr(port.Destination); ok {
+				users[port.Destination] = append(users[port.Destination], detail.Name)
+			}
+		}
+	}
+
+	dests := make([]string, 0, len(users))
+	for dest := range users {
+		dests = append(dests, dest)
+	}
+	sort.Strings(dests)
+
+	results := map[string]error{}
+	for _, dest := range dests {
+		addr, _ := destinationAddr(dest)
+		results[dest] = m.check(ctx, addr)
+	}
+
+	m.mu.Lock()
+	previous := m.up
+	m.up = map[string]bool{}
+	for dest, err := range results {
+		m.up[dest] = err == nil
+	}
+	m.mu.Unlock()
+
+	for _, dest := range dests {
+		err := results[dest]
+		wasUp, known := previous[dest]
+		if err == nil && (!known || wasUp) || err != nil && known && !wasUp {
+			continue
+		}
+
+		services := strings.Join(uniqueSorted(users[dest]), ", ")
+		e := Event{Type: EventHealthRecovered, Service: services, Destination: dest}
+		if err != nil {
+			e.Type = EventHealthFailed
+			e.Message = err.Error()
+		}
+		m.publish(e)
+	}
+}
+
+func uniqueSorted(items []string) []string {
+	sort.Strings(items)
+	var result []string
+	for i, item := range items {
+		if i == 0 || item != items[i-1] {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// RunMonitor polls right away and then every interval.
+func RunMonitor(ctx context.Context, m *Monitor, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := m.Poll(ctx); err != nil {
+			logger.Error("failed to read serve status for monitoring", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
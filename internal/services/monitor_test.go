@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeMonitorSource struct {
+	details    []ServiceDetailView
+	generation uint64
+	// during bumps the generation while status is being read, as a
+	// concurrent twintail change would.
+	during bool
+}
+
+func (s *fakeMonitorSource) GetServiceDetails() ([]ServiceDetailView, error) {
+	if s.during {
+		s.generation++
+	}
+	return s.details, nil
+}
+
+func (s *fakeMonitorSource) Generation() uint64 {
+	return s.generation
+}
+
+func monitorDetails(dests ...string) []ServiceDetailView {
+	detail := ServiceDetailView{Name: "web"}
+	for i, dest := range dests {
+		detail.Ports = append(detail.Ports, PortEntry{Protocol: "https", ExposePort: "443", Path: "/" + strings.Repeat("a", i), Destination: dest})
+	}
+	return []ServiceDetailView{detail}
+}
+
+func newTestMonitor(source MonitorSource, down map[string]bool) (*Monitor, *recordingPublisher) {
+	publisher := &recordingPublisher{}
+	m := NewMonitor(source, publisher)
+	m.check = func(ctx context.Context, addr string) error {
+		if down[addr] {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	return m, publisher
+}
+
+func TestDestinationAddr(t *testing.T) {
+	tests := map[string]string{
+		"http://localhost:3000":        "localhost:3000",
+		"https+insecure://10.0.0.5":    "10.0.0.5:443",
+		"http://[::1]:8080":            "[::1]:8080",
+		"3000":                         "localhost:3000",
+		"127.0.0.1:9000":               "127.0.0.1:9000",
+		"text:Hello":                   "",
+		"/var/www/html":                "",
+		"unix:/run/app.sock":           "",
+		"tcp://db.internal:5432":       "db.internal:5432",
+		"http://grafana.internal/path": "grafana.internal:80",
+	}
+	for dest, want := range tests {
+		got, ok := destinationAddr(dest)
+		if got != want || ok != (want != "") {
+			t.Errorf("destinationAddr(%q) = %q, %v; want %q", dest, got, ok, want)
+		}
+	}
+}
+
+func TestMonitor_HealthTransitions(t *testing.T) {
+	source := &fakeMonitorSource{details: monitorDetails("http://localhost:3000", "text:hi")}
+	down := map[string]bool{"localhost:3000": true}
+	m, publisher := newTestMonitor(source, down)
+
+	m.Poll(context.Background())
+	m.Poll(context.Background())
+	delete(down, "localhost:3000")
+	m.Poll(context.Background())
+
+	types := publisher.types()
+	if len(types) != 2 || types[0] != EventHealthFailed || types[1] != EventHealthRecovered {
+		t.Fatalf("expected one failure then one recovery, got %v", types)
+	}
+	if publisher.events[0].Service != "web" || publisher.events[0].Message != "connection refused" {
+		t.Errorf("unexpected failure event %+v", publisher.events[0])
+	}
+}
+
+func TestMonitor_DetectsDrift(t *testing.T) {
+	source := &fakeMonitorSource{details: monitorDetails("http://localhost:3000")}
+	m, publisher := newTestMonitor(source, nil)
+
+	m.Poll(context.Background())
+	source.details = monitorDetails("http://localhost:4000", "http://localhost:5000")
+	m.Poll(context.Background())
+
+	if len(publisher.events) != 1 || publisher.events[0].Type != EventDriftDetected {
+		t.Fatalf("expected a drift event, got %v", publisher.types())
+	}
+	msg := publisher.events[0].Message
+	if !strings.Contains(msg, "~ https:443:/ → http://localhost:4000 (was http://localhost:3000)") ||
+		!strings.Contains(msg, "+ https:443:/a → http://localhost:5000") {
+		t.Errorf("unexpected drift message:\n%s", msg)
+	}
+}
+
+func TestMonitor_IgnoresOwnChanges(t *testing.T) {
+	source := &fakeMonitorSource{details: monitorDetails("http://localhost:3000")}
+	m, publisher := newTestMonitor(source, nil)
+
+	m.Poll(context.Background())
+	source.generation += 2
+	source.details = monitorDetails("http://localhost:4000")
+	m.Poll(context.Background())
+
+	source.during = true
+	source.details = nil
+	m.Poll(context.Background())
+
+	if len(publisher.events) != 0 {
+		t.Errorf("expected no drift for twintail's own changes, got %v", publisher.types())
+	}
+}
+
+func TestMonitor_DestinationUp(t *testing.T) {
+	source := &fakeMonitorSource{details: monitorDetails("http://localhost:3000", "http://localhost:4000")}
+	m := NewMonitor(source, nil)
+	m.check = func(ctx context.Context, addr string) error {
+		if addr == "localhost:4000" {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+
+	if _, known := m.DestinationUp("http://localhost:3000"); known {
+		t.Error("expected health to be unknown before the first poll")
+	}
+	m.Poll(context.Background())
+
+	if up, known := m.DestinationUp("http://localhost:3000"); !up || !known {
+		t.Errorf("expected localhost:3000 up, got up=%v known=%v", up, known)
+	}
+	if up, known := m.DestinationUp("http://localhost:4000"); up || !known {
+		t.Errorf("expected localhost:4000 down, got up=%v known=%v", up, known)
+	}
+}
+
+func TestMonitor_Healthy(t *testing.T) {
+	source := &fakeMonitorSource{details: monitorDetails("http://localhost:3000")}
+	m, _ := newTestMonitor(source, nil)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	if err := m.Healthy(time.Minute); err == nil {
+		t.Error("expected unhealthy before the first poll")
+	}
+	if err := m.Poll(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := m.Healthy(time.Minute); err != nil {
+		t.Errorf("expected healthy right after a poll, got %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if err := m.Healthy(time.Minute); err == nil {
+		t.Error("expected unhealthy once the last poll is too old")
+	}
+}
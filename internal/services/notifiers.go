@@ -0,0 +1,188 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+func postNotification(ctx context.Context, client *http.Client, req *http.Request) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s responded %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
+
+// WebhookNotifier posts the event as JSON. With a secret, the body is signed
+// with HMAC-SHA256 in the X-Twintail-Signature header.
+type WebhookNotifier struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+func (n *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+// SignPayload returns the X-Twintail-Signature value for body.
+func SignPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Twintail-Event", e.Type)
+	if n.Secret != "" {
+		req.Header.Set("X-Twintail-Signature", SignPayload(n.Secret, body))
+	}
+	return postNotification(ctx, n.Client, req)
+}
+
+// NtfyNotifier publishes to an ntfy topic URL such as https://ntfy.sh/mytopic.
+type NtfyNotifier struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+func (n *NtfyNotifier) Name() string {
+	return "ntfy"
+}
+
+func (n *NtfyNotifier) Notify(ctx context.Context, e Event) error {
+	body := e.Message
+	if body == "" {
+		body = e.Title()
+	}
+	req, err := http.NewRequest(http.MethodPost, n.URL, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", mime.QEncoding.Encode("utf-8", e.Title()))
+	req.Header.Set("Tags", "twintail,"+e.Type)
+	if e.Type == EventHealthFailed || e.Type == EventDriftDetected {
+		req.Header.Set("Priority", "high")
+	}
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+	return postNotification(ctx, n.Client, req)
+}
+
+// SMTPNotifier mails the event as plain text. STARTTLS is used when the
+// server offers it, and authentication only when a username is set.
+// TLSConfig is optional; the server name is always the host of Addr.
+type SMTPNotifier struct {
+	Addr      string
+	From      string
+	To        []string
+	Username  string
+	Password  string
+	TLSConfig *tls.Config
+}
+
+func (n *SMTPNotifier) Name() string {
+	return "smtp"
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, e Event) error {
+	host, _, err := net.SplitHostPort(n.Addr)
+	if err != nil {
+		return err
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		config := &tls.Config{}
+		if n.TLSConfig != nil {
+			config = n.TLSConfig.Clone()
+		}
+		config.ServerName = host
+		if err := client.StartTLS(config); err != nil {
+			return err
+		}
+	}
+	if n.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.Username, n.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(n.From); err != nil {
+		return err
+	}
+	for _, to := range n.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(n.message(e)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (n *SMTPNotifier) message(e Event) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", n.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "[twintail] "+e.Title()))
+	fmt.Fprintf(&b, "Date: %s\r\n", e.Time.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(e.Text(), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotifier_SignsPayload(t *testing.T) {
+	var body []byte
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+	}))
+	defer srv.Close()
+
+	n := &WebhookNotifier{URL: srv.URL, Secret: "s3cret"}
+	err := n.Notify(context.Background(), Event{Type: EventEndpointAdded, Service: "web", Destination: "http://localhost:3000"})
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if header.Get("X-Twintail-Event") != EventEndpointAdded {
+		t.Errorf("unexpected event header %q", header.Get("X-Twintail-Event"))
+	}
+	if header.Get("X-Twintail-Signature") != SignPayload("s3cret", body) {
+		t.Errorf("signature does not match body")
+	}
+	var got Event
+	if err := json.Unmarshal(body, &got); err != nil || got.Service != "web" {
+		t.Errorf("unexpected body %s", body)
+	}
+}
+
+func TestWebhookNotifier_Unsigned(t *testing.T) {
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+	}))
+	defer srv.Close()
+
+	n := &WebhookNotifier{URL: srv.URL}
+	if err := n.Notify(context.Background(), Event{Type: EventTest}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if header.Get("X-Twintail-Signature") != "" {
+		t.Error("expected no signature without a secret")
+	}
+}
+
+func TestWebhookNotifier_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	n := &WebhookNotifier{URL: srv.URL}
+	err := n.Notify(context.Background(), Event{Type: EventTest})
+
+	if err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("expected a 502 error, got %v", err)
+	}
+}
+
+func TestSignPayload(t *testing.T) {
+	got := SignPayload("key", []byte("The quick brown fox jumps over the lazy dog"))
+	want := "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestNtfyNotifier(t *testing.T) {
+	var body []byte
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+	}))
+	defer srv.Close()
+
+	n := &NtfyNotifier{URL: srv.URL + "/twintail", Token: "tk_123"}
+	err := n.Notify(context.Background(), Event{Type: EventHealthFailed, Destination: "http://localhost:3000", Message: "connection refused"})
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if string(body) != "connection refused" {
+		t.Errorf("unexpected body %q", body)
+	}
+	if header.Get("Title") != "http://localhost:3000 is unreachable" {
+		t.Errorf("unexpected title %q", header.Get("Title"))
+	}
+	if header.Get("Priority") != "high" {
+		t.Errorf("expected high priority, got %q", header.Get("Priority"))
+	}
+	if header.Get("Authorization") != "Bearer tk_123" {
+		t.Errorf("unexpected authorization %q", header.Get("Authorization"))
+	}
+}
+
+// fakeSMTP accepts a single message and returns its envelope and data. With
+// a TLS config it offers STARTTLS.
+func fakeSMTP(t *testing.T, config *tls.Config) (string, <-chan []string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	lines := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { conn.Close() }()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+
+		var got []string
+		reply("220 localhost ESMTP")
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				lines <- got
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			got = append(got, line)
+			if inData {
+				if line == "." {
+					inData = false
+					reply("250 queued")
+				}
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+			case "EHLO", "HELO":
+				if _, secure := conn.(*tls.Conn); config != nil && !secure {
+					reply("250-localhost")
+					reply("250 STARTTLS")
+				} else {
+					reply("250 localhost")
+				}
+			case "STARTTLS":
+				reply("220 ready")
+				conn = tls.Server(conn, config)
+				r = bufio.NewReader(conn)
+			case "DATA":
+				inData = true
+				reply("354 go ahead")
+			case "QUIT":
+				reply("221 bye")
+				lines <- got
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String(), lines
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, lines := fakeSMTP(t, nil)
+
+	n := &SMTPNotifier{Addr: addr, From: "twintail@example.com", To: []string{"ops@example.com", "oncall@example.com"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := n.Notify(ctx, Event{Type: EventServiceCleared, Service: "web", Time: time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)})
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	session := strings.Join(<-lines, "\n")
+	for _, want := range []string{
+		"MAIL FROM:<twintail@example.com>",
+		"RCPT TO:<ops@example.com>",
+		"RCPT TO:<oncall@example.com>",
+		"Subject: [twintail] web: service cleared",
+		"QUIT",
+	} {
+		if !strings.Contains(session, want) {
+			t.Errorf("expected session to contain %q:\n%s", want, session)
+		}
+	}
+}
+
+func TestSMTPNotifier_StartTLS(t *testing.T) {
+	// The test server's certificate is valid for 127.0.0.1.
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	srv.Close()
+	addr, lines := fakeSMTP(t, srv.TLS)
+
+	n := &SMTPNotifier{
+		Addr:      addr,
+		From:      "twintail@example.com",
+		To:        []string{"ops@example.com"},
+		TLSConfig: srv.Client().Transport.(*http.Transport).TLSClientConfig,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := n.Notify(ctx, Event{Type: EventServiceCleared, Service: "web", Time: time.Now()})
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	session := strings.Join(<-lines, "\n")
+	if !strings.Contains(session, "STARTTLS") || !strings.Contains(session, "MAIL FROM:<twintail@example.com>") {
+		t.Errorf("expected the message to be sent after STARTTLS:\n%s", session)
+	}
+}
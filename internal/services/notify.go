@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	EventEndpointAdded   = "endpoint.added"
+	EventEndpointRemoved = "endpoint.removed"
+	EventServiceCleared  = "service.cleared"
+	EventHealthFailed    = "health.failed"
+	EventHealthRecovered = "health.recovered"
+	EventDriftDetected   = "drift.detected"
+	EventTest            = "test"
+)
+
+// EventTypes lists the events a notification target can filter on.
+var EventTypes = []string{
+	EventEndpointAdded,
+	EventEndpointRemoved,
+	EventServiceCleared,
+	EventHealthFailed,
+	EventHealthRecovered,
+	EventDriftDetected,
+}
+
+func IsEventType(name string) bool {
+	return slices.Contains(EventTypes, name)
+}
+
+type Event struct {
+	Type        string    `json:"type"`
+	Time        time.Time `json:"time"`
+	Service     string    `json:"service,omitempty"`
+	Protocol    string    `json:"protocol,omitempty"`
+	ExposePort  string    `json:"expose_port,omitempty"`
+	Path        string    `json:"path,omitempty"`
+	Destination string    `json:"destination,omitempty"`
+	Message     string    `json:"message,omitempty"`
+}
+
+func endpointEvent(eventType string, params EndpointParams) Event {
+	return Event{
+		Type:        eventType,
+		Service:     params.ServiceName,
+		Protocol:    params.Protocol,
+		ExposePort:  params.ExposePort,
+		Path:        normalizePath(params.Path),
+		Destination: params.Destination,
+	}
+}
+
+// Title is a one-line summary used as the subject of text notifications.
+func (e Event) Title() string {
+	switch e.Type {
+	case EventEndpointAdded:
+		return fmt.Sprintf("%s: added %s:%s%s → %s", e.Service, e.Protocol, e.ExposePort, e.Path, e.Destination)
+	case EventEndpointRemoved:
+		return fmt.Sprintf("%s: removed %s:%s%s → %s", e.Service, e.Protocol, e.ExposePort, e.Path, e.Destination)
+	case EventServiceCleared:
+		return fmt.Sprintf("%s: service cleared", e.Service)
+	case EventHealthFailed:
+		return fmt.Sprintf("%s is unreachable", e.Destination)
+	case EventHealthRecovered:
+		return fmt.Sprintf("%s is reachable again", e.Destination)
+	case EventDriftDetected:
+		return fmt.Sprintf("%s: serve config changed outside twintail", e.Service)
+	case EventTest:
+		return "twintail test notification"
+	}
+	return e.Type
+}
+
+// Text is the title followed by the message, if any.
+func (e Event) Text() string {
+	if e.Message == "" {
+		return e.Title()
+	}
+	return e.Title() + "\n\n" + e.Message
+}
+
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, e Event) error
+}
+
+// Target is a notifier with the events it wants. No events means all of
+// them; test events are always delivered.
+type Target struct {
+	Notifier Notifier
+	Events   []string
+}
+
+func (t Target) Accepts(eventType string) bool {
+	return eventType == EventTest || len(t.Events) == 0 || slices.Contains(t.Events, eventType)
+}
+
+type Publisher interface {
+	Publish(e Event)
+}
+
+type Delivery struct {
+	Target   Target
+	Attempts int
+	Err      error
+}
+
+const (
+	notifyQueueSize = 64
+	notifyAttempts  = 4
+	notifyTimeout   = 10 * time.Second
+)
+
+// Dispatcher delivers events to every target that accepts them, retrying
+// failures with exponential backoff. Publish never blocks the caller. Each
+// target has its own queue and worker, so a target that is down and being
+// retried does not hold up the others.
+type Dispatcher struct {
+	targets  []Target
+	queues   []chan Event
+	logger   *slog.Logger
+	attempts int
+	backoff  time.Duration
+	now      func() time.Time
+}
+
+func NewDispatcher(targets []Target, logger *slog.Logger) *Dispatcher {
+	queues := make([]chan Event, len(targets))
+	for i := range queues {
+		queues[i] = make(chan Event, notifyQueueSize)
+	}
+	return &Dispatcher{
+		targets:  targets,
+		queues:   queues,
+		logger:   logger,
+		attempts: notifyAttempts,
+		backoff:  2 * time.Second,
+		now:      time.Now,
+	}
+}
+
+func (d *Dispatcher) Targets() []Target {
+	return d.targets
+}
+
+func (d *Dispatcher) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = d.now()
+	}
+	for i, target := range d.targets {
+		if !target.Accepts(e.Type) {
+			continue
+		}
+		select {
+		case d.queues[i] <- e:
+		default:
+			d.logger.Error("notification queue full, dropping event", "notifier", target.Notifier.Name(), "event", e.Type, "service", e.Service)
+		}
+	}
+}
+
+// Run delivers queued events until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := range d.targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.work(ctx, d.targets[i], d.queues[i])
+		}()
+	}
+	wg.Wait()
+}
+
+func (d *Dispatcher) work(ctx context.Context, target Target, queue <-chan Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-queue:
+			delivery := d.deliver(ctx, target, e, d.attempts)
+			if delivery.Err != nil {
+				d.logger.Error("failed to send notification", "notifier", target.Notifier.Name(), "event", e.Type, "attempts", delivery.Attempts, "error", delivery.Err)
+			}
+		}
+	}
+}
+
+// Deliver sends e to each accepting target in turn, retrying every target up
+// to the attempt limit.
+func (d *Dispatcher) Deliver(ctx context.Context, e Event) []Delivery {
+	var deliveries []Delivery
+	for _, target := range d.targets {
+		if !target.Accepts(e.Type) {
+			continue
+		}
+		deliveries = append(deliveries, d.deliver(ctx, target, e, d.attempts))
+	}
+	return deliveries
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, target Target, e Event, attempts int) Delivery {
+	delivery := Delivery{Target: target}
+	wait := d.backoff
+	for delivery.Attempts < attempts {
+		if delivery.Attempts > 0 {
+			select {
+			case <-ctx.Done():
+				return delivery
+			case <-time.After(wait):
+			}
+			wait *= 2
+		}
+		delivery.Attempts++
+		sendCtx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		delivery.Err = target.Notifier.Notify(sendCtx, e)
+		cancel()
+		if delivery.Err == nil {
+			break
+		}
+	}
+	return delivery
+}
+
+// SendTest sends a test event to every target once, without retries, so the
+// settings page can show the result right away.
+func (d *Dispatcher) SendTest(ctx context.Context) []Delivery {
+	e := Event{Type: EventTest, Time: d.now(), Message: "Notifications from twintail are working."}
+	var deliveries []Delivery
+	for _, target := range d.targets {
+		deliveries = append(deliveries, d.deliver(ctx, target, e, 1))
+	}
+	return deliveries
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingPublisher struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (p *recordingPublisher) Publish(e Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, e)
+}
+
+func (p *recordingPublisher) types() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var types []string
+	for _, e := range p.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+type fakeNotifier struct {
+	mu       sync.Mutex
+	failures int
+	received []Event
+}
+
+func (n *fakeNotifier) Name() string {
+	return "fake"
+}
+
+func (n *fakeNotifier) Notify(ctx context.Context, e Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.received = append(n.received, e)
+	if n.failures > 0 {
+		n.failures--
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+func (n *fakeNotifier) count() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.received)
+}
+
+func testDispatcher(targets ...Target) *Dispatcher {
+	d := NewDispatcher(targets, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	d.backoff = time.Millisecond
+	return d
+}
+
+func TestTarget_Accepts(t *testing.T) {
+	all := Target{}
+	if !all.Accepts(EventDriftDetected) {
+		t.Error("expected a target without filters to accept every event")
+	}
+
+	filtered := Target{Events: []string{EventHealthFailed}}
+	if !filtered.Accepts(EventHealthFailed) {
+		t.Error("expected filtered target to accept health.failed")
+	}
+	if filtered.Accepts(EventEndpointAdded) {
+		t.Error("expected filtered target to skip endpoint.added")
+	}
+	if !filtered.Accepts(EventTest) {
+		t.Error("expected test events to bypass filters")
+	}
+}
+
+func TestDispatcher_DeliverFiltersTargets(t *testing.T) {
+	health := &fakeNotifier{}
+	everything := &fakeNotifier{}
+	d := testDispatcher(
+		Target{Notifier: health, Events: []string{EventHealthFailed}},
+		Target{Notifier: everything},
+	)
+
+	deliveries := d.Deliver(context.Background(), Event{Type: EventEndpointAdded, Service: "web"})
+
+	if len(deliveries) != 1 || deliveries[0].Target.Notifier != everything {
+		t.Fatalf("expected a single delivery to the unfiltered target, got %+v", deliveries)
+	}
+	if health.count() != 0 {
+		t.Error("expected filtered target not to be notified")
+	}
+}
+
+func TestDispatcher_DeliverRetries(t *testing.T) {
+	n := &fakeNotifier{failures: 2}
+	d := testDispatcher(Target{Notifier: n})
+
+	deliveries := d.Deliver(context.Background(), Event{Type: EventHealthFailed})
+
+	if deliveries[0].Err != nil {
+		t.Fatalf("expected delivery to succeed after retries, got %v", deliveries[0].Err)
+	}
+	if deliveries[0].Attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", deliveries[0].Attempts)
+	}
+}
+
+func TestDispatcher_DeliverGivesUp(t *testing.T) {
+	n := &fakeNotifier{failures: 10}
+	d := testDispatcher(Target{Notifier: n})
+
+	deliveries := d.Deliver(context.Background(), Event{Type: EventHealthFailed})
+
+	if deliveries[0].Err == nil {
+		t.Fatal("expected delivery to fail")
+	}
+	if n.count() != notifyAttempts {
+		t.Errorf("expected %d attempts, got %d", notifyAttempts, n.count())
+	}
+}
+
+func TestDispatcher_SendTestTriesOnce(t *testing.T) {
+	n := &fakeNotifier{failures: 1}
+	d := testDispatcher(Target{Notifier: n, Events: []string{EventDriftDetected}})
+
+	deliveries := d.SendTest(context.Background())
+
+	if len(deliveries) != 1 || deliveries[0].Err == nil || deliveries[0].Attempts != 1 {
+		t.Fatalf("expected a single failed attempt, got %+v", deliveries)
+	}
+	if n.received[0].Type != EventTest {
+		t.Errorf("expected test event, got %s", n.received[0].Type)
+	}
+}
+
+func TestDispatcher_RunDeliversPublishedEvents(t *testing.T) {
+	n := &fakeNotifier{}
+	d := testDispatcher(Target{Notifier: n})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	d.Publish(Event{Type: EventServiceCleared, Service: "web"})
+
+	deadline := time.Now().Add(time.Second)
+	for n.count() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n.count() != 1 {
+		t.Fatal("expected published event to be delivered")
+	}
+	if n.received[0].Time.IsZero() {
+		t.Error("expected publish to stamp the event time")
+	}
+}
+
+func TestUpdateEndpoint_PublishesEvents(t *testing.T) {
+	mockCommandError = nil
+	mockRemoveCommandError = nil
+	defer setupMockExecCommandWithEndpoint()()
+
+	publisher := &recordingPublisher{}
+	svc := NewTailscaleService()
+	svc.SetPublisher(publisher)
+	err := svc.UpdateEndpoint(UpdateEndpointParams{
+		ServiceName:    "my-service",
+		Protocol:       "https",
+		ExposePort:     "443",
+		OldDestination: "http://localhost:8080",
+		NewDestination: "http://localhost:9000",
+	})
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	types := publisher.types()
+	if len(types) != 2 || types[0] != EventEndpointRemoved || types[1] != EventEndpointAdded {
+		t.Fatalf("expected removed then added, got %v", types)
+	}
+	if publisher.events[1].Destination != "http://localhost:9000" || publisher.events[1].Path != "/" {
+		t.Errorf("unexpected added event %+v", publisher.events[1])
+	}
+}
+
+func TestAddEndpoint_FailureDoesNotPublish(t *testing.T) {
+	mockCommandError = errors.New("exit status 1")
+	defer func() { mockCommandError = nil }()
+	defer setupMockExecCommandWithEndpoint()()
+
+	publisher := &recordingPublisher{}
+	svc := NewTailscaleService()
+	svc.SetPublisher(publisher)
+	_ = svc.AddEndpoint(EndpointParams{ServiceName: "web", Protocol: "https", ExposePort: "443", Destination: "3000"})
+
+	if len(publisher.types()) != 0 {
+		t.Errorf("expected no events, got %v", publisher.types())
+	}
+}
+
+func TestGeneration_ChangesAroundMutations(t *testing.T) {
+	mockCommandError = nil
+	defer setupMockExecCommandWithEndpoint()()
+
+	svc := NewTailscaleService()
+	before := svc.Generation()
+	_ = svc.ClearService("web")
+
+	if svc.Generation() == before {
+		t.Error("expected a mutation to change the generation")
+	}
+}
+
+// blockingNotifier stands in for a target that is down: it does not return
+// until released.
+type blockingNotifier struct {
+	release chan struct{}
+}
+
+func (n *blockingNotifier) Name() string {
+	return "blocking"
+}
+
+func (n *blockingNotifier) Notify(ctx context.Context, e Event) error {
+	select {
+	case <-n.release:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+func TestDispatcher_RunDoesNotWaitForSlowTargets(t *testing.T) {
+	slow := &blockingNotifier{release: make(chan struct{})}
+	defer close(slow.release)
+	fast := &fakeNotifier{}
+	d := testDispatcher(Target{Notifier: slow}, Target{Notifier: fast})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	d.Publish(Event{Type: EventServiceCleared, Service: "web"})
+	d.Publish(Event{Type: EventServiceCleared, Service: "wiki"})
+
+	deadline := time.Now().Add(time.Second)
+	for fast.count() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if fast.count() != 2 {
+		t.Fatalf("expected the fast target to get both events while the slow one hangs, got %d", fast.count())
+	}
+}
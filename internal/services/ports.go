@@ -0,0 +1,200 @@
+package services
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const tcpStateListen = "0A"
+
+type ListeningPort struct {
+	Address  netip.Addr
+	Port     int
+	Process  string
+	Services []string
+	inode    string
+}
+
+// Destination returns the URL an endpoint would use to reach this port.
+func (p ListeningPort) Destination() string {
+	host := "localhost"
+	if !p.Address.IsUnspecified() && !p.Address.IsLoopback() {
+		host = p.Address.String()
+	}
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(p.Port))
+}
+
+func (p ListeningPort) InUse() bool {
+	return len(p.Services) > 0
+}
+
+type PortScanner struct {
+	ProcRoot string
+}
+
+func NewPortScanner() *PortScanner {
+	return &PortScanner{ProcRoot: "/proc"}
+}
+
+// ListeningPorts reads the listening TCP sockets from /proc/net/tcp{,6}.
+// Process names are filled in only for sockets whose owner is readable.
+func (s *PortScanner) ListeningPorts() ([]ListeningPort, error) {
+	var ports []ListeningPort
+	found := false
+	for _, name := range []string{"tcp", "tcp6"} {
+		entries, err := readProcNetTCP(filepath.Join(s.ProcRoot, "net", name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		ports = append(ports, entries...)
+	}
+	if !found {
+		return nil, fmt.Errorf("no tcp socket tables under %s", s.ProcRoot)
+	}
+
+	processes := s.socketOwners()
+	seen := make(map[string]bool)
+	var result []ListeningPort
+	for _, port := range ports {
+		key := port.Address.String() + ":" + strconv.Itoa(port.Port)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		port.Process = processes[port.inode]
+		result = append(result, port)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Port != result[j].Port {
+			return result[i].Port < result[j].Port
+		}
+		return result[i].Address.Less(result[j].Address)
+	})
+	return result, nil
+}
+
+func readProcNetTCP(path string) ([]ListeningPort, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ports []ListeningPort
+	scanner := bufio.NewScanner(f)
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != tcpStateListen {
+			continue
+		}
+		addr, port, err := parseProcAddr(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		ports = append(ports, ListeningPort{Address: addr, Port: port, inode: fields[9]})
+	}
+	return ports, scanner.Err()
+}
+
+// parseProcAddr decodes "0100007F:1F90". The address is stored as 32-bit
+// words in host byte order, which is little-endian on supported platforms.
+func parseProcAddr(s string) (netip.Addr, int, error) {
+	host, portHex, ok := strings.Cut(s, ":")
+	if !ok {
+		return netip.Addr{}, 0, fmt.Errorf("invalid socket address %q", s)
+	}
+	port, err := strconv.ParseUint(portHex, 16, 16)
+	if err != nil {
+		return netip.Addr{}, 0, fmt.Errorf("invalid socket port %q", s)
+	}
+	raw, err := hex.DecodeString(host)
+	if err != nil || (len(raw) != 4 && len(raw) != 16) {
+		return netip.Addr{}, 0, fmt.Errorf("invalid socket address %q", s)
+	}
+	for i := 0; i < len(raw); i += 4 {
+		raw[i], raw[i+1], raw[i+2], raw[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+	addr, _ := netip.AddrFromSlice(raw)
+	return addr.Unmap(), int(port), nil
+}
+
+func (s *PortScanner) socketOwners() map[string]string {
+	owners := make(map[string]string)
+	pids, _ := filepath.Glob(filepath.Join(s.ProcRoot, "[0-9]*"))
+	for _, pid := range pids {
+		fds, err := os.ReadDir(filepath.Join(pid, "fd"))
+		if err != nil {
+			continue
+		}
+		var comm string
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(pid, "fd", fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			if comm == "" {
+				data, err := os.ReadFile(filepath.Join(pid, "comm"))
+				if err != nil {
+					break
+				}
+				comm = strings.TrimSpace(string(data))
+			}
+			inode := strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")
+			if _, ok := owners[inode]; !ok {
+				owners[inode] = comm
+			}
+		}
+	}
+	return owners
+}
+
+// MarkInUse records which services already proxy to each listening port.
+func MarkInUse(ports []ListeningPort, svcs []ServiceView) []ListeningPort {
+	for i := range ports {
+		ports[i].Services = nil
+		for _, svc := range svcs {
+			for _, dest := range svc.Destinations {
+				if destinationMatches(dest, ports[i]) {
+					ports[i].Services = append(ports[i].Services, svc.Name)
+					break
+				}
+			}
+		}
+	}
+	return ports
+}
+
+func destinationMatches(dest string, port ListeningPort) bool {
+	u, err := url.Parse(dest)
+	if err != nil || u.Port() != strconv.Itoa(port.Port) {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return port.Address.IsUnspecified() || port.Address.IsLoopback()
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	if port.Address.IsUnspecified() {
+		return true
+	}
+	return addr == port.Address
+}
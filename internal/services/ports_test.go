@@ -0,0 +1,88 @@
+package services
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestListeningPorts_Fixture(t *testing.T) {
+	scanner := &PortScanner{ProcRoot: "testdata/proc"}
+
+	ports, err := scanner.ListeningPorts()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	type summary struct {
+		Address string
+		Port    int
+		Process string
+	}
+	var got []summary
+	for _, p := range ports {
+		got = append(got, summary{p.Address.String(), p.Port, p.Process})
+	}
+	want := []summary{
+		{"::1", 3000, ""},
+		{"0.0.0.0", 5432, ""},
+		{"::", 8077, "twintail"},
+		{"127.0.0.1", 8080, "node"},
+		{"100.64.0.1", 9000, ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected ports\n got: %v\nwant: %v", got, want)
+	}
+}
+
+func TestListeningPorts_MissingProc(t *testing.T) {
+	scanner := &PortScanner{ProcRoot: t.TempDir()}
+
+	if _, err := scanner.ListeningPorts(); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestListeningPort_Destination(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"0.0.0.0", "http://localhost:8080"},
+		{"127.0.0.1", "http://localhost:8080"},
+		{"::", "http://localhost:8080"},
+		{"100.64.0.1", "http://100.64.0.1:8080"},
+		{"fd7a::1", "http://[fd7a::1]:8080"},
+	}
+	for _, tt := range tests {
+		p := ListeningPort{Address: netip.MustParseAddr(tt.addr), Port: 8080}
+		if got := p.Destination(); got != tt.want {
+			t.Errorf("Destination() for %s = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestMarkInUse(t *testing.T) {
+	ports := []ListeningPort{
+		{Address: netip.MustParseAddr("0.0.0.0"), Port: 3000},
+		{Address: netip.MustParseAddr("127.0.0.1"), Port: 8080},
+		{Address: netip.MustParseAddr("100.64.0.1"), Port: 9000},
+	}
+	svcs := []ServiceView{
+		{Name: "web", Destinations: []string{"http://127.0.0.1:3000"}},
+		{Name: "api", Destinations: []string{"http://localhost:8080", "http://localhost:3000"}},
+		{Name: "other", Destinations: []string{"http://100.64.0.2:9000"}},
+	}
+
+	ports = MarkInUse(ports, svcs)
+
+	if !reflect.DeepEqual(ports[0].Services, []string{"web", "api"}) {
+		t.Errorf("expected port 3000 used by web and api, got %v", ports[0].Services)
+	}
+	if !reflect.DeepEqual(ports[1].Services, []string{"api"}) {
+		t.Errorf("expected port 8080 used by api, got %v", ports[1].Services)
+	}
+	if ports[2].InUse() {
+		t.Errorf("expected port 9000 to be free, got %v", ports[2].Services)
+	}
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const probeTimeout = 5 * time.Second
+
+// ProbeTiming holds how long each phase took, measured from the start of
+// the request. Phases that did not happen are zero.
+type ProbeTiming struct {
+	Connect   time.Duration
+	TLS       time.Duration
+	FirstByte time.Duration
+	Total     time.Duration
+}
+
+type ProbeTLS struct {
+	Version     string
+	CipherSuite string
+	Cert        *CertInfo
+}
+
+type ProbeHeader struct {
+	Name  string
+	Value string
+}
+
+// ProbeResult is the outcome of one request. TCP endpoints are probed with
+// a connection, so they have no status or headers. Skipped is set for
+// destinations that cannot be reached from here, such as text or file
+// handlers.
+type ProbeResult struct {
+	Target     string
+	StatusCode int
+	Status     string
+	Headers    []ProbeHeader
+	TLS        *ProbeTLS
+	Timing     ProbeTiming
+	Err        string
+	Skipped    bool
+}
+
+// OK reports whether the target answered without a server error.
+func (r ProbeResult) OK() bool {
+	return r.Err == "" && r.StatusCode < http.StatusInternalServerError
+}
+
+// EndpointProbe compares the endpoint as clients reach it through serve
+// with its destination reached directly.
+type EndpointProbe struct {
+	Endpoint PortEntry
+	Service  ProbeResult
+	Backend  ProbeResult
+}
+
+// Diagnosis returns the locale key naming the layer that fails. A broken
+// backend explains a failing service, so it is reported first.
+func (p EndpointProbe) Diagnosis() string {
+	switch {
+	case !p.Backend.OK():
+		return "probe.backend_failed"
+	case !p.Service.OK():
+		return "probe.serve_failed"
+	}
+	return "probe.ok"
+}
+
+type Prober struct {
+	dial  func(ctx context.Context, network, addr string) (net.Conn, error)
+	roots *x509.CertPool
+}
+
+func NewProber() *Prober {
+	var dialer net.Dialer
+	return &Prober{dial: dialer.DialContext}
+}
+
+// Probe requests the endpoint of the service at hostname and its
+// destination one after the other.
+func (p *Prober) Probe(ctx context.Context, hostname string, endpoint PortEntry) EndpointProbe {
+	result := EndpointProbe{Endpoint: endpoint}
+	switch endpoint.Protocol {
+	case "https", "http":
+		result.Service = p.request(ctx, serviceURL(hostname, endpoint), hostname)
+	default:
+		result.Service = p.connect(ctx, net.JoinHostPort(hostname, endpoint.ExposePort), endpoint.Protocol == "tcp+tls", hostname)
+	}
+
+	target := backendURL(endpoint.Destination)
+	switch {
+	case strings.HasPrefix(target, "http"):
+		result.Backend = p.request(ctx, target, "")
+	case strings.HasPrefix(target, "tcp://"):
+		result.Backend = p.connect(ctx, strings.TrimPrefix(target, "tcp://"), false, "")
+	default:
+		result.Backend = ProbeResult{Target: endpoint.Destination, Skipped: true}
+	}
+	return result
+}
+
+// serviceURL is the URL clients use for endpoint. The default port of the
+// scheme is left out, as serve shows it.
+func serviceURL(hostname string, endpoint PortEntry) string {
+	host := hostname
+	if (endpoint.Protocol == "https" && endpoint.ExposePort != "443") || (endpoint.Protocol == "http" && endpoint.ExposePort != "80") {
+		host = net.JoinHostPort(hostname, endpoint.ExposePort)
+	}
+	path := endpoint.Path
+	if path == "" {
+		path = "/"
+	}
+	return endpoint.Protocol + "://" + host + path
+}
+
+// backendURL turns a serve destination into the URL or tcp:// address to
+// probe. Bare ports and host:port mean plain HTTP, as they do for serve.
+func backendURL(dest string) string {
+	if port, err := strconv.Atoi(dest); err == nil && port > 0 && port <= 65535 {
+		return "http://localhost:" + dest
+	}
+	if !strings.Contains(dest, "://") {
+		_, port, err := net.SplitHostPort(dest)
+		if _, perr := strconv.Atoi(port); err == nil && perr == nil {
+			return "http://" + dest
+		}
+		return dest
+	}
+	if rest, ok := strings.CutPrefix(dest, "https+insecure://"); ok {
+		return "https://" + rest
+	}
+	return dest
+}
+
+func (p *Prober) request(ctx context.Context, target, serverName string) ProbeResult {
+	result := ProbeResult{Target: target}
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	trace := &httptrace.ClientTrace{
+		ConnectDone: func(network, addr string, err error) {
+			result.Timing.Connect = time.Since(start)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			result.Timing.TLS = time.Since(start)
+		},
+		GotFirstResponseByte: func() {
+			result.Timing.FirstByte = time.Since(start)
+		},
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, target, nil)
+	if err != nil {
+		result.Err = err.Error()
+		return result
+	}
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: p.dial,
+			// Certificates are verified separately so that a bad one is
+			// described instead of hiding the response.
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true, ServerName: serverName},
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	result.Timing.Total = time.Since(start)
+	if err != nil {
+		result.Err = err.Error()
+		return result
+	}
+	resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	result.Status = resp.Status
+	result.Headers = sortedHeaders(resp.Header)
+	if resp.TLS != nil {
+		result.TLS = p.describeTLS(req.URL.Hostname(), *resp.TLS)
+	}
+	return result
+}
+
+// connect probes a TCP endpoint, with a TLS handshake for tcp+tls.
+func (p *Prober) connect(ctx context.Context, addr string, useTLS bool, serverName string) ProbeResult {
+	result := ProbeResult{Target: addr}
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := p.dial(ctx, "tcp", addr)
+	result.Timing.Connect = time.Since(start)
+	if err != nil {
+		result.Timing.Total = result.Timing.Connect
+		result.Err = err.Error()
+		return result
+	}
+	defer conn.Close()
+	if useTLS {
+		tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true, ServerName: serverName})
+		err := tlsConn.HandshakeContext(ctx)
+		result.Timing.TLS = time.Since(start)
+		if err != nil {
+			result.Err = err.Error()
+		} else {
+			result.TLS = p.describeTLS(serverName, tlsConn.ConnectionState())
+		}
+	}
+	result.Timing.Total = time.Since(start)
+	return result
+}
+
+func (p *Prober) describeTLS(hostname string, state tls.ConnectionState) *ProbeTLS {
+	info := &ProbeTLS{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+	}
+	info.Cert, _ = newCertInfo(hostname, state.PeerCertificates, p.roots, time.Now())
+	return info
+}
+
+func sortedHeaders(header http.Header) []ProbeHeader {
+	var headers []ProbeHeader
+	for name, values := range header {
+		headers = append(headers, ProbeHeader{Name: name, Value: strings.Join(values, ", ")})
+	}
+	sort.Slice(headers, func(i, j int) bool {
+		return headers[i].Name < headers[j].Name
+	})
+	return headers
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestProber sends connections for hostname to addr, standing in for
+// the serve listener.
+func newTestProber(hostname, addr string) *Prober {
+	var dialer net.Dialer
+	return &Prober{dial: func(ctx context.Context, network, target string) (net.Conn, error) {
+		if host, _, _ := net.SplitHostPort(target); host == hostname {
+			target = addr
+		}
+		return dialer.DialContext(ctx, network, target)
+	}}
+}
+
+func newServeStandIn(t *testing.T, code int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+	}))
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProber_Probe(t *testing.T) {
+	serve := newServeStandIn(t, http.StatusOK)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Backend", "web")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer backend.Close()
+	p := newTestProber("example.com", serve.Listener.Addr().String())
+
+	result := p.Probe(context.Background(), "example.com", PortEntry{Protocol: "https", ExposePort: "443", Destination: backend.URL})
+
+	if result.Service.Target != "https://example.com/" || result.Service.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected service result %+v", result.Service)
+	}
+	if result.Service.TLS == nil || result.Service.TLS.Cert == nil || result.Service.TLS.Cert.SANs[0] != "example.com" {
+		t.Errorf("expected the TLS details of the serve listener, got %+v", result.Service.TLS)
+	}
+	if result.Backend.StatusCode != http.StatusNoContent || result.Backend.TLS != nil {
+		t.Errorf("unexpected backend result %+v", result.Backend)
+	}
+	if !hasHeader(result.Backend.Headers, "X-Backend", "web") {
+		t.Errorf("expected the backend headers, got %+v", result.Backend.Headers)
+	}
+	if result.Backend.Timing.Total <= 0 || result.Backend.Timing.FirstByte <= 0 {
+		t.Errorf("expected timings, got %+v", result.Backend.Timing)
+	}
+	if result.Diagnosis() != "probe.ok" {
+		t.Errorf("expected probe.ok, got %s", result.Diagnosis())
+	}
+}
+
+func TestProber_BackendDown(t *testing.T) {
+	serve := newServeStandIn(t, http.StatusBadGateway)
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	ln.Close()
+	p := newTestProber("example.com", serve.Listener.Addr().String())
+
+	result := p.Probe(context.Background(), "example.com", PortEntry{Protocol: "https", ExposePort: "443", Path: "/api", Destination: addr})
+
+	if result.Service.Target != "https://example.com/api" || result.Backend.Target != "http://"+addr {
+		t.Errorf("unexpected targets %s and %s", result.Service.Target, result.Backend.Target)
+	}
+	if result.Backend.Err == "" || result.Diagnosis() != "probe.backend_failed" {
+		t.Errorf("expected the backend to be blamed, got %+v", result)
+	}
+}
+
+func TestProber_ServeFails(t *testing.T) {
+	serve := newServeStandIn(t, http.StatusBadGateway)
+	backend := httptest.NewServer(http.NotFoundHandler())
+	defer backend.Close()
+	p := newTestProber("example.com", serve.Listener.Addr().String())
+
+	result := p.Probe(context.Background(), "example.com", PortEntry{Protocol: "https", ExposePort: "443", Destination: backend.URL})
+
+	if result.Diagnosis() != "probe.serve_failed" {
+		t.Errorf("expected the serve layer to be blamed, got %s: %+v", result.Diagnosis(), result)
+	}
+}
+
+func TestProber_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	p := newTestProber("db.example.com", ln.Addr().String())
+
+	result := p.Probe(context.Background(), "db.example.com", PortEntry{Protocol: "tcp", ExposePort: "5432", Destination: "tcp://" + ln.Addr().String()})
+
+	if result.Service.Target != "db.example.com:5432" || result.Service.Err != "" || result.Backend.Err != "" {
+		t.Errorf("expected both connections to succeed, got %+v", result)
+	}
+}
+
+func TestProber_SkipsTextDestination(t *testing.T) {
+	serve := newServeStandIn(t, http.StatusOK)
+	p := newTestProber("example.com", serve.Listener.Addr().String())
+
+	result := p.Probe(context.Background(), "example.com", PortEntry{Protocol: "https", ExposePort: "443", Destination: "text:hello"})
+
+	if !result.Backend.Skipped || result.Diagnosis() != "probe.ok" {
+		t.Errorf("expected the backend to be skipped, got %+v", result.Backend)
+	}
+}
+
+func TestBackendURL(t *testing.T) {
+	tests := map[string]string{
+		"3000":                            "http://localhost:3000",
+		"localhost:3000":                  "http://localhost:3000",
+		"http://10.0.0.5:8080":            "http://10.0.0.5:8080",
+		"https+insecure://localhost:8443": "https://localhost:8443",
+		"tcp://localhost:5432":            "tcp://localhost:5432",
+		"unix:/run/app.sock":              "unix:/run/app.sock",
+	}
+	for dest, want := range tests {
+		if got := backendURL(dest); got != want {
+			t.Errorf("backendURL(%q) = %q, want %q", dest, got, want)
+		}
+	}
+}
+
+func hasHeader(headers []ProbeHeader, name, value string) bool {
+	for _, h := range headers {
+		if h.Name == name && h.Value == value {
+			return true
+		}
+	}
+	return false
+}
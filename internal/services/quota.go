@@ -0,0 +1,42 @@
+package services
+
+// QuotaUsage compares the services advertised by this node with the service
+// limit. The limit is tailnet-wide, so Used undercounts when other nodes
+// advertise services as well.
+type QuotaUsage struct {
+	Used  int
+	Limit int
+}
+
+func NewQuotaUsage(svcs []ServiceView, limit int) QuotaUsage {
+	return QuotaUsage{Used: len(svcs), Limit: limit}
+}
+
+func (q QuotaUsage) Enabled() bool {
+	return q.Limit > 0
+}
+
+func (q QuotaUsage) Full() bool {
+	return q.Enabled() && q.Used >= q.Limit
+}
+
+func (q QuotaUsage) Percent() int {
+	if !q.Enabled() {
+		return 0
+	}
+	return min(q.Used*100/q.Limit, 100)
+}
+
+// WouldExceed reports whether advertising name adds a service beyond the
+// limit. Adding endpoints to an existing service does not count.
+func (q QuotaUsage) WouldExceed(svcs []ServiceView, name string) bool {
+	if !q.Enabled() {
+		return false
+	}
+	for _, svc := range svcs {
+		if svc.Name == name {
+			return false
+		}
+	}
+	return q.Used+1 > q.Limit
+}
@@ -0,0 +1,35 @@
+package services
+
+import "testing"
+
+func TestQuotaUsage(t *testing.T) {
+	svcs := []ServiceView{{Name: "a"}, {Name: "b"}}
+
+	quota := NewQuotaUsage(svcs, 2)
+
+	if !quota.Full() {
+		t.Error("expected quota to be full")
+	}
+	if quota.Percent() != 100 {
+		t.Errorf("expected 100 percent, got %d", quota.Percent())
+	}
+	if !quota.WouldExceed(svcs, "c") {
+		t.Error("expected new service to exceed quota")
+	}
+	if quota.WouldExceed(svcs, "a") {
+		t.Error("expected existing service not to exceed quota")
+	}
+}
+
+func TestQuotaUsage_Disabled(t *testing.T) {
+	svcs := []ServiceView{{Name: "a"}}
+
+	quota := NewQuotaUsage(svcs, 0)
+
+	if quota.Enabled() || quota.Full() {
+		t.Error("expected quota to be disabled")
+	}
+	if quota.WouldExceed(svcs, "b") {
+		t.Error("expected disabled quota never to be exceeded")
+	}
+}
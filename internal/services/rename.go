@@ -0,0 +1,81 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrRenameNotServing = errors.New("renamed service is not serving every endpoint")
+
+type RenameExecutor interface {
+	DuplicateExecutor
+	GetServiceByName(name string) (*ServiceDetailView, error)
+	ClearService(name string) error
+}
+
+// RenameResult records how far a rename got. The old service is only
+// cleared once the new one is verified, so at any failure one complete copy
+// is still serving.
+type RenameResult struct {
+	Plan        DuplicatePlan
+	Copy        DuplicateResult
+	VerifyErr   error
+	RolledBack  bool
+	RollbackErr error
+	ClearErr    error
+}
+
+func (r RenameResult) Verified() bool {
+	return r.Copy.OK() && r.VerifyErr == nil
+}
+
+func (r RenameResult) OK() bool {
+	return r.Verified() && r.ClearErr == nil
+}
+
+// RenameService re-creates every endpoint of source under newName, checks
+// that the new service serves all of them and then clears source. If the
+// copy is incomplete the new service is cleared again and source is left
+// untouched.
+func RenameService(executor RenameExecutor, source *ServiceDetailView, newName string) RenameResult {
+	result := RenameResult{Plan: PlanDuplicate(source, newName, nil)}
+
+	result.Copy = ExecuteDuplicate(executor, result.Plan)
+	if result.Copy.OK() {
+		result.VerifyErr = verifyRename(executor, result.Plan)
+	}
+	if !result.Verified() {
+		if len(result.Copy.Added) > 0 {
+			result.RollbackErr = executor.ClearService(newName)
+			result.RolledBack = result.RollbackErr == nil
+		}
+		return result
+	}
+
+	result.ClearErr = executor.ClearService(source.Name)
+	return result
+}
+
+func verifyRename(executor RenameExecutor, plan DuplicatePlan) error {
+	svc, err := executor.GetServiceByName(plan.Target)
+	if err != nil {
+		return err
+	}
+	if svc == nil {
+		return ErrRenameNotServing
+	}
+	for _, want := range plan.Endpoints {
+		key := PortEntry{Protocol: want.Protocol, ExposePort: want.ExposePort, Path: want.Path}.Key()
+		found := false
+		for _, port := range svc.Ports {
+			if port.Key() == key && port.Destination == want.Destination {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: %s missing", ErrRenameNotServing, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+// fakeServe keeps serve state in memory so that verification sees what the
+// earlier steps did.
+type fakeServe struct {
+	services map[string]*ServiceDetailView
+	failAdd  string
+	dropAdd  string
+	clearErr error
+	cleared  []string
+}
+
+func newFakeServe() *fakeServe {
+	return &fakeServe{services: map[string]*ServiceDetailView{
+		"old": {Name: "old", Ports: []PortEntry{
+			{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3000"},
+			{Protocol: "https", ExposePort: "443", Path: "/api", Destination: "http://localhost:4000"},
+		}},
+	}}
+}
+
+func (f *fakeServe) add(params EndpointParams) error {
+	if params.Path == f.failAdd {
+		return errors.New("add failed")
+	}
+	svc := f.services[params.ServiceName]
+	if svc == nil {
+		svc = &ServiceDetailView{Name: params.ServiceName}
+		f.services[params.ServiceName] = svc
+	}
+	if params.Path != f.dropAdd {
+		svc.Ports = append(svc.Ports, PortEntry{Protocol: params.Protocol, ExposePort: params.ExposePort, Path: params.Path, Destination: params.Destination})
+	}
+	return nil
+}
+
+func (f *fakeServe) AdvertiseService(params AdvertiseServiceParams) error {
+	return f.add(EndpointParams(params))
+}
+
+func (f *fakeServe) AddEndpoint(params EndpointParams) error {
+	return f.add(params)
+}
+
+func (f *fakeServe) GetServiceByName(name string) (*ServiceDetailView, error) {
+	return f.services[name], nil
+}
+
+func (f *fakeServe) ClearService(name string) error {
+	if f.clearErr != nil && name == "old" {
+		return f.clearErr
+	}
+	f.cleared = append(f.cleared, name)
+	delete(f.services, name)
+	return nil
+}
+
+func TestRenameService(t *testing.T) {
+	serve := newFakeServe()
+	result := RenameService(serve, serve.services["old"], "new")
+
+	if !result.OK() {
+		t.Fatalf("expected rename to succeed, got %+v", result)
+	}
+	if serve.services["old"] != nil {
+		t.Error("expected old service to be cleared")
+	}
+	if got := serve.services["new"]; got == nil || len(got.Ports) != 2 {
+		t.Errorf("expected new service with 2 endpoints, got %+v", got)
+	}
+}
+
+func TestRenameService_CopyFailsRollsBack(t *testing.T) {
+	serve := newFakeServe()
+	serve.failAdd = "/api"
+	result := RenameService(serve, serve.services["old"], "new")
+
+	if result.OK() || result.Verified() || !result.RolledBack {
+		t.Fatalf("expected rolled back failure, got %+v", result)
+	}
+	if serve.services["new"] != nil {
+		t.Error("expected partial copy to be cleared")
+	}
+	if serve.services["old"] == nil {
+		t.Error("expected old service to be kept")
+	}
+}
+
+func TestRenameService_VerifyFailsRollsBack(t *testing.T) {
+	serve := newFakeServe()
+	serve.dropAdd = "/api"
+	result := RenameService(serve, serve.services["old"], "new")
+
+	if !errors.Is(result.VerifyErr, ErrRenameNotServing) {
+		t.Fatalf("expected verification error, got %v", result.VerifyErr)
+	}
+	if !result.RolledBack || serve.services["old"] == nil {
+		t.Errorf("expected copy rolled back and old kept, got %+v", result)
+	}
+}
+
+func TestRenameService_ClearFailsKeepsBoth(t *testing.T) {
+	serve := newFakeServe()
+	serve.clearErr = errors.New("clear failed")
+	result := RenameService(serve, serve.services["old"], "new")
+
+	if result.OK() || !result.Verified() || result.ClearErr == nil {
+		t.Fatalf("expected verified copy with clear error, got %+v", result)
+	}
+	if serve.services["old"] == nil || serve.services["new"] == nil {
+		t.Error("expected both services to be serving")
+	}
+}
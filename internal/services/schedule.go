@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+// Schedule turns an endpoint on when EnableAt fires and off when DisableAt
+// fires. Enabled is the state the schedule asks for and Exposed is what was
+// last applied through tailscale serve; they differ while a change is
+// pending or being retried.
+type Schedule struct {
+	ID        string         `json:"id"`
+	Endpoint  EndpointParams `json:"endpoint"`
+	EnableAt  string         `json:"enable_at"`
+	DisableAt string         `json:"disable_at"`
+	Enabled   bool           `json:"enabled"`
+	Exposed   bool           `json:"exposed"`
+	CheckedAt time.Time      `json:"checked_at"`
+}
+
+func (s Schedule) ServiceName() string {
+	return s.Endpoint.ServiceName
+}
+
+func (s Schedule) Matches(port PortEntry) bool {
+	return s.Endpoint.Protocol == port.Protocol &&
+		s.Endpoint.ExposePort == port.ExposePort &&
+		normalizePath(s.Endpoint.Path) == normalizePath(port.Path)
+}
+
+func (s Schedule) specs() (CronSpec, CronSpec, error) {
+	on, err := ParseCron(s.EnableAt)
+	if err != nil {
+		return CronSpec{}, CronSpec{}, err
+	}
+	off, err := ParseCron(s.DisableAt)
+	if err != nil {
+		return CronSpec{}, CronSpec{}, err
+	}
+	return on, off, nil
+}
+
+// nextTransition returns the first enable or disable after t. When both fire
+// in the same minute the endpoint is disabled.
+func nextTransition(on, off CronSpec, t time.Time) (time.Time, bool) {
+	nextOn, nextOff := on.Next(t), off.Next(t)
+	switch {
+	case nextOn.IsZero():
+		return nextOff, false
+	case nextOff.IsZero() || nextOn.Before(nextOff):
+		return nextOn, true
+	default:
+		return nextOff, false
+	}
+}
+
+// NextTransition reports when the schedule next changes state after now and
+// whether that change enables the endpoint.
+func (s Schedule) NextTransition(now time.Time) (time.Time, bool) {
+	on, off, err := s.specs()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return nextTransition(on, off, now)
+}
+
+// stateAt replays every transition between CheckedAt and now, so that
+// windows missed while twintail was stopped still settle on the right state.
+func (s Schedule) stateAt(now time.Time) bool {
+	on, off, err := s.specs()
+	if err != nil {
+		return s.Enabled
+	}
+	enabled := s.Enabled
+	t := s.CheckedAt
+	for {
+		next, enable := nextTransition(on, off, t)
+		if next.IsZero() || next.After(now) {
+			return enabled
+		}
+		enabled, t = enable, next
+	}
+}
+
+// ValidateSchedule checks both expressions and that they ever fire.
+func ValidateSchedule(enableAt, disableAt string, now time.Time) error {
+	for _, expr := range []string{enableAt, disableAt} {
+		spec, err := ParseCron(expr)
+		if err != nil {
+			return err
+		}
+		if spec.Next(now).IsZero() {
+			return errors.New("cron expression " + expr + " never fires")
+		}
+	}
+	if enableAt == disableAt {
+		return errors.New("enable and disable times must differ")
+	}
+	return nil
+}
+
+// scheduleLookback is how far back a new schedule looks to decide whether
+// it starts inside or outside its window.
+const scheduleLookback = 31 * 24 * time.Hour
+
+type ScheduleStore struct {
+	path    string
+	mu      sync.Mutex
+	entries []Schedule
+}
+
+func NewScheduleStore(dataDir string) (*ScheduleStore, error) {
+	if err := os.MkdirAll(dataDir, 0o750); err != nil {
+		return nil, err
+	}
+	s := &ScheduleStore{path: filepath.Join(dataDir, "schedules.json")}
+	if err := loadJSON(s.path, &s.entries); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *ScheduleStore) save() error {
+	return saveJSON(s.path, s.entries)
+}
+
+// Add schedules an endpoint that is currently exposed. The endpoint is
+// disabled on the next run if now falls outside the window.
+func (s *ScheduleStore) Add(params EndpointParams, enableAt, disableAt string, now time.Time) (Schedule, error) {
+	if err := ValidateSchedule(enableAt, disableAt, now); err != nil {
+		return Schedule{}, err
+	}
+	id, err := newStoreID()
+	if err != nil {
+		return Schedule{}, err
+	}
+	sched := Schedule{
+		ID:        id,
+		Endpoint:  params,
+		EnableAt:  enableAt,
+		DisableAt: disableAt,
+		Enabled:   true,
+		Exposed:   true,
+		CheckedAt: now.Add(-scheduleLookback),
+	}
+	sched.Enabled = sched.stateAt(now)
+	sched.CheckedAt = now
+
+	port := PortEntry{Protocol: params.Protocol, ExposePort: params.ExposePort, Path: params.Path}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = s.without(func(old Schedule) bool {
+		return old.ServiceName() == params.ServiceName && old.Matches(port)
+	})
+	s.entries = append(s.entries, sched)
+	return sched, s.save()
+}
+
+func (s *ScheduleStore) Get(id string) (Schedule, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sched := range s.entries {
+		if sched.ID == id {
+			return sched, true
+		}
+	}
+	return Schedule{}, false
+}
+
+func (s *ScheduleStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = s.without(func(sched Schedule) bool { return sched.ID == id })
+	return s.save()
+}
+
+func (s *ScheduleStore) ForgetService(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = s.without(func(sched Schedule) bool { return sched.ServiceName() == name })
+	return s.save()
+}
+
+func (s *ScheduleStore) ForgetEndpoint(params EndpointParams) error {
+	port := PortEntry{Protocol: params.Protocol, ExposePort: params.ExposePort, Path: params.Path}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = s.without(func(sched Schedule) bool {
+		return sched.ServiceName() == params.ServiceName && sched.Matches(port)
+	})
+	return s.save()
+}
+
+func (s *ScheduleStore) RenameService(oldName, newName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.entries {
+		if s.entries[i].ServiceName() == oldName {
+			s.entries[i].Endpoint.ServiceName = newName
+		}
+	}
+	return s.save()
+}
+
+// UpdateEndpoint points the schedules of an edited endpoint at its new
+// destination, so that the next transition adds or removes the endpoint as
+// it is now.
+func (s *ScheduleStore) UpdateEndpoint(params UpdateEndpointParams) error {
+	port := PortEntry{Protocol: params.Protocol, ExposePort: params.ExposePort, Path: params.Path}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.entries {
+		if s.entries[i].ServiceName() == params.ServiceName && s.entries[i].Matches(port) {
+			s.entries[i].Endpoint.Destination = params.NewDestination
+		}
+	}
+	return s.save()
+}
+
+func (s *ScheduleStore) ForService(name string) []Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []Schedule
+	for _, sched := range s.entries {
+		if sched.ServiceName() == name {
+			result = append(result, sched)
+		}
+	}
+	return result
+}
+
+func (s *ScheduleStore) All() []Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Schedule(nil), s.entries...)
+}
+
+func (s *ScheduleStore) update(id string, change func(*Schedule)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.entries {
+		if s.entries[i].ID == id {
+			change(&s.entries[i])
+			return s.save()
+		}
+	}
+	return ErrScheduleNotFound
+}
+
+func (s *ScheduleStore) without(drop func(Schedule) bool) []Schedule {
+	kept := s.entries[:0:0]
+	for _, sched := range s.entries {
+		if !drop(sched) {
+			kept = append(kept, sched)
+		}
+	}
+	return kept
+}
+
+type ScheduleExecutor interface {
+	AddEndpoint(params EndpointParams) error
+	RemoveEndpoint(params EndpointParams) error
+}
+
+type ScheduleResult struct {
+	Schedule Schedule
+	Enabled  bool
+	Err      error
+}
+
+// RunSchedules brings every scheduled endpoint in line with its window at
+// now. Failed changes leave Exposed untouched and are retried on the next run.
+func RunSchedules(store *ScheduleStore, executor ScheduleExecutor, now time.Time) []ScheduleResult {
+	var results []ScheduleResult
+	for _, sched := range store.All() {
+		enabled := sched.stateAt(now)
+		if enabled == sched.Exposed {
+			if enabled != sched.Enabled {
+				store.update(sched.ID, func(s *Schedule) { s.Enabled, s.CheckedAt = enabled, now })
+			}
+			continue
+		}
+
+		var err error
+		if enabled {
+			err = executor.AddEndpoint(sched.Endpoint)
+		} else {
+			err = executor.RemoveEndpoint(sched.Endpoint)
+		}
+		saveErr := store.update(sched.ID, func(s *Schedule) {
+			s.Enabled, s.CheckedAt = enabled, now
+			if err == nil {
+				s.Exposed = enabled
+			}
+		})
+		if err == nil && !errors.Is(saveErr, ErrScheduleNotFound) {
+			err = saveErr
+		}
+		results = append(results, ScheduleResult{Schedule: sched, Enabled: enabled, Err: err})
+	}
+	return results
+}
+
+// RunScheduler applies schedules right away and then every interval.
+func RunScheduler(ctx context.Context, store *ScheduleStore, executor ScheduleExecutor, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, r := range RunSchedules(store, executor, time.Now()) {
+			action := "disable"
+			if r.Enabled {
+				action = "enable"
+			}
+			if r.Err != nil {
+				logger.Error("failed to apply endpoint schedule", "service", r.Schedule.ServiceName(), "action", action, "error", r.Err)
+			} else {
+				logger.Info("applied endpoint schedule", "service", r.Schedule.ServiceName(), "action", action)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type mockScheduleExecutor struct {
+	added   []EndpointParams
+	removed []EndpointParams
+	err     error
+}
+
+func (m *mockScheduleExecutor) AddEndpoint(params EndpointParams) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.added = append(m.added, params)
+	return nil
+}
+
+func (m *mockScheduleExecutor) RemoveEndpoint(params EndpointParams) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.removed = append(m.removed, params)
+	return nil
+}
+
+var officeEndpoint = EndpointParams{ServiceName: "wiki", Protocol: "https", ExposePort: "443", Destination: "http://localhost:3000"}
+
+// clock returns a time in March 2026; the 2nd is a Monday.
+func clock(day, hour, minute int) time.Time {
+	return time.Date(2026, 3, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestScheduleStore_AddInsideAndOutsideWindow(t *testing.T) {
+	store, _ := NewScheduleStore(t.TempDir())
+
+	inside, err := store.Add(officeEndpoint, "0 9 * * 1-5", "0 18 * * 1-5", clock(2, 10, 0))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !inside.Enabled {
+		t.Error("expected schedule added during office hours to be enabled")
+	}
+
+	outside, err := store.Add(officeEndpoint, "0 9 * * 1-5", "0 18 * * 1-5", clock(7, 10, 0))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if outside.Enabled {
+		t.Error("expected schedule added on a Saturday to be disabled")
+	}
+	if got := store.ForService("wiki"); len(got) != 1 || got[0].ID != outside.ID {
+		t.Errorf("expected the second schedule to replace the first, got %+v", got)
+	}
+}
+
+func TestScheduleStore_AddInvalid(t *testing.T) {
+	store, _ := NewScheduleStore(t.TempDir())
+	tests := [][2]string{
+		{"0 9 * * 1-5", "bad"},
+		{"0 9 * * 1-5", "0 9 * * 1-5"},
+		{"0 0 31 2 *", "0 18 * * *"},
+	}
+	for _, tt := range tests {
+		if _, err := store.Add(officeEndpoint, tt[0], tt[1], clock(2, 10, 0)); err == nil {
+			t.Errorf("expected %q / %q to be rejected", tt[0], tt[1])
+		}
+	}
+}
+
+func TestRunSchedules_OfficeHours(t *testing.T) {
+	store, _ := NewScheduleStore(t.TempDir())
+	store.Add(officeEndpoint, "0 9 * * 1-5", "0 18 * * 1-5", clock(2, 10, 0))
+	executor := &mockScheduleExecutor{}
+
+	if results := RunSchedules(store, executor, clock(2, 17, 59)); len(results) != 0 {
+		t.Fatalf("expected no change before 18:00, got %+v", results)
+	}
+
+	results := RunSchedules(store, executor, clock(2, 18, 0))
+	if len(results) != 1 || results[0].Enabled || results[0].Err != nil {
+		t.Fatalf("expected endpoint to be disabled at 18:00, got %+v", results)
+	}
+	if len(executor.removed) != 1 || executor.removed[0] != officeEndpoint {
+		t.Errorf("expected endpoint to be removed, got %+v", executor.removed)
+	}
+
+	if results := RunSchedules(store, executor, clock(3, 8, 59)); len(results) != 0 {
+		t.Fatalf("expected no change overnight, got %+v", results)
+	}
+
+	results = RunSchedules(store, executor, clock(3, 9, 0))
+	if len(results) != 1 || !results[0].Enabled {
+		t.Fatalf("expected endpoint to be enabled at 9:00, got %+v", results)
+	}
+	if len(executor.added) != 1 || executor.added[0] != officeEndpoint {
+		t.Errorf("expected endpoint to be added, got %+v", executor.added)
+	}
+
+	sched := store.ForService("wiki")[0]
+	next, enables := sched.NextTransition(clock(3, 9, 0))
+	if !next.Equal(clock(3, 18, 0)) || enables {
+		t.Errorf("expected next transition to disable at 18:00, got %v enables=%v", next, enables)
+	}
+}
+
+func TestRunSchedules_CatchesUpAfterDowntime(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewScheduleStore(dir)
+	store.Add(officeEndpoint, "0 9 * * 1-5", "0 18 * * 1-5", clock(2, 10, 0))
+
+	// twintail was stopped from Monday morning until Saturday.
+	reopened, err := NewScheduleStore(dir)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	executor := &mockScheduleExecutor{}
+	results := RunSchedules(reopened, executor, clock(7, 12, 0))
+	if len(results) != 1 || results[0].Enabled {
+		t.Fatalf("expected a single disable, got %+v", results)
+	}
+	if len(executor.added) != 0 {
+		t.Errorf("expected missed windows not to be replayed, got %+v", executor.added)
+	}
+}
+
+func TestRunSchedules_RetriesFailures(t *testing.T) {
+	store, _ := NewScheduleStore(t.TempDir())
+	store.Add(officeEndpoint, "0 9 * * 1-5", "0 18 * * 1-5", clock(2, 10, 0))
+	executor := &mockScheduleExecutor{err: errors.New("tailscale unavailable")}
+
+	results := RunSchedules(store, executor, clock(2, 18, 0))
+	if len(results) != 1 || results[0].Err == nil {
+		t.Fatalf("expected a failed disable, got %+v", results)
+	}
+	sched := store.ForService("wiki")[0]
+	if sched.Enabled || !sched.Exposed {
+		t.Errorf("expected pending disable, got enabled=%v exposed=%v", sched.Enabled, sched.Exposed)
+	}
+
+	executor.err = nil
+	results = RunSchedules(store, executor, clock(2, 18, 1))
+	if len(results) != 1 || results[0].Err != nil || len(executor.removed) != 1 {
+		t.Fatalf("expected the disable to be retried, got %+v", results)
+	}
+	if store.ForService("wiki")[0].Exposed {
+		t.Error("expected endpoint to be marked as not exposed")
+	}
+}
+
+func TestScheduleStore_Forget(t *testing.T) {
+	store, _ := NewScheduleStore(t.TempDir())
+	store.Add(officeEndpoint, "0 9 * * 1-5", "0 18 * * 1-5", clock(2, 10, 0))
+	other := officeEndpoint
+	other.ExposePort = "8443"
+	store.Add(other, "0 9 * * 1-5", "0 18 * * 1-5", clock(2, 10, 0))
+
+	store.ForgetEndpoint(EndpointParams{ServiceName: "wiki", Protocol: "https", ExposePort: "443"})
+	if got := store.ForService("wiki"); len(got) != 1 || got[0].Endpoint.ExposePort != "8443" {
+		t.Errorf("expected only the 8443 schedule to remain, got %+v", got)
+	}
+	store.ForgetService("wiki")
+	if got := store.All(); len(got) != 0 {
+		t.Errorf("expected no schedules, got %+v", got)
+	}
+}
+
+func TestScheduleStore_UpdateEndpoint(t *testing.T) {
+	store, _ := NewScheduleStore(t.TempDir())
+	store.Add(officeEndpoint, "0 9 * * 1-5", "0 18 * * 1-5", clock(2, 10, 0))
+
+	store.UpdateEndpoint(UpdateEndpointParams{ServiceName: "wiki", Protocol: "https", ExposePort: "443", OldDestination: officeEndpoint.Destination, NewDestination: "http://localhost:9090"})
+
+	if got := store.ForService("wiki"); len(got) != 1 || got[0].Endpoint.Destination != "http://localhost:9090" {
+		t.Errorf("expected the schedule to follow the new destination, got %+v", got)
+	}
+}
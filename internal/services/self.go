@@ -0,0 +1,76 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	SelfServeNode    = "node"
+	SelfServeService = "service"
+)
+
+type SelfServeParams struct {
+	Mode        string
+	ServiceName string
+	Destination string
+}
+
+// ParseSelfServe reads a SELF_SERVE value: "node" or "svc:<name>".
+func ParseSelfServe(spec string) (SelfServeParams, error) {
+	switch {
+	case spec == SelfServeNode:
+		return SelfServeParams{Mode: SelfServeNode}, nil
+	case strings.HasPrefix(spec, "svc:"):
+		name := strings.TrimPrefix(spec, "svc:")
+		if !validServiceName(name) {
+			return SelfServeParams{}, fmt.Errorf("invalid self serve service name %q", name)
+		}
+		return SelfServeParams{Mode: SelfServeService, ServiceName: name}, nil
+	}
+	return SelfServeParams{}, fmt.Errorf("unknown self serve mode %q", spec)
+}
+
+func validServiceName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, "-") && !strings.ContainsAny(name, "; \n\r`\x00")
+}
+
+func (s *TailscaleService) ServeSelf(params SelfServeParams) error {
+	if params.Mode == SelfServeService {
+		return s.AdvertiseService(AdvertiseServiceParams{
+			ServiceName: params.ServiceName,
+			Protocol:    "https",
+			ExposePort:  "443",
+			Destination: params.Destination,
+		})
+	}
+
+	// A node left serving twintail by an earlier run needs no change.
+	if status, err := s.readStatus(); err == nil && status.servesNode(params.Destination) {
+		return nil
+	}
+
+	defer s.beginMutation()()
+
+	cmd := execCommand("tailscale", s.syntax().serveNode(params.Destination)...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return newCommandError(output, err)
+	}
+	return nil
+}
+
+func (s *TailscaleService) UnserveSelf(params SelfServeParams) error {
+	if params.Mode == SelfServeService {
+		return s.ClearService(params.ServiceName)
+	}
+
+	defer s.beginMutation()()
+
+	cmd := execCommand("tailscale", s.syntax().unserveNode...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return newCommandError(output, err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSelfServe(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		wantMode string
+		wantName string
+		wantErr  bool
+	}{
+		{"node", "node", SelfServeNode, "", false},
+		{"service", "svc:twintail", SelfServeService, "twintail", false},
+		{"empty service name", "svc:", "", "", true},
+		{"service name with space", "svc:my service", "", "", true},
+		{"unknown", "bogus", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := ParseSelfServe(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSelfServe(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if params.Mode != tt.wantMode || params.ServiceName != tt.wantName {
+				t.Errorf("unexpected params %+v", params)
+			}
+		})
+	}
+}
+
+func captureCommands(t *testing.T) *[]string {
+	t.Helper()
+	var captured []string
+	oldExecCommand := execCommand
+	t.Cleanup(func() { execCommand = oldExecCommand })
+	execCommand = func(name string, args ...string) interface {
+		Output() ([]byte, error)
+		CombinedOutput() ([]byte, error)
+	} {
+		captured = append(captured, strings.Join(args, " "))
+		return &mockCmd{output: []byte("success")}
+	}
+	return &captured
+}
+
+func TestServeSelf_Service(t *testing.T) {
+	captured := captureCommands(t)
+
+	svc := NewTailscaleService()
+	params := SelfServeParams{Mode: SelfServeService, ServiceName: "twintail", Destination: "http://127.0.0.1:8077"}
+	if err := svc.ServeSelf(params); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := svc.UnserveSelf(params); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	want := []string{
+		"serve --service=svc:twintail --https=443 http://127.0.0.1:8077",
+		"serve clear svc:twintail",
+	}
+	if strings.Join(*captured, "|") != strings.Join(want, "|") {
+		t.Errorf("expected commands %v, got %v", want, *captured)
+	}
+}
+
+func TestServeSelf_Node(t *testing.T) {
+	captured := captureCommands(t)
+
+	svc := NewTailscaleService()
+	params := SelfServeParams{Mode: SelfServeNode, Destination: "unix:/run/twintail.sock"}
+	if err := svc.ServeSelf(params); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := svc.UnserveSelf(params); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	want := []string{
+		"serve status --json",
+		"serve --bg --https=443 unix:/run/twintail.sock",
+		"serve --https=443 off",
+	}
+	if strings.Join(*captured, "|") != strings.Join(want, "|") {
+		t.Errorf("expected commands %v, got %v", want, *captured)
+	}
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backend states reported by tailscale status.
+const (
+	BackendRunning          = "Running"
+	BackendNeedsLogin       = "NeedsLogin"
+	BackendNeedsMachineAuth = "NeedsMachineAuth"
+	BackendStopped          = "Stopped"
+)
+
+// KeyExpiryWarning is how long before the node key expires the dashboard
+// starts warning about it.
+const KeyExpiryWarning = 7 * 24 * time.Hour
+
+type statusJSON struct {
+	BackendState string `json:"BackendState"`
+	Self         *struct {
+		HostName     string     `json:"HostName"`
+		DNSName      string     `json:"DNSName"`
+		TailscaleIPs []string   `json:"TailscaleIPs"`
+		Relay        string     `json:"Relay"`
+		Tags         []string   `json:"Tags"`
+		KeyExpiry    *time.Time `json:"KeyExpiry"`
+		UserID       int64      `json:"UserID"`
+	} `json:"Self"`
+	CurrentTailnet *struct {
+		Name           string `json:"Name"`
+		MagicDNSSuffix string `json:"MagicDNSSuffix"`
+	} `json:"CurrentTailnet"`
+	User map[string]struct {
+		LoginName string `json:"LoginName"`
+	} `json:"User"`
+	Health      []string `json:"Health"`
+	CertDomains []string `json:"CertDomains"`
+}
+
+// TailnetStatus is this node's view of the tailnet. KeyExpiry is nil when
+// key expiry is disabled, as it is for tagged nodes.
+type TailnetStatus struct {
+	BackendState string
+	Tailnet      string
+	DNSName      string
+	HostName     string
+	LoginName    string
+	Tags         []string
+	IPs          []string
+	DERPRegion   string
+	KeyExpiry    *time.Time
+	Health       []string
+	CertDomains  []string
+}
+
+func parseTailnetStatus(data []byte) (*TailnetStatus, error) {
+	var raw statusJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	status := &TailnetStatus{
+		BackendState: raw.BackendState,
+		Health:       raw.Health,
+		CertDomains:  raw.CertDomains,
+	}
+	if raw.CurrentTailnet != nil {
+		status.Tailnet = raw.CurrentTailnet.Name
+	}
+	if self := raw.Self; self != nil {
+		status.HostName = self.HostName
+		status.DNSName = strings.TrimSuffix(self.DNSName, ".")
+		status.IPs = self.TailscaleIPs
+		status.DERPRegion = self.Relay
+		status.Tags = self.Tags
+		status.KeyExpiry = self.KeyExpiry
+		if user, ok := raw.User[strconv.FormatInt(self.UserID, 10)]; ok {
+			status.LoginName = user.LoginName
+		}
+	}
+	sort.Strings(status.Tags)
+	return status, nil
+}
+
+func (s *TailnetStatus) Running() bool {
+	return s.BackendState == BackendRunning
+}
+
+// Warnings returns locale keys for the conditions that make serve calls
+// fail, most severe first.
+func (s *TailnetStatus) Warnings(now time.Time) []string {
+	var warnings []string
+	switch s.BackendState {
+	case BackendRunning:
+	case BackendNeedsLogin:
+		warnings = append(warnings, "status.warn_logged_out")
+	case BackendNeedsMachineAuth:
+		warnings = append(warnings, "status.warn_machine_auth")
+	case BackendStopped:
+		warnings = append(warnings, "status.warn_stopped")
+	default:
+		warnings = append(warnings, "status.warn_not_running")
+	}
+	if s.KeyExpiry != nil {
+		switch {
+		case !now.Before(*s.KeyExpiry):
+			warnings = append(warnings, "status.warn_key_expired")
+		case s.KeyExpiry.Sub(now) < KeyExpiryWarning:
+			warnings = append(warnings, "status.warn_key_expiring")
+		}
+	}
+	return warnings
+}
+
+func (s *TailscaleService) GetTailnetStatus() (*TailnetStatus, error) {
+	return readTailnetStatus()
+}
+
+func readTailnetStatus() (*TailnetStatus, error) {
+	cmd := execCommand("tailscale", "status", "--json")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, outputError(err)
+	}
+	return parseTailnetStatus(output)
+}
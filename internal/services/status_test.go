@@ -0,0 +1,72 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetTailnetStatus(t *testing.T) {
+	data, err := os.ReadFile("testdata/status.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldExecCommand := execCommand
+	defer func() { execCommand = oldExecCommand }()
+	execCommand = func(name string, args ...string) interface {
+		Output() ([]byte, error)
+		CombinedOutput() ([]byte, error)
+	} {
+		if !reflect.DeepEqual(args, []string{"status", "--json"}) {
+			return &mockCmd{err: errors.New("unexpected command")}
+		}
+		return &mockCmd{output: data}
+	}
+
+	status, err := NewTailscaleService().GetTailnetStatus()
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !status.Running() || status.Tailnet != "alice@example.com" || status.LoginName != "alice@example.com" {
+		t.Errorf("unexpected state %+v", status)
+	}
+	if status.DNSName != "homelab.tail1234.ts.net" || status.HostName != "homelab" || status.DERPRegion != "fra" {
+		t.Errorf("unexpected node %+v", status)
+	}
+	if !reflect.DeepEqual(status.Tags, []string{"tag:homelab", "tag:server"}) || len(status.IPs) != 2 {
+		t.Errorf("unexpected tags %v or IPs %v", status.Tags, status.IPs)
+	}
+	if status.KeyExpiry == nil || !status.KeyExpiry.Equal(time.Date(2026, 4, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected key expiry %v", status.KeyExpiry)
+	}
+	if len(status.Health) != 1 {
+		t.Errorf("expected one health message, got %v", status.Health)
+	}
+}
+
+func TestTailnetStatus_Warnings(t *testing.T) {
+	expiry := time.Date(2026, 4, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		status TailnetStatus
+		now    time.Time
+		want   []string
+	}{
+		{"healthy", TailnetStatus{BackendState: BackendRunning, KeyExpiry: &expiry}, expiry.Add(-30 * 24 * time.Hour), nil},
+		{"no expiry", TailnetStatus{BackendState: BackendRunning}, expiry, nil},
+		{"expiring", TailnetStatus{BackendState: BackendRunning, KeyExpiry: &expiry}, expiry.Add(-48 * time.Hour), []string{"status.warn_key_expiring"}},
+		{"expired", TailnetStatus{BackendState: BackendNeedsLogin, KeyExpiry: &expiry}, expiry, []string{"status.warn_logged_out", "status.warn_key_expired"}},
+		{"stopped", TailnetStatus{BackendState: BackendStopped}, expiry, []string{"status.warn_stopped"}},
+		{"starting", TailnetStatus{BackendState: "Starting"}, expiry, []string{"status.warn_not_running"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.status.Warnings(tt.now); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
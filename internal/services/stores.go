@@ -0,0 +1,105 @@
+package services
+
+import "errors"
+
+// Stores are the records twintail keeps about services next to the serve
+// config. Whatever removes or renames a service or endpoint updates all of
+// them through Stores, so no record outlives what it describes. Any store
+// may be nil when the feature is not set up.
+type Stores struct {
+	Expiries  *ExpiryStore
+	Schedules *ScheduleStore
+	Drains    *DrainStore
+	Traffic   *TrafficStore
+}
+
+// CheckDrained returns ErrServiceDrained while name is drained on this
+// node, so that nothing advertises it again before it is undrained.
+func (s Stores) CheckDrained(name string) error {
+	if s.Drains != nil {
+		if _, ok := s.Drains.Get(name); ok {
+			return ErrServiceDrained
+		}
+	}
+	return nil
+}
+
+// Resolve returns svc with the destinations it really forwards to, for
+// copying its endpoints elsewhere.
+func (s Stores) Resolve(svc *ServiceDetailView) *ServiceDetailView {
+	if s.Traffic == nil {
+		return svc
+	}
+	return s.Traffic.Resolve(svc)
+}
+
+// ForgetService drops the records of a service that was cleared.
+func (s Stores) ForgetService(name string) error {
+	var errs []error
+	if s.Expiries != nil {
+		errs = append(errs, s.Expiries.ForgetService(name))
+	}
+	if s.Schedules != nil {
+		errs = append(errs, s.Schedules.ForgetService(name))
+	}
+	if s.Drains != nil {
+		errs = append(errs, s.Drains.ForgetService(name))
+	}
+	if s.Traffic != nil {
+		errs = append(errs, s.Traffic.ForgetService(name))
+	}
+	return errors.Join(errs...)
+}
+
+// ForgetEndpoint drops the records of an endpoint that was removed.
+func (s Stores) ForgetEndpoint(params EndpointParams) error {
+	var errs []error
+	if s.Expiries != nil {
+		errs = append(errs, s.Expiries.ForgetEndpoint(params))
+	}
+	if s.Schedules != nil {
+		errs = append(errs, s.Schedules.ForgetEndpoint(params))
+	}
+	if s.Drains != nil {
+		errs = append(errs, s.Drains.ForgetEndpoint(params))
+	}
+	if s.Traffic != nil {
+		errs = append(errs, s.Traffic.ForgetEndpoint(params))
+	}
+	return errors.Join(errs...)
+}
+
+// UpdateEndpoint keeps the destination copied into the records of an
+// endpoint in step with the serve config after the endpoint was edited, and
+// drops a meter the endpoint no longer passes through.
+func (s Stores) UpdateEndpoint(params UpdateEndpointParams) error {
+	var errs []error
+	if s.Expiries != nil {
+		errs = append(errs, s.Expiries.UpdateEndpoint(params))
+	}
+	if s.Schedules != nil {
+		errs = append(errs, s.Schedules.UpdateEndpoint(params))
+	}
+	if s.Traffic != nil {
+		errs = append(errs, s.Traffic.UpdateEndpoint(params))
+	}
+	return errors.Join(errs...)
+}
+
+// RenameService moves the records of a renamed service to its new name.
+func (s Stores) RenameService(oldName, newName string) error {
+	var errs []error
+	if s.Expiries != nil {
+		errs = append(errs, s.Expiries.RenameService(oldName, newName))
+	}
+	if s.Schedules != nil {
+		errs = append(errs, s.Schedules.RenameService(oldName, newName))
+	}
+	if s.Drains != nil {
+		errs = append(errs, s.Drains.RenameService(oldName, newName))
+	}
+	if s.Traffic != nil {
+		errs = append(errs, s.Traffic.RenameService(oldName, newName))
+	}
+	return errors.Join(errs...)
+}
@@ -1,11 +1,18 @@
 package services
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"net/netip"
 	"os/exec"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 var ErrTailscaleNotInstalled = errors.New("tailscale CLI not installed")
@@ -24,9 +31,12 @@ func IsTailscaleNotInstalledError(err error) bool {
 	return false
 }
 
+// CheckInstalled runs tailscale version and remembers the release it
+// reports, which picks the serve syntax. Old releases are not rejected here
+// so that the dashboard can explain what to upgrade.
 func (s *TailscaleService) CheckInstalled() error {
 	cmd := execCommand("tailscale", "version")
-	_, err := cmd.Output()
+	output, err := cmd.Output()
 	if err != nil {
 		var execErr *exec.Error
 		if errors.As(err, &execErr) && errors.Is(execErr.Err, exec.ErrNotFound) {
@@ -34,9 +44,68 @@ func (s *TailscaleService) CheckInstalled() error {
 		}
 		return err
 	}
+	if v, err := ParseVersion(string(output)); err == nil {
+		s.version.Store(&v)
+	}
 	return nil
 }
 
+// Version returns the release found by the last CheckInstalled, or false
+// before it ran or when the output was not recognized.
+func (s *TailscaleService) Version() (Version, bool) {
+	if v := s.version.Load(); v != nil {
+		return *v, true
+	}
+	return Version{}, false
+}
+
+// requireServices fails when the detected release predates Tailscale
+// Services. An unknown release is assumed to be current, and an old one is
+// detected again so that an upgrade is noticed without a restart.
+func (s *TailscaleService) requireServices() error {
+	v, ok := s.Version()
+	if !ok || v.Supported() == nil {
+		return nil
+	}
+	if err := s.CheckInstalled(); err != nil {
+		return err
+	}
+	v, _ = s.Version()
+	return v.Supported()
+}
+
+// syntax returns how to drive the detected release. An unknown release is
+// assumed to be current.
+func (s *TailscaleService) syntax() serveSyntax {
+	v, ok := s.Version()
+	if !ok {
+		return serveSyntaxes[0]
+	}
+	return syntaxFor(v)
+}
+
+func (s *TailscaleService) GetTailnetIPs() ([]netip.Addr, error) {
+	cmd := execCommand("tailscale", "ip")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []netip.Addr
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		ip, err := netip.ParseAddr(line)
+		if err != nil {
+			return nil, err
+		}
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
+
 var execCommand = func(name string, arg ...string) interface {
 	Output() ([]byte, error)
 	CombinedOutput() ([]byte, error)
@@ -53,8 +122,10 @@ type WebEntry struct {
 }
 
 type TCPEntry struct {
-	HTTP  bool `json:"HTTP,omitempty"`
-	HTTPS bool `json:"HTTPS,omitempty"`
+	HTTP         bool   `json:"HTTP,omitempty"`
+	HTTPS        bool   `json:"HTTPS,omitempty"`
+	TCPForward   string `json:"TCPForward,omitempty"`
+	TerminateTLS string `json:"TerminateTLS,omitempty"`
 }
 
 type Service struct {
@@ -63,22 +134,49 @@ type Service struct {
 }
 
 type ServeStatus struct {
-	Services map[string]Service `json:"Services"`
+	// TCP and Web are what the node serves itself, outside any service.
+	TCP         map[string]TCPEntry `json:"TCP,omitempty"`
+	Web         map[string]WebEntry `json:"Web,omitempty"`
+	Services    map[string]Service  `json:"Services"`
+	AllowFunnel map[string]bool     `json:"AllowFunnel,omitempty"`
 }
 
 type ServiceView struct {
 	Name     string
+	Hostname string
 	HTTPSUrl string
 	HTTPUrl  string
-	Proxy    string
+	// Proxy is the first of the sorted destinations, so that it does not
+	// change between requests.
+	Proxy        string
+	Destinations []string
+	// TCPProtocols holds tcp or tcp+tls for each TCP forwarder.
+	TCPProtocols []string
+	Funnel       bool
 }
 
 type PortEntry struct {
 	Protocol    string
 	ExposePort  string
+	Path        string
 	Destination string
 }
 
+// Key identifies the endpoint within its service as "protocol:port:path".
+func (p PortEntry) Key() string {
+	return p.Protocol + ":" + p.ExposePort + ":" + p.Path
+}
+
+func (p PortEntry) params(service string) EndpointParams {
+	return EndpointParams{
+		ServiceName: service,
+		Protocol:    p.Protocol,
+		ExposePort:  p.ExposePort,
+		Path:        p.Path,
+		Destination: p.Destination,
+	}
+}
+
 type ServiceDetailView struct {
 	Name     string
 	Hostname string
@@ -86,41 +184,107 @@ type ServiceDetailView struct {
 	Ports    []PortEntry
 }
 
-type TailscaleService struct{}
+type TailscaleService struct {
+	mutationMu sync.Mutex
+	pendingMu  sync.Mutex
+	pending    int
+	idle       chan struct{}
+	generation atomic.Uint64
+	publisher  Publisher
+	version    atomic.Pointer[Version]
+}
 
 func NewTailscaleService() *TailscaleService {
 	return &TailscaleService{}
 }
 
-func (s *TailscaleService) GetServeStatus() ([]ServiceView, error) {
-	cmd := execCommand("tailscale", "serve", "status", "--json")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
+// SetPublisher reports endpoint changes made through this service.
+func (s *TailscaleService) SetPublisher(p Publisher) {
+	s.publisher = p
+}
+
+func (s *TailscaleService) publish(e Event) {
+	if s.publisher != nil {
+		s.publisher.Publish(e)
 	}
+}
 
-	var status ServeStatus
-	if err := json.Unmarshal(output, &status); err != nil {
+// beginMutation serializes serve config changes so that multi-step updates
+// are never interleaved, and lets shutdown wait for them to finish.
+func (s *TailscaleService) beginMutation() func() {
+	s.pendingMu.Lock()
+	s.pending++
+	s.pendingMu.Unlock()
+
+	s.mutationMu.Lock()
+	s.generation.Add(1)
+	return func() {
+		s.generation.Add(1)
+		s.mutationMu.Unlock()
+
+		s.pendingMu.Lock()
+		s.pending--
+		if s.pending == 0 && s.idle != nil {
+			close(s.idle)
+			s.idle = nil
+		}
+		s.pendingMu.Unlock()
+	}
+}
+
+// Generation changes whenever a serve config change starts or finishes, so
+// a status read between two equal generations saw only outside changes.
+func (s *TailscaleService) Generation() uint64 {
+	return s.generation.Load()
+}
+
+func (s *TailscaleService) WaitForMutations(ctx context.Context) error {
+	s.pendingMu.Lock()
+	if s.pending == 0 {
+		s.pendingMu.Unlock()
+		return nil
+	}
+	if s.idle == nil {
+		s.idle = make(chan struct{})
+	}
+	done := s.idle
+	s.pendingMu.Unlock()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *TailscaleService) GetServeStatus() ([]ServiceView, error) {
+	status, err := s.readServeStatus()
+	if err != nil {
 		return nil, err
 	}
 
 	var services []ServiceView
 	for name, svc := range status.Services {
 		displayName := strings.TrimPrefix(name, "svc:")
-		var httpsUrl, httpUrl, proxy string
+		var hostname, httpsUrl, httpUrl, proxy string
+		var destinations, tcpProtocols []string
+		var funnel bool
 
 		for host, web := range svc.Web {
 			parts := strings.Split(host, ":")
 			if len(parts) != 2 {
 				continue
 			}
-			hostname := parts[0]
+			hostname = parts[0]
 			port := parts[1]
+			funnel = funnel || status.AllowFunnel[host]
 
 			for _, handler := range web.Handlers {
-				if handler.Proxy != "" && proxy == "" {
-					proxy = handler.Proxy
+				if handler.Proxy == "" {
+					continue
 				}
+				destinations = append(destinations, handler.Proxy)
 			}
 
 			if port == "443" {
@@ -132,11 +296,30 @@ func (s *TailscaleService) GetServeStatus() ([]ServiceView, error) {
 			}
 		}
 
+		for _, tcp := range svc.TCP {
+			switch {
+			case tcp.TCPForward == "":
+			case tcp.TerminateTLS != "":
+				tcpProtocols = append(tcpProtocols, "tcp+tls")
+			default:
+				tcpProtocols = append(tcpProtocols, "tcp")
+			}
+		}
+
+		sort.Strings(destinations)
+		sort.Strings(tcpProtocols)
+		if len(destinations) > 0 {
+			proxy = destinations[0]
+		}
 		services = append(services, ServiceView{
-			Name:     displayName,
-			HTTPSUrl: httpsUrl,
-			HTTPUrl:  httpUrl,
-			Proxy:    proxy,
+			Name:         displayName,
+			Hostname:     hostname,
+			HTTPSUrl:     httpsUrl,
+			HTTPUrl:      httpUrl,
+			Proxy:        proxy,
+			Destinations: destinations,
+			TCPProtocols: slices.Compact(tcpProtocols),
+			Funnel:       funnel,
 		})
 	}
 
@@ -147,24 +330,110 @@ func (s *TailscaleService) GetServeStatus() ([]ServiceView, error) {
 	return services, nil
 }
 
-func (s *TailscaleService) GetServiceByName(name string) (*ServiceDetailView, error) {
+// readServeStatus reads the serve config of this node's services. Releases
+// before MinServiceVersion have no Services key, and their config describes
+// the node itself, so they are reported as unsupported instead of empty.
+func (s *TailscaleService) readServeStatus() (*ServeStatus, error) {
+	if err := s.requireServices(); err != nil {
+		return nil, err
+	}
+	return s.readStatus()
+}
+
+// readStatus reads serve status --json the way the detected release prints
+// it.
+func (s *TailscaleService) readStatus() (*ServeStatus, error) {
+	output, err := serveStatusOutput()
+	if err != nil {
+		return nil, err
+	}
+	return s.syntax().parseStatus(output)
+}
+
+func serveStatusOutput() ([]byte, error) {
 	cmd := execCommand("tailscale", "serve", "status", "--json")
 	output, err := cmd.Output()
 	if err != nil {
-		return nil, err
+		return nil, outputError(err)
 	}
+	return output, nil
+}
 
+// parseServeStatus reads serve status --json of releases with Tailscale
+// Services, which list them next to what the node serves itself. Some
+// releases print nothing instead of an empty object when no serve config is
+// set.
+func parseServeStatus(output []byte) (*ServeStatus, error) {
 	var status ServeStatus
+	if len(bytes.TrimSpace(output)) == 0 {
+		return &status, nil
+	}
 	if err := json.Unmarshal(output, &status); err != nil {
 		return nil, err
 	}
+	return &status, nil
+}
+
+// parseNodeStatus reads serve status --json of releases before Tailscale
+// Services, which only describe what the node serves itself. Since 1.52 the
+// config of serve commands running in the foreground is listed under its own
+// key; it is left out, as it goes away with the command.
+func parseNodeStatus(output []byte) (*ServeStatus, error) {
+	var node struct {
+		TCP map[string]TCPEntry `json:"TCP"`
+		Web map[string]WebEntry `json:"Web"`
+	}
+	if len(bytes.TrimSpace(output)) > 0 {
+		if err := json.Unmarshal(output, &node); err != nil {
+			return nil, err
+		}
+	}
+	return &ServeStatus{TCP: node.TCP, Web: node.Web}, nil
+}
+
+// servesNode reports whether the node itself serves destination at
+// https:443.
+func (st *ServeStatus) servesNode(destination string) bool {
+	for host, web := range st.Web {
+		if strings.HasSuffix(host, ":443") && web.Handlers["/"].Proxy == destination {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *TailscaleService) GetServiceByName(name string) (*ServiceDetailView, error) {
+	status, err := s.readServeStatus()
+	if err != nil {
+		return nil, err
+	}
 
-	svcKey := "svc:" + name
-	svc, ok := status.Services[svcKey]
+	svc, ok := status.Services["svc:"+name]
 	if !ok {
 		return nil, nil
 	}
+	return serviceDetail(name, svc), nil
+}
 
+// GetServiceDetails returns every service with its endpoints from a single
+// status read.
+func (s *TailscaleService) GetServiceDetails() ([]ServiceDetailView, error) {
+	status, err := s.readServeStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	var details []ServiceDetailView
+	for key, svc := range status.Services {
+		details = append(details, *serviceDetail(strings.TrimPrefix(key, "svc:"), svc))
+	}
+	sort.Slice(details, func(i, j int) bool {
+		return details[i].Name < details[j].Name
+	})
+	return details, nil
+}
+
+func serviceDetail(name string, svc Service) *ServiceDetailView {
 	detail := &ServiceDetailView{
 		Name: name,
 	}
@@ -191,17 +460,27 @@ func (s *TailscaleService) GetServiceByName(name string) (*ServiceDetailView, er
 			}
 		}
 
-		for _, handler := range web.Handlers {
+		for path, handler := range web.Handlers {
 			if handler.Proxy != "" {
 				detail.Ports = append(detail.Ports, PortEntry{
 					Protocol:    protocol,
 					ExposePort:  port,
+					Path:        path,
 					Destination: handler.Proxy,
 				})
 			}
 		}
 	}
 
+	sort.Slice(detail.Ports, func(i, j int) bool {
+		pi, _ := strconv.Atoi(detail.Ports[i].ExposePort)
+		pj, _ := strconv.Atoi(detail.Ports[j].ExposePort)
+		if pi != pj {
+			return pi < pj
+		}
+		return detail.Ports[i].Path < detail.Ports[j].Path
+	})
+
 	if hasHTTPS {
 		detail.URL = "https://" + detail.Hostname
 	} else if hasHTTP {
@@ -212,38 +491,68 @@ func (s *TailscaleService) GetServiceByName(name string) (*ServiceDetailView, er
 		}
 	}
 
-	return detail, nil
+	return detail
 }
 
 type AdvertiseServiceParams struct {
 	ServiceName string
 	Protocol    string
 	ExposePort  string
+	Path        string
 	Destination string
 }
 
-func (s *TailscaleService) AdvertiseService(params AdvertiseServiceParams) error {
+func serveArgs(serviceName, protocol, exposePort, path, destination string) []string {
 	args := []string{
 		"serve",
-		"--service=svc:" + params.ServiceName,
-		"--" + params.Protocol + "=" + params.ExposePort,
-		params.Destination,
+		"--service=svc:" + serviceName,
+		"--" + protocol + "=" + exposePort,
 	}
+	if path != "" && path != "/" {
+		args = append(args, "--set-path="+path)
+	}
+	return append(args, destination)
+}
+
+func (s *TailscaleService) AdvertiseService(params AdvertiseServiceParams) error {
+	if err := s.requireServices(); err != nil {
+		return err
+	}
+	defer s.beginMutation()()
+
+	args := serveArgs(params.ServiceName, params.Protocol, params.ExposePort, params.Path, params.Destination)
 
 	cmd := execCommand("tailscale", args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return &CommandError{
-			Message: string(output),
-			Err:     err,
-		}
+		return newCommandError(output, err)
 	}
+	s.publish(endpointEvent(EventEndpointAdded, EndpointParams(params)))
 	return nil
 }
 
 type CommandError struct {
 	Message string
 	Err     error
+	Reason  error
+}
+
+func newCommandError(output []byte, err error) *CommandError {
+	return &CommandError{
+		Message: string(output),
+		Err:     err,
+		Reason:  classifyOutput(string(output)),
+	}
+}
+
+// outputError turns a failed Output call into a CommandError carrying the
+// stderr it captured, so that it can be classified like CombinedOutput.
+func outputError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return newCommandError(exitErr.Stderr, err)
+	}
+	return err
 }
 
 func (e *CommandError) Error() string {
@@ -253,15 +562,29 @@ func (e *CommandError) Error() string {
 	return e.Err.Error()
 }
 
+func (e *CommandError) Unwrap() []error {
+	var errs []error
+	if e.Reason != nil {
+		errs = append(errs, e.Reason)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
 func (s *TailscaleService) ClearService(name string) error {
+	if err := s.requireServices(); err != nil {
+		return err
+	}
+	defer s.beginMutation()()
+
 	cmd := execCommand("tailscale", "serve", "clear", "svc:"+name)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return &CommandError{
-			Message: string(output),
-			Err:     err,
-		}
+		return newCommandError(output, err)
 	}
+	s.publish(Event{Type: EventServiceCleared, Service: name})
 	return nil
 }
 
@@ -269,45 +592,47 @@ type EndpointParams struct {
 	ServiceName string
 	Protocol    string
 	ExposePort  string
+	Path        string
 	Destination string
 }
 
 func (s *TailscaleService) AddEndpoint(params EndpointParams) error {
-	args := []string{
-		"serve",
-		"--service=svc:" + params.ServiceName,
-		"--" + params.Protocol + "=" + params.ExposePort,
-		params.Destination,
+	defer s.beginMutation()()
+	return s.addEndpoint(params)
+}
+
+func (s *TailscaleService) addEndpoint(params EndpointParams) error {
+	if err := s.requireServices(); err != nil {
+		return err
 	}
+	args := serveArgs(params.ServiceName, params.Protocol, params.ExposePort, params.Path, params.Destination)
 
 	cmd := execCommand("tailscale", args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return &CommandError{
-			Message: string(output),
-			Err:     err,
-		}
+		return newCommandError(output, err)
 	}
+	s.publish(endpointEvent(EventEndpointAdded, params))
 	return nil
 }
 
 func (s *TailscaleService) RemoveEndpoint(params EndpointParams) error {
-	args := []string{
-		"serve",
-		"--service=svc:" + params.ServiceName,
-		"--" + params.Protocol + "=" + params.ExposePort,
-		params.Destination,
-		"off",
+	defer s.beginMutation()()
+	return s.removeEndpoint(params)
+}
+
+func (s *TailscaleService) removeEndpoint(params EndpointParams) error {
+	if err := s.requireServices(); err != nil {
+		return err
 	}
+	args := append(serveArgs(params.ServiceName, params.Protocol, params.ExposePort, params.Path, params.Destination), "off")
 
 	cmd := execCommand("tailscale", args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return &CommandError{
-			Message: string(output),
-			Err:     err,
-		}
+		return newCommandError(output, err)
 	}
+	s.publish(endpointEvent(EventEndpointRemoved, params))
 	return nil
 }
 
@@ -315,18 +640,22 @@ type UpdateEndpointParams struct {
 	ServiceName    string
 	Protocol       string
 	ExposePort     string
+	Path           string
 	OldDestination string
 	NewDestination string
 }
 
 func (s *TailscaleService) UpdateEndpoint(params UpdateEndpointParams) error {
+	defer s.beginMutation()()
+
 	removeParams := EndpointParams{
 		ServiceName: params.ServiceName,
 		Protocol:    params.Protocol,
 		ExposePort:  params.ExposePort,
+		Path:        params.Path,
 		Destination: params.OldDestination,
 	}
-	if err := s.RemoveEndpoint(removeParams); err != nil {
+	if err := s.removeEndpoint(removeParams); err != nil {
 		return err
 	}
 
@@ -334,7 +663,8 @@ func (s *TailscaleService) UpdateEndpoint(params UpdateEndpointParams) error {
 		ServiceName: params.ServiceName,
 		Protocol:    params.Protocol,
 		ExposePort:  params.ExposePort,
+		Path:        params.Path,
 		Destination: params.NewDestination,
 	}
-	return s.AddEndpoint(addParams)
+	return s.addEndpoint(addParams)
 }
@@ -1,9 +1,11 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"strings"
 	"testing"
+	"time"
 )
 
 type mockCmd struct {
@@ -82,6 +84,52 @@ func TestGetServeStatus_Success(t *testing.T) {
 	if services[0].Proxy != "http://localhost:3000" {
 		t.Errorf("expected proxy 'http://localhost:3000', got '%s'", services[0].Proxy)
 	}
+	if len(services[0].Destinations) != 1 || services[0].Destinations[0] != "http://localhost:3000" {
+		t.Errorf("expected destinations [http://localhost:3000], got %v", services[0].Destinations)
+	}
+}
+
+func TestGetServeStatus_StableProxyAndTCPProtocols(t *testing.T) {
+	oldExecCommand := execCommand
+	defer func() { execCommand = oldExecCommand }()
+	execCommand = func(name string, args ...string) interface {
+		Output() ([]byte, error)
+		CombinedOutput() ([]byte, error)
+	} {
+		return &mockCmd{output: []byte(`{
+			"Services": {
+				"svc:web": {
+					"TCP": {
+						"443": {"HTTPS": true},
+						"5432": {"TCPForward": "localhost:5432"},
+						"6379": {"TCPForward": "localhost:6379", "TerminateTLS": "web.example.com"}
+					},
+					"Web": {
+						"web.example.com:443": {
+							"Handlers": {
+								"/z": {"Proxy": "http://localhost:5000"},
+								"/a": {"Proxy": "http://localhost:4000"},
+								"/": {"Proxy": "http://localhost:3000"}
+							}
+						}
+					}
+				}
+			}
+		}`)}
+	}
+
+	for range 10 {
+		services, err := NewTailscaleService().GetServeStatus()
+		if err != nil || len(services) != 1 {
+			t.Fatalf("expected one service, got %+v, %v", services, err)
+		}
+		if services[0].Proxy != "http://localhost:3000" {
+			t.Fatalf("expected the smallest destination as proxy, got %s", services[0].Proxy)
+		}
+		if got := services[0].TCPProtocols; len(got) != 2 || got[0] != "tcp" || got[1] != "tcp+tls" {
+			t.Fatalf("expected tcp and tcp+tls, got %v", got)
+		}
+	}
 }
 
 func TestGetServeStatus_JSONParseError(t *testing.T) {
@@ -215,6 +263,30 @@ func TestAdvertiseService_Failure(t *testing.T) {
 	}
 }
 
+func TestGetServeStatus_HostnameAndFunnel(t *testing.T) {
+	mockServeOutput = []byte(`{
+		"Services": {
+			"svc:web": {"Web": {"web.tail.ts.net:443": {"Handlers": {"/": {"Proxy": "http://localhost:3000"}}}}},
+			"svc:api": {"Web": {"api.tail.ts.net:8080": {"Handlers": {"/": {"Proxy": "http://localhost:9000"}}}}}
+		},
+		"AllowFunnel": {"web.tail.ts.net:443": true}
+	}`)
+	defer func() { mockServeOutput = nil }()
+	defer setupMockExecCommand()()
+
+	svcs, err := NewTailscaleService().GetServeStatus()
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if svcs[0].Name != "api" || svcs[0].Hostname != "api.tail.ts.net" || svcs[0].Funnel {
+		t.Errorf("unexpected api view %+v", svcs[0])
+	}
+	if svcs[1].Name != "web" || !svcs[1].Funnel {
+		t.Errorf("expected web to be funneled, got %+v", svcs[1])
+	}
+}
+
 func TestGetServeStatus_EmptyServices(t *testing.T) {
 	mockServeOutput = []byte(`{"Services": {}}`)
 	mockCommandError = nil
@@ -692,6 +764,32 @@ func TestRemoveEndpoint_Failure(t *testing.T) {
 	}
 }
 
+func TestRemoveEndpoint_WithPath(t *testing.T) {
+	mockRemoveCommandError = nil
+	capturedRemoveArgs = nil
+	defer func() {
+		capturedRemoveArgs = nil
+	}()
+	defer setupMockExecCommandWithEndpoint()()
+
+	svc := NewTailscaleService()
+	params := EndpointParams{
+		ServiceName: "my-service",
+		Protocol:    "https",
+		ExposePort:  "443",
+		Path:        "/app1",
+		Destination: "http://localhost:8080",
+	}
+	if err := svc.RemoveEndpoint(params); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	argsStr := strings.Join(capturedRemoveArgs, " ")
+	if !strings.Contains(argsStr, "--set-path=/app1") {
+		t.Errorf("expected args to contain '--set-path=/app1', got '%s'", argsStr)
+	}
+}
+
 func TestUpdateEndpoint_Success(t *testing.T) {
 	mockCommandError = nil
 	mockRemoveCommandError = nil
@@ -1030,3 +1128,110 @@ func TestGetServeStatus_InvalidHostFormat(t *testing.T) {
 		t.Errorf("expected empty HTTPSUrl, got '%s'", services[0].HTTPSUrl)
 	}
 }
+
+func TestGetTailnetIPs_Success(t *testing.T) {
+	oldExecCommand := execCommand
+	defer func() { execCommand = oldExecCommand }()
+	execCommand = func(name string, args ...string) interface {
+		Output() ([]byte, error)
+		CombinedOutput() ([]byte, error)
+	} {
+		return &mockCmd{output: []byte("100.64.0.1\nfd7a:115c:a1e0::1\n")}
+	}
+
+	svc := NewTailscaleService()
+	ips, err := svc.GetTailnetIPs()
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(ips) != 2 {
+		t.Fatalf("expected 2 IPs, got %d", len(ips))
+	}
+	if ips[0].String() != "100.64.0.1" || ips[1].String() != "fd7a:115c:a1e0::1" {
+		t.Errorf("unexpected IPs %v", ips)
+	}
+}
+
+func TestGetTailnetIPs_InvalidOutput(t *testing.T) {
+	oldExecCommand := execCommand
+	defer func() { execCommand = oldExecCommand }()
+	execCommand = func(name string, args ...string) interface {
+		Output() ([]byte, error)
+		CombinedOutput() ([]byte, error)
+	} {
+		return &mockCmd{output: []byte("not an ip\n")}
+	}
+
+	svc := NewTailscaleService()
+	_, err := svc.GetTailnetIPs()
+
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+type blockingCmd struct {
+	release chan struct{}
+}
+
+func (b *blockingCmd) Output() ([]byte, error) {
+	<-b.release
+	return nil, nil
+}
+
+func (b *blockingCmd) CombinedOutput() ([]byte, error) {
+	<-b.release
+	return nil, nil
+}
+
+func TestWaitForMutations_WaitsForInFlightUpdate(t *testing.T) {
+	release := make(chan struct{})
+	oldExecCommand := execCommand
+	defer func() { execCommand = oldExecCommand }()
+	execCommand = func(name string, args ...string) interface {
+		Output() ([]byte, error)
+		CombinedOutput() ([]byte, error)
+	} {
+		return &blockingCmd{release: release}
+	}
+
+	svc := NewTailscaleService()
+	done := make(chan error)
+	go func() {
+		done <- svc.UpdateEndpoint(UpdateEndpointParams{
+			ServiceName:    "my-service",
+			Protocol:       "https",
+			ExposePort:     "443",
+			OldDestination: "http://localhost:8080",
+			NewDestination: "http://localhost:9090",
+		})
+	}()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		err := svc.WaitForMutations(ctx)
+		cancel()
+		if err != nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := svc.WaitForMutations(context.Background()); err != nil {
+		t.Errorf("expected no error after mutation finished, got %v", err)
+	}
+}
+
+func TestWaitForMutations_ReturnsWhenIdle(t *testing.T) {
+	svc := NewTailscaleService()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := svc.WaitForMutations(ctx); err != nil {
+		t.Errorf("expected no error without pending mutations, got %v", err)
+	}
+}
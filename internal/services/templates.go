@@ -0,0 +1,153 @@
+package services
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed templates/builtin.json
+var builtinTemplates []byte
+
+const templateHostPlaceholder = "{host}"
+
+type TemplateEndpoint struct {
+	Protocol    string `json:"protocol"`
+	ExposePort  string `json:"expose_port"`
+	Path        string `json:"path,omitempty"`
+	Destination string `json:"destination"`
+}
+
+type ServiceTemplate struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Endpoints   []TemplateEndpoint `json:"endpoints"`
+	Custom      bool               `json:"-"`
+}
+
+func (t ServiceTemplate) Validate() error {
+	if !validServiceName(t.ID) {
+		return fmt.Errorf("template %q: invalid id", t.ID)
+	}
+	if len(t.Endpoints) == 0 {
+		return fmt.Errorf("template %s: no endpoints", t.ID)
+	}
+	for _, ep := range t.Endpoints {
+		switch ep.Protocol {
+		case "https", "http", "tcp", "tcp+tls":
+		default:
+			return fmt.Errorf("template %s: invalid protocol %q", t.ID, ep.Protocol)
+		}
+		if _, err := strconv.Atoi(ep.ExposePort); err != nil {
+			return fmt.Errorf("template %s: invalid expose port %q", t.ID, ep.ExposePort)
+		}
+		if ep.Destination == "" || strings.ContainsAny(ep.Destination, "; \n\r`\x00") {
+			return fmt.Errorf("template %s: invalid destination %q", t.ID, ep.Destination)
+		}
+		if ep.Path != "" && (!strings.HasPrefix(ep.Path, "/") || strings.ContainsAny(ep.Path, "; \n\r`\x00")) {
+			return fmt.Errorf("template %s: invalid path %q", t.ID, ep.Path)
+		}
+	}
+	return nil
+}
+
+// Resolve fills the {host} placeholder of every endpoint destination.
+func (t ServiceTemplate) Resolve(host string) []TemplateEndpoint {
+	if host == "" {
+		host = "localhost"
+	}
+	endpoints := make([]TemplateEndpoint, len(t.Endpoints))
+	for i, ep := range t.Endpoints {
+		ep.Destination = strings.ReplaceAll(ep.Destination, templateHostPlaceholder, host)
+		endpoints[i] = ep
+	}
+	return endpoints
+}
+
+func parseTemplates(data []byte) ([]ServiceTemplate, error) {
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "{") {
+		var t ServiceTemplate
+		if err := json.Unmarshal(data, &t); err != nil {
+			return nil, err
+		}
+		return []ServiceTemplate{t}, nil
+	}
+	var templates []ServiceTemplate
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// LoadTemplates returns the built-in templates merged with the *.json files
+// in dir, which may each hold one template or a list. User templates replace
+// built-ins with the same id. Invalid files are skipped and reported in the
+// returned error.
+func LoadTemplates(dir string) ([]ServiceTemplate, error) {
+	builtins, err := parseTemplates(builtinTemplates)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]ServiceTemplate)
+	for _, t := range builtins {
+		byID[t.ID] = t
+	}
+
+	var errs []error
+	if dir != "" {
+		files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			templates, err := parseTemplates(data)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", file, err))
+				continue
+			}
+			for _, t := range templates {
+				if err := t.Validate(); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", file, err))
+					continue
+				}
+				t.Custom = true
+				byID[t.ID] = t
+			}
+		}
+	}
+
+	templates := make([]ServiceTemplate, 0, len(byID))
+	for _, t := range byID {
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+
+	if len(errs) > 0 {
+		return templates, errors.Join(errs...)
+	}
+	return templates, nil
+}
+
+func FindTemplate(templates []ServiceTemplate, id string) (ServiceTemplate, bool) {
+	for _, t := range templates {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return ServiceTemplate{}, false
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadTemplates_Builtin(t *testing.T) {
+	templates, err := LoadTemplates("")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for _, id := range []string{"grafana", "home-assistant", "jellyfin", "postgres"} {
+		if _, ok := FindTemplate(templates, id); !ok {
+			t.Errorf("expected built-in template %s", id)
+		}
+	}
+	for _, tmpl := range templates {
+		if err := tmpl.Validate(); err != nil {
+			t.Errorf("built-in template is invalid: %v", err)
+		}
+	}
+}
+
+func TestLoadTemplates_UserDir(t *testing.T) {
+	dir := t.TempDir()
+	custom := `{"id": "grafana", "name": "Grafana", "endpoints": [{"protocol": "https", "expose_port": "443", "destination": "http://{host}:3300"}]}`
+	list := `[{"id": "wiki", "name": "Wiki", "endpoints": [{"protocol": "http", "expose_port": "80", "destination": "http://{host}:8080"}]}]`
+	invalid := `{"id": "bad", "name": "Bad", "endpoints": [{"protocol": "ftp", "expose_port": "21", "destination": "ftp://{host}"}]}`
+	os.WriteFile(filepath.Join(dir, "grafana.json"), []byte(custom), 0644)
+	os.WriteFile(filepath.Join(dir, "more.json"), []byte(list), 0644)
+	os.WriteFile(filepath.Join(dir, "bad.json"), []byte(invalid), 0644)
+	os.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{`), 0644)
+
+	templates, err := LoadTemplates(dir)
+	if err == nil {
+		t.Error("expected error for invalid files, got nil")
+	}
+
+	grafana, ok := FindTemplate(templates, "grafana")
+	if !ok || !grafana.Custom || grafana.Endpoints[0].Destination != "http://{host}:3300" {
+		t.Errorf("expected user grafana template to replace the built-in, got %+v", grafana)
+	}
+	if _, ok := FindTemplate(templates, "wiki"); !ok {
+		t.Error("expected wiki template from list file")
+	}
+	if _, ok := FindTemplate(templates, "bad"); ok {
+		t.Error("expected invalid template to be skipped")
+	}
+	if _, ok := FindTemplate(templates, "jellyfin"); !ok {
+		t.Error("expected built-in templates to remain")
+	}
+}
+
+func TestServiceTemplate_Resolve(t *testing.T) {
+	tmpl := ServiceTemplate{ID: "app", Endpoints: []TemplateEndpoint{
+		{Protocol: "https", ExposePort: "443", Destination: "http://{host}:3000"},
+		{Protocol: "https", ExposePort: "443", Path: "/api", Destination: "http://{host}:4000"},
+	}}
+
+	defaults := tmpl.Resolve("")
+	if defaults[0].Destination != "http://localhost:3000" {
+		t.Errorf("expected localhost default, got %s", defaults[0].Destination)
+	}
+	resolved := tmpl.Resolve("nas")
+	if resolved[1].Destination != "http://nas:4000" || resolved[1].Path != "/api" {
+		t.Errorf("unexpected resolved endpoint %+v", resolved[1])
+	}
+	if tmpl.Endpoints[0].Destination != "http://{host}:3000" {
+		t.Error("expected Resolve not to modify the template")
+	}
+}
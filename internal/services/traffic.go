@@ -0,0 +1,608 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotMeasurable   = errors.New("only HTTP endpoints with an HTTP destination can be measured")
+	ErrAlreadyMeasured = errors.New("endpoint is already measured")
+	ErrNotMeasured     = errors.New("endpoint is not measured")
+)
+
+const (
+	// trafficHours is how many hours of request counts are kept for the
+	// chart.
+	trafficHours           = 24
+	trafficShutdownTimeout = 5 * time.Second
+)
+
+// TrafficStats counts the requests that went through a measured endpoint.
+// Status is keyed by status class ("2xx") and Clients by tailnet login.
+type TrafficStats struct {
+	Requests int64            `json:"requests"`
+	BytesIn  int64            `json:"bytes_in"`
+	BytesOut int64            `json:"bytes_out"`
+	Status   map[string]int64 `json:"status"`
+	Clients  map[string]int64 `json:"clients"`
+	Hours    []TrafficHour    `json:"hours"`
+}
+
+type TrafficHour struct {
+	Start    time.Time `json:"start"`
+	Requests int64     `json:"requests"`
+}
+
+// TrafficBar is one hour of the request chart. Height is a percentage of
+// the busiest hour.
+type TrafficBar struct {
+	Index    int
+	Start    time.Time
+	Requests int64
+	Height   int64
+}
+
+type TrafficCount struct {
+	Name  string
+	Count int64
+}
+
+func (st *TrafficStats) add(client string, status int, in, out int64, now time.Time) {
+	if st.Status == nil {
+		st.Status = make(map[string]int64)
+	}
+	if st.Clients == nil {
+		st.Clients = make(map[string]int64)
+	}
+	st.Requests++
+	st.BytesIn += in
+	st.BytesOut += out
+	st.Status[fmt.Sprintf("%dxx", status/100)]++
+	st.Clients[client]++
+
+	hour := now.Truncate(time.Hour)
+	if n := len(st.Hours); n > 0 && st.Hours[n-1].Start.Equal(hour) {
+		st.Hours[n-1].Requests++
+	} else {
+		st.Hours = append(st.Hours, TrafficHour{Start: hour, Requests: 1})
+	}
+	cutoff := hour.Add(-(trafficHours - 1) * time.Hour)
+	for len(st.Hours) > 0 && st.Hours[0].Start.Before(cutoff) {
+		st.Hours = st.Hours[1:]
+	}
+}
+
+func (st TrafficStats) clone() TrafficStats {
+	c := st
+	c.Status = make(map[string]int64, len(st.Status))
+	for k, v := range st.Status {
+		c.Status[k] = v
+	}
+	c.Clients = make(map[string]int64, len(st.Clients))
+	for k, v := range st.Clients {
+		c.Clients[k] = v
+	}
+	c.Hours = append([]TrafficHour(nil), st.Hours...)
+	return c
+}
+
+// Chart returns the request counts of the last 24 hours up to now, oldest
+// first.
+func (st TrafficStats) Chart(now time.Time) []TrafficBar {
+	end := now.Truncate(time.Hour)
+	bars := make([]TrafficBar, trafficHours)
+	for i := range bars {
+		bars[i].Index = i
+		bars[i].Start = end.Add(time.Duration(i-trafficHours+1) * time.Hour)
+	}
+	var peak int64
+	for _, h := range st.Hours {
+		i := trafficHours - 1 - int(end.Sub(h.Start)/time.Hour)
+		if i < 0 || i >= trafficHours {
+			continue
+		}
+		bars[i].Requests = h.Requests
+		peak = max(peak, h.Requests)
+	}
+	if peak > 0 {
+		for i := range bars {
+			bars[i].Height = bars[i].Requests * 100 / peak
+		}
+	}
+	return bars
+}
+
+// StatusCounts returns the requests per status class in class order.
+func (st TrafficStats) StatusCounts() []TrafficCount {
+	counts := sortedCounts(st.Status)
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Name < counts[j].Name })
+	return counts
+}
+
+// TopClients returns the n clients with the most requests.
+func (st TrafficStats) TopClients(n int) []TrafficCount {
+	counts := sortedCounts(st.Clients)
+	if len(counts) > n {
+		counts = counts[:n]
+	}
+	return counts
+}
+
+func (st TrafficStats) BytesInText() string {
+	return formatBytes(st.BytesIn)
+}
+
+func (st TrafficStats) BytesOutText() string {
+	return formatBytes(st.BytesOut)
+}
+
+// sortedCounts orders counts by count, highest first, and then by name.
+func sortedCounts(m map[string]int64) []TrafficCount {
+	counts := make([]TrafficCount, 0, len(m))
+	for name, count := range m {
+		counts = append(counts, TrafficCount{Name: name, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Name < counts[j].Name
+	})
+	return counts
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, exp := float64(n)/unit, 0
+	for value >= unit && exp < 3 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGT"[exp])
+}
+
+// Meter is an endpoint whose traffic passes through a proxy of twintail on
+// Addr. Endpoint keeps the destination the proxy forwards to, while the
+// serve config points at the proxy.
+type Meter struct {
+	Service   string       `json:"service"`
+	Endpoint  PortEntry    `json:"endpoint"`
+	Addr      string       `json:"addr"`
+	StartedAt time.Time    `json:"started_at"`
+	Stats     TrafficStats `json:"stats"`
+
+	server *http.Server
+}
+
+// ProxyDestination is the destination serve uses while the endpoint is
+// measured.
+func (m Meter) ProxyDestination() string {
+	return "http://" + m.Addr
+}
+
+// Matches reports whether port is the measured endpoint and still points at
+// the proxy.
+func (m Meter) Matches(port PortEntry) bool {
+	return port.Key() == m.Endpoint.Key() && port.Destination == m.ProxyDestination()
+}
+
+// measures reports whether m measures the endpoint of params.
+func (m *Meter) measures(params EndpointParams) bool {
+	return m.Service == params.ServiceName && m.Endpoint.Protocol == params.Protocol &&
+		m.Endpoint.ExposePort == params.ExposePort && normalizePath(m.Endpoint.Path) == normalizePath(params.Path)
+}
+
+func (m *Meter) clone() Meter {
+	c := *m
+	c.Stats = m.Stats.clone()
+	c.server = nil
+	return c
+}
+
+// Measurable reports whether the proxy can stand in for the destination of
+// endpoint. Only HTTP is proxied, since status codes and client identities
+// come from the requests.
+func Measurable(endpoint PortEntry) bool {
+	if endpoint.Protocol != "http" && endpoint.Protocol != "https" {
+		return false
+	}
+	return strings.HasPrefix(backendURL(endpoint.Destination), "http")
+}
+
+type TrafficExecutor interface {
+	GetServiceByName(name string) (*ServiceDetailView, error)
+	UpdateEndpoint(params UpdateEndpointParams) error
+}
+
+// TrafficStore keeps the measured endpoints and runs their proxies. Counts
+// are saved by Flush rather than on every request.
+type TrafficStore struct {
+	path   string
+	now    func() time.Time
+	mu     sync.Mutex
+	meters []*Meter
+	dirty  bool
+}
+
+func NewTrafficStore(dataDir string) (*TrafficStore, error) {
+	if err := os.MkdirAll(dataDir, 0o750); err != nil {
+		return nil, err
+	}
+	s := &TrafficStore{path: filepath.Join(dataDir, "traffic.json"), now: time.Now}
+	if err := loadJSON(s.path, &s.meters); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *TrafficStore) save() error {
+	return saveJSON(s.path, s.meters)
+}
+
+// Resume starts the proxies of the recorded meters on the addresses the
+// serve config still points at. Another process may hold an address by now,
+// so an endpoint whose proxy cannot listen again is pointed back at its
+// destination and its meter is removed.
+func (s *TrafficStore) Resume(executor TrafficExecutor) error {
+	s.mu.Lock()
+	var errs []error
+	var failed []Meter
+	for _, m := range s.meters {
+		if m.server != nil {
+			continue
+		}
+		ln, err := net.Listen("tcp", m.Addr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", m.Service, m.Endpoint.Key(), err))
+			failed = append(failed, m.clone())
+			continue
+		}
+		s.serve(m, ln)
+	}
+	s.mu.Unlock()
+
+	for _, m := range failed {
+		if err := s.Stop(executor, m.Service, m.Endpoint.Key()); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", m.Service, m.Endpoint.Key(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *TrafficStore) Get(name, key string) (Meter, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m := s.find(name, key); m != nil {
+		return m.clone(), true
+	}
+	return Meter{}, false
+}
+
+func (s *TrafficStore) ForService(name string) []Meter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var meters []Meter
+	for _, m := range s.meters {
+		if m.Service == name {
+			meters = append(meters, m.clone())
+		}
+	}
+	return meters
+}
+
+func (s *TrafficStore) find(name, key string) *Meter {
+	for _, m := range s.meters {
+		if m.Service == name && m.Endpoint.Key() == key {
+			return m
+		}
+	}
+	return nil
+}
+
+// Start puts a proxy in front of the destination of endpoint and points
+// the endpoint at it. The meter is recorded first so that a crash in
+// between leaves a proxy that Resume brings back.
+func (s *TrafficStore) Start(executor TrafficExecutor, name string, endpoint PortEntry, now time.Time) (Meter, error) {
+	if !Measurable(endpoint) {
+		return Meter{}, ErrNotMeasurable
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return Meter{}, err
+	}
+	m := &Meter{Service: name, Endpoint: endpoint, Addr: ln.Addr().String(), StartedAt: now}
+
+	s.mu.Lock()
+	if s.find(name, endpoint.Key()) != nil {
+		s.mu.Unlock()
+		ln.Close()
+		return Meter{}, ErrAlreadyMeasured
+	}
+	s.meters = append(s.meters, m)
+	s.serve(m, ln)
+	err = s.save()
+	s.mu.Unlock()
+	if err != nil {
+		return Meter{}, errors.Join(err, s.forget(name, endpoint.Key()))
+	}
+
+	if err := executor.UpdateEndpoint(UpdateEndpointParams{
+		ServiceName:    name,
+		Protocol:       endpoint.Protocol,
+		ExposePort:     endpoint.ExposePort,
+		Path:           endpoint.Path,
+		OldDestination: endpoint.Destination,
+		NewDestination: m.ProxyDestination(),
+	}); err != nil {
+		if forgetErr := s.forget(name, endpoint.Key()); forgetErr != nil {
+			return Meter{}, errors.Join(err, forgetErr)
+		}
+		return Meter{}, err
+	}
+	started, _ := s.Get(name, endpoint.Key())
+	return started, nil
+}
+
+// Stop points the endpoint back at its destination and removes the meter
+// with its counts. An endpoint that was changed or removed in the meantime
+// is left alone.
+func (s *TrafficStore) Stop(executor TrafficExecutor, name, key string) error {
+	m, ok := s.Get(name, key)
+	if !ok {
+		return ErrNotMeasured
+	}
+	svc, err := executor.GetServiceByName(name)
+	if err != nil {
+		return err
+	}
+	if svc != nil {
+		for _, port := range svc.Ports {
+			if !m.Matches(port) {
+				continue
+			}
+			if err := executor.UpdateEndpoint(UpdateEndpointParams{
+				ServiceName:    name,
+				Protocol:       port.Protocol,
+				ExposePort:     port.ExposePort,
+				Path:           port.Path,
+				OldDestination: port.Destination,
+				NewDestination: m.Endpoint.Destination,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return s.forget(name, key)
+}
+
+func (s *TrafficStore) forget(name, key string) error {
+	return s.remove(func(m *Meter) bool { return m.Service == name && m.Endpoint.Key() == key })
+}
+
+// ForgetService removes the meters of a service that was cleared and stops
+// their proxies.
+func (s *TrafficStore) ForgetService(name string) error {
+	return s.remove(func(m *Meter) bool { return m.Service == name })
+}
+
+// ForgetEndpoint removes the meter of an endpoint that was removed and stops
+// its proxy.
+func (s *TrafficStore) ForgetEndpoint(params EndpointParams) error {
+	return s.remove(func(m *Meter) bool { return m.measures(params) })
+}
+
+// UpdateEndpoint removes the meter of an endpoint that was pointed away from
+// its proxy, since no traffic passes through the proxy any more.
+func (s *TrafficStore) UpdateEndpoint(params UpdateEndpointParams) error {
+	endpoint := EndpointParams{
+		ServiceName: params.ServiceName,
+		Protocol:    params.Protocol,
+		ExposePort:  params.ExposePort,
+		Path:        params.Path,
+	}
+	return s.remove(func(m *Meter) bool {
+		return m.measures(endpoint) && params.NewDestination != m.ProxyDestination()
+	})
+}
+
+// RenameService moves the meters of a renamed service to its new name. The
+// endpoints were copied with their proxy destination, so the proxies keep
+// measuring them.
+func (s *TrafficStore) RenameService(oldName, newName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.meters {
+		if m.Service == oldName {
+			m.Service = newName
+		}
+	}
+	return s.save()
+}
+
+// Resolve returns svc with every measured endpoint pointing at its real
+// destination instead of the proxy, for features that copy endpoints
+// elsewhere.
+func (s *TrafficStore) Resolve(svc *ServiceDetailView) *ServiceDetailView {
+	if svc == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resolved := *svc
+	resolved.Ports = make([]PortEntry, len(svc.Ports))
+	for i, port := range svc.Ports {
+		if m := s.find(svc.Name, port.Key()); m != nil && m.Matches(port) {
+			port.Destination = m.Endpoint.Destination
+		}
+		resolved.Ports[i] = port
+	}
+	return &resolved
+}
+
+func (s *TrafficStore) remove(match func(m *Meter) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.meters[:0:0]
+	for _, m := range s.meters {
+		if match(m) {
+			if m.server != nil {
+				go shutdownServer(m.server)
+			}
+			continue
+		}
+		kept = append(kept, m)
+	}
+	s.meters = kept
+	return s.save()
+}
+
+// Flush saves the counts gathered since the last flush.
+func (s *TrafficStore) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	s.dirty = false
+	return s.save()
+}
+
+func (s *TrafficStore) record(m *Meter, client string, status int, in, out int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m.Stats.add(client, status, in, out, s.now())
+	s.dirty = true
+}
+
+// serve runs the proxy of m on ln. It must be called with s.mu held.
+func (s *TrafficStore) serve(m *Meter, ln net.Listener) {
+	target, _ := url.Parse(backendURL(m.Endpoint.Destination))
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			// Stay transparent: the destination sees what serve sent.
+			r.Out.Host = r.In.Host
+			for _, h := range []string{"X-Forwarded-For", "X-Forwarded-Host", "X-Forwarded-Proto"} {
+				if v, ok := r.In.Header[h]; ok {
+					r.Out.Header[h] = v
+				}
+			}
+		},
+		ErrorLog: log.New(io.Discard, "", 0),
+	}
+	if strings.HasPrefix(m.Endpoint.Destination, "https+insecure://") {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		proxy.Transport = transport
+	}
+
+	m.server = &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body := &countingReader{ReadCloser: r.Body}
+			r.Body = body
+			rw := &countingWriter{ResponseWriter: w, status: http.StatusOK}
+			proxy.ServeHTTP(rw, r)
+			s.record(m, trafficClient(r), rw.status, body.n, rw.n)
+		}),
+		ReadHeaderTimeout: 30 * time.Second,
+		ErrorLog:          log.New(io.Discard, "", 0),
+	}
+	go m.server.Serve(ln)
+}
+
+func shutdownServer(server *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), trafficShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		server.Close()
+	}
+}
+
+// trafficClient names who sent r. Serve adds Tailscale-User-Login for
+// requests from user devices; tagged devices are known by their address.
+func trafficClient(r *http.Request) string {
+	if login := r.Header.Get("Tailscale-User-Login"); login != "" {
+		return login
+	}
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		first, _, _ := strings.Cut(fwd, ",")
+		return strings.TrimSpace(first)
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	n           int64
+}
+
+func (w *countingWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = w.status >= 200
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// Unwrap lets the proxy flush and hijack the connection for upgrades.
+func (w *countingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// RunTrafficFlush saves the counts every interval until ctx is done.
+func RunTrafficFlush(ctx context.Context, store *TrafficStore, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := store.Flush(); err != nil {
+				logger.Error("failed to save traffic statistics", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type mockTrafficExecutor struct {
+	mockBulkExecutor
+	detail *ServiceDetailView
+}
+
+func (m *mockTrafficExecutor) GetServiceByName(name string) (*ServiceDetailView, error) {
+	return m.detail, nil
+}
+
+func newTrafficBackend(t *testing.T) *httptest.Server {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write(append([]byte("hello "), body...))
+	}))
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+func sendThrough(t *testing.T, m Meter, path, login string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, m.ProxyDestination()+path, nil)
+	if login != "" {
+		req.Header.Set("Tailscale-User-Login", login)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request through the proxy failed: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+// waitForRequests waits for the proxy to record the requests, which it does
+// after the response is sent.
+func waitForRequests(t *testing.T, store *TrafficStore, name, key string, n int64) Meter {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		m, _ := store.Get(name, key)
+		if m.Stats.Requests >= n || time.Now().After(deadline) {
+			return m
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTrafficStore_StartAndStop(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewTrafficStore(dir)
+	backend := newTrafficBackend(t)
+	endpoint := PortEntry{Protocol: "https", ExposePort: "443", Path: "/", Destination: backend.URL}
+	executor := &mockTrafficExecutor{}
+
+	m, err := store.Start(executor, "web", endpoint, time.Now())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(executor.updated) != 1 || executor.updated[0].NewDestination != m.ProxyDestination() {
+		t.Fatalf("expected the endpoint to point at the proxy, got %+v", executor.updated)
+	}
+	if _, err := store.Start(executor, "web", endpoint, time.Now()); !errors.Is(err, ErrAlreadyMeasured) {
+		t.Errorf("expected ErrAlreadyMeasured, got %v", err)
+	}
+
+	sendThrough(t, m, "/", "alice@example.com")
+	sendThrough(t, m, "/missing", "")
+	m = waitForRequests(t, store, "web", endpoint.Key(), 2)
+
+	if m.Stats.Requests != 2 || m.Stats.Status["2xx"] != 1 || m.Stats.Status["4xx"] != 1 {
+		t.Errorf("unexpected counts %+v", m.Stats)
+	}
+	if m.Stats.Clients["alice@example.com"] != 1 || m.Stats.Clients["127.0.0.1"] != 1 {
+		t.Errorf("unexpected clients %v", m.Stats.Clients)
+	}
+	if m.Stats.BytesOut < int64(len("hello ")) {
+		t.Errorf("expected the response bytes to be counted, got %d", m.Stats.BytesOut)
+	}
+
+	if err := store.Flush(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	reopened, _ := NewTrafficStore(dir)
+	if saved, ok := reopened.Get("web", endpoint.Key()); !ok || saved.Stats.Requests != 2 {
+		t.Fatalf("expected the counts to persist, got %+v", saved)
+	}
+
+	executor.detail = &ServiceDetailView{Name: "web", Ports: []PortEntry{{Protocol: "https", ExposePort: "443", Path: "/", Destination: m.ProxyDestination()}}}
+	if err := store.Stop(executor, "web", endpoint.Key()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(executor.updated) != 2 || executor.updated[1].NewDestination != backend.URL {
+		t.Errorf("expected the endpoint to point at its destination again, got %+v", executor.updated)
+	}
+	if meters := store.ForService("web"); len(meters) != 0 {
+		t.Errorf("expected the meter to be removed, got %+v", meters)
+	}
+	if err := store.Stop(executor, "web", endpoint.Key()); !errors.Is(err, ErrNotMeasured) {
+		t.Errorf("expected ErrNotMeasured, got %v", err)
+	}
+}
+
+func TestTrafficStore_StopLeavesChangedEndpoint(t *testing.T) {
+	store, _ := NewTrafficStore(t.TempDir())
+	endpoint := PortEntry{Protocol: "https", ExposePort: "443", Destination: newTrafficBackend(t).URL}
+	executor := &mockTrafficExecutor{}
+	store.Start(executor, "web", endpoint, time.Now())
+	executor.detail = &ServiceDetailView{Name: "web", Ports: []PortEntry{{Protocol: "https", ExposePort: "443", Destination: "http://localhost:4000"}}}
+
+	if err := store.Stop(executor, "web", endpoint.Key()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(executor.updated) != 1 {
+		t.Errorf("expected only the start to change the endpoint, got %+v", executor.updated)
+	}
+}
+
+func TestTrafficStore_StartFails(t *testing.T) {
+	store, _ := NewTrafficStore(t.TempDir())
+
+	tcp := PortEntry{Protocol: "tcp", ExposePort: "5432", Destination: "tcp://localhost:5432"}
+	if _, err := store.Start(&mockTrafficExecutor{}, "db", tcp, time.Now()); !errors.Is(err, ErrNotMeasurable) {
+		t.Errorf("expected ErrNotMeasurable, got %v", err)
+	}
+
+	endpoint := PortEntry{Protocol: "https", ExposePort: "443", Destination: "http://localhost:3000"}
+	executor := &mockTrafficExecutor{mockBulkExecutor: mockBulkExecutor{failOn: "web"}}
+	if _, err := store.Start(executor, "web", endpoint, time.Now()); err == nil {
+		t.Fatal("expected an error")
+	}
+	if meters := store.ForService("web"); len(meters) != 0 {
+		t.Errorf("expected no meter to be recorded, got %+v", meters)
+	}
+}
+
+func TestTrafficStore_Resume(t *testing.T) {
+	dir := t.TempDir()
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	ln.Close()
+	endpoint := PortEntry{Protocol: "http", ExposePort: "80", Destination: newTrafficBackend(t).URL}
+	m := Meter{Service: "web", Endpoint: endpoint, Addr: addr}
+	saveJSON(filepath.Join(dir, "traffic.json"), []Meter{m})
+
+	store, _ := NewTrafficStore(dir)
+	if err := store.Resume(&mockTrafficExecutor{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	sendThrough(t, m, "/", "")
+
+	if got := waitForRequests(t, store, "web", endpoint.Key(), 1); got.Stats.Requests != 1 {
+		t.Errorf("expected the proxy to run on %s again, got %+v", addr, got.Stats)
+	}
+}
+
+func TestTrafficStore_ResumeRestoresTakenAddress(t *testing.T) {
+	dir := t.TempDir()
+	taken, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer taken.Close()
+	endpoint := PortEntry{Protocol: "http", ExposePort: "80", Path: "/", Destination: "http://localhost:3000"}
+	m := Meter{Service: "web", Endpoint: endpoint, Addr: taken.Addr().String()}
+	saveJSON(filepath.Join(dir, "traffic.json"), []Meter{m})
+	proxied := endpoint
+	proxied.Destination = m.ProxyDestination()
+	executor := &mockTrafficExecutor{detail: &ServiceDetailView{Name: "web", Ports: []PortEntry{proxied}}}
+
+	store, _ := NewTrafficStore(dir)
+	if err := store.Resume(executor); err == nil {
+		t.Fatal("expected the taken address to be reported")
+	}
+
+	if len(executor.updated) != 1 || executor.updated[0].NewDestination != "http://localhost:3000" {
+		t.Errorf("expected the endpoint to point at its destination again, got %+v", executor.updated)
+	}
+	if _, ok := store.Get("web", endpoint.Key()); ok {
+		t.Error("expected the meter to be removed")
+	}
+}
+
+func TestTrafficStore_StartTwice(t *testing.T) {
+	store, _ := NewTrafficStore(t.TempDir())
+	endpoint := PortEntry{Protocol: "https", ExposePort: "443", Destination: "http://localhost:3000"}
+	executor := &mockTrafficExecutor{}
+
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			_, err := store.Start(executor, "web", endpoint, time.Now())
+			errs <- err
+		}()
+	}
+	first, second := <-errs, <-errs
+
+	if (first == nil) == (second == nil) || !errors.Is(errors.Join(first, second), ErrAlreadyMeasured) {
+		t.Errorf("expected exactly one start to succeed, got %v and %v", first, second)
+	}
+	if meters := store.ForService("web"); len(meters) != 1 {
+		t.Errorf("expected one meter, got %d", len(meters))
+	}
+}
+
+func TestTrafficStore_FollowsOtherFeatures(t *testing.T) {
+	store, _ := NewTrafficStore(t.TempDir())
+	root := PortEntry{Protocol: "https", ExposePort: "443", Path: "/", Destination: "http://localhost:3000"}
+	api := PortEntry{Protocol: "https", ExposePort: "443", Path: "/api", Destination: "http://localhost:4000"}
+	executor := &mockTrafficExecutor{}
+	rootMeter, _ := store.Start(executor, "web", root, time.Now())
+	apiMeter, _ := store.Start(executor, "web", api, time.Now())
+
+	proxied := []PortEntry{root, api}
+	proxied[0].Destination = rootMeter.ProxyDestination()
+	proxied[1].Destination = apiMeter.ProxyDestination()
+	resolved := store.Resolve(&ServiceDetailView{Name: "web", Ports: proxied})
+	if resolved.Ports[0].Destination != root.Destination || resolved.Ports[1].Destination != api.Destination {
+		t.Errorf("expected the real destinations, got %+v", resolved.Ports)
+	}
+	if proxied[0].Destination != rootMeter.ProxyDestination() {
+		t.Error("expected Resolve to leave its argument alone")
+	}
+
+	store.RenameService("web", "site")
+	if len(store.ForService("web")) != 0 || len(store.ForService("site")) != 2 {
+		t.Fatalf("expected the meters to move to the new name, got %+v", store.ForService("site"))
+	}
+
+	store.UpdateEndpoint(UpdateEndpointParams{ServiceName: "site", Protocol: "https", ExposePort: "443", Path: "/api", NewDestination: apiMeter.ProxyDestination()})
+	if len(store.ForService("site")) != 2 {
+		t.Error("expected an endpoint still pointing at its proxy to stay measured")
+	}
+	store.UpdateEndpoint(UpdateEndpointParams{ServiceName: "site", Protocol: "https", ExposePort: "443", Path: "/api", NewDestination: "http://localhost:5000"})
+	if _, ok := store.Get("site", api.Key()); ok {
+		t.Error("expected the repointed endpoint to stop being measured")
+	}
+
+	store.ForgetEndpoint(EndpointParams{ServiceName: "site", Protocol: "https", ExposePort: "443"})
+	if len(store.ForService("site")) != 0 {
+		t.Error("expected the removed endpoint to stop being measured")
+	}
+	// The proxy shuts down in the background.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", rootMeter.Addr)
+		if err != nil {
+			break
+		}
+		conn.Close()
+		if time.Now().After(deadline) {
+			t.Fatal("expected the proxy to be stopped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTrafficStats_Chart(t *testing.T) {
+	now := time.Date(2026, 3, 2, 9, 30, 0, 0, time.UTC)
+	var stats TrafficStats
+	stats.add("alice", 200, 0, 10, now.Add(-30*time.Hour))
+	stats.add("alice", 200, 0, 10, now.Add(-2*time.Hour))
+	for range 4 {
+		stats.add("bob", 502, 0, 10, now)
+	}
+
+	bars := stats.Chart(now)
+
+	if len(bars) != 24 || !bars[23].Start.Equal(now.Truncate(time.Hour)) {
+		t.Fatalf("expected 24 hours ending now, got %d ending %v", len(bars), bars[len(bars)-1].Start)
+	}
+	if bars[23].Height != 100 || bars[21].Requests != 1 || bars[21].Height != 25 || bars[0].Requests != 0 {
+		t.Errorf("unexpected bars %+v", bars)
+	}
+	if len(stats.Hours) != 2 {
+		t.Errorf("expected hours older than a day to be dropped, got %+v", stats.Hours)
+	}
+	if top := stats.TopClients(1); len(top) != 1 || top[0].Name != "bob" || top[0].Count != 4 {
+		t.Errorf("unexpected top clients %+v", top)
+	}
+	if status := stats.StatusCounts(); len(status) != 2 || status[0].Name != "2xx" {
+		t.Errorf("unexpected status counts %+v", status)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{512: "512 B", 1536: "1.5 KiB", 3 << 30: "3.0 GiB"} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
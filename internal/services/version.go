@@ -0,0 +1,110 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var ErrUnsupportedVersion = errors.New("unsupported tailscale version")
+
+// Version is a tailscale release as printed by tailscale version.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// Releases that changed the serve CLI twintail drives.
+var (
+	// MinServiceVersion added Tailscale Services: the --service flag,
+	// serve clear and the Services key of serve status --json.
+	MinServiceVersion = Version{1, 86, 0}
+	// flagServeVersion replaced "serve https:443 / <target>" with the
+	// --https=<port>, --bg and off syntax.
+	flagServeVersion = Version{1, 52, 0}
+)
+
+// serveSyntax is how twintail drives the releases from since on: how serve
+// status --json is read and which commands serve a destination on the node
+// itself at https:443.
+type serveSyntax struct {
+	since       Version
+	parseStatus func(output []byte) (*ServeStatus, error)
+	serveNode   func(destination string) []string
+	unserveNode []string
+}
+
+// serveSyntaxes lists a syntax per release that changed the serve CLI,
+// newest first.
+var serveSyntaxes = []serveSyntax{
+	{
+		since:       MinServiceVersion,
+		parseStatus: parseServeStatus,
+		serveNode:   func(destination string) []string { return []string{"serve", "--bg", "--https=443", destination} },
+		unserveNode: []string{"serve", "--https=443", "off"},
+	},
+	{
+		since:       flagServeVersion,
+		parseStatus: parseNodeStatus,
+		serveNode:   func(destination string) []string { return []string{"serve", "--bg", "--https=443", destination} },
+		unserveNode: []string{"serve", "--https=443", "off"},
+	},
+	{
+		parseStatus: parseNodeStatus,
+		serveNode:   func(destination string) []string { return []string{"serve", "https:443", "/", destination} },
+		unserveNode: []string{"serve", "https:443", "/", "off"},
+	},
+}
+
+// syntaxFor returns the syntax of release v.
+func syntaxFor(v Version) serveSyntax {
+	for _, syntax := range serveSyntaxes {
+		if v.AtLeast(syntax.since) {
+			return syntax
+		}
+	}
+	return serveSyntaxes[len(serveSyntaxes)-1]
+}
+
+var versionPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)`)
+
+// ParseVersion reads the first line of tailscale version, which may carry a
+// build suffix such as 1.80.2-t8e3c9a1a4-g1234abcd.
+func ParseVersion(output string) (Version, error) {
+	line, _, _ := strings.Cut(strings.TrimSpace(output), "\n")
+	m := versionPattern.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return Version{}, fmt.Errorf("unrecognized tailscale version %q", line)
+	}
+	var v Version
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	v.Patch, _ = strconv.Atoi(m[3])
+	return v, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+func (v Version) AtLeast(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major > other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor > other.Minor
+	}
+	return v.Patch >= other.Patch
+}
+
+// Supported returns an error naming the required release when v cannot
+// manage Tailscale Services.
+func (v Version) Supported() error {
+	if v.AtLeast(MinServiceVersion) {
+		return nil
+	}
+	return fmt.Errorf("%w: tailscale %s has no Tailscale Services, twintail needs %s or later", ErrUnsupportedVersion, v, MinServiceVersion)
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		output string
+		want   Version
+	}{
+		{"1.50.0", Version{1, 50, 0}},
+		{"1.80.2\n  tailscale commit: 8e3c9a1a4\n  go version: go1.23.4\n", Version{1, 80, 2}},
+		{"1.91.0-t1234abcd-g5678ef01\n", Version{1, 91, 0}},
+		{"  v1.86.3\n", Version{1, 86, 3}},
+	}
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.output)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseVersion(%q) = %v, %v; want %v", tt.output, got, err, tt.want)
+		}
+	}
+	if _, err := ParseVersion("tailscale: unknown command"); err == nil {
+		t.Error("expected an error for unrecognized output")
+	}
+}
+
+func TestVersion_Supported(t *testing.T) {
+	if err := (Version{1, 86, 0}).Supported(); err != nil {
+		t.Errorf("expected 1.86.0 to be supported, got %v", err)
+	}
+	if err := (Version{2, 0, 0}).Supported(); err != nil {
+		t.Errorf("expected 2.0.0 to be supported, got %v", err)
+	}
+	err := (Version{1, 84, 9}).Supported()
+	if !errors.Is(err, ErrUnsupportedVersion) || !strings.Contains(err.Error(), "1.84.9") || !strings.Contains(err.Error(), "1.86.0") {
+		t.Errorf("expected an error naming both versions, got %v", err)
+	}
+}
+
+// fakeRelease answers tailscale version with version and serve status with
+// the fixture recorded from that release.
+func fakeRelease(t *testing.T, version string) *[]string {
+	t.Helper()
+	status, err := os.ReadFile("testdata/serve/" + version + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var captured []string
+	oldExecCommand := execCommand
+	t.Cleanup(func() { execCommand = oldExecCommand })
+	execCommand = func(name string, args ...string) interface {
+		Output() ([]byte, error)
+		CombinedOutput() ([]byte, error)
+	} {
+		cmd := strings.Join(args, " ")
+		captured = append(captured, cmd)
+		switch cmd {
+		case "version":
+			return &mockCmd{output: []byte(version + "\n  tailscale commit: 8e3c9a1a4\n")}
+		case "serve status --json":
+			return &mockCmd{output: status}
+		}
+		return &mockCmd{output: []byte("success")}
+	}
+	return &captured
+}
+
+func TestGetServeStatus_Releases(t *testing.T) {
+	tests := []struct {
+		version string
+		want    []string
+	}{
+		{"1.86.0", []string{"web"}},
+		{"1.90.1", []string{"web", "wiki"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			fakeRelease(t, tt.version)
+			svc := NewTailscaleService()
+			if err := svc.CheckInstalled(); err != nil {
+				t.Fatal(err)
+			}
+
+			svcs, err := svc.GetServeStatus()
+
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			var names []string
+			for _, s := range svcs {
+				names = append(names, s.Name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("expected services %v, got %v", tt.want, names)
+			}
+			if svcs[0].HTTPSUrl != "https://web.tail1234.ts.net" || len(svcs[0].Destinations) != 2 {
+				t.Errorf("unexpected web service %+v", svcs[0])
+			}
+		})
+	}
+}
+
+func TestGetServeStatus_UnsupportedReleases(t *testing.T) {
+	for _, version := range []string{"1.50.0", "1.80.2"} {
+		t.Run(version, func(t *testing.T) {
+			captured := fakeRelease(t, version)
+			svc := NewTailscaleService()
+			if err := svc.CheckInstalled(); err != nil {
+				t.Fatalf("expected CheckInstalled to accept %s, got %v", version, err)
+			}
+
+			_, err := svc.GetServeStatus()
+
+			if ClassifyError(err) != ErrorKindUnsupportedVersion {
+				t.Errorf("expected an unsupported version error, got %v", err)
+			}
+			if err := svc.AddEndpoint(EndpointParams{ServiceName: "web", Protocol: "https", ExposePort: "443", Destination: "3000"}); !errors.Is(err, ErrUnsupportedVersion) {
+				t.Errorf("expected AddEndpoint to refuse, got %v", err)
+			}
+			if slices.Contains(*captured, "serve status --json") {
+				t.Errorf("expected the serve status not to be read, got %v", *captured)
+			}
+		})
+	}
+}
+
+func TestGetServeStatus_EmptyOutput(t *testing.T) {
+	status, err := parseServeStatus([]byte("\n"))
+
+	if err != nil || len(status.Services) != 0 {
+		t.Errorf("expected an empty status, got %+v, %v", status, err)
+	}
+}
+
+func TestServeSelf_LegacyNode(t *testing.T) {
+	captured := fakeRelease(t, "1.50.0")
+	svc := NewTailscaleService()
+	svc.CheckInstalled()
+	params := SelfServeParams{Mode: SelfServeNode, Destination: "http://127.0.0.1:8077"}
+
+	if err := svc.ServeSelf(params); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := svc.UnserveSelf(params); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	want := []string{"version", "serve status --json", "serve https:443 / http://127.0.0.1:8077", "serve https:443 / off"}
+	if !reflect.DeepEqual(*captured, want) {
+		t.Errorf("expected commands %v, got %v", want, *captured)
+	}
+}
+
+func TestParseStatus_Releases(t *testing.T) {
+	tests := []struct {
+		version  string
+		node     string
+		services int
+	}{
+		{"1.50.0", "http://127.0.0.1:3000", 0},
+		{"1.80.2", "http://127.0.0.1:3000", 0},
+		{"1.86.0", "", 1},
+		{"1.90.1", "http://127.0.0.1:8077", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			output, err := os.ReadFile("testdata/serve/" + tt.version + ".json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, _ := ParseVersion(tt.version)
+
+			status, err := syntaxFor(v).parseStatus(output)
+
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if tt.node != "" && !status.servesNode(tt.node) {
+				t.Errorf("expected the node to serve %s, got %+v", tt.node, status.Web)
+			}
+			if tt.node == "" && len(status.Web) != 0 {
+				t.Errorf("expected the node to serve nothing, got %+v", status.Web)
+			}
+			if len(status.Services) != tt.services {
+				t.Errorf("expected %d services, got %+v", tt.services, status.Services)
+			}
+		})
+	}
+}
+
+func TestServeSelf_NodeAlreadyServed(t *testing.T) {
+	tests := []struct {
+		version string
+		want    []string
+	}{
+		{"1.50.0", []string{"version", "serve status --json"}},
+		{"1.80.2", []string{"version", "serve status --json"}},
+		{"1.86.0", []string{"version", "serve status --json", "serve --bg --https=443 http://127.0.0.1:3000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			captured := fakeRelease(t, tt.version)
+			svc := NewTailscaleService()
+			svc.CheckInstalled()
+
+			if err := svc.ServeSelf(SelfServeParams{Mode: SelfServeNode, Destination: "http://127.0.0.1:3000"}); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if !reflect.DeepEqual(*captured, tt.want) {
+				t.Errorf("expected commands %v, got %v", tt.want, *captured)
+			}
+		})
+	}
+}
+
+func TestRequireServices_NoticesUpgrade(t *testing.T) {
+	fakeRelease(t, "1.80.2")
+	svc := NewTailscaleService()
+	svc.CheckInstalled()
+	fakeRelease(t, "1.90.1")
+
+	if _, err := svc.GetServeStatus(); err != nil {
+		t.Fatalf("expected the upgrade to be noticed, got %v", err)
+	}
+	if v, _ := svc.Version(); v != (Version{1, 90, 1}) {
+		t.Errorf("expected version 1.90.1, got %v", v)
+	}
+}
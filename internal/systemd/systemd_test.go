@@ -0,0 +1,138 @@
+package systemd
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func listenNotifySocket(t *testing.T) *net.UnixConn {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("failed to listen on notify socket: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+	return conn
+}
+
+func readState(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	buf := make([]byte, 256)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("failed to read notification: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestNotify_NoSocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+
+	sent, err := Notify("READY=1")
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if sent {
+		t.Error("expected notification not to be sent")
+	}
+}
+
+func TestNotify_Ready(t *testing.T) {
+	conn := listenNotifySocket(t)
+
+	if err := Ready(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if got := readState(t, conn); got != "READY=1" {
+		t.Errorf("expected 'READY=1', got '%s'", got)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+
+	if got := WatchdogInterval(); got != 30*time.Second {
+		t.Errorf("expected 30s, got %v", got)
+	}
+
+	t.Setenv("WATCHDOG_PID", "1")
+	if got := WatchdogInterval(); got != 0 {
+		t.Errorf("expected watchdog for another pid to be ignored, got %v", got)
+	}
+}
+
+func TestRunWatchdog_Pings(t *testing.T) {
+	conn := listenNotifySocket(t)
+	t.Setenv("WATCHDOG_USEC", "20000")
+	t.Setenv("WATCHDOG_PID", "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go RunWatchdog(ctx, func() error { return nil })
+
+	if got := readState(t, conn); got != "WATCHDOG=1" {
+		t.Errorf("expected 'WATCHDOG=1', got '%s'", got)
+	}
+}
+
+func TestListeners_NotActivated(t *testing.T) {
+	t.Setenv("LISTEN_PID", "")
+	t.Setenv("LISTEN_FDS", "")
+
+	listeners, err := Listeners()
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(listeners) != 0 {
+		t.Errorf("expected no listeners, got %d", len(listeners))
+	}
+}
+
+func TestListeners_Activated(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer tcp.Close()
+	file, err := tcp.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("failed to get listener file: %v", err)
+	}
+	defer file.Close()
+
+	oldStart := listenFdsStart
+	defer func() { listenFdsStart = oldStart }()
+	listenFdsStart = int(file.Fd())
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv("LISTEN_FDNAMES", "twintail.socket")
+
+	listeners, err := Listeners()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(listeners) != 1 {
+		t.Fatalf("expected 1 listener, got %d", len(listeners))
+	}
+	defer listeners[0].Close()
+
+	if listeners[0].Addr().String() != tcp.Addr().String() {
+		t.Errorf("expected address %s, got %s", tcp.Addr(), listeners[0].Addr())
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Error("expected LISTEN_FDS to be unset after use")
+	}
+}